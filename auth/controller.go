@@ -31,6 +31,14 @@ type User struct {
 	Email            string `json:"email"`
 	LightningAddress string `json:"lightning_address"`
 	Verified         bool   `json:"verified"`
+
+	// SubscriptionPriceInCents is the monthly price to access all the
+	// videos of this user. Zero means subscriptions are disabled.
+	SubscriptionPriceInCents int64 `json:"subscription_price_in_cents"`
+
+	// NotificationPreference is how the user is notified of new purchases
+	// and uploads: instant, daily or off.
+	NotificationPreference string `json:"notification_preference"`
 }
 
 type LoginRequest struct {
@@ -239,7 +247,9 @@ func (c *Controller) UpdateProfileHandler(gCtx *gin.Context) {
 	}
 
 	var req struct {
-		LightningAddress string `json:"lightningAddress"`
+		LightningAddress         string `json:"lightningAddress"`
+		SubscriptionPriceInCents *int64 `json:"subscriptionPriceInCents"`
+		NotificationPreference   string `json:"notificationPreference"`
 	}
 
 	if err := gCtx.ShouldBindJSON(&req); err != nil {
@@ -247,6 +257,18 @@ func (c *Controller) UpdateProfileHandler(gCtx *gin.Context) {
 		return
 	}
 
+	if req.SubscriptionPriceInCents != nil && *req.SubscriptionPriceInCents < 0 {
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": "Subscription price must be non-negative"})
+		return
+	}
+
+	if req.NotificationPreference != "" &&
+		!email.ValidNotificationPreference(req.NotificationPreference) {
+
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": "Notification preference must be instant, daily or off"})
+		return
+	}
+
 	err := c.store.UpdateUserLightningAddress(gCtx, userIDInt, req.LightningAddress)
 	if err != nil {
 		c.logger.Error("Failed to update user lightning address", "error", err)
@@ -254,6 +276,26 @@ func (c *Controller) UpdateProfileHandler(gCtx *gin.Context) {
 		return
 	}
 
+	if req.SubscriptionPriceInCents != nil {
+		err = c.store.UpdateUserSubscriptionPrice(gCtx, userIDInt,
+			*req.SubscriptionPriceInCents)
+		if err != nil {
+			c.logger.Error("Failed to update user subscription price", "error", err)
+			gCtx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			return
+		}
+	}
+
+	if req.NotificationPreference != "" {
+		err = c.store.UpdateUserNotificationPreference(gCtx, userIDInt,
+			req.NotificationPreference)
+		if err != nil {
+			c.logger.Error("Failed to update user notification preference", "error", err)
+			gCtx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			return
+		}
+	}
+
 	gCtx.JSON(http.StatusOK, gin.H{"message": "Profile updated successfully"})
 }
 
@@ -19,6 +19,16 @@ type Store interface {
 	UpdateUserLightningAddress(ctx context.Context, id int64,
 		lightningAddress string) error
 	UpdateUserVerified(ctx context.Context, email string, verified bool) error
+
+	// UpdateUserSubscriptionPrice sets the monthly subscription price of a
+	// creator. A zero price disables subscriptions.
+	UpdateUserSubscriptionPrice(ctx context.Context, id int64,
+		priceInCents int64) error
+
+	// UpdateUserNotificationPreference sets how a user is notified of new
+	// purchases and uploads.
+	UpdateUserNotificationPreference(ctx context.Context, id int64,
+		preference string) error
 	StoreToken(ctx context.Context, email, token string,
 		expiration time.Time) error
 	VerifyToken(ctx context.Context, token string) (string, error)
@@ -0,0 +1,20 @@
+package cleanup
+
+import "time"
+
+// DefaultConfig returns all default values for the Config struct.
+func DefaultConfig() *Config {
+	return &Config{
+		Interval:      time.Hour,
+		InvoiceExpiry: 24 * time.Hour,
+		GracePeriod:   24 * time.Hour,
+		BatchSize:     500,
+	}
+}
+
+type Config struct {
+	Interval      time.Duration `long:"interval" description:"Time between cleanup runs, 0 disables the job"`
+	InvoiceExpiry time.Duration `long:"invoice_expiry" description:"Expiry of the invoices of the L402 challenges"`
+	GracePeriod   time.Duration `long:"grace_period" description:"Time unpaid offers are kept after their invoice expired"`
+	BatchSize     int64         `long:"batch_size" description:"Number of offers checked per batch"`
+}
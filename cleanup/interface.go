@@ -0,0 +1,44 @@
+package cleanup
+
+import (
+	"context"
+	"time"
+
+	"github.com/fewsats/blockbuster/auth"
+	"github.com/fewsats/blockbuster/orders"
+)
+
+// Store is the interface for removing stale data.
+type Store interface {
+	// ListExpiredUnpaidOffers returns up to limit offers created before the
+	// given time that have neither a purchase nor a settled invoice, in ID
+	// order after the given offer ID.
+	ListExpiredUnpaidOffers(ctx context.Context, createdBefore time.Time,
+		afterID uint64, limit int64) ([]*orders.Offer, error)
+
+	// DeleteUnpaidOffer deletes the offer and the root keys of its
+	// credentials unless it has been purchased.
+	DeleteUnpaidOffer(ctx context.Context, paymentHash string) (bool, int64,
+		error)
+
+	// DeleteExpiredTokens removes the expired login tokens.
+	DeleteExpiredTokens(ctx context.Context) (int64, error)
+
+	// UpsertInvoiceStatus stores the status of an invoice.
+	UpsertInvoiceStatus(ctx context.Context, paymentHash, preimage string,
+		settled bool) (*auth.InvoiceStatus, error)
+}
+
+// InvoiceProvider is the interface for checking if an invoice was paid.
+type InvoiceProvider interface {
+	// GetInvoicePreimage returns the preimage of the invoice, or an empty
+	// string if it is not paid.
+	GetInvoicePreimage(ctx context.Context, paymentHash string) (string,
+		error)
+}
+
+// Queue is the interface for the job queue that runs the cleanup.
+type Queue interface {
+	RegisterRecurring(kind string, interval time.Duration,
+		run func(ctx context.Context) error)
+}
@@ -0,0 +1,188 @@
+package cleanup
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+
+	"github.com/fewsats/blockbuster/utils"
+)
+
+// metrics holds the counters of the removed rows, exposed through expvar.
+var metrics = expvar.NewMap("cleanup")
+
+const (
+	metricRuns            = "runs"
+	metricFailedRuns      = "failed_runs"
+	metricOffersDeleted   = "offers_deleted"
+	metricRootKeysDeleted = "root_keys_deleted"
+	metricPaidOffersKept  = "paid_offers_kept"
+	metricTokensDeleted   = "tokens_deleted"
+	metricOffersFailed    = "offers_failed"
+)
+
+// jobCleanup is the kind of the recurring cleanup job.
+const jobCleanup = "cleanup"
+
+// Stats are the rows removed by a cleanup run.
+type Stats struct {
+	OffersDeleted   int64
+	RootKeysDeleted int64
+
+	// PaidOffersKept are the offers without purchase whose invoice was
+	// paid, e.g. gifts that were never streamed.
+	PaidOffersKept int64
+
+	TokensDeleted int64
+
+	// OffersFailed are the offers whose invoice could not be checked,
+	// retried on the next run.
+	OffersFailed int64
+}
+
+// Job periodically deletes the unpaid offers with their root keys and the
+// expired login tokens.
+type Job struct {
+	store    Store
+	provider InvoiceProvider
+	queue    Queue
+
+	cfg    *Config
+	clock  utils.Clock
+	logger *slog.Logger
+}
+
+// NewJob creates a new cleanup job.
+func NewJob(store Store, provider InvoiceProvider, queue Queue, cfg *Config,
+	clock utils.Clock, logger *slog.Logger) *Job {
+
+	return &Job{
+		store:    store,
+		provider: provider,
+		queue:    queue,
+		cfg:      cfg,
+		clock:    clock,
+		logger:   logger,
+	}
+}
+
+// RegisterJobs registers the job in the queue to run every configured
+// interval, unless disabled.
+func (j *Job) RegisterJobs() {
+	if j.cfg.Interval <= 0 {
+		j.logger.Info("Cleanup job disabled")
+		return
+	}
+
+	j.queue.RegisterRecurring(jobCleanup, j.cfg.Interval,
+		func(ctx context.Context) error {
+			_, err := j.Run(ctx)
+			return err
+		})
+}
+
+// Run removes the unpaid offers whose invoice expired more than the grace
+// period ago, together with their root keys, and the expired login tokens.
+func (j *Job) Run(ctx context.Context) (*Stats, error) {
+	metrics.Add(metricRuns, 1)
+
+	stats, err := j.run(ctx)
+
+	metrics.Add(metricOffersDeleted, stats.OffersDeleted)
+	metrics.Add(metricRootKeysDeleted, stats.RootKeysDeleted)
+	metrics.Add(metricPaidOffersKept, stats.PaidOffersKept)
+	metrics.Add(metricTokensDeleted, stats.TokensDeleted)
+	metrics.Add(metricOffersFailed, stats.OffersFailed)
+
+	if err != nil {
+		metrics.Add(metricFailedRuns, 1)
+		return stats, err
+	}
+
+	j.logger.Info("Cleanup finished",
+		"offersDeleted", stats.OffersDeleted,
+		"rootKeysDeleted", stats.RootKeysDeleted,
+		"paidOffersKept", stats.PaidOffersKept,
+		"tokensDeleted", stats.TokensDeleted,
+		"offersFailed", stats.OffersFailed)
+
+	return stats, nil
+}
+
+func (j *Job) run(ctx context.Context) (*Stats, error) {
+	stats := &Stats{}
+
+	tokens, err := j.store.DeleteExpiredTokens(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("failed to delete expired tokens: %w", err)
+	}
+	stats.TokensDeleted = tokens
+
+	// The offers are walked in ID order, so the ones that can't be
+	// checked are skipped until the next run instead of being listed
+	// again ahead of the rest.
+	cutoff := j.clock.Now().Add(-j.cfg.InvoiceExpiry - j.cfg.GracePeriod)
+	var afterID uint64
+	for {
+		offers, err := j.store.ListExpiredUnpaidOffers(ctx, cutoff,
+			afterID, j.cfg.BatchSize)
+		if err != nil {
+			return stats, fmt.Errorf("failed to list offers: %w", err)
+		}
+
+		for _, offer := range offers {
+			if ctx.Err() != nil {
+				return stats, ctx.Err()
+			}
+			afterID = offer.ID
+
+			err := j.collectOffer(ctx, offer.PaymentHash, stats)
+			if err != nil {
+				j.logger.Warn("Unable to collect offer",
+					"paymentHash", offer.PaymentHash,
+					"error", err)
+				stats.OffersFailed++
+			}
+		}
+
+		if int64(len(offers)) < j.cfg.BatchSize {
+			return stats, nil
+		}
+	}
+}
+
+// collectOffer deletes the offer for the payment hash if its invoice was
+// never paid. Paid invoices are marked as settled so the offer is not
+// checked again.
+func (j *Job) collectOffer(ctx context.Context, paymentHash string,
+	stats *Stats) error {
+
+	preimage, err := j.provider.GetInvoicePreimage(ctx, paymentHash)
+	if err != nil {
+		return fmt.Errorf("failed to check invoice: %w", err)
+	}
+
+	if preimage != "" {
+		_, err := j.store.UpsertInvoiceStatus(ctx, paymentHash, preimage,
+			true)
+		if err != nil {
+			return fmt.Errorf("failed to mark invoice as settled: %w", err)
+		}
+
+		stats.PaidOffersKept++
+		return nil
+	}
+
+	deleted, rootKeys, err := j.store.DeleteUnpaidOffer(ctx, paymentHash)
+	if err != nil {
+		return err
+	}
+
+	if deleted {
+		stats.OffersDeleted++
+		stats.RootKeysDeleted += rootKeys
+	}
+
+	return nil
+}
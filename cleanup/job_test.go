@@ -0,0 +1,164 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/fewsats/blockbuster/auth"
+	"github.com/fewsats/blockbuster/orders"
+	"github.com/fewsats/blockbuster/utils"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockStore struct {
+	mock.Mock
+}
+
+func (m *MockStore) ListExpiredUnpaidOffers(ctx context.Context,
+	createdBefore time.Time, afterID uint64,
+	limit int64) ([]*orders.Offer, error) {
+
+	args := m.Called(ctx, createdBefore, afterID, limit)
+	return args.Get(0).([]*orders.Offer), args.Error(1)
+}
+
+func (m *MockStore) DeleteUnpaidOffer(ctx context.Context,
+	paymentHash string) (bool, int64, error) {
+
+	args := m.Called(ctx, paymentHash)
+	return args.Bool(0), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockStore) DeleteExpiredTokens(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockStore) UpsertInvoiceStatus(ctx context.Context, paymentHash,
+	preimage string, settled bool) (*auth.InvoiceStatus, error) {
+
+	args := m.Called(ctx, paymentHash, preimage, settled)
+	return args.Get(0).(*auth.InvoiceStatus), args.Error(1)
+}
+
+type MockInvoiceProvider struct {
+	mock.Mock
+}
+
+func (m *MockInvoiceProvider) GetInvoicePreimage(ctx context.Context,
+	paymentHash string) (string, error) {
+
+	args := m.Called(ctx, paymentHash)
+	return args.String(0), args.Error(1)
+}
+
+// TestRun tests that only the offers whose invoice was not paid are deleted,
+// that the offers that can't be checked don't hold up the rest and that the
+// removed rows are counted.
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 9, 3, 0, 0, 0, 0, time.UTC)
+	clock := utils.NewMockClock()
+	clock.SetMockClockTime(now)
+
+	cfg := DefaultConfig()
+	cfg.BatchSize = 3
+	cutoff := now.Add(-48 * time.Hour)
+
+	store := new(MockStore)
+	provider := new(MockInvoiceProvider)
+
+	store.On("DeleteExpiredTokens", ctx).Return(int64(4), nil)
+	store.On("ListExpiredUnpaidOffers", ctx, cutoff, uint64(0),
+		int64(3)).Return([]*orders.Offer{
+		{ID: 1, PaymentHash: "unknown"},
+		{ID: 2, PaymentHash: "unpaid"},
+		{ID: 3, PaymentHash: "paid"},
+	}, nil)
+
+	// The next batch starts after the last listed offer, so the invoice
+	// that could not be checked is not listed again.
+	store.On("ListExpiredUnpaidOffers", ctx, cutoff, uint64(3),
+		int64(3)).Return([]*orders.Offer{
+		{ID: 5, PaymentHash: "later"},
+	}, nil)
+
+	provider.On("GetInvoicePreimage", ctx, "unpaid").Return("", nil)
+	provider.On("GetInvoicePreimage", ctx, "later").Return("", nil)
+	provider.On("GetInvoicePreimage", ctx, "paid").Return("preimage", nil)
+	provider.On("GetInvoicePreimage", ctx, "unknown").Return("",
+		errors.New("provider unavailable"))
+
+	store.On("DeleteUnpaidOffer", ctx, "unpaid").Return(true, int64(2), nil)
+	store.On("DeleteUnpaidOffer", ctx, "later").Return(true, int64(1), nil)
+	store.On("UpsertInvoiceStatus", ctx, "paid", "preimage", true).Return(
+		&auth.InvoiceStatus{}, nil)
+
+	job := NewJob(store, provider, nil, cfg, clock, slog.Default())
+
+	deleted := utils.MetricValue(metrics, metricOffersDeleted)
+	stats, err := job.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, &Stats{
+		OffersDeleted:   2,
+		RootKeysDeleted: 3,
+		PaidOffersKept:  1,
+		TokensDeleted:   4,
+		OffersFailed:    1,
+	}, stats)
+	require.Equal(t, deleted+2,
+		utils.MetricValue(metrics, metricOffersDeleted))
+
+	store.AssertExpectations(t)
+	provider.AssertExpectations(t)
+	store.AssertNotCalled(t, "DeleteUnpaidOffer", ctx, "paid")
+	store.AssertNotCalled(t, "DeleteUnpaidOffer", ctx, "unknown")
+}
+
+// recurringQueue records the recurring jobs registered in it.
+type recurringQueue struct {
+	intervals map[string]time.Duration
+	runs      map[string]func(ctx context.Context) error
+}
+
+func (q *recurringQueue) RegisterRecurring(kind string,
+	interval time.Duration, run func(ctx context.Context) error) {
+
+	q.intervals[kind] = interval
+	q.runs[kind] = run
+}
+
+// TestRegisterJobs tests that the job runs as a recurring job of the queue
+// unless disabled.
+func TestRegisterJobs(t *testing.T) {
+	ctx := context.Background()
+
+	store := new(MockStore)
+	store.On("DeleteExpiredTokens", ctx).Return(int64(0),
+		errors.New("database unavailable"))
+
+	queue := &recurringQueue{
+		intervals: make(map[string]time.Duration),
+		runs:      make(map[string]func(ctx context.Context) error),
+	}
+	cfg := DefaultConfig()
+	job := NewJob(store, new(MockInvoiceProvider), queue, cfg,
+		utils.NewMockClock(), slog.Default())
+
+	job.RegisterJobs()
+	require.Equal(t, cfg.Interval, queue.intervals[jobCleanup])
+
+	failed := utils.MetricValue(metrics, metricFailedRuns)
+	require.Error(t, queue.runs[jobCleanup](ctx))
+	require.Equal(t, failed+1, utils.MetricValue(metrics, metricFailedRuns))
+
+	// A zero interval disables the job.
+	delete(queue.intervals, jobCleanup)
+	cfg.Interval = 0
+	job.RegisterJobs()
+	require.NotContains(t, queue.intervals, jobCleanup)
+}
@@ -1,15 +1,38 @@
 package cloudflare
 
+import "time"
+
 // DefaultConfig returns all default values for the Config struct.
 func DefaultConfig() *Config {
-	return &Config{}
+	return &Config{
+		StreamURLExpiry: 23 * time.Hour,
+		MaxDuration:     time.Hour,
+		UploadExpiry:    2 * time.Hour,
+	}
 }
 
 type Config struct {
-	Endpoint         *string `long:"endpoint" description:"Cloudflare R2 endpoint."`
-	AccessKey        string  `long:"access_key" description:"Cloudflare R2 API token."`
-	SecretAccessKey  string  `long:"secret_access_key" description:"Cloudflare R2 API token."`
-	PublicBucketName string  `long:"public_bucket_name" description:"Cloudflare R2 public bucket name."`
-	APIToken         string  `long:"api_token" description:"Cloudflare API token for Streams"`
-	AccountID        string  `long:"account_id" description:"Cloudflare account ID"`
+	APIToken      string `long:"api_token" description:"Cloudflare API token for Streams"`
+	AccountID     string `long:"account_id" description:"Cloudflare account ID"`
+	WebhookSecret string `long:"webhook_secret" description:"Secret of the Stream webhook, returned when registering it"`
+
+	CustomerDomain  string        `long:"customer_domain" description:"Stream customer domain the videos are played from, e.g. customer-<code>.cloudflarestream.com"`
+	StreamURLExpiry time.Duration `long:"stream_url_expiry" description:"Maximum lifetime of the signed stream URLs, shortened to the expiration of the credentials paying for the video"`
+	SigningKeyID    string        `long:"signing_key_id" description:"ID of the Stream signing key used to sign the stream tokens locally. If empty, the tokens are requested from the Cloudflare API"`
+	SigningKeyPEM   string        `long:"signing_key_pem" description:"Base64 encoded PEM of the Stream signing key, as returned when creating it"`
+	BindClientIP    bool          `long:"bind_client_ip" description:"Only allow the IP that paid for a video to play it with its stream token"`
+	AllowedOrigins  []string      `long:"allowed_origin" description:"Origin allowed to embed the uploaded videos. Can be set multiple times, any origin is allowed if unset"`
+
+	MaxDuration        time.Duration           `long:"max_duration" description:"Maximum duration of the uploaded videos"`
+	CreatorMaxDuration map[int64]time.Duration `long:"creator_max_duration" description:"Maximum duration of the videos of a creator as user_id:duration, overriding max_duration. Can be set multiple times"`
+	UploadExpiry       time.Duration           `long:"upload_expiry" description:"Time a video upload URL is valid for"`
+}
+
+// maxDuration returns the maximum duration of the videos of a creator.
+func (c *Config) maxDuration(userID int64) time.Duration {
+	if maxDuration, ok := c.CreatorMaxDuration[userID]; ok {
+		return maxDuration
+	}
+
+	return c.MaxDuration
 }
@@ -3,10 +3,12 @@ package cloudflare
 import (
 	"context"
 	"fmt"
-	"io"
+	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/cloudflare/cloudflare-go"
+	"github.com/fewsats/blockbuster/video"
 )
 
 const (
@@ -16,7 +18,6 @@ const (
 
 // Service is the main storage service interface.
 type Service struct {
-	r2      *R2Service
 	streams *StreamsService
 
 	cfg *Config
@@ -24,34 +25,33 @@ type Service struct {
 
 // NewService creates a new storage service.
 func NewService(cfg *Config) (*Service, error) {
-	r2, err := NewR2Service(cfg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create R2 service: %w", err)
-	}
-
 	streams, err := NewStreamsService(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Streams service: %w", err)
 	}
 
 	return &Service{
-		r2:      r2,
 		streams: streams,
 
 		cfg: cfg,
 	}, nil
 }
 
-// PublicFileURL returns the URL of a file in the storage provider.
-func (s *Service) PublicFileURL(key string) string {
-	return s.r2.publicFileURL(key)
-}
+// GenerateStreamURL generates the signed URLs to stream a video, valid for
+// the configured time or until the credentials expire if it is earlier. The
+// URLs are bound to the client IP if configured.
+func (s *Service) GenerateStreamURL(ctx context.Context, key string,
+	opts *video.StreamURLOptions) (*video.StreamURLs, error) {
+
+	expiry := streamURLExpiry(time.Now(), s.cfg.StreamURLExpiry,
+		opts.ExpiresAt)
 
-// GenerateVideoViewURL generates a presigned URL for a video in the storage provider.
-func (s *Service) GenerateStreamURL(ctx context.Context,
-	key string) (string, string, error) {
+	var clientIP string
+	if s.cfg.BindClientIP {
+		clientIP = opts.ClientIP
+	}
 
-	return s.streams.generateStreamURL(ctx, key)
+	return s.streams.generateStreamURL(ctx, key, expiry, clientIP)
 }
 
 func (s *Service) GetStreamVideoInfo(ctx context.Context,
@@ -60,23 +60,75 @@ func (s *Service) GetStreamVideoInfo(ctx context.Context,
 	return s.streams.getStreamVideoInfo(ctx, externalID)
 }
 
-func (s *Service) GenerateVideoUploadURL(ctx context.Context) (string,
-	string, error) {
+// ListStreamVideos returns every video of the account in Stream.
+func (s *Service) ListStreamVideos(
+	ctx context.Context) ([]cloudflare.StreamVideo, error) {
 
-	return s.streams.generateVideoUploadURL(ctx)
+	return s.streams.listVideos(ctx)
 }
 
-func (s *Service) UploadPublicFile(ctx context.Context, fileID string,
-	prefix string, reader io.ReadSeeker) (string, error) {
-
-	key := fmt.Sprintf("%s/%s", prefix, fileID)
-	return s.r2.uploadPublicFile(ctx, key, reader)
+// DeleteVideo deletes the video from Stream.
+func (s *Service) DeleteVideo(ctx context.Context, externalID string) error {
+	return s.streams.deleteVideo(ctx, externalID)
 }
 
-func (s *Service) DeletePublicFile(ctx context.Context, key string) error {
-	err := s.r2.deletePublicFile(ctx, key)
+// GenerateVideoUploadURL creates a direct upload URL limited to the maximum
+// duration of the videos of the given creator, or a tus resumable upload if
+// requested.
+func (s *Service) GenerateVideoUploadURL(ctx context.Context, userID int64,
+	opts *video.UploadOptions) (*video.Upload, error) {
+
+	maxDuration := s.cfg.maxDuration(userID)
+	expiry := time.Now().Add(s.cfg.UploadExpiry)
+
+	if opts.Resumable {
+		uploadURL, externalID, err := s.streams.generateTUSUploadURL(ctx,
+			opts.Name, strconv.FormatInt(userID, 10), opts.SizeInBytes,
+			maxDuration, expiry)
+		if err != nil {
+			return nil, err
+		}
+
+		return &video.Upload{
+			ExternalID: externalID,
+			URL:        uploadURL,
+			Resumable:  true,
+		}, nil
+	}
+
+	uploadURL, externalID, err := s.streams.generateVideoUploadURL(ctx,
+		maxDuration, expiry)
 	if err != nil {
-		return fmt.Errorf("failed to delete file from storage: %w", err)
+		return nil, err
 	}
-	return nil
+
+	return &video.Upload{
+		ExternalID: externalID,
+		URL:        uploadURL,
+	}, nil
+}
+
+// ImportVideo has Stream copy the video from the source URL. Stream can't
+// limit the duration of the copies, so the duration of the imports is only
+// checked once processed.
+func (s *Service) ImportVideo(ctx context.Context, userID int64, sourceURL,
+	name string) (string, error) {
+
+	return s.streams.importVideo(ctx, sourceURL, name,
+		strconv.FormatInt(userID, 10))
+}
+
+// MaxDuration returns the maximum duration of the videos of the given
+// creator.
+func (s *Service) MaxDuration(userID int64) time.Duration {
+	return s.cfg.maxDuration(userID)
+}
+
+// VerifyWebhookSignature checks the signature of a Stream webhook, sent in
+// its WebhookSignatureHeader, with the configured webhook secret.
+func (s *Service) VerifyWebhookSignature(headers http.Header,
+	body []byte) error {
+
+	return VerifyWebhookSignature(s.cfg.WebhookSecret,
+		headers.Get(WebhookSignatureHeader), body, time.Now())
 }
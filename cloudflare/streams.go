@@ -2,38 +2,71 @@ package cloudflare
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/cloudflare/cloudflare-go"
+	"github.com/fewsats/blockbuster/video"
 )
 
+// ErrMissingCustomerDomain is returned when the Stream customer domain is not
+// configured.
+var ErrMissingCustomerDomain = errors.New("missing Stream customer domain")
+
+// streamListLimit is the maximum number of videos listed per request.
+const streamListLimit = 1000
+
 type StreamsService struct {
-	api       *cloudflare.API
-	accountID string
+	api            *cloudflare.API
+	accountID      string
+	customerDomain string
+	allowedOrigins []string
+
+	// signer signs the stream tokens locally, nil to request them from
+	// the API when no signing key is configured.
+	signer *streamTokenSigner
 }
 
 func NewStreamsService(cfg *Config) (*StreamsService, error) {
+	if cfg.CustomerDomain == "" {
+		return nil, ErrMissingCustomerDomain
+	}
+
 	api, err := cloudflare.NewWithAPIToken(cfg.APIToken)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Cloudflare API client: %w", err)
 	}
 
+	var signer *streamTokenSigner
+	if cfg.SigningKeyID != "" {
+		signer, err = newStreamTokenSigner(cfg.SigningKeyID,
+			cfg.SigningKeyPEM)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &StreamsService{
 		api: api,
 
-		accountID: cfg.AccountID,
+		accountID:      cfg.AccountID,
+		customerDomain: cfg.CustomerDomain,
+		allowedOrigins: cfg.AllowedOrigins,
+		signer:         signer,
 	}, nil
 }
 
-func (s *StreamsService) generateVideoUploadURL(ctx context.Context) (string, string, error) {
+func (s *StreamsService) generateVideoUploadURL(ctx context.Context,
+	maxDuration time.Duration, expiry time.Time) (string, string, error) {
 
-	expiry := time.Now().Add(2 * time.Hour)
 	params := cloudflare.StreamCreateVideoParameters{
 		AccountID:          s.accountID,
-		MaxDurationSeconds: 3600, // 1 hour max duration
+		MaxDurationSeconds: int(maxDuration.Seconds()),
 		Expiry:             &expiry,
 		RequireSignedURLs:  true,
+		AllowedOrigins:     s.allowedOrigins,
 	}
 
 	// Call the API to create a direct upload URL
@@ -45,6 +78,65 @@ func (s *StreamsService) generateVideoUploadURL(ctx context.Context) (string, st
 	return result.UploadURL, result.UID, nil
 }
 
+// generateTUSUploadURL creates a tus resumable upload of a video of
+// uploadLength bytes for the creator. The returned URL is a one-time upload
+// URL the client sends the chunks of the file to.
+func (s *StreamsService) generateTUSUploadURL(ctx context.Context,
+	name, creator string, uploadLength int64, maxDuration time.Duration,
+	expiry time.Time) (string, string, error) {
+
+	params := cloudflare.StreamInitiateTUSUploadParameters{
+		DirectUserUpload: true,
+		TusResumable:     cloudflare.TusProtocolVersion1_0_0,
+		UploadLength:     uploadLength,
+		UploadCreator:    creator,
+		Metadata: cloudflare.TUSUploadMetadata{
+			Name:               name,
+			MaxDurationSeconds: int(maxDuration.Seconds()),
+			RequireSignedURLs:  true,
+			AllowedOrigins:     strings.Join(s.allowedOrigins, ","),
+			Expiry:             &expiry,
+		},
+	}
+
+	result, err := s.api.StreamInitiateTUSVideoUpload(ctx,
+		cloudflare.AccountIdentifier(s.accountID), params)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create Stream tus upload: %w",
+			err)
+	}
+
+	uploadURL := result.ResponseHeaders.Get("Location")
+	externalID := result.ResponseHeaders.Get("Stream-Media-Id")
+	if uploadURL == "" || externalID == "" {
+		return "", "", errors.New("missing Stream tus upload location")
+	}
+
+	return uploadURL, externalID, nil
+}
+
+// importVideo creates a video of the creator that Stream copies from the
+// source URL in the background, and returns its UID.
+func (s *StreamsService) importVideo(ctx context.Context, sourceURL, name,
+	creator string) (string, error) {
+
+	params := cloudflare.StreamUploadFromURLParameters{
+		AccountID:         s.accountID,
+		URL:               sourceURL,
+		Creator:           creator,
+		RequireSignedURLs: true,
+		AllowedOrigins:    s.allowedOrigins,
+		Meta:              map[string]interface{}{"name": name},
+	}
+
+	result, err := s.api.StreamUploadFromURL(ctx, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to copy video to Stream: %w", err)
+	}
+
+	return result.UID, nil
+}
+
 func (s *StreamsService) getStreamVideoInfo(ctx context.Context,
 	externalID string) (*cloudflare.StreamVideo, error) {
 
@@ -61,25 +153,115 @@ func (s *StreamsService) getStreamVideoInfo(ctx context.Context,
 	return &video, nil
 }
 
-func (s *StreamsService) generateStreamURL(ctx context.Context,
-	externalID string) (string, string, error) {
+// listVideos returns every video of the account. The API returns the
+// newest videos first, up to streamListLimit per request, so the pages are
+// requested with the creation time of the oldest video listed so far.
+func (s *StreamsService) listVideos(
+	ctx context.Context) ([]cloudflare.StreamVideo, error) {
 
-	expiry := time.Now().Add(23 * time.Hour).Unix()
+	var (
+		videos []cloudflare.StreamVideo
+		seen   = make(map[string]bool)
+		before *time.Time
+	)
+	for {
+		page, err := s.api.StreamListVideos(ctx,
+			cloudflare.StreamListParameters{
+				AccountID: s.accountID,
+				Before:    before,
+				Limit:     streamListLimit,
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error listing stream videos: %w", err)
+		}
 
-	params := cloudflare.StreamSignedURLParameters{
+		for _, v := range page {
+			if seen[v.UID] {
+				continue
+			}
+			seen[v.UID] = true
+			videos = append(videos, v)
+		}
+
+		if len(page) < streamListLimit || page[len(page)-1].Created == nil {
+			return videos, nil
+		}
+
+		// The API filters by whole seconds, so the videos created in the
+		// same second as the last one of the page are listed again with
+		// the next page.
+		next := page[len(page)-1].Created.Truncate(time.Second).
+			Add(time.Second)
+		if before != nil && !next.Before(*before) {
+			return videos, nil
+		}
+		before = &next
+	}
+}
+
+// deleteVideo deletes a video from Stream, ignoring the videos that are
+// already gone.
+func (s *StreamsService) deleteVideo(ctx context.Context,
+	externalID string) error {
+
+	err := s.api.StreamDeleteVideo(ctx, cloudflare.StreamParameters{
 		AccountID: s.accountID,
 		VideoID:   externalID,
-		EXP:       int(expiry),
+	})
+
+	var notFound *cloudflare.NotFoundError
+	if err != nil && !errors.As(err, &notFound) {
+		return fmt.Errorf("error deleting stream video: %w", err)
 	}
 
-	token, err := s.api.StreamCreateSignedURL(ctx, params)
+	return nil
+}
+
+// generateStreamURL returns the URLs of a video with a token valid until the
+// expiry, bound to the client IP if not empty. The token is signed locally
+// when a signing key is configured, by the API otherwise.
+func (s *StreamsService) generateStreamURL(ctx context.Context,
+	externalID string, expiry time.Time,
+	clientIP string) (*video.StreamURLs, error) {
+
+	token, err := s.streamToken(ctx, externalID, expiry, clientIP)
 	if err != nil {
-		return "", "", fmt.Errorf("error generating signed URL: %w", err)
+		return nil, fmt.Errorf("error generating signed URL: %w", err)
 	}
 
-	customerSubdomain := "customer-2kawuipn4kja54d5.cloudflarestream.com"
-	HLSURL := fmt.Sprintf("https://%s/%s/manifest/video.m3u8", customerSubdomain, token)
-	DashURL := fmt.Sprintf("https://%s/%s/manifest/video.mpd", customerSubdomain, token)
+	return &video.StreamURLs{
+		HLSURL:  fmt.Sprintf("https://%s/%s/manifest/video.m3u8", s.customerDomain, token),
+		DashURL: fmt.Sprintf("https://%s/%s/manifest/video.mpd", s.customerDomain, token),
+	}, nil
+}
+
+func (s *StreamsService) streamToken(ctx context.Context, externalID string,
+	expiry time.Time, clientIP string) (string, error) {
+
+	if s.signer != nil {
+		return s.signer.sign(externalID, expiry, clientIP)
+	}
+
+	params := cloudflare.StreamSignedURLParameters{
+		AccountID:   s.accountID,
+		VideoID:     externalID,
+		EXP:         int(expiry.Unix()),
+		AccessRules: streamAccessRules(clientIP),
+	}
+
+	return s.api.StreamCreateSignedURL(ctx, params)
+}
+
+// streamURLExpiry returns when a stream URL signed at the given time expires:
+// after its maximum lifetime, or at expiresAt if it is earlier.
+func streamURLExpiry(now time.Time, lifetime time.Duration,
+	expiresAt *time.Time) time.Time {
+
+	expiry := now.Add(lifetime)
+	if expiresAt != nil && expiresAt.Before(expiry) {
+		return *expiresAt
+	}
 
-	return HLSURL, DashURL, nil
+	return expiry
 }
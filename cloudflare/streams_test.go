@@ -0,0 +1,120 @@
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamURLExpiry(t *testing.T) {
+	now := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	// Without credentials expiration the URL lives its full lifetime.
+	expiry := streamURLExpiry(now, 23*time.Hour, nil)
+	require.Equal(t, now.Add(23*time.Hour), expiry)
+
+	// Credentials expiring earlier shorten it.
+	expiresAt := now.Add(time.Hour)
+	expiry = streamURLExpiry(now, 23*time.Hour, &expiresAt)
+	require.Equal(t, expiresAt, expiry)
+
+	// But never extend it.
+	expiresAt = now.Add(30 * 24 * time.Hour)
+	expiry = streamURLExpiry(now, 23*time.Hour, &expiresAt)
+	require.Equal(t, now.Add(23*time.Hour), expiry)
+}
+
+func TestMaxDuration(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.CreatorMaxDuration = map[int64]time.Duration{7: 3 * time.Hour}
+
+	require.Equal(t, time.Hour, cfg.maxDuration(1))
+	require.Equal(t, 3*time.Hour, cfg.maxDuration(7))
+}
+
+func TestGenerateTUSUploadURL(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/accounts/account/stream", r.URL.Path)
+			require.Equal(t, "true", r.URL.Query().Get("direct_user"))
+			header = r.Header
+
+			w.Header().Set("Location", "https://upload.example.com/tus/1")
+			w.Header().Set("Stream-Media-Id", "uid")
+			w.WriteHeader(http.StatusCreated)
+		},
+	))
+	defer server.Close()
+
+	api, err := cloudflare.NewWithAPIToken("token",
+		cloudflare.BaseURL(server.URL))
+	require.NoError(t, err)
+
+	streams := &StreamsService{
+		api:            api,
+		accountID:      "account",
+		allowedOrigins: []string{"a.com", "b.com"},
+	}
+
+	expiry := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	uploadURL, externalID, err := streams.generateTUSUploadURL(
+		context.Background(), "title", "7", 1024, time.Hour, expiry,
+	)
+	require.NoError(t, err)
+	require.Equal(t, "https://upload.example.com/tus/1", uploadURL)
+	require.Equal(t, "uid", externalID)
+
+	// The size, creator and restrictions of the video are sent along.
+	require.Equal(t, "1.0.0", header.Get("Tus-Resumable"))
+	require.Equal(t, "1024", header.Get("Upload-Length"))
+	require.Equal(t, "7", header.Get("Upload-Creator"))
+
+	metadata := header.Get("Upload-Metadata")
+	require.Contains(t, metadata, "name dGl0bGU=")
+	require.Contains(t, metadata, "maxDurationSeconds MzYwMA==")
+	require.Contains(t, metadata, "requiresignedurls")
+	require.Contains(t, metadata, "allowedorigins YS5jb20sYi5jb20=")
+}
+
+func TestImportVideo(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/accounts/account/stream/copy", r.URL.Path)
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"success": true, "result": {"uid": "uid"}}`))
+		},
+	))
+	defer server.Close()
+
+	api, err := cloudflare.NewWithAPIToken("token",
+		cloudflare.BaseURL(server.URL))
+	require.NoError(t, err)
+
+	streams := &StreamsService{
+		api:            api,
+		accountID:      "account",
+		allowedOrigins: []string{"a.com"},
+	}
+
+	externalID, err := streams.importVideo(context.Background(),
+		"https://example.com/video.mp4", "title", "7")
+	require.NoError(t, err)
+	require.Equal(t, "uid", externalID)
+
+	// The copies have the same restrictions as the uploads.
+	require.Equal(t, "https://example.com/video.mp4", body["url"])
+	require.Equal(t, "7", body["creator"])
+	require.Equal(t, true, body["requireSignedURLs"])
+	require.Equal(t, []any{"a.com"}, body["allowedOrigins"])
+	require.Equal(t, map[string]any{"name": "title"}, body["meta"])
+}
@@ -0,0 +1,80 @@
+package cloudflare
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamTokenSigner(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	pemBytes := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+	pemBase64 := base64.StdEncoding.EncodeToString(pemBytes)
+
+	_, err = newStreamTokenSigner("keyID", "not a key")
+	require.ErrorIs(t, err, ErrInvalidSigningKey)
+
+	signer, err := newStreamTokenSigner("keyID", pemBase64)
+	require.NoError(t, err)
+
+	expiry := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	token, err := signer.sign("videoID", expiry, "203.0.113.7")
+	require.NoError(t, err)
+
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+
+	// The token is signed with RS256 by the key.
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	err = rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:],
+		signature)
+	require.NoError(t, err)
+
+	var header streamTokenHeader
+	decodeTokenPart(t, parts[0], &header)
+	require.Equal(t, "RS256", header.Alg)
+	require.Equal(t, "keyID", header.Kid)
+
+	// It grants access to the video until the expiry, only from the IP.
+	var claims streamTokenClaims
+	decodeTokenPart(t, parts[1], &claims)
+	require.Equal(t, "videoID", claims.Sub)
+	require.Equal(t, "keyID", claims.Kid)
+	require.Equal(t, expiry.Unix(), claims.Exp)
+	require.Len(t, claims.AccessRules, 2)
+	require.Equal(t, []string{"203.0.113.7"}, claims.AccessRules[0].IP)
+	require.Equal(t, "block", claims.AccessRules[1].Action)
+
+	// Tokens are not bound to an IP by default.
+	token, err = signer.sign("videoID", expiry, "")
+	require.NoError(t, err)
+
+	claims = streamTokenClaims{}
+	decodeTokenPart(t, strings.Split(token, ".")[1], &claims)
+	require.Empty(t, claims.AccessRules)
+}
+
+func decodeTokenPart(t *testing.T, part string, v any) {
+	t.Helper()
+
+	decoded, err := base64.RawURLEncoding.DecodeString(part)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(decoded, v))
+}
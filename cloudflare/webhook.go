@@ -0,0 +1,73 @@
+package cloudflare
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// WebhookSignatureHeader holds the signature of the Stream webhooks as
+	// "time=<unix timestamp>,sig1=<hex signature>".
+	WebhookSignatureHeader = "Webhook-Signature"
+
+	// WebhookTolerance is the maximum age of a webhook signature, to
+	// reject replayed requests.
+	WebhookTolerance = 5 * time.Minute
+)
+
+var (
+	// ErrInvalidWebhookSignature is returned when the signature of a
+	// webhook is missing, stale or does not match its body.
+	ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+)
+
+// VerifyWebhookSignature checks the signature header of a Stream webhook,
+// which is the hex encoded HMAC-SHA256 of "<unix timestamp>.<body>" with the
+// webhook secret.
+func VerifyWebhookSignature(secret, header string, body []byte,
+	now time.Time) error {
+
+	if secret == "" {
+		return fmt.Errorf("%w: webhook secret not configured",
+			ErrInvalidWebhookSignature)
+	}
+
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "time":
+			timestamp = value
+		case "sig1":
+			signature = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || signature == "" {
+		return fmt.Errorf("%w: malformed header",
+			ErrInvalidWebhookSignature)
+	}
+
+	age := now.Sub(time.Unix(unix, 0))
+	if age > WebhookTolerance || age < -WebhookTolerance {
+		return fmt.Errorf("%w: stale timestamp", ErrInvalidWebhookSignature)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac.Sum(nil), expected) {
+		return ErrInvalidWebhookSignature
+	}
+
+	return nil
+}
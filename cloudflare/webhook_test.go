@@ -0,0 +1,76 @@
+package cloudflare
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestVerifyWebhookSignature tests the verification of the Stream webhook
+// signatures.
+func TestVerifyWebhookSignature(t *testing.T) {
+	const secret = "secret"
+	body := []byte(`{"uid":"externalID","readyToStream":true}`)
+	now := time.Unix(1725148800, 0)
+
+	sign := func(timestamp int64, body []byte) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		fmt.Fprintf(mac, "%d.%s", timestamp, body)
+
+		return fmt.Sprintf("time=%d,sig1=%s", timestamp,
+			hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	tests := []struct {
+		name    string
+		secret  string
+		header  string
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			secret: secret,
+			header: sign(now.Unix(), body),
+		},
+		{
+			name:    "tampered body",
+			secret:  secret,
+			header:  sign(now.Unix(), []byte(`{"uid":"other"}`)),
+			wantErr: true,
+		},
+		{
+			name:    "stale",
+			secret:  secret,
+			header:  sign(now.Add(-time.Hour).Unix(), body),
+			wantErr: true,
+		},
+		{
+			name:    "malformed",
+			secret:  secret,
+			header:  "sig1=abc",
+			wantErr: true,
+		},
+		{
+			name:    "no secret",
+			header:  sign(now.Unix(), body),
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := VerifyWebhookSignature(tc.secret, tc.header, body, now)
+			if tc.wantErr {
+				require.ErrorIs(t, err, ErrInvalidWebhookSignature)
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
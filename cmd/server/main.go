@@ -1,21 +1,31 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/fewsats/blockbuster/auth"
+	"github.com/fewsats/blockbuster/cleanup"
 	"github.com/fewsats/blockbuster/cloudflare"
 	"github.com/fewsats/blockbuster/config"
 	"github.com/fewsats/blockbuster/email"
+	"github.com/fewsats/blockbuster/jobs"
 	"github.com/fewsats/blockbuster/l402"
 	"github.com/fewsats/blockbuster/lightning"
 	"github.com/fewsats/blockbuster/orders"
+	"github.com/fewsats/blockbuster/reconcile"
+	"github.com/fewsats/blockbuster/selfhosted"
 	"github.com/fewsats/blockbuster/server"
+	"github.com/fewsats/blockbuster/storage"
 	storePkg "github.com/fewsats/blockbuster/store"
 	"github.com/fewsats/blockbuster/utils"
 	"github.com/fewsats/blockbuster/video"
+	"github.com/fewsats/blockbuster/webhooks"
 )
 
 func main() {
@@ -47,18 +57,79 @@ func main() {
 	}
 
 	emailService := email.NewResendService(logger, &cfg.Email)
-	cloudflareService, err := cloudflare.NewService(&cfg.Cloudflare)
+	jobQueue := jobs.NewQueue(store, &cfg.Jobs, clock, logger)
+
+	notifier := email.NewNotifier(emailService, store, jobQueue, &cfg.Email,
+		clock, logger)
+	notifier.RegisterJobs()
+
+	if cfg.Storage.Provider == storage.ProviderLocal &&
+		cfg.Storage.Local.SigningKey == "" {
+
+		logger.Warn("No storage signing key configured, signed URLs " +
+			"will not survive a restart")
+	}
+	publicStorage, err := storage.New(&cfg.Storage, storage.BucketPublic,
+		clock)
 	if err != nil {
-		logger.Error("Failed to create cloudflare service", "error", err)
-		return
+		logger.Error("Failed to create storage", "error", err)
+		os.Exit(1)
+	}
+	privateStorage, err := storage.New(&cfg.Storage, storage.BucketPrivate,
+		clock)
+	if err != nil {
+		logger.Error("Failed to create storage", "error", err)
+		os.Exit(1)
+	}
+
+	var (
+		streamingService  video.CloudflareService
+		streamLister      reconcile.StreamLister
+		uploadExpiry      time.Duration
+		selfHostedService *selfhosted.Service
+	)
+	switch cfg.Video.StreamingProvider {
+	case video.StreamingProviderCloudflare:
+		cloudflareService, err := cloudflare.NewService(&cfg.Cloudflare)
+		if err != nil {
+			logger.Error("Failed to create cloudflare service", "error", err)
+			return
+		}
+		streamingService = cloudflareService
+		streamLister = cloudflareService
+		uploadExpiry = cfg.Cloudflare.UploadExpiry
+
+	case video.StreamingProviderSelfHosted:
+		selfHostedService, err = selfhosted.NewService(&cfg.SelfHosted,
+			store, privateStorage, jobQueue, clock, logger)
+		if err != nil {
+			logger.Error("Failed to create self-hosted service",
+				"error", err)
+			os.Exit(1)
+		}
+		streamingService = selfHostedService
+		streamLister = selfHostedService
+		uploadExpiry = cfg.SelfHosted.UploadExpiry
+
+	default:
+		logger.Error(
+			"Unknown streaming provider",
+			"provider", cfg.Video.StreamingProvider,
+		)
+		os.Exit(1)
 	}
 
-	var invoiceProvider l402.InvoiceProvider
+	var (
+		invoiceProvider l402.InvoiceProvider
+		payer           orders.Payer
+	)
 	switch cfg.Lightning.Provider {
 	case lightning.ProviderAlby:
-		invoiceProvider = lightning.NewAlbyProvider(
+		albyProvider := lightning.NewAlbyProvider(
 			http.DefaultClient, cfg.Lightning.Alby.APIKey,
 		)
+		invoiceProvider = albyProvider
+		payer = albyProvider
 
 	default:
 		logger.Error(
@@ -71,23 +142,64 @@ func main() {
 		logger, invoiceProvider, &cfg.L402, store, clock,
 	)
 
+	dispatcher := webhooks.NewDispatcher(store,
+		webhooks.NewHTTPClient(&cfg.Webhooks), jobQueue, &cfg.Webhooks, clock,
+		logger)
+	dispatcher.RegisterJobs()
+
 	// Managers
-	ordersMgr := orders.NewManager(logger, store)
-	videoMgr := video.NewManager(ordersMgr, cloudflareService, authenticator,
-		store, logger, clock)
+	ordersMgr := orders.NewManager(logger, store, payer, invoiceProvider,
+		notifier, dispatcher, &cfg.Orders)
+	videoMgr := video.NewManager(ordersMgr, streamingService, publicStorage,
+		authenticator, notifier, dispatcher, jobQueue, store, logger, clock,
+		&cfg.Video)
+
+	var selfHostedController *selfhosted.Controller
+	if selfHostedService != nil {
+		selfHostedService.RegisterJobs(videoMgr)
+		selfHostedController = selfhosted.NewController(selfHostedService,
+			logger)
+	}
 
 	authController := auth.NewController(emailService, invoiceProvider, logger, store, clock, &cfg.Auth)
 	videoController := video.NewController(videoMgr, authenticator, store, logger, &cfg.Video)
-
-	srv, err := server.NewServer(logger, cfg, authController, videoController)
+	ordersController := orders.NewController(ordersMgr, authenticator, logger)
+	webhooksController := webhooks.NewController(dispatcher, logger)
+	storageController := storage.NewController(logger, publicStorage,
+		privateStorage)
+
+	srv, err := server.NewServer(logger, cfg, authController, videoController,
+		ordersController, webhooksController, storageController,
+		selfHostedController)
 	if err != nil {
 		logger.Error("Failed to create server", "error", err)
 		os.Exit(1)
 	}
 
+	// Background jobs
+	cleanupJob := cleanup.NewJob(store, invoiceProvider, jobQueue,
+		&cfg.Cleanup, clock, logger)
+	cleanupJob.RegisterJobs()
+
+	reconcileJob := reconcile.NewJob(store, streamLister, videoMgr,
+		publicStorage, jobQueue, uploadExpiry, &cfg.Reconcile, clock,
+		logger)
+	reconcileJob.RegisterJobs()
+
+	jobQueue.Start()
+	defer jobQueue.Stop()
+
+	// Stop the server on SIGINT or SIGTERM, the deferred calls then stop the
+	// background jobs once their current work is done.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt,
+		syscall.SIGTERM)
+	defer stop()
+
 	logger.Info("Starting server", "port", cfg.Port)
-	if err := srv.Run(); err != nil {
+	if err := srv.Run(ctx); err != nil {
 		logger.Error("Server error", "error", err)
 		os.Exit(1)
 	}
+
+	logger.Info("Server stopped")
 }
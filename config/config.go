@@ -6,12 +6,19 @@ import (
 	"os"
 
 	"github.com/fewsats/blockbuster/auth"
+	"github.com/fewsats/blockbuster/cleanup"
 	"github.com/fewsats/blockbuster/cloudflare"
 	"github.com/fewsats/blockbuster/email"
+	"github.com/fewsats/blockbuster/jobs"
 	"github.com/fewsats/blockbuster/l402"
 	"github.com/fewsats/blockbuster/lightning"
+	"github.com/fewsats/blockbuster/orders"
+	"github.com/fewsats/blockbuster/reconcile"
+	"github.com/fewsats/blockbuster/selfhosted"
+	"github.com/fewsats/blockbuster/storage"
 	"github.com/fewsats/blockbuster/store"
 	"github.com/fewsats/blockbuster/video"
+	"github.com/fewsats/blockbuster/webhooks"
 	"github.com/gin-gonic/gin"
 	"github.com/jessevdk/go-flags"
 )
@@ -26,13 +33,6 @@ type EmailConfig struct {
 	APIKey   string `long:"api_key" description:"Email provider API key"`
 }
 
-type StorageConfig struct {
-	Provider string `long:"provider" description:"Storage provider"`
-	Local    struct {
-		Path string `long:"path" description:"Local storage path"`
-	} `group:"local" namespace:"local"`
-}
-
 type Config struct {
 	LogLevel          string `long:"log_level" description:"Logging level {debug, info, warn, error}"`
 	Port              int    `long:"port" description:"Port to listen on"`
@@ -42,10 +42,17 @@ type Config struct {
 	Auth       auth.Config       `group:"auth" namespace:"auth"`
 	Email      email.Config      `group:"email" namespace:"email"`
 	Cloudflare cloudflare.Config `group:"cloudflare" namespace:"cloudflare"`
+	Storage    storage.Config    `group:"storage" namespace:"storage"`
+	SelfHosted selfhosted.Config `group:"selfhosted" namespace:"selfhosted"`
 	Store      store.Config      `group:"store" namespace:"store"`
 	Lightning  lightning.Config  `group:"lightning" namespace:"lightning"`
 	L402       l402.Config       `group:"l402" namespace:"l402"`
 	Video      video.Config      `group:"video" namespace:"video"`
+	Orders     orders.Config     `group:"orders" namespace:"orders"`
+	Cleanup    cleanup.Config    `group:"cleanup" namespace:"cleanup"`
+	Reconcile  reconcile.Config  `group:"reconcile" namespace:"reconcile"`
+	Webhooks   webhooks.Config   `group:"webhooks" namespace:"webhooks"`
+	Jobs       jobs.Config       `group:"jobs" namespace:"jobs"`
 }
 
 func (c *Config) Validate() error {
@@ -88,9 +95,16 @@ func DefaultConfig() *Config {
 		Email:      *email.DefaultConfig(),
 		Store:      *store.DefaultConfig(),
 		Cloudflare: *cloudflare.DefaultConfig(),
+		Storage:    *storage.DefaultConfig(),
+		SelfHosted: *selfhosted.DefaultConfig(),
 		Lightning:  *lightning.DefaultConfig(),
 		L402:       *l402.DefaultConfig(),
 		Video:      *video.DefaultConfig(),
+		Orders:     *orders.DefaultConfig(),
+		Cleanup:    *cleanup.DefaultConfig(),
+		Reconcile:  *reconcile.DefaultConfig(),
+		Webhooks:   *webhooks.DefaultConfig(),
+		Jobs:       *jobs.DefaultConfig(),
 	}
 }
 
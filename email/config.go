@@ -1,11 +1,15 @@
 package email
 
+import "time"
+
 // DefaultConfig returns all default values for the Config struct.
 func DefaultConfig() *Config {
 	return &Config{
 		Provider: "resend",
 		APIKey:   "",
 		BaseURL:  "http://localhost:8080",
+
+		DigestInterval: 24 * time.Hour,
 	}
 }
 
@@ -14,4 +18,6 @@ type Config struct {
 	Provider string `long:"provider" description:"Email provider"`
 	APIKey   string `long:"api_key" description:"Email provider API key"`
 	BaseURL  string `long:"base_url" description:"Base URL for the application"`
+
+	DigestInterval time.Duration `long:"digest_interval" description:"Time between the notification digests sent to the users that chose them, 0 disables them"`
 }
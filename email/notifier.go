@@ -0,0 +1,356 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/fewsats/blockbuster/jobs"
+	"github.com/fewsats/blockbuster/orders"
+	"github.com/fewsats/blockbuster/utils"
+)
+
+const (
+	// NotificationInstant sends an email for every event.
+	NotificationInstant = "instant"
+
+	// NotificationDaily groups the events of the day in a single email.
+	NotificationDaily = "daily"
+
+	// NotificationOff disables the notifications.
+	NotificationOff = "off"
+
+	// EventPurchase is the event of a new purchase of a creator's content.
+	EventPurchase = "purchase"
+
+	// EventVideoUpload is the event of a new video upload.
+	EventVideoUpload = "video_upload"
+
+	// EventVideoImport is the event of a new video copied from a URL.
+	EventVideoImport = "video_import"
+
+	// jobNotify is the kind of the jobs that deliver an event according to
+	// the preference of its user.
+	jobNotify = "email_notify"
+
+	// jobSendDigests is the kind of the recurring job that sends the
+	// digests.
+	jobSendDigests = "email_send_digests"
+)
+
+// ValidNotificationPreference reports whether the preference is one of the
+// supported ones.
+func ValidNotificationPreference(preference string) bool {
+	switch preference {
+	case NotificationInstant, NotificationDaily, NotificationOff:
+		return true
+	}
+
+	return false
+}
+
+// Recipient is the user that receives the notifications.
+type Recipient struct {
+	Email string
+
+	// Preference is one of the Notification values.
+	Preference string
+}
+
+// Event is something a user is notified about.
+type Event struct {
+	// Type is one of the Event values.
+	Type string `json:"type"`
+
+	ExternalID  string `json:"external_id"`
+	PaymentHash string `json:"payment_hash,omitempty"`
+
+	// ServiceType, Title, PriceInCents and Currency describe the content
+	// sold in a purchase. The title is looked up when the event is
+	// delivered.
+	ServiceType  string `json:"service_type,omitempty"`
+	Title        string `json:"title,omitempty"`
+	PriceInCents uint64 `json:"price_in_cents,omitempty"`
+	Currency     string `json:"currency,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// notifyPayload is the payload of the jobs that deliver an event.
+type notifyPayload struct {
+	UserID int64  `json:"user_id"`
+	Event  *Event `json:"event"`
+}
+
+// Digest holds the events pending to be sent to a user.
+type Digest struct {
+	UserID    int64
+	Recipient Recipient
+	Events    []*Event
+
+	// LastID is the ID of the last pending event included in the digest.
+	LastID int64
+}
+
+// NotifierStore is the interface for the notification preferences and the
+// events pending for the daily digests.
+type NotifierStore interface {
+	// GetNotificationRecipient returns the email and the notification
+	// preference of a user.
+	GetNotificationRecipient(ctx context.Context, userID int64) (*Recipient,
+		error)
+
+	// GetPurchaseTitle returns the title of the content sold with the
+	// given service type and external ID, even if it has been deleted.
+	GetPurchaseTitle(ctx context.Context, serviceType,
+		externalID string) (string, error)
+
+	// AddPendingNotification stores an event for the digest of a user.
+	AddPendingNotification(ctx context.Context, userID int64,
+		event *Event) error
+
+	// ListPendingDigests returns the pending events grouped by user.
+	ListPendingDigests(ctx context.Context) ([]*Digest, error)
+
+	// DeletePendingNotifications removes the pending events of a user up
+	// to the given ID.
+	DeletePendingNotifications(ctx context.Context, userID,
+		lastID int64) error
+}
+
+// Sender is the interface for sending emails.
+type Sender interface {
+	SendEmail(to, subject, html string) error
+}
+
+// Queue is the interface for the job queue that delivers the notifications
+// and sends the digests.
+type Queue interface {
+	Register(kind string, handler jobs.Handler)
+	RegisterRecurring(kind string, interval time.Duration,
+		run func(ctx context.Context) error)
+	Enqueue(ctx context.Context, kind string, payload any) (*jobs.Job,
+		error)
+}
+
+// Notifier emails creators about their new purchases and uploads, either
+// instantly or in a daily digest, depending on their preference.
+type Notifier struct {
+	sender Sender
+	store  NotifierStore
+	queue  Queue
+
+	cfg    *Config
+	clock  utils.Clock
+	logger *slog.Logger
+}
+
+// NewNotifier creates a new email notifier.
+func NewNotifier(sender Sender, store NotifierStore, queue Queue,
+	cfg *Config, clock utils.Clock, logger *slog.Logger) *Notifier {
+
+	return &Notifier{
+		sender: sender,
+		store:  store,
+		queue:  queue,
+		cfg:    cfg,
+		clock:  clock,
+		logger: logger,
+	}
+}
+
+// RegisterJobs registers the delivery of the events and, unless disabled,
+// the digests sent every configured interval in the queue.
+func (n *Notifier) RegisterJobs() {
+	n.queue.Register(jobNotify, n.deliver)
+
+	if n.cfg.DigestInterval <= 0 {
+		n.logger.Info("Notification digests disabled")
+		return
+	}
+
+	n.queue.RegisterRecurring(jobSendDigests, n.cfg.DigestInterval,
+		n.SendDigests)
+}
+
+// RegisterNewPurchaseEvent notifies the seller of a new purchase.
+func (n *Notifier) RegisterNewPurchaseEvent(ctx context.Context,
+	purchase *orders.Purchase) error {
+
+	return n.notify(ctx, int64(purchase.UserID), &Event{
+		Type:         EventPurchase,
+		ExternalID:   purchase.ExternalID,
+		PaymentHash:  purchase.PaymentHash,
+		ServiceType:  purchase.ServiceType,
+		PriceInCents: purchase.PriceInCents,
+		Currency:     purchase.Currency,
+		CreatedAt:    n.clock.Now(),
+	})
+}
+
+// RegisterNewVideoUploadEvent notifies the user of a new video upload.
+func (n *Notifier) RegisterNewVideoUploadEvent(ctx context.Context,
+	userID int64, externalID string) error {
+
+	return n.notify(ctx, userID, &Event{
+		Type:       EventVideoUpload,
+		ExternalID: externalID,
+		CreatedAt:  n.clock.Now(),
+	})
+}
+
+// RegisterNewVideoImportEvent notifies the user of a new video import.
+func (n *Notifier) RegisterNewVideoImportEvent(ctx context.Context,
+	userID int64, externalID string) error {
+
+	return n.notify(ctx, userID, &Event{
+		Type:       EventVideoImport,
+		ExternalID: externalID,
+		CreatedAt:  n.clock.Now(),
+	})
+}
+
+// notify enqueues the delivery of an event, so sending the email doesn't
+// hold up the request that caused it.
+func (n *Notifier) notify(ctx context.Context, userID int64,
+	event *Event) error {
+
+	_, err := n.queue.Enqueue(ctx, jobNotify, &notifyPayload{
+		UserID: userID,
+		Event:  event,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enqueue notification: %w", err)
+	}
+
+	return nil
+}
+
+// deliver emails an event or stores it for the next digest, depending on the
+// preference of its user.
+func (n *Notifier) deliver(ctx context.Context, job *jobs.Job) error {
+	var payload notifyPayload
+	if err := job.Decode(&payload); err != nil {
+		return fmt.Errorf("failed to decode payload: %w", err)
+	}
+	userID, event := payload.UserID, payload.Event
+
+	recipient, err := n.store.GetNotificationRecipient(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get notification recipient: %w", err)
+	}
+
+	if recipient.Preference == NotificationOff {
+		return nil
+	}
+
+	if event.Type == EventPurchase && event.Title == "" {
+		event.Title, err = n.store.GetPurchaseTitle(ctx, event.ServiceType,
+			event.ExternalID)
+		if err != nil {
+			return fmt.Errorf("failed to get purchase title: %w", err)
+		}
+	}
+
+	if recipient.Preference == NotificationDaily {
+		return n.store.AddPendingNotification(ctx, userID, event)
+	}
+
+	subject, body := n.eventEmail(event)
+
+	return n.sender.SendEmail(recipient.Email, subject, body)
+}
+
+// SendDigests emails every user their pending events. Events of users that
+// turned notifications off since are discarded.
+func (n *Notifier) SendDigests(ctx context.Context) error {
+	digests, err := n.store.ListPendingDigests(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list pending digests: %w", err)
+	}
+
+	var errs []error
+	for _, digest := range digests {
+		if digest.Recipient.Preference != NotificationOff {
+			err := n.sender.SendEmail(digest.Recipient.Email,
+				"Your Blockbuster daily digest",
+				n.digestEmail(digest.Events))
+			if err != nil {
+				// The events are kept for the next digest.
+				errs = append(errs, fmt.Errorf("failed to send digest "+
+					"to user %d: %w", digest.UserID, err))
+				continue
+			}
+		}
+
+		err := n.store.DeletePendingNotifications(ctx, digest.UserID,
+			digest.LastID)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// eventEmail returns the subject and the HTML body of the email for a single
+// event.
+func (n *Notifier) eventEmail(event *Event) (string, string) {
+	subject := "New video on Blockbuster"
+	if event.Type == EventPurchase {
+		subject = "New purchase on Blockbuster"
+	}
+
+	return subject, "<p>Hi!<br><br>" + n.eventLine(event) + "</p>"
+}
+
+// digestEmail returns the HTML body of a digest.
+func (n *Notifier) digestEmail(events []*Event) string {
+	var body strings.Builder
+	body.WriteString("<p>Hi!<br><br>Here is what happened on Blockbuster " +
+		"since the last digest:</p><ul>")
+	for _, event := range events {
+		body.WriteString("<li>" + n.eventLine(event) + "</li>")
+	}
+	body.WriteString("</ul>")
+
+	return body.String()
+}
+
+// eventLine describes an event in HTML.
+func (n *Notifier) eventLine(event *Event) string {
+	externalID := html.EscapeString(event.ExternalID)
+
+	switch event.Type {
+	case EventPurchase:
+		title := externalID
+		if event.Title != "" {
+			title = html.EscapeString(event.Title)
+		}
+
+		return fmt.Sprintf("New purchase of <b>%s</b> for %s (payment "+
+			"hash %s).", title, formatAmount(event.PriceInCents,
+			event.Currency), html.EscapeString(event.PaymentHash))
+
+	case EventVideoImport:
+		link := n.cfg.BaseURL + "/video/" + externalID
+		return fmt.Sprintf("Your video <a href=\"%s\">%s</a> was "+
+			"created and is being imported.", link, link)
+
+	default:
+		link := n.cfg.BaseURL + "/video/" + externalID
+		return fmt.Sprintf("Your video <a href=\"%s\">%s</a> was "+
+			"created and is waiting for its upload.", link, link)
+	}
+}
+
+// formatAmount formats an amount in cents of the given currency, e.g. "5.00
+// USD".
+func formatAmount(cents uint64, currency string) string {
+	return fmt.Sprintf("%d.%02d %s", cents/100, cents%100,
+		html.EscapeString(currency))
+}
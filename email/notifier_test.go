@@ -0,0 +1,273 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fewsats/blockbuster/jobs"
+	"github.com/fewsats/blockbuster/orders"
+	"github.com/fewsats/blockbuster/utils"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockNotifierStore struct {
+	mock.Mock
+}
+
+func (m *MockNotifierStore) GetNotificationRecipient(ctx context.Context,
+	userID int64) (*Recipient, error) {
+
+	args := m.Called(ctx, userID)
+	return args.Get(0).(*Recipient), args.Error(1)
+}
+
+func (m *MockNotifierStore) GetPurchaseTitle(ctx context.Context,
+	serviceType, externalID string) (string, error) {
+
+	args := m.Called(ctx, serviceType, externalID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockNotifierStore) AddPendingNotification(ctx context.Context,
+	userID int64, event *Event) error {
+
+	args := m.Called(ctx, userID, event)
+	return args.Error(0)
+}
+
+func (m *MockNotifierStore) ListPendingDigests(
+	ctx context.Context) ([]*Digest, error) {
+
+	args := m.Called(ctx)
+	return args.Get(0).([]*Digest), args.Error(1)
+}
+
+func (m *MockNotifierStore) DeletePendingNotifications(ctx context.Context,
+	userID, lastID int64) error {
+
+	args := m.Called(ctx, userID, lastID)
+	return args.Error(0)
+}
+
+// memoryQueue keeps the enqueued jobs until run.
+type memoryQueue struct {
+	handlers  map[string]jobs.Handler
+	intervals map[string]time.Duration
+	jobs      []*jobs.Job
+}
+
+func newMemoryQueue() *memoryQueue {
+	return &memoryQueue{
+		handlers:  make(map[string]jobs.Handler),
+		intervals: make(map[string]time.Duration),
+	}
+}
+
+func (m *memoryQueue) Register(kind string, handler jobs.Handler) {
+	m.handlers[kind] = handler
+}
+
+func (m *memoryQueue) RegisterRecurring(kind string, interval time.Duration,
+	run func(ctx context.Context) error) {
+
+	m.intervals[kind] = interval
+	m.handlers[kind] = func(ctx context.Context, _ *jobs.Job) error {
+		return run(ctx)
+	}
+}
+
+func (m *memoryQueue) Enqueue(_ context.Context, kind string,
+	payload any) (*jobs.Job, error) {
+
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &jobs.Job{Kind: kind, Payload: string(encoded)}
+	m.jobs = append(m.jobs, job)
+
+	return job, nil
+}
+
+// runAll runs the enqueued jobs.
+func (m *memoryQueue) runAll(t *testing.T, ctx context.Context) {
+	for _, job := range m.jobs {
+		require.NoError(t, m.handlers[job.Kind](ctx, job))
+	}
+	m.jobs = nil
+}
+
+type MockSender struct {
+	mock.Mock
+}
+
+func (m *MockSender) SendEmail(to, subject, html string) error {
+	args := m.Called(to, subject, html)
+	return args.Error(0)
+}
+
+// TestNotifyPurchase tests that purchases are notified with their title and
+// amount according to the preference of the seller.
+func TestNotifyPurchase(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	clock := utils.NewMockClock()
+	clock.SetMockClockTime(now)
+
+	tests := []struct {
+		name       string
+		preference string
+		setupMocks func(*MockNotifierStore, *MockSender)
+	}{
+		{
+			name:       "instant",
+			preference: NotificationInstant,
+			setupMocks: func(store *MockNotifierStore, sender *MockSender) {
+				store.On("GetPurchaseTitle", ctx, "videos",
+					"externalID").Return("My <video>", nil)
+				sender.On("SendEmail", "creator@fewsats.com",
+					"New purchase on Blockbuster",
+					mock.MatchedBy(func(html string) bool {
+						return strings.Contains(html,
+							"New purchase of <b>My &lt;video&gt;</b> "+
+								"for 5.50 USD")
+					})).Return(nil)
+			},
+		},
+		{
+			name:       "daily",
+			preference: NotificationDaily,
+			setupMocks: func(store *MockNotifierStore, sender *MockSender) {
+				store.On("GetPurchaseTitle", ctx, "videos",
+					"externalID").Return("My <video>", nil)
+				store.On("AddPendingNotification", ctx, int64(1),
+					&Event{
+						Type:         EventPurchase,
+						ExternalID:   "externalID",
+						PaymentHash:  "paymentHash",
+						ServiceType:  "videos",
+						Title:        "My <video>",
+						PriceInCents: 550,
+						Currency:     "USD",
+						CreatedAt:    now,
+					}).Return(nil)
+			},
+		},
+		{
+			name:       "off",
+			preference: NotificationOff,
+			setupMocks: func(*MockNotifierStore, *MockSender) {},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store := new(MockNotifierStore)
+			sender := new(MockSender)
+
+			store.On("GetNotificationRecipient", ctx, int64(1)).Return(
+				&Recipient{
+					Email:      "creator@fewsats.com",
+					Preference: tc.preference,
+				}, nil,
+			)
+			tc.setupMocks(store, sender)
+
+			queue := newMemoryQueue()
+			notifier := NewNotifier(sender, store, queue, DefaultConfig(),
+				clock, slog.Default())
+			notifier.RegisterJobs()
+
+			// The event is delivered by a job, not while registering it.
+			err := notifier.RegisterNewPurchaseEvent(ctx, &orders.Purchase{
+				UserID:       1,
+				ExternalID:   "externalID",
+				ServiceType:  "videos",
+				PaymentHash:  "paymentHash",
+				PriceInCents: 550,
+				Currency:     "USD",
+			})
+			require.NoError(t, err)
+			require.Len(t, queue.jobs, 1)
+			store.AssertNotCalled(t, "GetNotificationRecipient", ctx,
+				int64(1))
+			sender.AssertNotCalled(t, "SendEmail", mock.Anything,
+				mock.Anything, mock.Anything)
+
+			queue.runAll(t, ctx)
+
+			store.AssertExpectations(t)
+			sender.AssertExpectations(t)
+		})
+	}
+}
+
+// TestSendDigests tests that the recurring digest job emails every user once
+// with their pending events, which are then removed.
+func TestSendDigests(t *testing.T) {
+	ctx := context.Background()
+	store := new(MockNotifierStore)
+	sender := new(MockSender)
+
+	store.On("ListPendingDigests", ctx).Return([]*Digest{
+		{
+			UserID:    1,
+			Recipient: Recipient{"one@fewsats.com", NotificationDaily},
+			Events: []*Event{
+				{Type: EventPurchase, ExternalID: "first"},
+				{Type: EventVideoUpload, ExternalID: "second"},
+			},
+			LastID: 7,
+		},
+		{
+			UserID:    2,
+			Recipient: Recipient{"two@fewsats.com", NotificationOff},
+			Events:    []*Event{{Type: EventPurchase}},
+			LastID:    8,
+		},
+	}, nil)
+	sender.On("SendEmail", "one@fewsats.com", "Your Blockbuster daily digest",
+		mock.MatchedBy(func(html string) bool {
+			return len(html) > 0
+		})).Return(nil).Once()
+	store.On("DeletePendingNotifications", ctx, int64(1), int64(7)).Return(nil)
+	store.On("DeletePendingNotifications", ctx, int64(2), int64(8)).Return(nil)
+
+	queue := newMemoryQueue()
+	notifier := NewNotifier(sender, store, queue, DefaultConfig(),
+		utils.NewMockClock(), slog.Default())
+	notifier.RegisterJobs()
+	require.Equal(t, 24*time.Hour, queue.intervals[jobSendDigests])
+
+	_, err := queue.Enqueue(ctx, jobSendDigests, nil)
+	require.NoError(t, err)
+	queue.runAll(t, ctx)
+
+	store.AssertExpectations(t)
+	sender.AssertExpectations(t)
+}
+
+// TestEventLines tests that uploads and imports are described differently.
+func TestEventLines(t *testing.T) {
+	notifier := NewNotifier(nil, nil, nil, DefaultConfig(),
+		utils.NewMockClock(), slog.Default())
+
+	upload := notifier.eventLine(&Event{
+		Type:       EventVideoUpload,
+		ExternalID: "externalID",
+	})
+	require.Contains(t, upload, "waiting for its upload")
+
+	imported := notifier.eventLine(&Event{
+		Type:       EventVideoImport,
+		ExternalID: "externalID",
+	})
+	require.Contains(t, imported, "being imported")
+	require.NotContains(t, imported, "upload")
+}
@@ -36,3 +36,16 @@ func (s *ResendService) SendMagicLink(to, token string) error {
 	_, err := s.client.Emails.Send(params)
 	return err
 }
+
+// SendEmail sends an email with the given subject and HTML body.
+func (s *ResendService) SendEmail(to, subject, html string) error {
+	params := &resend.SendEmailRequest{
+		From:    "Blockbuster <blockbuster@fewsats.com>",
+		To:      []string{to},
+		Subject: subject,
+		Html:    html,
+	}
+
+	_, err := s.client.Emails.Send(params)
+	return err
+}
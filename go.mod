@@ -6,6 +6,7 @@ require (
 	github.com/aws/aws-sdk-go v1.49.6
 	github.com/btcsuite/btcd/btcec/v2 v2.3.4
 	github.com/cloudflare/cloudflare-go v0.104.0
+	github.com/gin-contrib/cors v1.7.2
 	github.com/gin-contrib/sessions v1.0.1
 	github.com/gin-gonic/gin v1.10.0
 	github.com/golang-migrate/migrate/v4 v4.18.1
@@ -13,6 +14,7 @@ require (
 	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/resendlabs/resend-go v1.7.0
 	github.com/stretchr/testify v1.9.0
+	golang.org/x/image v0.18.0
 	gopkg.in/macaroon.v2 v2.1.0
 )
 
@@ -25,7 +27,6 @@ require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/decred/dcrd/crypto/blake256 v1.0.0 // indirect
 	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.0.1 // indirect
-	github.com/gin-contrib/cors v1.7.2 // indirect
 	github.com/google/go-querystring v1.1.0 // indirect
 	github.com/gorilla/context v1.1.2 // indirect
 	github.com/gorilla/securecookie v1.1.2 // indirect
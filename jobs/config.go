@@ -0,0 +1,24 @@
+package jobs
+
+import "time"
+
+// DefaultConfig returns all default values for the Config struct.
+func DefaultConfig() *Config {
+	return &Config{
+		Workers:      4,
+		PollInterval: time.Second,
+		LeaseTimeout: 5 * time.Minute,
+		MaxAttempts:  5,
+		RetryBackoff: 30 * time.Second,
+		MaxBackoff:   time.Hour,
+	}
+}
+
+type Config struct {
+	Workers      int           `long:"workers" description:"Number of concurrent workers, 0 disables the queue"`
+	PollInterval time.Duration `long:"poll_interval" description:"Time an idle worker waits before checking for jobs again"`
+	LeaseTimeout time.Duration `long:"lease_timeout" description:"Time a worker has to run a job before it is given to another worker"`
+	MaxAttempts  int64         `long:"max_attempts" description:"Attempts before a job is moved to the dead-letter state"`
+	RetryBackoff time.Duration `long:"retry_backoff" description:"Delay before the first retry, doubled on every attempt"`
+	MaxBackoff   time.Duration `long:"max_backoff" description:"Maximum delay between retries"`
+}
@@ -0,0 +1,71 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Job is a unit of background work stored in the queue.
+type Job struct {
+	ID int64
+
+	// Kind selects the handler that runs the job.
+	Kind string
+
+	// Payload is the JSON encoded input of the handler.
+	Payload string
+
+	// Status is one of the Status values.
+	Status string
+
+	// Attempts is the number of times the job has been leased. It also
+	// identifies the current lease.
+	Attempts    int64
+	MaxAttempts int64
+
+	// RunAt is when the job is due or, while it is running, when its lease
+	// expires.
+	RunAt time.Time
+
+	// LastError is the error of the last failed attempt.
+	LastError string
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Decode unmarshals the payload of the job into v.
+func (j *Job) Decode(v any) error {
+	return json.Unmarshal([]byte(j.Payload), v)
+}
+
+// Store is the interface for persisting the queue.
+type Store interface {
+	// InsertJob stores a new pending job and sets its ID.
+	InsertJob(ctx context.Context, job *Job) error
+
+	// InsertUniqueJob stores a new pending job and sets its ID, unless
+	// there is already a pending job of its kind. It returns whether the
+	// job was stored.
+	InsertUniqueJob(ctx context.Context, job *Job) (bool, error)
+
+	// LeaseJob marks the next due job as running until lockedUntil and
+	// increments its attempts. It returns ErrNoJobs if no job is due.
+	LeaseJob(ctx context.Context, now, lockedUntil time.Time) (*Job, error)
+
+	// CompleteJob removes a job that succeeded. It returns ErrLeaseLost if
+	// the lease of the job expired and it was given to another worker.
+	CompleteJob(ctx context.Context, job *Job) error
+
+	// ExtendJobLease moves the end of the lease of a running job to
+	// lockedUntil. It returns ErrLeaseLost if the lease of the job expired
+	// and it was given to another worker.
+	ExtendJobLease(ctx context.Context, job *Job,
+		lockedUntil time.Time) error
+
+	// ReleaseJob stores the status, run at and last error of a failed job.
+	// It returns ErrLeaseLost if the lease of the job expired and it was
+	// given to another worker.
+	ReleaseJob(ctx context.Context, job *Job) error
+}
@@ -0,0 +1,445 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/fewsats/blockbuster/utils"
+)
+
+const (
+	// StatusPending is the status of a job waiting to be run.
+	StatusPending = "pending"
+
+	// StatusRunning is the status of a job leased by a worker.
+	StatusRunning = "running"
+
+	// StatusDead is the status of a job that ran out of attempts. Dead jobs
+	// are kept in the store for inspection and are not run again.
+	StatusDead = "dead"
+)
+
+var (
+	// ErrNoJobs is returned when no job is due.
+	ErrNoJobs = errors.New("no jobs due")
+
+	// ErrLeaseLost is returned when a worker reports the result of a job
+	// whose lease expired.
+	ErrLeaseLost = errors.New("job lease lost")
+
+	// ErrUnknownKind is returned when enqueuing or running a job without
+	// a registered handler.
+	ErrUnknownKind = errors.New("unknown job kind")
+)
+
+// metrics holds the counters of the processed jobs, exposed through expvar.
+var metrics = expvar.NewMap("jobs")
+
+const (
+	metricSucceeded = "succeeded"
+	metricRetried   = "retried"
+	metricDead      = "dead"
+)
+
+// Handler runs a job. Returning an error retries the job with backoff until
+// it runs out of attempts. The context is cancelled when the attempt times
+// out or the lease of the job is lost.
+type Handler func(ctx context.Context, job *Job) error
+
+// Options customize how the jobs of a kind are run.
+type Options struct {
+	// Timeout limits every attempt, the lease timeout if zero. The lease is
+	// extended while the job runs, so the timeout can be longer.
+	Timeout time.Duration
+
+	// OnDead is called, if set, once a job runs out of attempts with the
+	// error of its last attempt, e.g. to mark what it was working on as
+	// failed.
+	OnDead func(ctx context.Context, job *Job, err error)
+}
+
+// registration is the handler of the jobs of a kind and its options.
+type registration struct {
+	handler Handler
+	opts    Options
+
+	// interval is the time between the runs of a recurring kind, zero for
+	// the rest.
+	interval time.Duration
+}
+
+// Queue is a durable job queue processed by a pool of workers. Jobs are
+// leased for a visibility timeout, extended while they run, so the jobs of a
+// crashed worker are run again once their lease expires.
+type Queue struct {
+	store Store
+
+	mu    sync.RWMutex
+	kinds map[string]*registration
+
+	cfg    *Config
+	clock  utils.Clock
+	logger *slog.Logger
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewQueue creates a new job queue.
+func NewQueue(store Store, cfg *Config, clock utils.Clock,
+	logger *slog.Logger) *Queue {
+
+	return &Queue{
+		store:  store,
+		kinds:  make(map[string]*registration),
+		cfg:    cfg,
+		clock:  clock,
+		logger: logger,
+		quit:   make(chan struct{}),
+	}
+}
+
+// Register sets the handler of the jobs of the given kind.
+func (q *Queue) Register(kind string, handler Handler) {
+	q.RegisterWithOptions(kind, handler, Options{})
+}
+
+// RegisterWithOptions sets the handler of the jobs of the given kind and how
+// they are run.
+func (q *Queue) RegisterWithOptions(kind string, handler Handler,
+	opts Options) {
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.kinds[kind] = &registration{handler: handler, opts: opts}
+}
+
+// RegisterRecurring sets a job of the given kind that runs every interval.
+// There is at most one pending run: each run schedules the next one before
+// it starts and ScheduleRecurring, called by Start, schedules the first one
+// if there is none, so the schedule survives restarts. Failed runs are not
+// retried, the next run takes over.
+func (q *Queue) RegisterRecurring(kind string, interval time.Duration,
+	run func(ctx context.Context) error) {
+
+	handler := func(ctx context.Context, job *Job) error {
+		err := q.schedule(ctx, kind, q.clock.Now().Add(interval))
+		if err != nil {
+			return err
+		}
+
+		if err := run(ctx); err != nil {
+			q.logger.Error("Recurring job failed",
+				"jobID", job.ID, "kind", kind, "error", err)
+		}
+
+		return nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.kinds[kind] = &registration{handler: handler, interval: interval}
+}
+
+// Enqueue stores a job of the given kind to be run as soon as possible.
+func (q *Queue) Enqueue(ctx context.Context, kind string,
+	payload any) (*Job, error) {
+
+	return q.EnqueueAt(ctx, kind, payload, q.clock.Now())
+}
+
+// EnqueueAt stores a job of the given kind to be run at the given time.
+func (q *Queue) EnqueueAt(ctx context.Context, kind string, payload any,
+	runAt time.Time) (*Job, error) {
+
+	job, err := q.newJob(kind, payload, runAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := q.store.InsertJob(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to enqueue %s job: %w", kind, err)
+	}
+
+	return job, nil
+}
+
+// schedule stores the next run of a recurring kind at the given time unless
+// one is already pending.
+func (q *Queue) schedule(ctx context.Context, kind string,
+	runAt time.Time) error {
+
+	job, err := q.newJob(kind, struct{}{}, runAt)
+	if err != nil {
+		return err
+	}
+
+	if _, err := q.store.InsertUniqueJob(ctx, job); err != nil {
+		return fmt.Errorf("failed to schedule %s job: %w", kind, err)
+	}
+
+	return nil
+}
+
+// newJob returns a pending job of a registered kind with the encoded
+// payload.
+func (q *Queue) newJob(kind string, payload any, runAt time.Time) (*Job,
+	error) {
+
+	if q.registration(kind) == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKind, kind)
+	}
+
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	return &Job{
+		Kind:        kind,
+		Payload:     string(encoded),
+		Status:      StatusPending,
+		MaxAttempts: q.cfg.MaxAttempts,
+		RunAt:       runAt,
+	}, nil
+}
+
+// Start schedules the recurring kinds and runs the configured number of
+// workers until Stop is called.
+func (q *Queue) Start() {
+	if q.cfg.Workers <= 0 {
+		q.logger.Info("Job queue disabled")
+		return
+	}
+
+	if err := q.ScheduleRecurring(context.Background()); err != nil {
+		q.logger.Error("Failed to schedule recurring jobs", "error", err)
+	}
+
+	for i := 0; i < q.cfg.Workers; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			q.work()
+		}()
+	}
+}
+
+// ScheduleRecurring schedules a run of every recurring kind that has none
+// pending, due now.
+func (q *Queue) ScheduleRecurring(ctx context.Context) error {
+	q.mu.RLock()
+	var recurring []string
+	for kind, reg := range q.kinds {
+		if reg.interval > 0 {
+			recurring = append(recurring, kind)
+		}
+	}
+	q.mu.RUnlock()
+
+	var errs []error
+	for _, kind := range recurring {
+		err := q.schedule(ctx, kind, q.clock.Now())
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Stop stops the workers and waits for the jobs they are running.
+func (q *Queue) Stop() {
+	close(q.quit)
+	q.wg.Wait()
+}
+
+// work runs the due jobs one at a time, waiting a poll interval whenever
+// there are none.
+func (q *Queue) work() {
+	for {
+		select {
+		case <-q.quit:
+			return
+		default:
+		}
+
+		ran, err := q.RunNext(context.Background())
+		if err != nil {
+			q.logger.Error("Failed to run job", "error", err)
+		}
+		if ran && err == nil {
+			continue
+		}
+
+		select {
+		case <-time.After(q.cfg.PollInterval):
+		case <-q.quit:
+			return
+		}
+	}
+}
+
+// RunNext leases the next due job and runs it. It returns false if no job
+// was due.
+func (q *Queue) RunNext(ctx context.Context) (bool, error) {
+	now := q.clock.Now()
+	job, err := q.store.LeaseJob(ctx, now, now.Add(q.cfg.LeaseTimeout))
+	switch {
+	case errors.Is(err, ErrNoJobs):
+		return false, nil
+
+	case err != nil:
+		return false, fmt.Errorf("failed to lease job: %w", err)
+	}
+
+	// The lease of the last attempt expired before the job finished, so
+	// there are no attempts left to run it again.
+	if job.Attempts > job.MaxAttempts {
+		return true, q.release(ctx, job, errors.New("lease expired"))
+	}
+
+	if err := q.run(ctx, job); err != nil {
+		return true, q.release(ctx, job, err)
+	}
+
+	err = q.store.CompleteJob(ctx, job)
+	if err != nil {
+		return true, fmt.Errorf("failed to complete job(%d): %w", job.ID,
+			err)
+	}
+
+	metrics.Add(metricSucceeded, 1)
+
+	return true, nil
+}
+
+// run calls the handler of the job, extending its lease while it runs and
+// turning panics into errors.
+func (q *Queue) run(ctx context.Context, job *Job) (err error) {
+	reg := q.registration(job.Kind)
+	if reg == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownKind, job.Kind)
+	}
+
+	timeout := reg.opts.Timeout
+	if timeout == 0 {
+		timeout = q.cfg.LeaseTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stop := q.heartbeat(ctx, cancel, job)
+	defer stop()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	return reg.handler(ctx, job)
+}
+
+// heartbeat extends the lease of the job every third of the lease timeout
+// until the returned function is called. The job is cancelled if its lease
+// is lost, as another worker may be running it already.
+func (q *Queue) heartbeat(ctx context.Context, cancel context.CancelFunc,
+	job *Job) func() {
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(q.cfg.LeaseTimeout / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+
+			lockedUntil := q.clock.Now().Add(q.cfg.LeaseTimeout)
+			err := q.store.ExtendJobLease(ctx, job, lockedUntil)
+			switch {
+			case errors.Is(err, ErrLeaseLost):
+				q.logger.Warn("Job lease lost", "jobID", job.ID,
+					"kind", job.Kind)
+				cancel()
+
+				return
+
+			case err != nil:
+				q.logger.Error("Failed to extend job lease",
+					"jobID", job.ID, "kind", job.Kind, "error", err)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// release schedules a failed job for a retry with backoff or moves it to
+// the dead-letter state once it runs out of attempts.
+func (q *Queue) release(ctx context.Context, job *Job, jobErr error) error {
+	now := q.clock.Now()
+
+	job.LastError = utils.LastError(jobErr)
+
+	if job.Attempts >= job.MaxAttempts {
+		job.Status = StatusDead
+		job.RunAt = now
+		metrics.Add(metricDead, 1)
+	} else {
+		job.Status = StatusPending
+		job.RunAt = now.Add(utils.Backoff(job.Attempts,
+			q.cfg.RetryBackoff, q.cfg.MaxBackoff))
+		metrics.Add(metricRetried, 1)
+	}
+
+	q.logger.Warn("Job failed",
+		"jobID", job.ID,
+		"kind", job.Kind,
+		"attempts", job.Attempts,
+		"status", job.Status,
+		"error", jobErr)
+
+	if err := q.store.ReleaseJob(ctx, job); err != nil {
+		return fmt.Errorf("failed to release job(%d): %w", job.ID, err)
+	}
+
+	reg := q.registration(job.Kind)
+	if job.Status == StatusDead && reg != nil && reg.opts.OnDead != nil {
+		reg.opts.OnDead(ctx, job, jobErr)
+	}
+
+	return nil
+}
+
+// registration returns the handler and options of the given kind, or nil if
+// there is none.
+func (q *Queue) registration(kind string) *registration {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	return q.kinds[kind]
+}
@@ -11,6 +11,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"strings"
 	"time"
 
@@ -34,8 +35,39 @@ var (
 
 	// ErrInvalidPreimage is returned when the preimage is invalid.
 	ErrInvalidPreimage = errors.New("invalid preimage")
+
+	// ErrInvalidCaveat is returned when the macaroon contains a caveat that
+	// is not satisfied by the current request, e.g. it already expired.
+	ErrInvalidCaveat = errors.New("invalid caveat")
+
+	// ErrCaveatCheckFailed is wrapped by the caveat checkers that could not
+	// tell whether the caveat is satisfied, e.g. because the database is
+	// unavailable. Those errors are returned as they are instead of
+	// invalidating the credentials.
+	ErrCaveatCheckFailed = errors.New("unable to check caveat")
+
+	// ErrInvoiceNotPaid is returned when credentials are requested for an
+	// invoice that has not been paid yet.
+	ErrInvoiceNotPaid = errors.New("invoice not paid")
+
+	// ErrRevokedCredentials is returned when the credentials were disabled,
+	// e.g. because the purchase was refunded.
+	ErrRevokedCredentials = errors.New("revoked credentials")
 )
 
+const (
+	// CaveatExpiresAt is the caveat holding the RFC3339 timestamp after
+	// which the credentials are no longer valid.
+	CaveatExpiresAt = "expires_at"
+
+	// maxClockSkew is how far in the future the timestamp of a signed
+	// request can be.
+	maxClockSkew = time.Minute
+)
+
+// CaveatChecker validates the value of a caveat against the current request.
+type CaveatChecker func(value string) error
+
 // Authenticator is an authenticator that uses L402 tokens.
 type Authenticator struct {
 	provider InvoiceProvider
@@ -70,7 +102,7 @@ func (l *Authenticator) mintMacaroon(location string, pubKey, rootKey []byte,
 	}
 
 	for key, value := range caveats {
-		if key == "expires_at" {
+		if key == CaveatExpiresAt {
 			// Validate the expires_at format
 			_, err := time.Parse(time.RFC3339, value)
 			if err != nil {
@@ -124,19 +156,9 @@ func (l *Authenticator) NewChallenge(ctx context.Context, productName string,
 		return nil, fmt.Errorf("unable to decode payment hash: %v", err)
 	}
 
-	var identifier bytes.Buffer
-	if err := binary.Write(&identifier, byteOrder, uint16(0)); err != nil {
-		return nil, fmt.Errorf("unable to write version: %v", err)
-	}
-
-	err = binary.Write(&identifier, byteOrder, paymentHash[:])
-	if err != nil {
-		return nil, fmt.Errorf("unable to write payment hash: %v", err)
-	}
-
-	err = binary.Write(&identifier, byteOrder, pubKey[:])
+	identifier, err := newIdentifier(paymentHash, pubKey[:])
 	if err != nil {
-		return nil, fmt.Errorf("unable to write token ID: %v", err)
+		return nil, err
 	}
 
 	location := "fewsats.com"
@@ -162,17 +184,160 @@ func (l *Authenticator) NewChallenge(ctx context.Context, productName string,
 	return NewChallenge(mac, lnInvoice), nil
 }
 
+// newIdentifier returns the v0 macaroon identifier binding the payment hash
+// to the public key of the user.
+func newIdentifier(paymentHash, pubKey []byte) (*bytes.Buffer, error) {
+	var identifier bytes.Buffer
+	if err := binary.Write(&identifier, byteOrder, uint16(0)); err != nil {
+		return nil, fmt.Errorf("unable to write version: %v", err)
+	}
+
+	err := binary.Write(&identifier, byteOrder, paymentHash[:])
+	if err != nil {
+		return nil, fmt.Errorf("unable to write payment hash: %v", err)
+	}
+
+	err = binary.Write(&identifier, byteOrder, pubKey[:])
+	if err != nil {
+		return nil, fmt.Errorf("unable to write token ID: %v", err)
+	}
+
+	return &identifier, nil
+}
+
+// PaidCredentials returns the base64 encoded macaroon and the preimage of the
+// credentials minted for the given payment hash and public key, as long as
+// their invoice has been paid. Otherwise ErrInvoiceNotPaid is returned.
+func (l *Authenticator) PaidCredentials(ctx context.Context, paymentHashHex,
+	pubKeyHex string) (string, string, error) {
+
+	paymentHash, err := hex.DecodeString(paymentHashHex)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to decode payment hash: %v", err)
+	}
+
+	pubKeyBytes, err := hex.DecodeString(pubKeyHex)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to decode pubKeyHex: %v", err)
+	}
+
+	var pubKey [32]byte
+	copy(pubKey[:], pubKeyBytes)
+
+	identifier, err := newIdentifier(paymentHash, pubKey[:])
+	if err != nil {
+		return "", "", err
+	}
+
+	encodedMacaroon, err := l.store.GetEncodedMacaroon(ctx,
+		hex.EncodeToString(identifier.Bytes()))
+	if err != nil {
+		return "", "", fmt.Errorf("unable to retrieve macaroon: %w", err)
+	}
+
+	preimage, err := l.provider.GetInvoicePreimage(ctx, paymentHashHex)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to check invoice: %w", err)
+	}
+
+	if preimage == "" {
+		return "", "", ErrInvoiceNotPaid
+	}
+
+	return encodedMacaroon, preimage, nil
+}
+
+// RestoreCredentials returns the paid credentials minted for the given public
+// key that have not expired yet, so their owner can use them again after
+// losing them.
+func (l *Authenticator) RestoreCredentials(ctx context.Context,
+	pubKeyHex string) ([]*StoredCredentials, error) {
+
+	stored, err := l.store.ListPaidCredentials(ctx, pubKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list credentials: %w", err)
+	}
+
+	credentials := make([]*StoredCredentials, 0, len(stored))
+	for _, creds := range stored {
+		expiresAt, err := macaroonExpiration(creds.Macaroon)
+		if err != nil {
+			l.logger.Error("unable to check stored macaroon",
+				"payment_hash", creds.PaymentHash,
+				"error", err,
+			)
+			continue
+		}
+
+		if expiresAt != nil && !l.clock.Now().Before(*expiresAt) {
+			continue
+		}
+
+		creds.ExpiresAt = expiresAt
+		credentials = append(credentials, creds)
+	}
+
+	return credentials, nil
+}
+
+// macaroonExpiration returns the expiration in the expires_at caveat of the
+// given base64 encoded macaroon, nil if it has none.
+func macaroonExpiration(macBase64 string) (*time.Time, error) {
+	macBytes, err := base64.RawURLEncoding.DecodeString(macBase64)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode macaroon: %v", err)
+	}
+
+	mac := &macaroon.Macaroon{}
+	if err := mac.UnmarshalBinary(macBytes); err != nil {
+		return nil, fmt.Errorf("invalid macaroon: %v", err)
+	}
+
+	for _, caveat := range mac.Caveats() {
+		key, value, err := ParseCaveat(string(caveat.Id))
+		if err != nil || key != CaveatExpiresAt {
+			continue
+		}
+
+		expiresAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expires_at format: %v", err)
+		}
+
+		return &expiresAt, nil
+	}
+
+	return nil, nil
+}
+
+// ValidatePubKey checks that the given hex string is a valid x-only public
+// key, as used by Nostr.
+func ValidatePubKey(pubKeyHex string) error {
+	pubKeyBytes, err := hex.DecodeString(pubKeyHex)
+	if err != nil {
+		return fmt.Errorf("invalid public key hex: %w", err)
+	}
+
+	_, err = schnorr.ParsePubKey(pubKeyBytes)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+
+	return nil
+}
+
 // ValidateL402Credentials validates the L402 credentials in the Authorization
-// header.
+// header. Every caveat other than expires_at needs a checker in the given map
+// for the credentials to be valid, the one of expires_at is optional.
 func (l *Authenticator) ValidateL402Credentials(ctx context.Context,
-	authHeader string) (string, error) {
+	authHeader string, checkers map[string]CaveatChecker) (string, error) {
 
 	creds, err := l.ExtractCredentials(authHeader)
 	if err != nil {
 		return "", fmt.Errorf("unable to extract credentials: %w", err)
 	}
 
-	err = l.ValidateCredentials(ctx, creds)
+	err = l.ValidateCredentials(ctx, creds, checkers)
 	if err != nil {
 		return "", fmt.Errorf("unable to validate credentials: %w", err)
 	}
@@ -214,12 +379,10 @@ func (l *Authenticator) ExtractCredentials(authHeader string) (*Credentials,
 	return DecodeL402Credentials(macBase64, preimageHex)
 }
 
-// ValidateL402Credentials validates the L402 credentials in the Authorization
-// header.
-//
-// TODO(positiveblue): add req context to check the caveats.
+// ValidateCredentials validates the L402 credentials and checks their caveats
+// with the given checkers.
 func (l *Authenticator) ValidateCredentials(ctx context.Context,
-	creds *Credentials) error {
+	creds *Credentials, checkers map[string]CaveatChecker) error {
 
 	err := creds.ValidatePreimage()
 	if err != nil {
@@ -229,29 +392,72 @@ func (l *Authenticator) ValidateCredentials(ctx context.Context,
 	// Retrieve the root key for the token ID.
 	rootKey, err := l.store.GetRootKey(ctx, creds.Identifier)
 	if err != nil {
-		return fmt.Errorf("unable to retrieve root key: %v", err)
+		return fmt.Errorf("unable to retrieve root key: %w", err)
 	}
 
-	err = creds.VerifyMacaroon(rootKey)
+	err = creds.VerifyMacaroon(rootKey, func(caveat string) error {
+		return l.checkCaveat(caveat, checkers)
+	})
 	if err != nil {
-		return fmt.Errorf("unable to verify macaroon: %v", err)
+		return fmt.Errorf("unable to verify macaroon: %w", err)
 	}
 
 	return nil
 }
 
-func (l *Authenticator) ValidateSignature(pubKeyHex, signatureHex,
-	domain string, timestamp int64) error {
+// checkCaveat checks a single first party caveat. The expiration is always
+// enforced and then passed to its checker, if any. The rest of caveats are
+// delegated to the given checkers.
+func (l *Authenticator) checkCaveat(caveat string,
+	checkers map[string]CaveatChecker) error {
 
-	// Check if the timestamp is within 10 minutes of the current time
-	if time.Since(time.Unix(timestamp, 0)) > 10*time.Minute {
-		return fmt.Errorf("timestamp is too old")
+	key, value, err := ParseCaveat(caveat)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCaveat, err)
 	}
 
-	// TODO(pol) set up domain properly instead of hardcoding
-	// Check if the domain is valid
-	if domain != l.cfg.Domain {
-		return fmt.Errorf("invalid domain")
+	if key == CaveatExpiresAt {
+		expiresAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fmt.Errorf("%w: invalid expires_at format: %v",
+				ErrInvalidCaveat, err)
+		}
+
+		if !l.clock.Now().Before(expiresAt) {
+			return fmt.Errorf("%w: credentials expired at %s",
+				ErrInvalidCaveat, value)
+		}
+
+		if checker, ok := checkers[CaveatExpiresAt]; ok {
+			return checker(value)
+		}
+
+		return nil
+	}
+
+	checker, ok := checkers[key]
+	if !ok {
+		return fmt.Errorf("%w: unsupported caveat %s", ErrInvalidCaveat,
+			key)
+	}
+
+	err = checker(value)
+	switch {
+	case errors.Is(err, ErrCaveatCheckFailed):
+		return err
+
+	case err != nil:
+		return fmt.Errorf("%w: %s: %v", ErrInvalidCaveat, key, err)
+	}
+
+	return nil
+}
+
+func (l *Authenticator) ValidateSignature(pubKeyHex, signatureHex,
+	domain string, timestamp int64) error {
+
+	if err := l.validateSignedRequest(domain, timestamp); err != nil {
+		return err
 	}
 
 	// Create the message to be signed
@@ -266,6 +472,62 @@ func (l *Authenticator) ValidateSignature(pubKeyHex, signatureHex,
 	return nil
 }
 
+// ValidateActionSignature validates a signature of the message returned by
+// ActionMessage, which binds it to a single action and its parameters so it
+// can't be replayed for a different request.
+func (l *Authenticator) ValidateActionSignature(pubKeyHex, signatureHex,
+	domain string, timestamp int64, action string, params ...string) error {
+
+	if err := l.validateSignedRequest(domain, timestamp); err != nil {
+		return err
+	}
+
+	message := ActionMessage(domain, timestamp, action, params...)
+	err := verifySignature(pubKeyHex, signatureHex, message)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+
+	return nil
+}
+
+// ActionMessage returns the message signed for an action:
+// "<domain>:<action>:<params...>:<timestamp>".
+func ActionMessage(domain string, timestamp int64, action string,
+	params ...string) string {
+
+	parts := append([]string{domain, action}, params...)
+	parts = append(parts, strconv.FormatInt(timestamp, 10))
+
+	return strings.Join(parts, ":")
+}
+
+// validateSignedRequest checks the domain and the timestamp of a signed
+// request, which must be within the last 10 minutes.
+func (l *Authenticator) validateSignedRequest(domain string,
+	timestamp int64) error {
+
+	// Check if the timestamp is within 10 minutes of the current time
+	signedAt := time.Unix(timestamp, 0)
+	if time.Since(signedAt) > 10*time.Minute {
+		return fmt.Errorf("timestamp is too old")
+	}
+
+	// Allow for some clock skew, but no more, as a timestamp in the future
+	// would extend the validity of the signature.
+	if time.Until(signedAt) > maxClockSkew {
+		return fmt.Errorf("timestamp is in the future")
+	}
+
+	// TODO(pol) set up domain properly instead of hardcoding
+	// Check if the domain is valid
+	if domain != l.cfg.Domain {
+		return fmt.Errorf("invalid domain")
+	}
+
+	return nil
+}
+
 func verifySignature(pubKeyHex, signatureHex, message string) error {
 	pubKeyBytes, err := hex.DecodeString(pubKeyHex)
 	if err != nil {
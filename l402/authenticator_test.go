@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"log/slog"
@@ -57,6 +58,20 @@ func (m *MockStore) GetRootKey(ctx context.Context,
 	return args.Get(0).(string), args.Error(1)
 }
 
+func (m *MockStore) GetEncodedMacaroon(ctx context.Context,
+	identifier string) (string, error) {
+
+	args := m.Called(ctx, identifier)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockStore) ListPaidCredentials(ctx context.Context,
+	pubKeyHex string) ([]*StoredCredentials, error) {
+
+	args := m.Called(ctx, pubKeyHex)
+	return args.Get(0).([]*StoredCredentials), args.Error(1)
+}
+
 func (m *MockStore) StoreInvoice(ctx context.Context,
 	userID uint64, invoice *lightning.LNInvoice) error {
 
@@ -240,6 +255,14 @@ func TestValidateSignature(t *testing.T) {
 			timestamp:     time.Now().Add(-11 * time.Minute).Unix(),
 			expectedError: "timestamp is too old",
 		},
+		{
+			name:          "future timestamp",
+			pubKeyHex:     pubKeyHex,
+			signatureHex:  signatureHex,
+			domain:        "localhost:8080",
+			timestamp:     time.Now().Add(11 * time.Minute).Unix(),
+			expectedError: "timestamp is in the future",
+		},
 		{
 			name:          "invalid signature",
 			pubKeyHex:     pubKeyHex,
@@ -264,3 +287,207 @@ func TestValidateSignature(t *testing.T) {
 		})
 	}
 }
+
+// TestValidateActionSignature tests that action signatures are only valid for
+// the action and parameters they were made for.
+func TestValidateActionSignature(t *testing.T) {
+	domain := "localhost:8080"
+	timestamp := time.Now().Unix()
+	authenticator := &Authenticator{cfg: DefaultConfig()}
+
+	message := ActionMessage(domain, timestamp, "refund", "hash", "invoice")
+	require.Equal(t, fmt.Sprintf("localhost:8080:refund:hash:invoice:%d",
+		timestamp), message)
+	pubKeyHex, signatureHex := generateKeysAndSignature(message)
+
+	err := authenticator.ValidateActionSignature(pubKeyHex, signatureHex,
+		domain, timestamp, "refund", "hash", "invoice")
+	require.NoError(t, err)
+
+	// The signature can't be used for other parameters or actions.
+	err = authenticator.ValidateActionSignature(pubKeyHex, signatureHex,
+		domain, timestamp, "refund", "hash", "other")
+	require.ErrorContains(t, err, "signature verification failed")
+
+	err = authenticator.ValidateActionSignature(pubKeyHex, signatureHex,
+		domain, timestamp, "library")
+	require.ErrorContains(t, err, "signature verification failed")
+
+	// The timestamp is checked as in the other signed requests.
+	future := time.Now().Add(time.Hour).Unix()
+	message = ActionMessage(domain, future, "library")
+	pubKeyHex, signatureHex = generateKeysAndSignature(message)
+	err = authenticator.ValidateActionSignature(pubKeyHex, signatureHex,
+		domain, future, "library")
+	require.ErrorContains(t, err, "timestamp is in the future")
+}
+
+func TestCheckCaveat(t *testing.T) {
+	clock := utils.NewMockClock()
+	clock.SetMockClockTime(time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC))
+	authenticator := &Authenticator{cfg: DefaultConfig(), clock: clock}
+
+	checkers := map[string]CaveatChecker{
+		"external_id": func(value string) error {
+			if value != "video" {
+				return fmt.Errorf("invalid video")
+			}
+			return nil
+		},
+		"bundle_id": func(value string) error {
+			return fmt.Errorf("%w: database is down",
+				ErrCaveatCheckFailed)
+		},
+	}
+
+	testCases := []struct {
+		name        string
+		caveat      string
+		expectedErr error
+	}{
+		{
+			name:   "valid expiration",
+			caveat: "expires_at=2024-09-02T00:00:00Z",
+		},
+		{
+			name:        "expired",
+			caveat:      "expires_at=2024-08-31T00:00:00Z",
+			expectedErr: ErrInvalidCaveat,
+		},
+		{
+			name:        "invalid expiration",
+			caveat:      "expires_at=tomorrow",
+			expectedErr: ErrInvalidCaveat,
+		},
+		{
+			name:   "valid checker",
+			caveat: "external_id=video",
+		},
+		{
+			name:        "failed checker",
+			caveat:      "external_id=other",
+			expectedErr: ErrInvalidCaveat,
+		},
+		{
+			name:        "checker unable to check",
+			caveat:      "bundle_id=bundle",
+			expectedErr: ErrCaveatCheckFailed,
+		},
+		{
+			name:        "unsupported caveat",
+			caveat:      "creator_id=1",
+			expectedErr: ErrInvalidCaveat,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := authenticator.checkCaveat(tc.caveat, checkers)
+			if tc.expectedErr != nil {
+				require.ErrorIs(t, err, tc.expectedErr)
+
+				// Failed checks don't invalidate the credentials.
+				if tc.expectedErr == ErrCaveatCheckFailed {
+					require.NotErrorIs(t, err,
+						ErrInvalidCaveat)
+				}
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+
+	// The expiration is passed to its checker once enforced.
+	var expiresAt string
+	checkers[CaveatExpiresAt] = func(value string) error {
+		expiresAt = value
+		return nil
+	}
+
+	err := authenticator.checkCaveat("expires_at=2024-08-31T00:00:00Z",
+		checkers)
+	require.ErrorIs(t, err, ErrInvalidCaveat)
+	require.Empty(t, expiresAt)
+
+	err = authenticator.checkCaveat("expires_at=2024-09-02T00:00:00Z",
+		checkers)
+	require.NoError(t, err)
+	require.Equal(t, "2024-09-02T00:00:00Z", expiresAt)
+}
+
+// TestPaidCredentials tests that the credentials bound to a public key are
+// only returned once their invoice is paid.
+func TestPaidCredentials(t *testing.T) {
+	ctx := context.Background()
+	paymentHash := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	pubKeyHex := "384b61fb5cc7fae5cb849ebd69a66c4f8535fa95cba90346a0204c034301bb3d"
+	identifier := "0000" + paymentHash + pubKeyHex
+
+	mockProvider := new(MockInvoiceProvider)
+	mockStore := new(MockStore)
+	authenticator := NewAuthenticator(slog.Default(), mockProvider,
+		DefaultConfig(), mockStore, new(utils.MockClock))
+
+	mockStore.On("GetEncodedMacaroon", ctx, identifier).Return("macaroon", nil)
+
+	mockProvider.On("GetInvoicePreimage", ctx, paymentHash).Return("", nil).Once()
+	_, _, err := authenticator.PaidCredentials(ctx, paymentHash, pubKeyHex)
+	require.ErrorIs(t, err, ErrInvoiceNotPaid)
+
+	mockProvider.On("GetInvoicePreimage", ctx, paymentHash).Return("preimage", nil)
+	macaroon, preimage, err := authenticator.PaidCredentials(ctx, paymentHash,
+		pubKeyHex)
+	require.NoError(t, err)
+	require.Equal(t, "macaroon", macaroon)
+	require.Equal(t, "preimage", preimage)
+}
+
+// TestRestoreCredentials tests that only unexpired credentials are restored.
+func TestRestoreCredentials(t *testing.T) {
+	ctx := context.Background()
+	pubKeyHex := "384b61fb5cc7fae5cb849ebd69a66c4f8535fa95cba90346a0204c034301bb3d"
+
+	clock := utils.NewMockClock()
+	clock.SetMockClockTime(time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC))
+
+	mockStore := new(MockStore)
+	authenticator := NewAuthenticator(slog.Default(), new(MockInvoiceProvider),
+		DefaultConfig(), mockStore, clock)
+
+	encode := func(expiresAt string) string {
+		caveats := map[string]string{"external_id": "video"}
+		if expiresAt != "" {
+			caveats[CaveatExpiresAt] = expiresAt
+		}
+
+		mac, err := authenticator.mintMacaroon("fewsats.com", []byte("id"),
+			bytes.Repeat([]byte{0x01}, 32), caveats)
+		require.NoError(t, err)
+
+		macBytes, err := mac.MarshalBinary()
+		require.NoError(t, err)
+
+		return base64.RawURLEncoding.EncodeToString(macBytes)
+	}
+
+	mockStore.On("ListPaidCredentials", ctx, pubKeyHex).Return(
+		[]*StoredCredentials{
+			{PaymentHash: "valid", Macaroon: encode("2024-10-01T00:00:00Z")},
+			{PaymentHash: "expired", Macaroon: encode("2024-08-01T00:00:00Z")},
+			{PaymentHash: "noExpiration", Macaroon: encode("")},
+			{PaymentHash: "invalid", Macaroon: "invalid"},
+		}, nil,
+	)
+
+	credentials, err := authenticator.RestoreCredentials(ctx, pubKeyHex)
+	require.NoError(t, err)
+	require.Len(t, credentials, 2)
+
+	require.Equal(t, "valid", credentials[0].PaymentHash)
+	require.Equal(t, time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC),
+		credentials[0].ExpiresAt.UTC())
+
+	require.Equal(t, "noExpiration", credentials[1].PaymentHash)
+	require.Nil(t, credentials[1].ExpiresAt)
+}
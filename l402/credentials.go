@@ -7,6 +7,7 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"strings"
 
 	"gopkg.in/macaroon.v2"
 )
@@ -114,19 +115,35 @@ func (c *Credentials) ValidatePreimage() error {
 }
 
 // VerifyMacaroon verifies the macaroon with the given root key and checks
-// that all the caveats are valid.
-func (c *Credentials) VerifyMacaroon(rootKey string) error {
+// that all the caveats are valid using the given check function.
+func (c *Credentials) VerifyMacaroon(rootKey string,
+	check func(caveat string) error) error {
+
 	rootKeyBytes, err := hex.DecodeString(rootKey)
 	if err != nil {
 		return fmt.Errorf("unable to decode root key: %v", err)
 	}
 
-	_, err = c.Macaroon.VerifySignature(rootKeyBytes, nil)
+	caveats, err := c.Macaroon.VerifySignature(rootKeyBytes, nil)
 	if err != nil {
 		return fmt.Errorf("unable to verify macaroon: %v", err)
 	}
 
-	// TODO(positiveblue): Add caveat logic.
+	for _, caveat := range caveats {
+		if err := check(caveat); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
+
+// ParseCaveat splits a first party caveat in its key and value.
+func ParseCaveat(caveat string) (string, string, error) {
+	key, value, found := strings.Cut(caveat, "=")
+	if !found || key == "" {
+		return "", "", fmt.Errorf("invalid caveat format: %s", caveat)
+	}
+
+	return key, value, nil
+}
@@ -3,10 +3,12 @@ package l402_test
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"testing"
 
 	"github.com/fewsats/blockbuster/l402"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/macaroon.v2"
 )
 
 const macBase64 = "AgELZmV3c2F0cy5jb20CQgAAPm7liCfo4ClO2QCUZGJZ6P2fzmrjz9mvreU5cKQs30M8EVtMs-PbDVuhiaoTBNNg8ULIvf-89xHY-MPnE2RxZwACH2V4cGlyZXNfYXQ9MjAyNS0wOS0yN1QxNToxMzo1N1oAAixleHRlcm5hbF9pZD1mNDMzZTM1YmEzMzk0NDQxYmIxNzQ5YWFiMjFiMTdlOQAABiBXk7cYhCcslZf5ssgEym6wWNa10aUIS1R5z6H31QMXog"
@@ -130,5 +132,45 @@ func TestValidatePreimage(t *testing.T) {
 }
 
 func TestVerifyMacaroon(t *testing.T) {
-	// TODO(positiveblue): Verify macaroon including caveats.
+	rootKey := bytes.Repeat([]byte{0x01}, 32)
+	mac, err := macaroon.New(rootKey, []byte("id"), "fewsats.com",
+		macaroon.LatestVersion)
+	require.NoError(t, err)
+	require.NoError(t, mac.AddFirstPartyCaveat([]byte("external_id=video")))
+
+	creds := &l402.Credentials{Macaroon: mac}
+
+	var caveats []string
+	err = creds.VerifyMacaroon(hex.EncodeToString(rootKey),
+		func(caveat string) error {
+			caveats = append(caveats, caveat)
+			return nil
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"external_id=video"}, caveats)
+
+	// A failing caveat check invalidates the macaroon.
+	err = creds.VerifyMacaroon(hex.EncodeToString(rootKey),
+		func(caveat string) error {
+			return errors.New("caveat not satisfied")
+		},
+	)
+	require.ErrorContains(t, err, "caveat not satisfied")
+
+	// A different root key invalidates the macaroon.
+	err = creds.VerifyMacaroon(hex.EncodeToString(rootKey[:31]),
+		func(string) error { return nil },
+	)
+	require.Error(t, err)
+}
+
+func TestParseCaveat(t *testing.T) {
+	key, value, err := l402.ParseCaveat("expires_at=2025-09-27T15:13:57Z")
+	require.NoError(t, err)
+	require.Equal(t, "expires_at", key)
+	require.Equal(t, "2025-09-27T15:13:57Z", value)
+
+	_, _, err = l402.ParseCaveat("no_value")
+	require.Error(t, err)
 }
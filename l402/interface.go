@@ -2,6 +2,7 @@ package l402
 
 import (
 	"context"
+	"time"
 
 	"github.com/fewsats/blockbuster/lightning"
 )
@@ -24,4 +25,30 @@ type Store interface {
 
 	// GetRootKey retrieves the root key for a given token ID.
 	GetRootKey(ctx context.Context, identifier string) (string, error)
+
+	// GetEncodedMacaroon retrieves the base64 encoded macaroon minted for a
+	// given token ID.
+	GetEncodedMacaroon(ctx context.Context, identifier string) (string, error)
+
+	// ListPaidCredentials returns the credentials minted for the given
+	// public key whose invoice is known to be settled.
+	ListPaidCredentials(ctx context.Context,
+		pubKeyHex string) ([]*StoredCredentials, error)
+}
+
+// StoredCredentials are paid credentials as kept by the store, ready to be
+// sent back to their owner.
+type StoredCredentials struct {
+	// PaymentHash is the payment hash of the invoice paid.
+	PaymentHash string
+
+	// Macaroon is the base64 encoded macaroon.
+	Macaroon string
+
+	// Preimage is the hex encoded preimage of the invoice.
+	Preimage string
+
+	// ExpiresAt is the value of the expires_at caveat of the macaroon, nil
+	// if it has none.
+	ExpiresAt *time.Time
 }
@@ -156,3 +156,58 @@ func (a *AlbyInvoiceProvider) GetInvoicePreimage(ctx context.Context,
 
 	return invoiceResponse.Preimage, nil
 }
+
+// AlbyPaymentResponse represents the response from Alby's API when paying a
+// LN invoice.
+type AlbyPaymentResponse struct {
+	Amount          uint64 `json:"amount"`
+	Fee             uint64 `json:"fee"`
+	PaymentHash     string `json:"payment_hash"`
+	PaymentPreimage string `json:"payment_preimage"`
+}
+
+// PayInvoice pays the given LN invoice from the Alby account.
+func (a *AlbyInvoiceProvider) PayInvoice(ctx context.Context,
+	paymentRequest string) (*Payment, error) {
+
+	jsonData, err := json.Marshal(map[string]string{
+		"invoice": paymentRequest,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	url := "https://api.getalby.com/payments/bolt11"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url,
+		bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+a.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("failed to pay invoice, status code: %d",
+			resp.StatusCode)
+	}
+
+	var paymentResponse AlbyPaymentResponse
+	err = json.NewDecoder(resp.Body).Decode(&paymentResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Payment{
+		PaymentHash:  paymentResponse.PaymentHash,
+		Preimage:     paymentResponse.PaymentPreimage,
+		AmountInSats: paymentResponse.Amount,
+		FeeInSats:    paymentResponse.Fee,
+	}, nil
+}
@@ -2,6 +2,7 @@ package lightning_test
 
 import (
 	"context"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
@@ -111,3 +112,89 @@ func TestCreateInvoiceHandlesHTTPError(t *testing.T) {
 	require.NotNil(t, err)
 	require.Contains(t, err.Error(), "401")
 }
+
+func TestPayInvoice(t *testing.T) {
+	var reqBody string
+	httpClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			body, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			reqBody = string(body)
+
+			require.Equal(t, "https://api.getalby.com/payments/bolt11",
+				req.URL.String())
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body: io.NopCloser(strings.NewReader(`{"amount":16,` +
+					`"fee":1,"payment_hash":"hash",` +
+					`"payment_preimage":"preimage"}`)),
+			}, nil
+		},
+	}
+
+	albyProvider := lightning.NewAlbyProvider(httpClient, "fakeToken")
+
+	payment, err := albyProvider.PayInvoice(context.Background(), "lnbc160n1")
+	require.NoError(t, err)
+	require.Equal(t, `{"invoice":"lnbc160n1"}`, reqBody)
+	require.Equal(t, &lightning.Payment{
+		PaymentHash:  "hash",
+		Preimage:     "preimage",
+		AmountInSats: 16,
+		FeeInSats:    1,
+	}, payment)
+}
+
+func TestDecodeInvoiceAmount(t *testing.T) {
+	testCases := []struct {
+		name           string
+		paymentRequest string
+		expectedMsat   uint64
+		expectedErr    bool
+	}{
+		{
+			name:           "nano bitcoin",
+			paymentRequest: "lnbc160n1pnyfazspp5n7zukez5c7m4y32qchrvddkvkld827",
+			expectedMsat:   16000,
+		},
+		{
+			name:           "milli bitcoin on regtest",
+			paymentRequest: "lnbcrt2m1pnyfazs",
+			expectedMsat:   200000000,
+		},
+		{
+			name:           "pico bitcoin",
+			paymentRequest: "lntb10p1pnyfazs",
+			expectedMsat:   1,
+		},
+		{
+			name:           "no amount",
+			paymentRequest: "lnbc1pnyfazs",
+			expectedMsat:   0,
+		},
+		{
+			name:           "sub-millisatoshi amount",
+			paymentRequest: "lnbc1p1pnyfazs",
+			expectedErr:    true,
+		},
+		{
+			name:           "unknown prefix",
+			paymentRequest: "lnxx10n1pnyfazs",
+			expectedErr:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			msat, err := lightning.DecodeInvoiceAmount(tc.paymentRequest)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedMsat, msat)
+		})
+	}
+}
@@ -1,5 +1,11 @@
 package lightning
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 // Amount represents an amount in a specific currency.
 type Amount struct {
 	// Amount is the amount in the currency's smallest unit.
@@ -23,3 +29,80 @@ type LNInvoice struct {
 	// PaymentRequest is the Lightning Network invoice payment request.
 	PaymentRequest string
 }
+
+// Payment represents an outgoing Lightning Network payment.
+type Payment struct {
+	// PaymentHash is the hash of the paid invoice.
+	PaymentHash string
+
+	// Preimage is the preimage revealed by the payment.
+	Preimage string
+
+	// AmountInSats is the amount paid, without fees.
+	AmountInSats uint64
+
+	// FeeInSats is the routing fee paid.
+	FeeInSats uint64
+}
+
+// invoicePrefixes are the human-readable prefixes of BOLT11 invoices of the
+// supported networks, longest first so regtest is not mistaken for mainnet.
+var invoicePrefixes = []string{"lnbcrt", "lntbs", "lnbc", "lntb", "lnsb"}
+
+// amountMultipliers maps the BOLT11 amount multipliers to the number of
+// millisatoshis of one unit.
+var amountMultipliers = map[byte]float64{
+	'm': 1e8,
+	'u': 1e5,
+	'n': 1e2,
+	'p': 1e-1,
+}
+
+// DecodeInvoiceAmount returns the amount in millisatoshis encoded in the
+// human-readable part of a BOLT11 invoice. Zero means the invoice has no
+// amount.
+func DecodeInvoiceAmount(paymentRequest string) (uint64, error) {
+	invoice := strings.ToLower(paymentRequest)
+	separator := strings.LastIndexByte(invoice, '1')
+	if separator < 0 {
+		return 0, fmt.Errorf("invalid invoice: missing separator")
+	}
+	hrp := invoice[:separator]
+
+	var (
+		amount string
+		found  bool
+	)
+	for _, prefix := range invoicePrefixes {
+		if strings.HasPrefix(hrp, prefix) {
+			amount = strings.TrimPrefix(hrp, prefix)
+			found = true
+			break
+		}
+	}
+	if !found {
+		return 0, fmt.Errorf("invalid invoice: unknown prefix")
+	}
+
+	if amount == "" {
+		return 0, nil
+	}
+
+	unitMsat := float64(1e11)
+	if multiplier, ok := amountMultipliers[amount[len(amount)-1]]; ok {
+		unitMsat = multiplier
+		amount = amount[:len(amount)-1]
+	}
+
+	value, err := strconv.ParseUint(amount, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid invoice amount: %w", err)
+	}
+
+	msat := float64(value) * unitMsat
+	if msat != float64(uint64(msat)) {
+		return 0, fmt.Errorf("invalid invoice amount: sub-millisatoshi")
+	}
+
+	return uint64(msat), nil
+}
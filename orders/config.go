@@ -0,0 +1,12 @@
+package orders
+
+// DefaultConfig returns all default values for the Config struct.
+func DefaultConfig() *Config {
+	return &Config{}
+}
+
+type Config struct {
+	// AdminUserIDs are the users that can resolve the refunds of any
+	// creator.
+	AdminUserIDs []int64 `long:"admin_user_id" description:"ID of a user allowed to resolve every refund, can be repeated"`
+}
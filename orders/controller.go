@@ -0,0 +1,257 @@
+package orders
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Controller struct {
+	orders        *Manager
+	authenticator Authenticator
+
+	logger *slog.Logger
+}
+
+func NewController(ordersMgr *Manager, authenticator Authenticator,
+	logger *slog.Logger) *Controller {
+
+	return &Controller{
+		orders:        ordersMgr,
+		authenticator: authenticator,
+
+		logger: logger,
+	}
+}
+
+func (c *Controller) RegisterPublicRoutes(router *gin.Engine) {
+	router.POST("/refund", c.RequestRefund)
+}
+
+func (c *Controller) RegisterProtectedRoutes(router *gin.Engine) {
+	router.GET("/user/refunds", c.ListRefunds)
+	router.POST("/refund/:id/approve", c.ApproveRefund)
+	router.POST("/refund/:id/reject", c.RejectRefund)
+	router.GET("/user/ledger", c.ListLedgerEntries)
+}
+
+// RefundAction is the action signed in the refund requests.
+const RefundAction = "refund"
+
+// RefundRequest is a request signed by the payer or the recipient of a
+// purchase to get its payment back to the given invoice. The signed message
+// is "<domain>:refund:<payment_hash>:<sha256(invoice)>:<timestamp>", so the
+// signature can't be replayed with another purchase or invoice. Buyers
+// without a public key bound to the purchase sign with any key and send the
+// L402 credentials of the purchase in the Authorization header instead.
+type RefundRequest struct {
+	PubKey    string `json:"pub_key" binding:"required"`
+	Domain    string `json:"domain" binding:"required"`
+	Timestamp int64  `json:"timestamp" binding:"required"`
+	Signature string `json:"signature" binding:"required"`
+
+	PaymentHash string `json:"payment_hash" binding:"required"`
+	Reason      string `json:"reason" binding:"required,max=1000"`
+	Invoice     string `json:"invoice" binding:"required"`
+}
+
+// RequestRefund creates a pending refund for a purchase.
+func (c *Controller) RequestRefund(gCtx *gin.Context) {
+	ctx := gCtx.Request.Context()
+
+	var req RefundRequest
+	if err := gCtx.ShouldBindJSON(&req); err != nil {
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	invoiceHash := sha256.Sum256([]byte(req.Invoice))
+	err := c.authenticator.ValidateActionSignature(req.PubKey,
+		req.Signature, req.Domain, req.Timestamp, RefundAction,
+		req.PaymentHash, hex.EncodeToString(invoiceHash[:]))
+	if err != nil {
+		c.logger.Debug("invalid signature", "error", err)
+		gCtx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid signature"})
+		return
+	}
+
+	authHeader := gCtx.GetHeader("Authorization")
+	paidCredentials := authHeader != ""
+	if paidCredentials {
+		err := c.validatePaidCredentials(authHeader, req.PaymentHash)
+		if err != nil {
+			c.logger.Debug("invalid credentials", "error", err)
+			gCtx.JSON(http.StatusUnauthorized,
+				gin.H{"error": "invalid credentials"})
+			return
+		}
+	}
+
+	refund, err := c.orders.RequestRefund(ctx, &RequestRefundParams{
+		PubKey:          req.PubKey,
+		PaidCredentials: paidCredentials,
+		PaymentHash:     req.PaymentHash,
+		Reason:          req.Reason,
+		PaymentRequest:  req.Invoice,
+	})
+	switch {
+	case errors.Is(err, ErrNotFound):
+		gCtx.JSON(http.StatusNotFound, gin.H{"error": "purchase not found"})
+		return
+
+	case errors.Is(err, ErrRefundNotAllowed):
+		gCtx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+
+	case errors.Is(err, ErrInvalidRefundInvoice):
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+
+	case errors.Is(err, ErrRefundExists):
+		gCtx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+
+	case err != nil:
+		c.logger.Error("Failed to request refund", "error", err)
+		gCtx.JSON(http.StatusInternalServerError,
+			gin.H{"error": "failed to request refund"})
+		return
+	}
+
+	gCtx.JSON(http.StatusCreated, gin.H{"refund": refund})
+}
+
+// validatePaidCredentials checks that the L402 credentials in the
+// Authorization header belong to the given payment hash and that their
+// preimage was revealed by paying it. Expired credentials are still valid,
+// the refund doesn't grant access to anything.
+func (c *Controller) validatePaidCredentials(authHeader,
+	paymentHash string) error {
+
+	creds, err := c.authenticator.ExtractCredentials(authHeader)
+	if err != nil {
+		return err
+	}
+
+	if hex.EncodeToString(creds.PaymentHash[:]) != paymentHash {
+		return fmt.Errorf("credentials are not for payment hash(%s)",
+			paymentHash)
+	}
+
+	return creds.ValidatePreimage()
+}
+
+// ListRefunds returns the refunds the user can resolve.
+func (c *Controller) ListRefunds(gCtx *gin.Context) {
+	userID := gCtx.GetInt64("user_id")
+
+	refunds, err := c.orders.ListRefunds(gCtx.Request.Context(), userID)
+	if err != nil {
+		c.logger.Error("Failed to list refunds", "error", err)
+		gCtx.JSON(http.StatusInternalServerError,
+			gin.H{"error": "failed to list refunds"})
+		return
+	}
+
+	gCtx.JSON(http.StatusOK, gin.H{"refunds": refunds})
+}
+
+// ApproveRefund pays the invoice of a refund.
+func (c *Controller) ApproveRefund(gCtx *gin.Context) {
+	userID := gCtx.GetInt64("user_id")
+
+	refundID, err := strconv.ParseInt(gCtx.Param("id"), 10, 64)
+	if err != nil {
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": "invalid refund ID"})
+		return
+	}
+
+	refund, err := c.orders.ApproveRefund(gCtx.Request.Context(), userID,
+		refundID)
+	if err != nil {
+		c.handleResolveError(gCtx, refundID, err)
+		return
+	}
+
+	gCtx.JSON(http.StatusOK, gin.H{"refund": refund})
+}
+
+// RejectRefundRequest holds the reason of a rejection.
+type RejectRefundRequest struct {
+	Reason string `json:"reason" binding:"max=1000"`
+}
+
+// RejectRefund rejects a pending refund.
+func (c *Controller) RejectRefund(gCtx *gin.Context) {
+	userID := gCtx.GetInt64("user_id")
+
+	refundID, err := strconv.ParseInt(gCtx.Param("id"), 10, 64)
+	if err != nil {
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": "invalid refund ID"})
+		return
+	}
+
+	var req RejectRefundRequest
+	if err := gCtx.ShouldBindJSON(&req); err != nil {
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	refund, err := c.orders.RejectRefund(gCtx.Request.Context(), userID,
+		refundID, req.Reason)
+	if err != nil {
+		c.handleResolveError(gCtx, refundID, err)
+		return
+	}
+
+	gCtx.JSON(http.StatusOK, gin.H{"refund": refund})
+}
+
+// ListLedgerEntries returns the sales and refunds of the user.
+func (c *Controller) ListLedgerEntries(gCtx *gin.Context) {
+	userID := gCtx.GetInt64("user_id")
+
+	entries, err := c.orders.ListLedgerEntries(gCtx.Request.Context(),
+		userID)
+	if err != nil {
+		c.logger.Error("Failed to list ledger entries", "error", err)
+		gCtx.JSON(http.StatusInternalServerError,
+			gin.H{"error": "failed to list ledger entries"})
+		return
+	}
+
+	gCtx.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// handleResolveError maps the errors of approving or rejecting a refund to
+// HTTP responses.
+func (c *Controller) handleResolveError(gCtx *gin.Context, refundID int64,
+	err error) {
+
+	switch {
+	case errors.Is(err, ErrNotFound):
+		gCtx.JSON(http.StatusNotFound, gin.H{"error": "refund not found"})
+
+	case errors.Is(err, ErrRefundNotAllowed):
+		gCtx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+
+	case errors.Is(err, ErrInvalidRefundStatus):
+		gCtx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+
+	case errors.Is(err, ErrRefundPaymentFailed):
+		gCtx.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+
+	default:
+		c.logger.Error("Failed to resolve refund", "refundID", refundID,
+			"error", err)
+		gCtx.JSON(http.StatusInternalServerError,
+			gin.H{"error": "failed to resolve refund"})
+	}
+}
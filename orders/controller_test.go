@@ -0,0 +1,131 @@
+package orders
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fewsats/blockbuster/l402"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAuthenticator accepts every signature and decodes the Authorization
+// headers found in its map.
+type fakeAuthenticator map[string]*l402.Credentials
+
+func (f fakeAuthenticator) ValidateActionSignature(_, _, _ string, _ int64,
+	_ string, _ ...string) error {
+
+	return nil
+}
+
+func (f fakeAuthenticator) ExtractCredentials(
+	authHeader string) (*l402.Credentials, error) {
+
+	creds, ok := f[authHeader]
+	if !ok {
+		return nil, errors.New("invalid L402 token")
+	}
+
+	return creds, nil
+}
+
+// TestRequestRefundCredentials tests that refunds can be requested with the
+// L402 credentials of the purchase, as long as they are for its payment hash
+// and their preimage pays it.
+func TestRequestRefundCredentials(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	preimage := [32]byte{1}
+	paymentHash := sha256.Sum256(preimage[:])
+	paymentHashHex := hex.EncodeToString(paymentHash[:])
+
+	newStore := func() *refundStore {
+		store := newRefundStore()
+		store.purchases[paymentHashHex] = &Purchase{
+			UserID:       testCreatorID,
+			PaymentHash:  paymentHashHex,
+			AmountInSats: 1500,
+		}
+
+		return store
+	}
+
+	authenticator := fakeAuthenticator{
+		"L402 paid": {PaymentHash: paymentHash, Preimage: preimage},
+		"L402 other": {
+			PaymentHash: sha256.Sum256([]byte("other")),
+			Preimage:    preimage,
+		},
+		"L402 unpaid": {PaymentHash: paymentHash},
+	}
+
+	tests := []struct {
+		name       string
+		authHeader string
+		wantStatus int
+	}{
+		{
+			name:       "paid",
+			authHeader: "L402 paid",
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "noCredentials",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "otherPaymentHash",
+			authHeader: "L402 other",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "wrongPreimage",
+			authHeader: "L402 unpaid",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "malformed",
+			authHeader: "L402 malformed",
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newRefundManager(newStore(), &fakePayer{})
+			controller := NewController(m, authenticator, slog.Default())
+
+			router := gin.New()
+			controller.RegisterPublicRoutes(router)
+
+			body, err := json.Marshal(&RefundRequest{
+				PubKey:      "anyKey",
+				Domain:      "localhost",
+				Timestamp:   1,
+				Signature:   "signature",
+				PaymentHash: paymentHashHex,
+				Reason:      "never watched",
+				Invoice:     "lnbc10u1refund",
+			})
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPost, "/refund",
+				bytes.NewReader(body))
+			if tc.authHeader != "" {
+				req.Header.Set("Authorization", tc.authHeader)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, tc.wantStatus, w.Code, w.Body.String())
+		})
+	}
+}
@@ -3,6 +3,9 @@ package orders
 import (
 	"context"
 	"time"
+
+	"github.com/fewsats/blockbuster/l402"
+	"github.com/fewsats/blockbuster/lightning"
 )
 
 // Offer represents an offer that is available for purchase.
@@ -27,13 +30,67 @@ type Offer struct {
 	Currency string `json:"currency"`
 
 	// ExpirationDate is the expiration date for the credentials linked to
-	// this offer. Only used in the Subscription-based pricing plans.
+	// this offer. Only used in the time-limited pricing plans (rentals and
+	// subscriptions).
 	ExpirationDate *time.Time `json:"expiration_date"`
 
+	// PromoCodeID is the ID of the promo code applied to the price, zero if
+	// none was used.
+	PromoCodeID int64 `json:"promo_code_id"`
+
+	// PayerPubKey is the public key of the user that requested the offer.
+	PayerPubKey string `json:"payer_pub_key"`
+
+	// RecipientPubKey is the public key the credentials are bound to. It
+	// only differs from PayerPubKey for gifts.
+	RecipientPubKey string `json:"recipient_pub_key"`
+
+	// AmountInSats is the amount of the invoice of the offer.
+	AmountInSats uint64 `json:"amount_in_sats"`
+
+	// ServiceType is the type of the service sold, used for the purchase
+	// of the offer.
+	ServiceType string `json:"service_type"`
+
 	// CreatedAt is the timestamp when the offer was created.
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// CreateOfferParams holds the parameters needed to create an offer.
+type CreateOfferParams struct {
+	// UserID is the user ID of the user selling the service.
+	UserID int64
+
+	// PriceInCents is the price of the offer, after any discount.
+	PriceInCents uint64
+
+	// ExternalID is the external ID of the service being sold.
+	ExternalID string
+
+	// PaymentHash is the payment hash of the invoice for the offer.
+	PaymentHash string
+
+	// ExpirationDate is the expiration date of the credentials, nil for
+	// offers that do not expire before the credentials do.
+	ExpirationDate *time.Time
+
+	// PromoCodeID is the ID of the promo code applied to the price, zero if
+	// none was used.
+	PromoCodeID int64
+
+	// PayerPubKey is the public key of the user requesting the offer.
+	PayerPubKey string
+
+	// RecipientPubKey is the public key the credentials are bound to.
+	RecipientPubKey string
+
+	// AmountInSats is the amount of the invoice of the offer.
+	AmountInSats uint64
+
+	// ServiceType is the type of the service being sold.
+	ServiceType string
+}
+
 // Purchase represents a purchase made by the end clients.
 type Purchase struct {
 	// ID is the unique identifier of the purchase.
@@ -62,28 +119,223 @@ type Purchase struct {
 	// purchase.
 	ExpirationDate *time.Time `json:"expiration_date"`
 
+	// PayerPubKey is the public key of the user that paid the purchase.
+	PayerPubKey string `json:"payer_pub_key"`
+
+	// RecipientPubKey is the public key of the user the purchase was made
+	// for. It only differs from PayerPubKey for gifts.
+	RecipientPubKey string `json:"recipient_pub_key"`
+
+	// AmountInSats is the amount paid, zero for the purchases recorded
+	// before it was tracked.
+	AmountInSats uint64 `json:"amount_in_sats"`
+
 	// CreatedAt is the timestamp when the purchase was created.
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// RecordPurchaseParams holds the parameters needed to record a purchase.
+type RecordPurchaseParams struct {
+	// PaymentHash is the payment hash of the offer being purchased.
+	PaymentHash string
+
+	// ServiceType is the type of the service. (STaaS, DaaS, FaaS...)
+	ServiceType string
+
+	// ViewedExternalID is the external ID of the video whose view counter
+	// is incremented together with the purchase. Empty means no view is
+	// counted.
+	ViewedExternalID string
+}
+
+// Refund is the request of a buyer to get back the payment of a purchase.
+type Refund struct {
+	// ID is the unique identifier of the refund.
+	ID int64 `json:"id"`
+
+	// PaymentHash is the payment hash of the refunded purchase.
+	PaymentHash string `json:"payment_hash"`
+
+	// UserID is the user ID of the creator that sold the purchase.
+	UserID int64 `json:"-"`
+
+	// RequesterPubKey is the public key that requested the refund.
+	RequesterPubKey string `json:"requester_pub_key"`
+
+	// Reason is the explanation given by the buyer.
+	Reason string `json:"reason"`
+
+	// PaymentRequest is the invoice to be paid to the buyer.
+	PaymentRequest string `json:"payment_request"`
+
+	// AmountInSats is the amount of the invoice to be paid.
+	AmountInSats uint64 `json:"amount_in_sats"`
+
+	// Status is one of the RefundStatus values.
+	Status string `json:"status"`
+
+	// Resolution is the reason of a rejection or of a failed payout.
+	Resolution string `json:"resolution"`
+
+	// ResolvedBy is the user ID of the creator or admin that approved or
+	// rejected the refund, zero while pending.
+	ResolvedBy int64 `json:"resolved_by"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RequestRefundParams holds the parameters of a refund request.
+type RequestRefundParams struct {
+	// PubKey is the public key that signed the request. It must be the
+	// payer or the recipient of the purchase unless the credentials of the
+	// purchase were presented.
+	PubKey string
+
+	// PaidCredentials reports whether the requester proved to hold the
+	// paid credentials of the purchase. It is how the buyers without a
+	// public key bound to the purchase request their refunds.
+	PaidCredentials bool
+
+	// PaymentHash is the payment hash of the purchase.
+	PaymentHash string
+
+	// Reason is the explanation given by the buyer.
+	Reason string
+
+	// PaymentRequest is the invoice the refund is paid to.
+	PaymentRequest string
+}
+
+// UpdateRefundStatusParams holds the parameters to move a refund from one
+// status to another.
+type UpdateRefundStatusParams struct {
+	ID         int64
+	FromStatus string
+	Status     string
+	Resolution string
+
+	// ResolvedBy is kept unchanged when zero.
+	ResolvedBy int64
+}
+
+// LedgerEntry is a movement of the balance of a creator.
+type LedgerEntry struct {
+	ID          int64  `json:"id"`
+	UserID      int64  `json:"-"`
+	PaymentHash string `json:"payment_hash"`
+
+	// EntryType is either sale or refund.
+	EntryType string `json:"entry_type"`
+
+	// AmountInCents and AmountInSats are negative for refunds. The cents
+	// of partial refunds are pro-rated to the sats refunded.
+	AmountInCents int64  `json:"amount_in_cents"`
+	AmountInSats  int64  `json:"amount_in_sats"`
+	Currency      string `json:"currency"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // Store is the interface for storing and retrieving order related data.
 type Store interface {
 	// InsertOffer inserts a new offer into the store.
 	InsertOffer(ctx context.Context, offer *Offer) (uint64, error)
 
-	// GetOfferByPaymentHash returns the offer for the given payment hash.
+	// GetOfferByPaymentHash returns the offer for the given payment hash,
+	// or ErrNotFound if there is none.
 	GetOfferByPaymentHash(ctx context.Context, payreq string) (*Offer, error)
 
+	// ListRecipientOffers returns the most recent offers, up to limit, for
+	// the given service whose credentials are bound to the recipient public
+	// key.
+	ListRecipientOffers(ctx context.Context, externalID,
+		recipientPubKey string, limit int64) ([]*Offer, error)
+
 	// InsertPurchase inserts a new purchase into the store.
 	InsertPurchase(ctx context.Context, purchase *Purchase) (uint64, error)
 
 	// GetPurchaseByPaymentHash returns the purchase for the given payment hash.
 	GetPurchaseByPaymentHash(ctx context.Context, payreq string) (*Purchase,
 		error)
+
+	// ListRecipientPurchases returns the purchases made for the given
+	// public key, most recent first.
+	ListRecipientPurchases(ctx context.Context,
+		recipientPubKey string) ([]*Purchase, error)
+
+	// RecordPurchase atomically creates the purchase for the offer linked to
+	// the payment hash, if it does not exist yet, and updates the related
+	// counters and the ledger. The returned bool reports whether the
+	// purchase was created by this call.
+	RecordPurchase(ctx context.Context, params *RecordPurchaseParams) (
+		*Purchase, bool, error)
+
+	// CreateRefund stores a new refund, returning ErrRefundExists if the
+	// purchase already has one.
+	CreateRefund(ctx context.Context, refund *Refund) error
+
+	// GetRefund returns the refund with the given ID.
+	GetRefund(ctx context.Context, id int64) (*Refund, error)
+
+	// ListUserRefunds returns the refunds of the purchases sold by a user.
+	ListUserRefunds(ctx context.Context, userID int64) ([]*Refund, error)
+
+	// ListRefunds returns all the refunds.
+	ListRefunds(ctx context.Context) ([]*Refund, error)
+
+	// UpdateRefundStatus moves a refund to a new status, returning
+	// ErrInvalidRefundStatus if it is not in the expected one.
+	UpdateRefundStatus(ctx context.Context,
+		params *UpdateRefundStatusParams) error
+
+	// CompleteRefund atomically reverses the sale of a paid refund in the
+	// ledger and revokes the credentials of the purchase. It can be called
+	// again if it failed.
+	CompleteRefund(ctx context.Context, refund *Refund) error
+
+	// ListUserLedgerEntries returns the ledger of a user, most recent first.
+	ListUserLedgerEntries(ctx context.Context,
+		userID int64) ([]*LedgerEntry, error)
+}
+
+// Payer is the interface for paying Lightning invoices.
+type Payer interface {
+	// PayInvoice pays the given invoice.
+	PayInvoice(ctx context.Context,
+		paymentRequest string) (*lightning.Payment, error)
+}
+
+// InvoiceProvider is the interface for checking if an invoice was paid.
+type InvoiceProvider interface {
+	// GetInvoicePreimage returns the preimage of the invoice, or an empty
+	// string if it is not paid.
+	GetInvoicePreimage(ctx context.Context, paymentHash string) (string,
+		error)
+}
+
+// Authenticator is the interface for validating signed requests.
+type Authenticator interface {
+	// ValidateActionSignature validates a signature of
+	// "<domain>:<action>:<params...>:<timestamp>".
+	ValidateActionSignature(pubKeyHex, signatureHex, domain string,
+		timestamp int64, action string, params ...string) error
+
+	// ExtractCredentials extracts the L402 credentials from the
+	// Authorization header.
+	ExtractCredentials(authHeader string) (*l402.Credentials, error)
 }
 
 // NotificationService is the interface for sending notifications.
 type NotificationService interface {
-	// RegisterNewPurchaseEvent sends a notification for a new purchase.
-	RegisterNewPurchaseEvent(externalID, paymentHash, email string) error
+	// RegisterNewPurchaseEvent notifies the seller of a new purchase.
+	RegisterNewPurchaseEvent(ctx context.Context, purchase *Purchase) error
+}
+
+// WebhookDispatcher is the interface for pushing events to the webhook
+// endpoints of the users.
+type WebhookDispatcher interface {
+	// Enqueue schedules the delivery of an event of the user.
+	Enqueue(ctx context.Context, userID int64, eventType string,
+		data any) error
 }
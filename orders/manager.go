@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+
+	"github.com/fewsats/blockbuster/webhooks"
 )
 
 var (
@@ -18,16 +20,38 @@ type Manager struct {
 	// store is the store for orders related data.
 	store Store
 
+	// payer pays the invoices of the approved refunds.
+	payer Payer
+
+	// invoices checks whether the offers without a purchase were paid.
+	invoices InvoiceProvider
+
+	// notifier notifies the sellers of their new purchases.
+	notifier NotificationService
+
+	// webhooks pushes the new purchases to the sellers' endpoints.
+	webhooks WebhookDispatcher
+
+	// cfg is the configuration for orders related operations.
+	cfg *Config
+
 	// logger is the logger for orders related operations.
 	logger *slog.Logger
 }
 
 // NewManager creates a new orders manager.
-func NewManager(logger *slog.Logger, store Store) *Manager {
+func NewManager(logger *slog.Logger, store Store, payer Payer,
+	invoices InvoiceProvider, notifier NotificationService,
+	webhooks WebhookDispatcher, cfg *Config) *Manager {
 
 	return &Manager{
-		store:  store,
-		logger: logger,
+		store:    store,
+		payer:    payer,
+		invoices: invoices,
+		notifier: notifier,
+		webhooks: webhooks,
+		cfg:      cfg,
+		logger:   logger,
 	}
 }
 
@@ -55,63 +79,96 @@ type PurchaseInfo struct {
 	Currency string `json:"currency"`
 }
 
-func (m *Manager) CreateOffer(ctx context.Context, userID int64,
-	PriceInCents uint64, externalID, paymentHash string) error {
+// CreateOffer creates a new offer for the given params.
+func (m *Manager) CreateOffer(ctx context.Context,
+	params *CreateOfferParams) error {
 
 	offer := &Offer{
-		UserID:       uint64(userID),
-		ExternalID:   externalID,
-		PaymentHash:  paymentHash,
-		PriceInCents: PriceInCents,
+		UserID:       uint64(params.UserID),
+		ExternalID:   params.ExternalID,
+		PaymentHash:  params.PaymentHash,
+		PriceInCents: params.PriceInCents,
 		Currency:     "USD",
 
-		ExpirationDate: nil,
+		ExpirationDate:  params.ExpirationDate,
+		PromoCodeID:     params.PromoCodeID,
+		PayerPubKey:     params.PayerPubKey,
+		RecipientPubKey: params.RecipientPubKey,
+		AmountInSats:    params.AmountInSats,
+		ServiceType:     params.ServiceType,
 	}
 
 	_, err := m.store.InsertOffer(ctx, offer)
 	if err != nil {
-		return fmt.Errorf("failed to insert offer for %s: %w", externalID, err)
+		return fmt.Errorf("failed to insert offer for %s: %w",
+			params.ExternalID, err)
 	}
 
 	return nil
 }
 
-// RecordPurchase creates a new purchase if there is not one already for
-// the given payment hash.
-func (m *Manager) RecordPurchase(ctx context.Context, payHash, serviceType string) error {
-	_, err := m.store.GetPurchaseByPaymentHash(ctx, payHash)
-	if err == nil {
-		// Purchase already exists, nothing to record.
-		return nil
-	}
+// ListRecipientOffers returns the most recent offers for the given service
+// whose credentials are bound to the recipient public key.
+func (m *Manager) ListRecipientOffers(ctx context.Context, externalID,
+	recipientPubKey string, limit int64) ([]*Offer, error) {
 
-	if !errors.Is(err, ErrNotFound) {
-		return fmt.Errorf("failed to get purchase by payment hash(%s): %w",
-			payHash, err)
+	offers, err := m.store.ListRecipientOffers(ctx, externalID,
+		recipientPubKey, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list offers for %s: %w",
+			externalID, err)
 	}
 
-	offer, err := m.store.GetOfferByPaymentHash(ctx, payHash)
+	return offers, nil
+}
+
+// ListRecipientPurchases returns the purchases made for the given public key,
+// most recent first.
+func (m *Manager) ListRecipientPurchases(ctx context.Context,
+	recipientPubKey string) ([]*Purchase, error) {
+
+	purchases, err := m.store.ListRecipientPurchases(ctx, recipientPubKey)
 	if err != nil {
-		return fmt.Errorf("failed to get offer by payment hash(%s): %w",
-			payHash, err)
+		return nil, fmt.Errorf("failed to list purchases: %w", err)
 	}
 
-	// TODO(pol) check this fields are saved to DB and populated correctly
-	purchase := &Purchase{
-		UserID:       offer.UserID,
-		ExternalID:   offer.ExternalID,
-		ServiceType:  serviceType,
-		PaymentHash:  payHash,
-		PriceInCents: offer.PriceInCents,
-		Currency:     offer.Currency,
+	return purchases, nil
+}
 
-		ExpirationDate: offer.ExpirationDate,
-	}
+// RecordPurchase creates a new purchase if there is not one already for
+// the given payment hash.
+func (m *Manager) RecordPurchase(ctx context.Context, payHash, serviceType string) error {
+	return m.recordPurchase(ctx, &RecordPurchaseParams{
+		PaymentHash: payHash,
+		ServiceType: serviceType,
+	})
+}
+
+// RecordPurchaseAndView creates a new purchase if there is not one already
+// for the given payment hash and counts a new view for the video with the
+// given external ID. Both updates happen atomically.
+func (m *Manager) RecordPurchaseAndView(ctx context.Context, payHash,
+	serviceType, externalID string) error {
+
+	return m.recordPurchase(ctx, &RecordPurchaseParams{
+		PaymentHash:      payHash,
+		ServiceType:      serviceType,
+		ViewedExternalID: externalID,
+	})
+}
+
+func (m *Manager) recordPurchase(ctx context.Context,
+	params *RecordPurchaseParams) error {
 
-	_, err = m.store.InsertPurchase(ctx, purchase)
+	purchase, created, err := m.store.RecordPurchase(ctx, params)
 	if err != nil {
-		return fmt.Errorf("failed to insert purchase for offer %d: %w",
-			offer.ID, err)
+		return fmt.Errorf("failed to record purchase for payment hash(%s): %w",
+			params.PaymentHash, err)
+	}
+
+	if !created {
+		// Purchase already exists, nothing else to do.
+		return nil
 	}
 
 	m.logger.Info("Purchase recorded",
@@ -120,5 +177,25 @@ func (m *Manager) RecordPurchase(ctx context.Context, payHash, serviceType strin
 		"name", purchase.ExternalID,
 		"serviceType", purchase.ServiceType)
 
+	// A failed notification must not fail the purchase.
+	if m.notifier != nil {
+		err = m.notifier.RegisterNewPurchaseEvent(ctx, purchase)
+		if err != nil {
+			m.logger.Error("Failed to notify purchase",
+				"paymentHash", purchase.PaymentHash,
+				"error", err)
+		}
+	}
+
+	if m.webhooks != nil {
+		err = m.webhooks.Enqueue(ctx, int64(purchase.UserID),
+			webhooks.EventPurchaseCreated, purchase)
+		if err != nil {
+			m.logger.Error("Failed to enqueue purchase webhook",
+				"paymentHash", purchase.PaymentHash,
+				"error", err)
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,333 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/fewsats/blockbuster/lightning"
+)
+
+const (
+	// RefundStatusPending is the status of a refund waiting for the
+	// creator or an admin.
+	RefundStatusPending = "pending"
+
+	// RefundStatusApproved is the status of a refund being paid.
+	RefundStatusApproved = "approved"
+
+	// RefundStatusRejected is the status of a refund that won't be paid.
+	RefundStatusRejected = "rejected"
+
+	// RefundStatusPaid is the status of a refund whose invoice was paid.
+	RefundStatusPaid = "paid"
+
+	// RefundStatusFailed is the status of an approved refund whose invoice
+	// could not be paid. It can be approved again.
+	RefundStatusFailed = "failed"
+)
+
+var (
+	// ErrRefundExists is returned when the purchase already has a refund.
+	ErrRefundExists = errors.New("refund already requested")
+
+	// ErrRefundNotAllowed is returned when the user is not allowed to
+	// request or resolve the refund.
+	ErrRefundNotAllowed = errors.New("refund not allowed")
+
+	// ErrInvalidRefundInvoice is returned when the invoice of a refund
+	// request can't be paid.
+	ErrInvalidRefundInvoice = errors.New("invalid refund invoice")
+
+	// ErrInvalidRefundStatus is returned when the refund is not in the
+	// status required by the operation.
+	ErrInvalidRefundStatus = errors.New("invalid refund status")
+
+	// ErrRefundPaymentFailed is returned when the invoice of an approved
+	// refund could not be paid.
+	ErrRefundPaymentFailed = errors.New("refund payment failed")
+)
+
+// RequestRefund creates a pending refund for the purchase with the given
+// payment hash. The request must be signed by the payer or the recipient of
+// the purchase, or come with its paid credentials, and the invoice can't
+// exceed the amount paid. Offers paid but never used have no purchase yet,
+// so it is recorded first.
+func (m *Manager) RequestRefund(ctx context.Context,
+	params *RequestRefundParams) (*Refund, error) {
+
+	purchase, err := m.paidPurchase(ctx, params.PaymentHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if !params.PaidCredentials && params.PubKey != purchase.PayerPubKey &&
+		params.PubKey != purchase.RecipientPubKey {
+
+		return nil, ErrRefundNotAllowed
+	}
+
+	amountInMsat, err := lightning.DecodeInvoiceAmount(params.PaymentRequest)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRefundInvoice, err)
+	}
+
+	switch {
+	case amountInMsat == 0 || amountInMsat%1000 != 0:
+		return nil, fmt.Errorf("%w: amount must be a whole number of "+
+			"sats", ErrInvalidRefundInvoice)
+
+	case amountInMsat/1000 > purchase.AmountInSats:
+		return nil, fmt.Errorf("%w: amount exceeds the %d sats paid",
+			ErrInvalidRefundInvoice, purchase.AmountInSats)
+	}
+
+	refund := &Refund{
+		PaymentHash:     purchase.PaymentHash,
+		UserID:          int64(purchase.UserID),
+		RequesterPubKey: params.PubKey,
+		Reason:          params.Reason,
+		PaymentRequest:  params.PaymentRequest,
+		AmountInSats:    amountInMsat / 1000,
+		Status:          RefundStatusPending,
+	}
+
+	if err := m.store.CreateRefund(ctx, refund); err != nil {
+		return nil, err
+	}
+
+	m.logger.Info("Refund requested",
+		"refundID", refund.ID,
+		"userID", refund.UserID,
+		"paymentHash", refund.PaymentHash,
+		"amountInSats", refund.AmountInSats)
+
+	return refund, nil
+}
+
+// paidPurchase returns the purchase for the given payment hash. If there is
+// none but the invoice of its offer was paid, the purchase is recorded.
+// ErrNotFound is returned for unknown or unpaid offers.
+func (m *Manager) paidPurchase(ctx context.Context,
+	paymentHash string) (*Purchase, error) {
+
+	purchase, err := m.store.GetPurchaseByPaymentHash(ctx, paymentHash)
+	if !errors.Is(err, ErrNotFound) {
+		return purchase, err
+	}
+
+	offer, err := m.store.GetOfferByPaymentHash(ctx, paymentHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.invoices == nil {
+		return nil, errors.New("no lightning provider to check invoices")
+	}
+
+	preimage, err := m.invoices.GetInvoicePreimage(ctx, paymentHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check invoice for payment "+
+			"hash(%s): %w", paymentHash, err)
+	}
+
+	if preimage == "" {
+		return nil, ErrNotFound
+	}
+
+	err = m.recordPurchase(ctx, &RecordPurchaseParams{
+		PaymentHash: paymentHash,
+		ServiceType: offer.ServiceType,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m.store.GetPurchaseByPaymentHash(ctx, paymentHash)
+}
+
+// ListRefunds returns the refunds the user can resolve: all of them for
+// admins and the ones of their own sales otherwise.
+func (m *Manager) ListRefunds(ctx context.Context,
+	userID int64) ([]*Refund, error) {
+
+	if m.isAdmin(userID) {
+		return m.store.ListRefunds(ctx)
+	}
+
+	return m.store.ListUserRefunds(ctx, userID)
+}
+
+// ApproveRefund pays the invoice of a pending or failed refund. Once paid,
+// the sale is reversed in the ledger and the credentials of the purchase
+// are revoked. Approving a paid refund again only retries that bookkeeping,
+// in case it failed after the payment.
+func (m *Manager) ApproveRefund(ctx context.Context, userID,
+	refundID int64) (*Refund, error) {
+
+	refund, err := m.resolvableRefund(ctx, userID, refundID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch refund.Status {
+	// Failed payouts can be retried, so both statuses can be approved.
+	case RefundStatusPending, RefundStatusFailed:
+		if err := m.payRefund(ctx, userID, refund); err != nil {
+			return nil, err
+		}
+
+	case RefundStatusPaid:
+		// Only the bookkeeping is left.
+
+	default:
+		return nil, ErrInvalidRefundStatus
+	}
+
+	// The invoice is paid by now, so the bookkeeping must not be cut short
+	// by the request going away.
+	ctx = context.WithoutCancel(ctx)
+	if err := m.store.CompleteRefund(ctx, refund); err != nil {
+		return nil, err
+	}
+
+	return m.store.GetRefund(ctx, refund.ID)
+}
+
+// payRefund pays the invoice of an approvable refund and records it as paid,
+// or as failed if the payment did not go through.
+func (m *Manager) payRefund(ctx context.Context, userID int64,
+	refund *Refund) error {
+
+	// The conditional update guarantees that concurrent approvals pay the
+	// invoice only once.
+	err := m.store.UpdateRefundStatus(ctx, &UpdateRefundStatusParams{
+		ID:         refund.ID,
+		FromStatus: refund.Status,
+		Status:     RefundStatusApproved,
+		ResolvedBy: userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	payment, err := m.payInvoice(ctx, refund.PaymentRequest)
+
+	// Whatever happened to the request, the outcome of the payment has to
+	// be recorded.
+	ctx = context.WithoutCancel(ctx)
+	if err != nil {
+		m.logger.Error("Failed to pay refund", "refundID", refund.ID,
+			"error", err)
+
+		updateErr := m.store.UpdateRefundStatus(ctx,
+			&UpdateRefundStatusParams{
+				ID:         refund.ID,
+				FromStatus: RefundStatusApproved,
+				Status:     RefundStatusFailed,
+				Resolution: err.Error(),
+			},
+		)
+		if updateErr != nil {
+			return fmt.Errorf("failed to mark refund(%d) as failed: %w",
+				refund.ID, updateErr)
+		}
+
+		return fmt.Errorf("%w: %v", ErrRefundPaymentFailed, err)
+	}
+
+	// Record the payment before anything else so a failure in the
+	// bookkeeping can't lead to paying the refund twice.
+	err = m.store.UpdateRefundStatus(ctx, &UpdateRefundStatusParams{
+		ID:         refund.ID,
+		FromStatus: RefundStatusApproved,
+		Status:     RefundStatusPaid,
+	})
+	if err != nil {
+		m.logger.Error("Failed to mark paid refund", "refundID", refund.ID,
+			"paymentHash", refund.PaymentHash, "error", err)
+
+		return fmt.Errorf("failed to mark refund(%d) as paid: %w",
+			refund.ID, err)
+	}
+
+	m.logger.Info("Refund paid",
+		"refundID", refund.ID,
+		"resolvedBy", userID,
+		"paymentHash", refund.PaymentHash,
+		"feeInSats", payment.FeeInSats)
+
+	return nil
+}
+
+// RejectRefund rejects a pending refund with the given reason.
+func (m *Manager) RejectRefund(ctx context.Context, userID, refundID int64,
+	reason string) (*Refund, error) {
+
+	refund, err := m.resolvableRefund(ctx, userID, refundID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.store.UpdateRefundStatus(ctx, &UpdateRefundStatusParams{
+		ID:         refund.ID,
+		FromStatus: RefundStatusPending,
+		Status:     RefundStatusRejected,
+		Resolution: reason,
+		ResolvedBy: userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.logger.Info("Refund rejected", "refundID", refund.ID,
+		"resolvedBy", userID)
+
+	return m.store.GetRefund(ctx, refund.ID)
+}
+
+// ListLedgerEntries returns the ledger of the user, most recent first.
+func (m *Manager) ListLedgerEntries(ctx context.Context,
+	userID int64) ([]*LedgerEntry, error) {
+
+	entries, err := m.store.ListUserLedgerEntries(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ledger entries: %w", err)
+	}
+
+	return entries, nil
+}
+
+// payInvoice pays the invoice with the configured payer, if any.
+func (m *Manager) payInvoice(ctx context.Context,
+	paymentRequest string) (*lightning.Payment, error) {
+
+	if m.payer == nil {
+		return nil, errors.New("no lightning provider to pay invoices")
+	}
+
+	return m.payer.PayInvoice(ctx, paymentRequest)
+}
+
+// resolvableRefund returns the refund if the user is its creator or an
+// admin.
+func (m *Manager) resolvableRefund(ctx context.Context, userID,
+	refundID int64) (*Refund, error) {
+
+	refund, err := m.store.GetRefund(ctx, refundID)
+	if err != nil {
+		return nil, err
+	}
+
+	if refund.UserID != userID && !m.isAdmin(userID) {
+		return nil, ErrRefundNotAllowed
+	}
+
+	return refund, nil
+}
+
+// isAdmin returns whether the user can resolve the refunds of any creator.
+func (m *Manager) isAdmin(userID int64) bool {
+	return slices.Contains(m.cfg.AdminUserIDs, userID)
+}
@@ -0,0 +1,551 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/fewsats/blockbuster/lightning"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// testPaymentHash is the payment hash of the purchase being refunded.
+	testPaymentHash = "paymentHash"
+
+	// testCreatorID is the user that sold the purchase.
+	testCreatorID = 1
+
+	// testAdminID is a user allowed to resolve every refund.
+	testAdminID = 99
+)
+
+// refundStore is an in-memory Store for the refund tests. The methods the
+// refunds don't use are left to the embedded nil Store.
+type refundStore struct {
+	Store
+
+	mu        sync.Mutex
+	offers    map[string]*Offer
+	purchases map[string]*Purchase
+	refunds   []*Refund
+	ledger    []*LedgerEntry
+	revoked   map[string]bool
+
+	// completeErr is returned by CompleteRefund when set.
+	completeErr error
+}
+
+func newRefundStore() *refundStore {
+	return &refundStore{
+		purchases: map[string]*Purchase{
+			testPaymentHash: {
+				UserID:          testCreatorID,
+				PaymentHash:     testPaymentHash,
+				PriceInCents:    100,
+				PayerPubKey:     "payer",
+				RecipientPubKey: "recipient",
+				AmountInSats:    1500,
+			},
+		},
+		offers:  make(map[string]*Offer),
+		revoked: make(map[string]bool),
+	}
+}
+
+func (s *refundStore) GetOfferByPaymentHash(_ context.Context,
+	paymentHash string) (*Offer, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	offer, ok := s.offers[paymentHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	copied := *offer
+	return &copied, nil
+}
+
+func (s *refundStore) RecordPurchase(_ context.Context,
+	params *RecordPurchaseParams) (*Purchase, bool, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if purchase, ok := s.purchases[params.PaymentHash]; ok {
+		return purchase, false, nil
+	}
+
+	offer, ok := s.offers[params.PaymentHash]
+	if !ok {
+		return nil, false, ErrNotFound
+	}
+
+	purchase := &Purchase{
+		UserID:          offer.UserID,
+		ExternalID:      offer.ExternalID,
+		ServiceType:     params.ServiceType,
+		PaymentHash:     offer.PaymentHash,
+		PriceInCents:    offer.PriceInCents,
+		PayerPubKey:     offer.PayerPubKey,
+		RecipientPubKey: offer.RecipientPubKey,
+		AmountInSats:    offer.AmountInSats,
+	}
+	s.purchases[params.PaymentHash] = purchase
+
+	return purchase, true, nil
+}
+
+func (s *refundStore) GetPurchaseByPaymentHash(_ context.Context,
+	paymentHash string) (*Purchase, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purchase, ok := s.purchases[paymentHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	copied := *purchase
+	return &copied, nil
+}
+
+func (s *refundStore) CreateRefund(_ context.Context, refund *Refund) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.refunds {
+		if existing.PaymentHash == refund.PaymentHash {
+			return ErrRefundExists
+		}
+	}
+
+	refund.ID = int64(len(s.refunds) + 1)
+	copied := *refund
+	s.refunds = append(s.refunds, &copied)
+
+	return nil
+}
+
+func (s *refundStore) GetRefund(_ context.Context, id int64) (*Refund,
+	error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id < 1 || id > int64(len(s.refunds)) {
+		return nil, ErrNotFound
+	}
+
+	copied := *s.refunds[id-1]
+	return &copied, nil
+}
+
+func (s *refundStore) UpdateRefundStatus(_ context.Context,
+	params *UpdateRefundStatusParams) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refund := s.refunds[params.ID-1]
+	if refund.Status != params.FromStatus {
+		return ErrInvalidRefundStatus
+	}
+
+	refund.Status = params.Status
+	refund.Resolution = params.Resolution
+	if params.ResolvedBy != 0 {
+		refund.ResolvedBy = params.ResolvedBy
+	}
+
+	return nil
+}
+
+func (s *refundStore) CompleteRefund(_ context.Context,
+	refund *Refund) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.completeErr != nil {
+		return s.completeErr
+	}
+
+	stored := s.refunds[refund.ID-1]
+	if stored.Status != RefundStatusPaid {
+		return ErrInvalidRefundStatus
+	}
+
+	for _, entry := range s.ledger {
+		if entry.PaymentHash == stored.PaymentHash {
+			return nil
+		}
+	}
+
+	s.ledger = append(s.ledger, &LedgerEntry{
+		UserID:       stored.UserID,
+		PaymentHash:  stored.PaymentHash,
+		EntryType:    "refund",
+		AmountInSats: -int64(stored.AmountInSats),
+	})
+	s.revoked[stored.PaymentHash] = true
+
+	return nil
+}
+
+// fakePayer records the invoices it pays.
+type fakePayer struct {
+	mu   sync.Mutex
+	paid []string
+
+	// err is returned instead of paying when set.
+	err error
+
+	// release, when set, blocks the payments until it is closed.
+	release chan struct{}
+}
+
+func (p *fakePayer) PayInvoice(_ context.Context,
+	paymentRequest string) (*lightning.Payment, error) {
+
+	if p.release != nil {
+		<-p.release
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	p.paid = append(p.paid, paymentRequest)
+
+	return &lightning.Payment{FeeInSats: 1}, nil
+}
+
+func (p *fakePayer) payments() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.paid)
+}
+
+// fakeInvoices maps the payment hashes of the paid invoices to their
+// preimages.
+type fakeInvoices map[string]string
+
+func (f fakeInvoices) GetInvoicePreimage(_ context.Context,
+	paymentHash string) (string, error) {
+
+	return f[paymentHash], nil
+}
+
+// newRefundManager returns a manager with an admin, backed by the given
+// store and payer.
+func newRefundManager(store Store, payer Payer) *Manager {
+	cfg := DefaultConfig()
+	cfg.AdminUserIDs = []int64{testAdminID}
+
+	return NewManager(slog.Default(), store, payer, fakeInvoices{}, nil,
+		nil, cfg)
+}
+
+// requestTestRefund requests a refund of 1000 sats of the test purchase.
+func requestTestRefund(t *testing.T, m *Manager) *Refund {
+	refund, err := m.RequestRefund(context.Background(),
+		&RequestRefundParams{
+			PubKey:         "payer",
+			PaymentHash:    testPaymentHash,
+			Reason:         "wrong video",
+			PaymentRequest: "lnbc10u1refund",
+		},
+	)
+	require.NoError(t, err)
+
+	return refund
+}
+
+// TestRequestRefund tests that refunds can only be requested by the payer or
+// the recipient, once, and up to the amount paid.
+func TestRequestRefund(t *testing.T) {
+	ctx := context.Background()
+	m := newRefundManager(newRefundStore(), &fakePayer{})
+
+	request := func(pubKey, paymentRequest string) (*Refund, error) {
+		return m.RequestRefund(ctx, &RequestRefundParams{
+			PubKey:         pubKey,
+			PaymentHash:    testPaymentHash,
+			Reason:         "wrong video",
+			PaymentRequest: paymentRequest,
+		})
+	}
+
+	_, err := request("stranger", "lnbc10u1refund")
+	require.ErrorIs(t, err, ErrRefundNotAllowed)
+
+	_, err = m.RequestRefund(ctx, &RequestRefundParams{
+		PubKey:         "payer",
+		PaymentHash:    "unknown",
+		PaymentRequest: "lnbc10u1refund",
+	})
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// The invoice can't exceed the 1500 sats paid, nor have no amount or
+	// fractions of a sat.
+	for _, paymentRequest := range []string{
+		"lnbc20u1refund", "lnbc1refund", "lnbc1p1refund", "refund",
+	} {
+		_, err = request("payer", paymentRequest)
+		require.ErrorIs(t, err, ErrInvalidRefundInvoice, paymentRequest)
+	}
+
+	// The recipient of a gift can request it too.
+	refund, err := request("recipient", "lnbc15u1refund")
+	require.NoError(t, err)
+	require.Equal(t, RefundStatusPending, refund.Status)
+	require.EqualValues(t, 1500, refund.AmountInSats)
+	require.EqualValues(t, testCreatorID, refund.UserID)
+	require.Equal(t, "recipient", refund.RequesterPubKey)
+
+	_, err = request("payer", "lnbc10u1refund")
+	require.ErrorIs(t, err, ErrRefundExists)
+}
+
+// TestRequestRefundPaidCredentials tests that the holder of the paid
+// credentials of a purchase can request its refund whatever key signs it.
+func TestRequestRefundPaidCredentials(t *testing.T) {
+	store := newRefundStore()
+	store.purchases[testPaymentHash].PayerPubKey = ""
+	store.purchases[testPaymentHash].RecipientPubKey = ""
+	m := newRefundManager(store, &fakePayer{})
+
+	params := &RequestRefundParams{
+		PubKey:         "anyKey",
+		PaymentHash:    testPaymentHash,
+		Reason:         "wrong video",
+		PaymentRequest: "lnbc10u1refund",
+	}
+
+	_, err := m.RequestRefund(context.Background(), params)
+	require.ErrorIs(t, err, ErrRefundNotAllowed)
+
+	params.PaidCredentials = true
+	refund, err := m.RequestRefund(context.Background(), params)
+	require.NoError(t, err)
+	require.Equal(t, RefundStatusPending, refund.Status)
+	require.Equal(t, "anyKey", refund.RequesterPubKey)
+}
+
+// TestRequestRefundUnusedOffer tests that a refund can be requested for a
+// paid offer whose credentials were never used, recording its purchase, but
+// not for an unpaid one.
+func TestRequestRefundUnusedOffer(t *testing.T) {
+	ctx := context.Background()
+	store := newRefundStore()
+	for _, paymentHash := range []string{"paidHash", "unpaidHash"} {
+		store.offers[paymentHash] = &Offer{
+			UserID:       testCreatorID,
+			ExternalID:   "externalID",
+			PaymentHash:  paymentHash,
+			PriceInCents: 100,
+			PayerPubKey:  "payer",
+			AmountInSats: 1500,
+			ServiceType:  "videos",
+		}
+	}
+
+	m := newRefundManager(store, &fakePayer{})
+	m.invoices = fakeInvoices{"paidHash": "preimage"}
+
+	request := func(paymentHash string) (*Refund, error) {
+		return m.RequestRefund(ctx, &RequestRefundParams{
+			PubKey:         "payer",
+			PaymentHash:    paymentHash,
+			Reason:         "never watched",
+			PaymentRequest: "lnbc15u1refund",
+		})
+	}
+
+	_, err := request("unpaidHash")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = store.GetPurchaseByPaymentHash(ctx, "unpaidHash")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	refund, err := request("paidHash")
+	require.NoError(t, err)
+	require.Equal(t, RefundStatusPending, refund.Status)
+	require.EqualValues(t, 1500, refund.AmountInSats)
+	require.EqualValues(t, testCreatorID, refund.UserID)
+
+	purchase, err := store.GetPurchaseByPaymentHash(ctx, "paidHash")
+	require.NoError(t, err)
+	require.Equal(t, "videos", purchase.ServiceType)
+	require.Equal(t, "externalID", purchase.ExternalID)
+}
+
+// TestApproveRefund tests that approving a refund pays its invoice once,
+// then reverses the sale and revokes the credentials of the purchase.
+func TestApproveRefund(t *testing.T) {
+	ctx := context.Background()
+	store := newRefundStore()
+	payer := &fakePayer{}
+	m := newRefundManager(store, payer)
+	refund := requestTestRefund(t, m)
+
+	// Only the creator of the sale and the admins can approve it.
+	_, err := m.ApproveRefund(ctx, testCreatorID+1, refund.ID)
+	require.ErrorIs(t, err, ErrRefundNotAllowed)
+	require.Zero(t, payer.payments())
+
+	refund, err = m.ApproveRefund(ctx, testCreatorID, refund.ID)
+	require.NoError(t, err)
+	require.Equal(t, RefundStatusPaid, refund.Status)
+	require.EqualValues(t, testCreatorID, refund.ResolvedBy)
+	require.Equal(t, []string{"lnbc10u1refund"}, payer.paid)
+
+	require.Len(t, store.ledger, 1)
+	require.EqualValues(t, -1000, store.ledger[0].AmountInSats)
+	require.True(t, store.revoked[testPaymentHash])
+
+	// Approving it again doesn't pay it twice.
+	refund, err = m.ApproveRefund(ctx, testAdminID, refund.ID)
+	require.NoError(t, err)
+	require.Equal(t, RefundStatusPaid, refund.Status)
+	require.Equal(t, 1, payer.payments())
+	require.Len(t, store.ledger, 1)
+
+	// Nor can it be rejected once paid.
+	_, err = m.RejectRefund(ctx, testCreatorID, refund.ID, "too late")
+	require.ErrorIs(t, err, ErrInvalidRefundStatus)
+}
+
+// TestApproveRefundPaymentFailure tests that a refund whose invoice could not
+// be paid is marked as failed and can be approved again.
+func TestApproveRefundPaymentFailure(t *testing.T) {
+	ctx := context.Background()
+	store := newRefundStore()
+	payer := &fakePayer{err: errors.New("no route")}
+	m := newRefundManager(store, payer)
+	refund := requestTestRefund(t, m)
+
+	_, err := m.ApproveRefund(ctx, testAdminID, refund.ID)
+	require.ErrorIs(t, err, ErrRefundPaymentFailed)
+
+	refund, err = store.GetRefund(ctx, refund.ID)
+	require.NoError(t, err)
+	require.Equal(t, RefundStatusFailed, refund.Status)
+	require.Equal(t, "no route", refund.Resolution)
+	require.EqualValues(t, testAdminID, refund.ResolvedBy)
+	require.Empty(t, store.ledger)
+	require.False(t, store.revoked[testPaymentHash])
+
+	// Failed refunds can't be rejected, only retried.
+	_, err = m.RejectRefund(ctx, testCreatorID, refund.ID, "")
+	require.ErrorIs(t, err, ErrInvalidRefundStatus)
+
+	payer.err = nil
+	refund, err = m.ApproveRefund(ctx, testCreatorID, refund.ID)
+	require.NoError(t, err)
+	require.Equal(t, RefundStatusPaid, refund.Status)
+	require.Empty(t, refund.Resolution)
+	require.Equal(t, 1, payer.payments())
+	require.Len(t, store.ledger, 1)
+	require.True(t, store.revoked[testPaymentHash])
+}
+
+// TestApproveRefundCompletionFailure tests that a refund stays paid when its
+// bookkeeping fails after the payment, and that approving it again completes
+// it without paying it twice.
+func TestApproveRefundCompletionFailure(t *testing.T) {
+	ctx := context.Background()
+	store := newRefundStore()
+	store.completeErr = errors.New("database is locked")
+	payer := &fakePayer{}
+	m := newRefundManager(store, payer)
+	refund := requestTestRefund(t, m)
+
+	_, err := m.ApproveRefund(ctx, testCreatorID, refund.ID)
+	require.ErrorIs(t, err, store.completeErr)
+
+	refund, err = store.GetRefund(ctx, refund.ID)
+	require.NoError(t, err)
+	require.Equal(t, RefundStatusPaid, refund.Status)
+	require.Equal(t, 1, payer.payments())
+	require.Empty(t, store.ledger)
+	require.False(t, store.revoked[testPaymentHash])
+
+	store.completeErr = nil
+	_, err = m.ApproveRefund(ctx, testCreatorID, refund.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, payer.payments())
+	require.Len(t, store.ledger, 1)
+	require.True(t, store.revoked[testPaymentHash])
+}
+
+// TestConcurrentRefundApprovals tests that concurrent approvals of the same
+// refund pay its invoice only once.
+func TestConcurrentRefundApprovals(t *testing.T) {
+	ctx := context.Background()
+	store := newRefundStore()
+	payer := &fakePayer{release: make(chan struct{})}
+	m := newRefundManager(store, payer)
+	refund := requestTestRefund(t, m)
+
+	const approvals = 5
+	errs := make(chan error, approvals)
+	for i := 0; i < approvals; i++ {
+		go func() {
+			_, err := m.ApproveRefund(ctx, testCreatorID, refund.ID)
+			errs <- err
+		}()
+	}
+
+	// The payment is held until every other approval was turned down.
+	for i := 0; i < approvals-1; i++ {
+		require.ErrorIs(t, <-errs, ErrInvalidRefundStatus)
+	}
+	close(payer.release)
+	require.NoError(t, <-errs)
+
+	require.Equal(t, 1, payer.payments())
+	require.Len(t, store.ledger, 1)
+}
+
+// TestRejectRefund tests that only pending refunds can be rejected, by their
+// creator or an admin, and that they are not paid afterwards.
+func TestRejectRefund(t *testing.T) {
+	ctx := context.Background()
+	store := newRefundStore()
+	payer := &fakePayer{}
+	m := newRefundManager(store, payer)
+	refund := requestTestRefund(t, m)
+
+	_, err := m.RejectRefund(ctx, testCreatorID+1, refund.ID, "no")
+	require.ErrorIs(t, err, ErrRefundNotAllowed)
+
+	_, err = m.RejectRefund(ctx, testCreatorID, refund.ID+1, "no")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	refund, err = m.RejectRefund(ctx, testCreatorID, refund.ID,
+		"watched it all")
+	require.NoError(t, err)
+	require.Equal(t, RefundStatusRejected, refund.Status)
+	require.Equal(t, "watched it all", refund.Resolution)
+	require.EqualValues(t, testCreatorID, refund.ResolvedBy)
+
+	_, err = m.RejectRefund(ctx, testAdminID, refund.ID, "again")
+	require.ErrorIs(t, err, ErrInvalidRefundStatus)
+
+	_, err = m.ApproveRefund(ctx, testAdminID, refund.ID)
+	require.ErrorIs(t, err, ErrInvalidRefundStatus)
+	require.Zero(t, payer.payments())
+	require.Empty(t, store.ledger)
+	require.False(t, store.revoked[testPaymentHash])
+}
@@ -0,0 +1,16 @@
+package reconcile
+
+import "time"
+
+// DefaultConfig returns all default values for the Config struct.
+func DefaultConfig() *Config {
+	return &Config{
+		Interval:    6 * time.Hour,
+		GracePeriod: time.Hour,
+	}
+}
+
+type Config struct {
+	Interval    time.Duration `long:"interval" description:"Time between reconciliation runs, 0 disables the job"`
+	GracePeriod time.Duration `long:"grace_period" description:"Time given to the uploads after their URL expired before their video is expired, also the minimum age of the unreferenced cover images deleted"`
+}
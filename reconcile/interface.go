@@ -0,0 +1,66 @@
+package reconcile
+
+import (
+	"context"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/fewsats/blockbuster/storage"
+	"github.com/fewsats/blockbuster/video"
+)
+
+// VideoState is the state of a video in the database.
+type VideoState struct {
+	ExternalID string
+	Status     string
+
+	// Deleted is set for the videos in the trash, and Purged once their
+	// assets were deleted.
+	Deleted bool
+	Purged  bool
+}
+
+// Store is the interface for listing the videos in the database.
+type Store interface {
+	// ListStaleUploads returns the videos created before the given time
+	// that are still awaiting their upload.
+	ListStaleUploads(ctx context.Context,
+		createdBefore time.Time) ([]*video.Video, error)
+
+	// ListVideoStates returns the state of every video, including the
+	// deleted ones.
+	ListVideoStates(ctx context.Context) ([]*VideoState, error)
+
+	// ListBundleExternalIDs returns the external IDs of every bundle,
+	// including the deleted ones.
+	ListBundleExternalIDs(ctx context.Context) ([]string, error)
+}
+
+// StreamLister lists the videos of the streaming backend.
+type StreamLister interface {
+	// ListStreamVideos returns every video of the backend.
+	ListStreamVideos(ctx context.Context) ([]cloudflare.StreamVideo, error)
+}
+
+// VideoManager is the interface for expiring the uploads.
+type VideoManager interface {
+	// ExpireUpload expires a video whose upload window passed, unless the
+	// info of the backend shows it was uploaded.
+	ExpireUpload(ctx context.Context, video *video.Video,
+		videoInfo *cloudflare.StreamVideo) (*video.Video, error)
+}
+
+// Storage is the interface of the bucket holding the cover images.
+type Storage interface {
+	// List returns the files whose key starts with the given prefix.
+	List(ctx context.Context, prefix string) ([]storage.Object, error)
+
+	// Delete removes the file with the given key.
+	Delete(ctx context.Context, key string) error
+}
+
+// Queue is the interface for the job queue that runs the reconciliation.
+type Queue interface {
+	RegisterRecurring(kind string, interval time.Duration,
+		run func(ctx context.Context) error)
+}
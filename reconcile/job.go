@@ -0,0 +1,280 @@
+package reconcile
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/fewsats/blockbuster/utils"
+	"github.com/fewsats/blockbuster/video"
+)
+
+// metrics holds the counters of the reconciled videos, exposed through
+// expvar.
+var metrics = expvar.NewMap("reconcile")
+
+const (
+	metricRuns           = "runs"
+	metricFailedRuns     = "failed_runs"
+	metricUploadsExpired = "uploads_expired"
+	metricCoversDeleted  = "covers_deleted"
+	metricDiscrepancies  = "discrepancies"
+)
+
+// jobReconcile is the kind of the recurring reconciliation job.
+const jobReconcile = "reconcile"
+
+const (
+	// DiscrepancyOrphanedStreamVideo is reported for the videos of the
+	// streaming backend without a video in the database, or whose video
+	// was purged.
+	DiscrepancyOrphanedStreamVideo = "orphaned_stream_video"
+
+	// DiscrepancyMissingStreamVideo is reported for the videos processing
+	// or ready in the database that the streaming backend does not have.
+	DiscrepancyMissingStreamVideo = "missing_stream_video"
+)
+
+// Discrepancy is a mismatch between the database and the streaming backend
+// that is reported rather than fixed.
+type Discrepancy struct {
+	Kind       string
+	ExternalID string
+}
+
+// Stats are the changes and discrepancies found by a reconciliation run.
+type Stats struct {
+	UploadsExpired int64
+	CoversDeleted  int64
+
+	Discrepancies []Discrepancy
+}
+
+// Job periodically reconciles the videos in the database with the streaming
+// backend and the storage: it expires the videos that were never uploaded,
+// deletes the cover images no video or bundle references and reports the
+// videos only one side knows about.
+type Job struct {
+	store   Store
+	streams StreamLister
+	videos  VideoManager
+	storage Storage
+	queue   Queue
+
+	// uploadExpiry is the lifetime of the upload URLs of the streaming
+	// backend.
+	uploadExpiry time.Duration
+
+	cfg    *Config
+	clock  utils.Clock
+	logger *slog.Logger
+}
+
+// NewJob creates a new reconciliation job for a streaming backend whose upload
+// URLs expire after uploadExpiry.
+func NewJob(store Store, streams StreamLister, videos VideoManager,
+	storage Storage, queue Queue, uploadExpiry time.Duration, cfg *Config,
+	clock utils.Clock, logger *slog.Logger) *Job {
+
+	return &Job{
+		store:        store,
+		streams:      streams,
+		videos:       videos,
+		storage:      storage,
+		queue:        queue,
+		uploadExpiry: uploadExpiry,
+		cfg:          cfg,
+		clock:        clock,
+		logger:       logger,
+	}
+}
+
+// RegisterJobs registers the job in the queue to run every configured
+// interval, unless disabled.
+func (j *Job) RegisterJobs() {
+	if j.cfg.Interval <= 0 {
+		j.logger.Info("Reconcile job disabled")
+		return
+	}
+
+	j.queue.RegisterRecurring(jobReconcile, j.cfg.Interval,
+		func(ctx context.Context) error {
+			_, err := j.Run(ctx)
+			return err
+		})
+}
+
+// Run reconciles the videos whose upload window, the upload expiry plus the
+// grace period, is over. Every discrepancy is logged.
+func (j *Job) Run(ctx context.Context) (*Stats, error) {
+	metrics.Add(metricRuns, 1)
+
+	stats, err := j.run(ctx)
+
+	metrics.Add(metricUploadsExpired, stats.UploadsExpired)
+	metrics.Add(metricCoversDeleted, stats.CoversDeleted)
+	metrics.Add(metricDiscrepancies, int64(len(stats.Discrepancies)))
+
+	for _, discrepancy := range stats.Discrepancies {
+		j.logger.Warn("Video discrepancy found",
+			"kind", discrepancy.Kind,
+			"externalID", discrepancy.ExternalID)
+	}
+
+	if err != nil {
+		metrics.Add(metricFailedRuns, 1)
+		return stats, err
+	}
+
+	j.logger.Info("Reconcile finished",
+		"uploadsExpired", stats.UploadsExpired,
+		"coversDeleted", stats.CoversDeleted,
+		"discrepancies", len(stats.Discrepancies))
+
+	return stats, nil
+}
+
+func (j *Job) run(ctx context.Context) (*Stats, error) {
+	stats := &Stats{}
+	cutoff := j.clock.Now().Add(-j.uploadExpiry - j.cfg.GracePeriod)
+
+	streamVideos, err := j.streams.ListStreamVideos(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("failed to list stream videos: %w", err)
+	}
+
+	streamVideosByID := make(map[string]*cloudflare.StreamVideo,
+		len(streamVideos))
+	for i := range streamVideos {
+		streamVideosByID[streamVideos[i].UID] = &streamVideos[i]
+	}
+
+	err = j.expireUploads(ctx, cutoff, streamVideosByID, stats)
+	if err != nil {
+		return stats, err
+	}
+
+	// The videos and bundles referencing their cover image. Purged videos
+	// have no assets left.
+	owners := make(map[string]bool)
+
+	states, err := j.store.ListVideoStates(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("failed to list videos: %w", err)
+	}
+
+	for _, state := range states {
+		if state.Purged {
+			continue
+		}
+		owners[state.ExternalID] = true
+
+		_, ok := streamVideosByID[state.ExternalID]
+		if !ok && !state.Deleted && (state.Status == video.StatusReady ||
+			state.Status == video.StatusProcessing) {
+
+			stats.Discrepancies = append(stats.Discrepancies, Discrepancy{
+				Kind:       DiscrepancyMissingStreamVideo,
+				ExternalID: state.ExternalID,
+			})
+		}
+	}
+
+	// Stream videos are created before their video, so only the ones past
+	// their upload window are orphaned for sure.
+	for _, streamVideo := range streamVideos {
+		if owners[streamVideo.UID] || streamVideo.Created == nil ||
+			!streamVideo.Created.Before(cutoff) {
+
+			continue
+		}
+
+		stats.Discrepancies = append(stats.Discrepancies, Discrepancy{
+			Kind:       DiscrepancyOrphanedStreamVideo,
+			ExternalID: streamVideo.UID,
+		})
+	}
+
+	bundles, err := j.store.ListBundleExternalIDs(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("failed to list bundles: %w", err)
+	}
+	for _, externalID := range bundles {
+		owners[externalID] = true
+	}
+
+	return stats, j.deleteUnreferencedCovers(ctx, cutoff, owners, stats)
+}
+
+// expireUploads expires the videos still awaiting their upload once their
+// upload window is over, with the info listed by the streaming backend in
+// case their upload was missed.
+func (j *Job) expireUploads(ctx context.Context, cutoff time.Time,
+	streamVideos map[string]*cloudflare.StreamVideo, stats *Stats) error {
+
+	uploads, err := j.store.ListStaleUploads(ctx, cutoff)
+	if err != nil {
+		return fmt.Errorf("failed to list stale uploads: %w", err)
+	}
+
+	for _, upload := range uploads {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		updated, err := j.videos.ExpireUpload(ctx, upload,
+			streamVideos[upload.ExternalID])
+		if err != nil {
+			// Uploads that can't be expired now are retried on the
+			// next run.
+			j.logger.Warn("Unable to expire upload",
+				"externalID", upload.ExternalID,
+				"error", err)
+			continue
+		}
+
+		if updated.Status == video.StatusExpired {
+			stats.UploadsExpired++
+		}
+	}
+
+	return nil
+}
+
+// deleteUnreferencedCovers deletes the cover images, and their variants,
+// older than the cutoff that no video or bundle references, e.g. the ones
+// uploaded for a video that failed to be created.
+func (j *Job) deleteUnreferencedCovers(ctx context.Context, cutoff time.Time,
+	owners map[string]bool, stats *Stats) error {
+
+	covers, err := j.storage.List(ctx, video.CoverImagePrefix)
+	if err != nil {
+		return fmt.Errorf("failed to list cover images: %w", err)
+	}
+
+	for _, cover := range covers {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		externalID := video.CoverImageOwner(cover.Key)
+		if owners[externalID] || !cover.ModifiedAt.Before(cutoff) {
+			continue
+		}
+
+		err := j.storage.Delete(ctx, cover.Key)
+		if err != nil {
+			j.logger.Warn("Unable to delete cover image",
+				"key", cover.Key,
+				"error", err)
+			continue
+		}
+
+		stats.CoversDeleted++
+	}
+
+	return nil
+}
@@ -0,0 +1,246 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/fewsats/blockbuster/storage"
+	"github.com/fewsats/blockbuster/utils"
+	"github.com/fewsats/blockbuster/video"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockStore struct {
+	mock.Mock
+}
+
+func (m *MockStore) ListStaleUploads(ctx context.Context,
+	createdBefore time.Time) ([]*video.Video, error) {
+
+	args := m.Called(ctx, createdBefore)
+	return args.Get(0).([]*video.Video), args.Error(1)
+}
+
+func (m *MockStore) ListVideoStates(ctx context.Context) ([]*VideoState,
+	error) {
+
+	args := m.Called(ctx)
+	return args.Get(0).([]*VideoState), args.Error(1)
+}
+
+func (m *MockStore) ListBundleExternalIDs(ctx context.Context) ([]string,
+	error) {
+
+	args := m.Called(ctx)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+type MockStreamLister struct {
+	mock.Mock
+}
+
+func (m *MockStreamLister) ListStreamVideos(
+	ctx context.Context) ([]cloudflare.StreamVideo, error) {
+
+	args := m.Called(ctx)
+	return args.Get(0).([]cloudflare.StreamVideo), args.Error(1)
+}
+
+type MockVideoManager struct {
+	mock.Mock
+}
+
+func (m *MockVideoManager) ExpireUpload(ctx context.Context, v *video.Video,
+	videoInfo *cloudflare.StreamVideo) (*video.Video, error) {
+
+	args := m.Called(ctx, v, videoInfo)
+	return args.Get(0).(*video.Video), args.Error(1)
+}
+
+type MockStorage struct {
+	mock.Mock
+}
+
+func (m *MockStorage) List(ctx context.Context,
+	prefix string) ([]storage.Object, error) {
+
+	args := m.Called(ctx, prefix)
+	return args.Get(0).([]storage.Object), args.Error(1)
+}
+
+func (m *MockStorage) Delete(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+// TestRun tests that the uploads past their window are expired, the
+// unreferenced cover images deleted and the videos only one side knows about
+// reported.
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 9, 3, 12, 0, 0, 0, time.UTC)
+	clock := utils.NewMockClock()
+	clock.SetMockClockTime(now)
+
+	cutoff := now.Add(-3 * time.Hour)
+	old := cutoff.Add(-time.Minute)
+	recent := now.Add(-time.Minute)
+
+	abandoned := &video.Video{ExternalID: "abandoned",
+		Status: video.StatusAwaitingUpload}
+	uploaded := &video.Video{ExternalID: "uploaded",
+		Status: video.StatusAwaitingUpload}
+	uploadedInfo := cloudflare.StreamVideo{UID: "uploaded", Created: &old,
+		ReadyToStream: true}
+
+	store := new(MockStore)
+	store.On("ListStaleUploads", ctx, cutoff).Return(
+		[]*video.Video{abandoned, uploaded}, nil,
+	)
+	store.On("ListVideoStates", ctx).Return([]*VideoState{
+		{ExternalID: "abandoned", Status: video.StatusExpired},
+		{ExternalID: "uploaded", Status: video.StatusReady},
+		{ExternalID: "missing", Status: video.StatusReady},
+		{ExternalID: "trashed", Status: video.StatusReady, Deleted: true},
+		{ExternalID: "purged", Status: video.StatusReady, Deleted: true,
+			Purged: true},
+	}, nil)
+	store.On("ListBundleExternalIDs", ctx).Return([]string{"bundle"}, nil)
+
+	streams := new(MockStreamLister)
+	streams.On("ListStreamVideos", ctx).Return([]cloudflare.StreamVideo{
+		uploadedInfo,
+		{UID: "trashed", Created: &old},
+		{UID: "purged", Created: &old},
+		{UID: "orphaned", Created: &old},
+
+		// Its video may not be created yet.
+		{UID: "new", Created: &recent},
+	}, nil)
+
+	videos := new(MockVideoManager)
+	videos.On("ExpireUpload", ctx, abandoned,
+		(*cloudflare.StreamVideo)(nil)).Return(&video.Video{
+		ExternalID: "abandoned",
+		Status:     video.StatusExpired,
+	}, nil)
+	videos.On("ExpireUpload", ctx, uploaded, &uploadedInfo).Return(
+		&video.Video{ExternalID: "uploaded", Status: video.StatusReady},
+		nil,
+	)
+
+	covers := new(MockStorage)
+	covers.On("List", ctx, video.CoverImagePrefix).Return([]storage.Object{
+		{Key: "cover-images/abandoned", ModifiedAt: old},
+		{Key: "cover-images/trashed", ModifiedAt: old},
+		{Key: "cover-images/trashed-card.jpg", ModifiedAt: old},
+		{Key: "cover-images/bundle", ModifiedAt: old},
+		{Key: "cover-images/purged", ModifiedAt: old},
+		{Key: "cover-images/purged-og.jpg", ModifiedAt: old},
+		{Key: "cover-images/failed", ModifiedAt: old},
+		{Key: "cover-images/new", ModifiedAt: recent},
+	}, nil)
+	covers.On("Delete", ctx, "cover-images/purged").Return(nil)
+	covers.On("Delete", ctx, "cover-images/purged-og.jpg").Return(nil)
+	covers.On("Delete", ctx, "cover-images/failed").Return(
+		errors.New("unavailable"),
+	)
+
+	job := NewJob(store, streams, videos, covers, nil, 2*time.Hour,
+		DefaultConfig(), clock, slog.Default())
+
+	runs := utils.MetricValue(metrics, metricRuns)
+	expired := utils.MetricValue(metrics, metricUploadsExpired)
+
+	stats, err := job.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), stats.UploadsExpired)
+	require.Equal(t, int64(2), stats.CoversDeleted)
+	require.Equal(t, []Discrepancy{
+		{Kind: DiscrepancyMissingStreamVideo, ExternalID: "missing"},
+		{Kind: DiscrepancyOrphanedStreamVideo, ExternalID: "purged"},
+		{Kind: DiscrepancyOrphanedStreamVideo, ExternalID: "orphaned"},
+	}, stats.Discrepancies)
+
+	require.Equal(t, runs+1, utils.MetricValue(metrics, metricRuns))
+	require.Equal(t, expired+1,
+		utils.MetricValue(metrics, metricUploadsExpired))
+
+	store.AssertExpectations(t)
+	videos.AssertExpectations(t)
+	covers.AssertExpectations(t)
+}
+
+// TestRunStreamError tests that nothing is expired when the videos of the
+// streaming backend can't be listed.
+func TestRunStreamError(t *testing.T) {
+	ctx := context.Background()
+
+	streams := new(MockStreamLister)
+	streams.On("ListStreamVideos", ctx).Return(
+		([]cloudflare.StreamVideo)(nil), errors.New("unavailable"),
+	)
+
+	store := new(MockStore)
+	job := NewJob(store, streams, nil, nil, nil, 2*time.Hour,
+		DefaultConfig(), utils.NewMockClock(), slog.Default())
+
+	failed := utils.MetricValue(metrics, metricFailedRuns)
+
+	_, err := job.Run(ctx)
+	require.Error(t, err)
+	require.Equal(t, failed+1,
+		utils.MetricValue(metrics, metricFailedRuns))
+	store.AssertNotCalled(t, "ListStaleUploads", mock.Anything,
+		mock.Anything)
+}
+
+// recurringQueue records the recurring jobs registered in it.
+type recurringQueue struct {
+	intervals map[string]time.Duration
+	runs      map[string]func(ctx context.Context) error
+}
+
+func (q *recurringQueue) RegisterRecurring(kind string,
+	interval time.Duration, run func(ctx context.Context) error) {
+
+	q.intervals[kind] = interval
+	q.runs[kind] = run
+}
+
+// TestRegisterJobs tests that the job runs as a recurring job of the queue
+// unless disabled.
+func TestRegisterJobs(t *testing.T) {
+	ctx := context.Background()
+
+	streams := new(MockStreamLister)
+	streams.On("ListStreamVideos", ctx).Return(
+		([]cloudflare.StreamVideo)(nil), errors.New("unavailable"),
+	)
+
+	queue := &recurringQueue{
+		intervals: make(map[string]time.Duration),
+		runs:      make(map[string]func(ctx context.Context) error),
+	}
+	cfg := DefaultConfig()
+	job := NewJob(new(MockStore), streams, nil, nil, queue, 2*time.Hour,
+		cfg, utils.NewMockClock(), slog.Default())
+
+	job.RegisterJobs()
+	require.Equal(t, cfg.Interval, queue.intervals[jobReconcile])
+
+	runs := utils.MetricValue(metrics, metricRuns)
+	require.Error(t, queue.runs[jobReconcile](ctx))
+	require.Equal(t, runs+1, utils.MetricValue(metrics, metricRuns))
+
+	// A zero interval disables the job.
+	delete(queue.intervals, jobReconcile)
+	cfg.Interval = 0
+	job.RegisterJobs()
+	require.NotContains(t, queue.intervals, jobReconcile)
+}
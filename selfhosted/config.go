@@ -0,0 +1,28 @@
+package selfhosted
+
+import "time"
+
+// DefaultConfig returns all default values for the Config struct.
+func DefaultConfig() *Config {
+	return &Config{
+		BaseURL:       "http://localhost:8080",
+		UploadPath:    "uploads",
+		MaxUploadSize: 5 << 30, // 5 GiB
+		UploadExpiry:  2 * time.Hour,
+		URLExpiry:     time.Hour,
+		JobTimeout:    2 * time.Hour,
+	}
+}
+
+type Config struct {
+	BaseURL       string        `long:"base_url" description:"Base URL of the server, used to build the upload URLs"`
+	UploadPath    string        `long:"upload_path" description:"Directory where the uploads are kept until they are processed"`
+	MaxUploadSize int64         `long:"max_upload_size" description:"Maximum size of an uploaded video in bytes"`
+	UploadExpiry  time.Duration `long:"upload_expiry" description:"Time a video upload URL is valid for"`
+	URLExpiry     time.Duration `long:"url_expiry" description:"Time the signed URLs to stream a video are valid for"`
+	SigningKey    string        `long:"signing_key" description:"Key signing the upload URLs. A random one is used if empty, invalidating the URLs on restart"`
+	FFmpegPath    string        `long:"ffmpeg_path" description:"Path of the ffmpeg binary used to transcode the uploads to H.264 MP4. If empty, only MP4 uploads are accepted and served as they are"`
+	JobTimeout    time.Duration `long:"job_timeout" description:"Time an attempt to download or process a video has before it is retried"`
+
+	ImportAllowPrivate bool `long:"import_allow_private" description:"Allow importing videos from loopback and private network addresses"`
+}
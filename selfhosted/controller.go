@@ -0,0 +1,98 @@
+package selfhosted
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uploadFormField is the form field of the video file in multipart uploads,
+// the same Cloudflare Stream direct uploads use.
+const uploadFormField = "file"
+
+type Controller struct {
+	service *Service
+
+	logger *slog.Logger
+}
+
+// NewController creates the controller receiving the uploads.
+func NewController(service *Service, logger *slog.Logger) *Controller {
+	return &Controller{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (c *Controller) RegisterPublicRoutes(router *gin.Engine) {
+	router.POST("/selfhosted/upload/:id", c.Upload)
+}
+
+// Upload receives the video file, either as the file field of a multipart
+// form or as the raw body. The request is authorized by the signature of
+// the upload URL.
+func (c *Controller) Upload(gCtx *gin.Context) {
+	externalID := gCtx.Param("id")
+
+	body, err := uploadBody(gCtx.Request)
+	if err != nil {
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = c.service.Upload(gCtx.Request.Context(), externalID,
+		gCtx.Query("expires"), gCtx.Query("signature"), body)
+	switch {
+	case err == nil:
+		gCtx.JSON(http.StatusOK, gin.H{"video_id": externalID})
+
+	case errors.Is(err, ErrInvalidSignature):
+		gCtx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+
+	case errors.Is(err, ErrNotFound):
+		gCtx.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+
+	case errors.Is(err, ErrStatusChanged):
+		gCtx.JSON(http.StatusConflict, gin.H{"error": "Video already uploaded"})
+
+	case errors.Is(err, ErrUploadTooLarge):
+		gCtx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+
+	default:
+		c.logger.Error("Failed to upload video", "externalID", externalID,
+			"error", err)
+		gCtx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload video"})
+	}
+}
+
+// uploadBody returns the reader of the uploaded file, streaming multipart
+// forms instead of buffering them.
+func uploadBody(req *http.Request) (io.Reader, error) {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return req.Body, nil
+	}
+
+	reader, err := req.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, errors.New("missing file field")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if part.FormName() == uploadFormField {
+			return part, nil
+		}
+	}
+}
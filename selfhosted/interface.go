@@ -0,0 +1,81 @@
+package selfhosted
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/fewsats/blockbuster/jobs"
+	"github.com/fewsats/blockbuster/video"
+)
+
+// The statuses of the videos match the states of Cloudflare Stream, so the
+// video manager handles both backends alike.
+const (
+	StatusPendingUpload = "pendingupload"
+	StatusProcessing    = "inprogress"
+	StatusReady         = "ready"
+	StatusError         = "error"
+)
+
+// Video is a video uploaded to the self-hosted backend.
+type Video struct {
+	ExternalID      string
+	Status          string
+	StatusReason    string
+	SizeInBytes     int64
+	UploadExpiresAt time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+type Store interface {
+	// CreateSelfHostedVideo stores a new video pending its upload.
+	CreateSelfHostedVideo(ctx context.Context, video *Video) error
+
+	// GetSelfHostedVideo returns the video with the given external ID or
+	// ErrNotFound.
+	GetSelfHostedVideo(ctx context.Context, externalID string) (*Video,
+		error)
+
+	// ListSelfHostedVideos returns every video.
+	ListSelfHostedVideos(ctx context.Context) ([]*Video, error)
+
+	// DeleteSelfHostedVideo removes the video.
+	DeleteSelfHostedVideo(ctx context.Context, externalID string) error
+
+	// UpdateSelfHostedVideoStatus moves the video from the current status
+	// to the given one, returning ErrStatusChanged if it is no longer in
+	// the current status.
+	UpdateSelfHostedVideoStatus(ctx context.Context, externalID,
+		currentStatus, status, reason string, sizeInBytes int64) error
+}
+
+// Storage stores the video files in a private bucket.
+type Storage interface {
+	// Put uploads the file under the given key.
+	Put(ctx context.Context, key string, body io.ReadSeeker,
+		contentType string) error
+
+	// SignedURL returns a URL to download the file until it expires.
+	SignedURL(ctx context.Context, key string,
+		expiry time.Duration) (string, error)
+
+	// Delete removes the file.
+	Delete(ctx context.Context, key string) error
+}
+
+// Queue runs the processing of the uploads in the background.
+type Queue interface {
+	RegisterWithOptions(kind string, handler jobs.Handler,
+		opts jobs.Options)
+	Enqueue(ctx context.Context, kind string, payload any) (*jobs.Job, error)
+}
+
+// VideoManager is notified when the processing of a video finishes.
+type VideoManager interface {
+	// RefreshVideoStatus updates the video with its status in the
+	// backend.
+	RefreshVideoStatus(ctx context.Context,
+		externalID string) (*video.Video, error)
+}
@@ -0,0 +1,639 @@
+package selfhosted
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/fewsats/blockbuster/jobs"
+	"github.com/fewsats/blockbuster/utils"
+	"github.com/fewsats/blockbuster/video"
+)
+
+const (
+	// processJobKind is the kind of the jobs processing the uploads.
+	processJobKind = "selfhosted.process"
+
+	// importJobKind is the kind of the jobs downloading the imported
+	// videos.
+	importJobKind = "selfhosted.import"
+
+	// contentTypeMP4 is the only format served by the backend.
+	contentTypeMP4 = "video/mp4"
+)
+
+var (
+	// ErrNotFound is returned when a video does not exist.
+	ErrNotFound = errors.New("video not found")
+
+	// ErrStatusChanged is returned when a video is no longer in the
+	// expected status, e.g. it was uploaded twice.
+	ErrStatusChanged = errors.New("video status changed")
+
+	// ErrInvalidSignature is returned for tampered or expired upload URLs.
+	ErrInvalidSignature = errors.New("invalid or expired signature")
+
+	// ErrUploadTooLarge is returned for uploads over the maximum size.
+	ErrUploadTooLarge = errors.New("upload too large")
+
+	// ErrPrivateAddress is returned when importing a video from a
+	// loopback or private network address, unless allowed.
+	ErrPrivateAddress = utils.ErrPrivateAddress
+
+	// ErrWebhooksUnsupported is returned when verifying a Stream webhook,
+	// as the backend updates the videos itself.
+	ErrWebhooksUnsupported = errors.New("webhooks are not supported by " +
+		"the self-hosted backend")
+)
+
+// processPayload is the payload of the jobs processing the uploads.
+type processPayload struct {
+	ExternalID string `json:"external_id"`
+}
+
+// importPayload is the payload of the jobs downloading the imported videos.
+type importPayload struct {
+	ExternalID string `json:"external_id"`
+	SourceURL  string `json:"source_url"`
+}
+
+// Service is a streaming backend that hosts the videos itself: uploads are
+// received by the server, optionally transcoded with ffmpeg and kept in the
+// storage, from where they are streamed through short-lived signed URLs. It
+// implements the Stream API used by the video manager.
+type Service struct {
+	cfg        *Config
+	store      Store
+	storage    Storage
+	queue      Queue
+	videos     VideoManager
+	signingKey []byte
+
+	// client downloads the imported videos.
+	client *http.Client
+
+	clock  utils.Clock
+	logger *slog.Logger
+}
+
+// NewService creates a new self-hosted streaming backend.
+func NewService(cfg *Config, store Store, storage Storage, queue Queue,
+	clock utils.Clock, logger *slog.Logger) (*Service, error) {
+
+	if err := os.MkdirAll(cfg.UploadPath, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+
+	signingKey := []byte(cfg.SigningKey)
+	if len(signingKey) == 0 {
+		logger.Warn("No self-hosted signing key configured, upload URLs " +
+			"will not survive a restart")
+
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			return nil, fmt.Errorf("failed to generate signing key: %w",
+				err)
+		}
+	}
+
+	return &Service{
+		cfg:        cfg,
+		store:      store,
+		storage:    storage,
+		queue:      queue,
+		signingKey: signingKey,
+		client:     utils.NewPublicHTTPClient(cfg.ImportAllowPrivate),
+		clock:      clock,
+		logger:     logger,
+	}, nil
+}
+
+// RegisterJobs registers the processing of the uploads in the queue. The
+// video manager is notified once a video is processed. The jobs can run for
+// the configured job timeout, and their videos are marked as failed once
+// they run out of attempts.
+func (s *Service) RegisterJobs(videos VideoManager) {
+	s.videos = videos
+
+	opts := jobs.Options{
+		Timeout: s.cfg.JobTimeout,
+		OnDead:  s.failDeadJob,
+	}
+	s.queue.RegisterWithOptions(processJobKind, s.processUpload, opts)
+	s.queue.RegisterWithOptions(importJobKind, s.importVideo, opts)
+}
+
+// GenerateVideoUploadURL creates a video pending its upload and returns the
+// signed URL to upload it to. The duration of the videos is not limited, only
+// their size, and the uploads are never resumable.
+func (s *Service) GenerateVideoUploadURL(ctx context.Context, _ int64,
+	_ *video.UploadOptions) (*video.Upload, error) {
+
+	externalID, err := newExternalID()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := s.clock.Now().Add(s.cfg.UploadExpiry)
+	err = s.store.CreateSelfHostedVideo(ctx, &Video{
+		ExternalID:      externalID,
+		Status:          StatusPendingUpload,
+		UploadExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create video: %w", err)
+	}
+
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", s.sign(externalID, expires))
+
+	uploadURL := fmt.Sprintf("%s/selfhosted/upload/%s?%s", s.cfg.BaseURL,
+		externalID, query.Encode())
+
+	return &video.Upload{
+		ExternalID: externalID,
+		URL:        uploadURL,
+	}, nil
+}
+
+// ImportVideo creates a video processing from the start and queues the
+// download of its file from the source URL, which is then processed like the
+// uploads.
+func (s *Service) ImportVideo(ctx context.Context, _ int64, sourceURL,
+	_ string) (string, error) {
+
+	externalID, err := newExternalID()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.store.CreateSelfHostedVideo(ctx, &Video{
+		ExternalID:      externalID,
+		Status:          StatusProcessing,
+		UploadExpiresAt: s.clock.Now(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create video: %w", err)
+	}
+
+	_, err = s.queue.Enqueue(ctx, importJobKind, &importPayload{
+		ExternalID: externalID,
+		SourceURL:  sourceURL,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to queue import: %w", err)
+	}
+
+	return externalID, nil
+}
+
+// GetStreamVideoInfo returns the video in the format of Cloudflare Stream.
+func (s *Service) GetStreamVideoInfo(ctx context.Context,
+	externalID string) (*cloudflare.StreamVideo, error) {
+
+	v, err := s.store.GetSelfHostedVideo(ctx, externalID)
+	if err != nil {
+		return nil, err
+	}
+
+	return streamVideo(v), nil
+}
+
+// ListStreamVideos returns every video in the format of Cloudflare Stream.
+func (s *Service) ListStreamVideos(
+	ctx context.Context) ([]cloudflare.StreamVideo, error) {
+
+	videos, err := s.store.ListSelfHostedVideos(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]cloudflare.StreamVideo, 0, len(videos))
+	for _, v := range videos {
+		result = append(result, *streamVideo(v))
+	}
+
+	return result, nil
+}
+
+// streamVideo converts a video to the format of Cloudflare Stream.
+func streamVideo(v *Video) *cloudflare.StreamVideo {
+	return &cloudflare.StreamVideo{
+		UID:           v.ExternalID,
+		Created:       &v.CreatedAt,
+		ReadyToStream: v.Status == StatusReady,
+		Size:          int(v.SizeInBytes),
+		UploadExpiry:  &v.UploadExpiresAt,
+		Status: cloudflare.StreamVideoStatus{
+			State:           v.Status,
+			ErrorReasonText: v.StatusReason,
+		},
+	}
+}
+
+// GenerateStreamURL returns a short-lived signed URL of the video file, that
+// expires no later than the credentials paying for it. The storage serves it
+// with range requests so players can seek.
+func (s *Service) GenerateStreamURL(ctx context.Context, externalID string,
+	opts *video.StreamURLOptions) (*video.StreamURLs, error) {
+
+	expiry := s.cfg.URLExpiry
+	if opts.ExpiresAt != nil && opts.ExpiresAt.Sub(s.clock.Now()) < expiry {
+		expiry = opts.ExpiresAt.Sub(s.clock.Now())
+	}
+
+	mp4URL, err := s.storage.SignedURL(ctx, videoKey(externalID), expiry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign video URL: %w", err)
+	}
+
+	return &video.StreamURLs{MP4URL: mp4URL}, nil
+}
+
+// VerifyWebhookSignature rejects every webhook, they are only sent by
+// Cloudflare Stream.
+func (s *Service) VerifyWebhookSignature(http.Header, []byte) error {
+	return ErrWebhooksUnsupported
+}
+
+// MaxDuration returns zero, the self-hosted videos are not limited by
+// duration.
+func (s *Service) MaxDuration(int64) time.Duration {
+	return 0
+}
+
+// DeleteVideo deletes the file of the video and its pending upload, if any,
+// and then the video itself.
+func (s *Service) DeleteVideo(ctx context.Context, externalID string) error {
+	err := os.Remove(s.uploadPath(externalID))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete upload: %w", err)
+	}
+
+	err = s.storage.Delete(ctx, videoKey(externalID))
+	if err != nil {
+		return fmt.Errorf("failed to delete video file: %w", err)
+	}
+
+	return s.store.DeleteSelfHostedVideo(ctx, externalID)
+}
+
+// Upload receives the file of a video pending its upload and queues its
+// processing. The upload is limited to the configured maximum size.
+func (s *Service) Upload(ctx context.Context, externalID, expires,
+	signature string, body io.Reader) error {
+
+	if err := s.verify(externalID, expires, signature); err != nil {
+		return err
+	}
+
+	v, err := s.store.GetSelfHostedVideo(ctx, externalID)
+	if err != nil {
+		return err
+	}
+	if v.Status != StatusPendingUpload {
+		return ErrStatusChanged
+	}
+
+	// The file is written aside and only moved in place once the upload
+	// claimed the video, so a concurrent upload of the same video can't
+	// overwrite or remove it.
+	tempPath, written, err := s.writeUpload(externalID, body)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.UpdateSelfHostedVideoStatus(ctx, externalID,
+		StatusPendingUpload, StatusProcessing, "", written)
+	if err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	if err := os.Rename(tempPath, s.uploadPath(externalID)); err != nil {
+		os.Remove(tempPath)
+		s.fail(ctx, externalID, "failed to store upload")
+
+		return fmt.Errorf("failed to store upload: %w", err)
+	}
+
+	_, err = s.queue.Enqueue(ctx, processJobKind, &processPayload{
+		ExternalID: externalID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to queue processing: %w", err)
+	}
+
+	return nil
+}
+
+// writeUpload writes the file of a video to a new temporary file next to its
+// upload, up to the configured maximum size, and returns its path.
+func (s *Service) writeUpload(externalID string, body io.Reader) (string,
+	int64, error) {
+
+	file, err := os.CreateTemp(s.cfg.UploadPath, externalID+".*.tmp")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create upload: %w", err)
+	}
+	defer file.Close()
+
+	// Read one byte over the limit to tell a file of the maximum size
+	// from a larger one.
+	limited := io.LimitReader(body, s.cfg.MaxUploadSize+1)
+	written, err := io.Copy(file, limited)
+	if err == nil && written > s.cfg.MaxUploadSize {
+		err = ErrUploadTooLarge
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", 0, err
+	}
+
+	return file.Name(), written, nil
+}
+
+// importVideo downloads the file of an imported video and processes it.
+// Videos whose file can't be downloaded are marked as failed.
+func (s *Service) importVideo(ctx context.Context, job *jobs.Job) error {
+	var payload importPayload
+	if err := job.Decode(&payload); err != nil {
+		return fmt.Errorf("failed to decode payload: %w", err)
+	}
+
+	err := s.download(ctx, payload.ExternalID, payload.SourceURL)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+
+		s.logger.Warn("Failed to download imported video",
+			"externalID", payload.ExternalID, "error", err)
+
+		return s.fail(ctx, payload.ExternalID,
+			fmt.Sprintf("failed to download video: %v", err))
+	}
+
+	return s.process(ctx, payload.ExternalID)
+}
+
+// download writes the file at the source URL to the upload of the video.
+func (s *Service) download(ctx context.Context, externalID,
+	sourceURL string) error {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sourceURL,
+		nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if resp.ContentLength > s.cfg.MaxUploadSize {
+		return ErrUploadTooLarge
+	}
+
+	tempPath, _, err := s.writeUpload(externalID, resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tempPath, s.uploadPath(externalID)); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	return nil
+}
+
+// processUpload processes an uploaded video.
+func (s *Service) processUpload(ctx context.Context, job *jobs.Job) error {
+	var payload processPayload
+	if err := job.Decode(&payload); err != nil {
+		return fmt.Errorf("failed to decode payload: %w", err)
+	}
+
+	return s.process(ctx, payload.ExternalID)
+}
+
+// process transcodes the upload when ffmpeg is configured, or checks it is
+// an MP4 file otherwise, and moves it to the storage. Uploads that can't be
+// processed mark their video as failed.
+func (s *Service) process(ctx context.Context, externalID string) error {
+	source := s.uploadPath(externalID)
+	if s.cfg.FFmpegPath != "" {
+		transcoded, err := s.transcode(ctx, source)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+
+			s.logger.Warn("Failed to transcode video",
+				"externalID", externalID, "error", err)
+
+			return s.fail(ctx, externalID, "failed to transcode video")
+		}
+		defer os.Remove(transcoded)
+
+		source = transcoded
+	}
+
+	file, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("failed to open upload: %w", err)
+	}
+	defer file.Close()
+
+	isMP4, err := sniffMP4(file)
+	if err != nil {
+		return err
+	}
+	if !isMP4 {
+		return s.fail(ctx, externalID, "unsupported format, only MP4 "+
+			"videos are accepted")
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat upload: %w", err)
+	}
+
+	err = s.storage.Put(ctx, videoKey(externalID), file, contentTypeMP4)
+	if err != nil {
+		return fmt.Errorf("failed to store video: %w", err)
+	}
+
+	err = s.store.UpdateSelfHostedVideoStatus(ctx, externalID,
+		StatusProcessing, StatusReady, "", info.Size())
+	if err != nil && !errors.Is(err, ErrStatusChanged) {
+		return fmt.Errorf("failed to update video: %w", err)
+	}
+
+	os.Remove(s.uploadPath(externalID))
+	s.notify(ctx, externalID)
+
+	return nil
+}
+
+// fail marks a video as failed with the given reason and removes its
+// upload.
+func (s *Service) fail(ctx context.Context, externalID,
+	reason string) error {
+
+	err := s.store.UpdateSelfHostedVideoStatus(ctx, externalID,
+		StatusProcessing, StatusError, reason, 0)
+	if err != nil && !errors.Is(err, ErrStatusChanged) {
+		return fmt.Errorf("failed to update video: %w", err)
+	}
+
+	os.Remove(s.uploadPath(externalID))
+	s.notify(ctx, externalID)
+
+	return nil
+}
+
+// failDeadJob marks the video of a processing or import job that ran out of
+// attempts as failed, so it is not left processing forever.
+func (s *Service) failDeadJob(ctx context.Context, job *jobs.Job,
+	jobErr error) {
+
+	// The payloads of both kinds identify the video the same way.
+	var payload processPayload
+	if err := job.Decode(&payload); err != nil {
+		s.logger.Error("Failed to decode dead job", "jobID", job.ID,
+			"error", err)
+		return
+	}
+
+	s.logger.Error("Giving up on video", "externalID", payload.ExternalID,
+		"kind", job.Kind, "error", jobErr)
+
+	err := s.fail(ctx, payload.ExternalID, "failed to process video")
+	if err != nil {
+		s.logger.Error("Failed to mark video as failed",
+			"externalID", payload.ExternalID, "error", err)
+	}
+}
+
+// notify lets the video manager pick up the new status of the video.
+func (s *Service) notify(ctx context.Context, externalID string) {
+	if s.videos == nil {
+		return
+	}
+
+	_, err := s.videos.RefreshVideoStatus(ctx, externalID)
+	if err != nil {
+		s.logger.Error("Failed to refresh video status",
+			"externalID", externalID, "error", err)
+	}
+}
+
+// transcode converts the upload to an H.264/AAC MP4 file with its index at
+// the start, so it can be played while downloading.
+func (s *Service) transcode(ctx context.Context, source string) (string,
+	error) {
+
+	target := source + ".mp4"
+	cmd := exec.CommandContext(ctx, s.cfg.FFmpegPath,
+		"-y", "-v", "error", "-i", source,
+		"-c:v", "libx264", "-preset", "veryfast", "-c:a", "aac",
+		"-movflags", "+faststart", "-f", "mp4", target,
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		os.Remove(target)
+		return "", fmt.Errorf("%w: %s", err, output)
+	}
+
+	return target, nil
+}
+
+// verify checks the expiration time and the signature of an upload URL.
+func (s *Service) verify(externalID, expires, signature string) error {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || s.clock.Now().Unix() > unix {
+		return ErrInvalidSignature
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	actual, _ := hex.DecodeString(s.sign(externalID, expires))
+	if !hmac.Equal(expected, actual) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// sign returns the hex encoded HMAC-SHA256 of the upload of a video.
+func (s *Service) sign(externalID, expires string) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	fmt.Fprintf(mac, "upload/%s:%s", externalID, expires)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newExternalID returns a random external ID for a new video.
+func newExternalID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate video ID: %w", err)
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+// uploadPath returns where the upload of a video is kept until processed.
+func (s *Service) uploadPath(externalID string) string {
+	return filepath.Join(s.cfg.UploadPath, externalID+".upload")
+}
+
+// videoKey returns the storage key of the file of a video.
+func videoKey(externalID string) string {
+	return fmt.Sprintf("videos/%s.mp4", externalID)
+}
+
+// sniffMP4 reports whether the file is an MP4 video, leaving it rewound.
+func sniffMP4(file io.ReadSeeker) (bool, error) {
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) &&
+		!errors.Is(err, io.EOF) {
+
+		return false, fmt.Errorf("failed to read upload: %w", err)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return false, fmt.Errorf("failed to rewind upload: %w", err)
+	}
+
+	return http.DetectContentType(header[:n]) == contentTypeMP4, nil
+}
@@ -0,0 +1,379 @@
+package selfhosted
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/fewsats/blockbuster/jobs"
+	"github.com/fewsats/blockbuster/utils"
+	"github.com/stretchr/testify/require"
+)
+
+// testMP4 starts with the ftyp box of an MP4 file.
+var testMP4 = append([]byte{
+	0x00, 0x00, 0x00, 0x18, 'f', 't', 'y', 'p', 'm', 'p', '4', '2',
+	0x00, 0x00, 0x00, 0x00, 'm', 'p', '4', '2', 'i', 's', 'o', 'm',
+}, []byte("video data")...)
+
+// memoryStore is an in-memory Store for the service tests.
+type memoryStore struct {
+	videos map[string]*Video
+}
+
+func (m *memoryStore) CreateSelfHostedVideo(_ context.Context,
+	v *Video) error {
+
+	copied := *v
+	m.videos[v.ExternalID] = &copied
+
+	return nil
+}
+
+func (m *memoryStore) GetSelfHostedVideo(_ context.Context,
+	externalID string) (*Video, error) {
+
+	v, ok := m.videos[externalID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	copied := *v
+	return &copied, nil
+}
+
+func (m *memoryStore) ListSelfHostedVideos(
+	context.Context) ([]*Video, error) {
+
+	var videos []*Video
+	for _, v := range m.videos {
+		videos = append(videos, v)
+	}
+
+	return videos, nil
+}
+
+func (m *memoryStore) DeleteSelfHostedVideo(_ context.Context,
+	externalID string) error {
+
+	delete(m.videos, externalID)
+	return nil
+}
+
+func (m *memoryStore) UpdateSelfHostedVideoStatus(_ context.Context,
+	externalID, currentStatus, status, reason string,
+	sizeInBytes int64) error {
+
+	v, ok := m.videos[externalID]
+	if !ok {
+		return ErrNotFound
+	}
+	if v.Status != currentStatus {
+		return ErrStatusChanged
+	}
+
+	v.Status = status
+	v.StatusReason = reason
+	if sizeInBytes != 0 {
+		v.SizeInBytes = sizeInBytes
+	}
+
+	return nil
+}
+
+// memoryStorage keeps the video files in memory.
+type memoryStorage struct {
+	files map[string][]byte
+
+	// err is returned by Put when set.
+	err error
+}
+
+func (m *memoryStorage) Put(_ context.Context, key string,
+	body io.ReadSeeker, _ string) error {
+
+	if m.err != nil {
+		return m.err
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	m.files[key] = data
+
+	return nil
+}
+
+func (m *memoryStorage) SignedURL(_ context.Context, key string,
+	_ time.Duration) (string, error) {
+
+	return "https://storage.example.com/" + key, nil
+}
+
+func (m *memoryStorage) Delete(_ context.Context, key string) error {
+	delete(m.files, key)
+	return nil
+}
+
+// memoryQueue records the registered kinds and the enqueued jobs.
+type memoryQueue struct {
+	opts map[string]jobs.Options
+	jobs []*jobs.Job
+}
+
+func (m *memoryQueue) RegisterWithOptions(kind string, _ jobs.Handler,
+	opts jobs.Options) {
+
+	m.opts[kind] = opts
+}
+
+func (m *memoryQueue) Enqueue(_ context.Context, kind string,
+	payload any) (*jobs.Job, error) {
+
+	job := &jobs.Job{ID: int64(len(m.jobs) + 1), Kind: kind}
+	m.jobs = append(m.jobs, job)
+
+	return job, nil
+}
+
+// newTestService returns a service backed by in-memory fakes.
+func newTestService(t *testing.T) (*Service, *memoryStore, *memoryStorage,
+	*utils.MockClock) {
+
+	cfg := DefaultConfig()
+	cfg.UploadPath = t.TempDir()
+	cfg.SigningKey = "upload-secret"
+
+	store := &memoryStore{videos: make(map[string]*Video)}
+	storage := &memoryStorage{files: make(map[string][]byte)}
+	queue := &memoryQueue{opts: make(map[string]jobs.Options)}
+
+	clock := utils.NewMockClock()
+	clock.SetMockClockTime(time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC))
+
+	service, err := NewService(cfg, store, storage, queue, clock,
+		slog.Default())
+	require.NoError(t, err)
+	service.RegisterJobs(nil)
+
+	return service, store, storage, clock
+}
+
+// createUpload stores a video processing with the given uploaded file.
+func createUpload(t *testing.T, s *Service, store *memoryStore,
+	externalID string, data []byte) {
+
+	store.videos[externalID] = &Video{
+		ExternalID: externalID,
+		Status:     StatusProcessing,
+	}
+
+	tempPath, _, err := s.writeUpload(externalID, bytes.NewReader(data))
+	require.NoError(t, err)
+	require.NoError(t, os.Rename(tempPath, s.uploadPath(externalID)))
+}
+
+// hookReader runs its hook before the first read of its data.
+type hookReader struct {
+	io.Reader
+	hook func()
+}
+
+func (r *hookReader) Read(p []byte) (int, error) {
+	if r.hook != nil {
+		r.hook()
+		r.hook = nil
+	}
+
+	return r.Reader.Read(p)
+}
+
+// TestUploadSignature tests that the upload URLs are only valid for their
+// video and until they expire.
+func TestUploadSignature(t *testing.T) {
+	s, _, _, clock := newTestService(t)
+
+	expires := strconv.FormatInt(clock.Now().Add(time.Hour).Unix(), 10)
+	signature := s.sign("video", expires)
+
+	require.NoError(t, s.verify("video", expires, signature))
+
+	// The signature is bound to the video and the expiration time.
+	require.ErrorIs(t, s.verify("other", expires, signature),
+		ErrInvalidSignature)
+
+	later := strconv.FormatInt(clock.Now().Add(2*time.Hour).Unix(), 10)
+	require.ErrorIs(t, s.verify("video", later, signature),
+		ErrInvalidSignature)
+
+	require.ErrorIs(t, s.verify("video", expires, "not hex"),
+		ErrInvalidSignature)
+	require.ErrorIs(t, s.verify("video", "never", signature),
+		ErrInvalidSignature)
+
+	// Nor can it be used with another signing key.
+	other, _, _, _ := newTestService(t)
+	other.signingKey = []byte("other-secret")
+	require.ErrorIs(t, other.verify("video", expires, signature),
+		ErrInvalidSignature)
+
+	// It stops working once expired.
+	clock.SetMockClockTime(clock.Now().Add(time.Hour + time.Second))
+	require.ErrorIs(t, s.verify("video", expires, signature),
+		ErrInvalidSignature)
+}
+
+// TestUploadRace tests that an upload losing the video to a concurrent one
+// leaves the file of the winner in place.
+func TestUploadRace(t *testing.T) {
+	ctx := context.Background()
+	s, store, _, clock := newTestService(t)
+
+	store.videos["video"] = &Video{
+		ExternalID: "video",
+		Status:     StatusPendingUpload,
+	}
+	expires := strconv.FormatInt(clock.Now().Add(time.Hour).Unix(), 10)
+	signature := s.sign("video", expires)
+
+	// The other upload claims the video while this one is being written.
+	body := &hookReader{
+		Reader: bytes.NewReader([]byte("loser")),
+		hook: func() {
+			err := s.Upload(ctx, "video", expires, signature,
+				bytes.NewReader(testMP4))
+			require.NoError(t, err)
+		},
+	}
+	err := s.Upload(ctx, "video", expires, signature, body)
+	require.ErrorIs(t, err, ErrStatusChanged)
+
+	require.Equal(t, StatusProcessing, store.videos["video"].Status)
+	require.EqualValues(t, len(testMP4), store.videos["video"].SizeInBytes)
+	data, err := os.ReadFile(s.uploadPath("video"))
+	require.NoError(t, err)
+	require.Equal(t, testMP4, data)
+	require.Len(t, s.queue.(*memoryQueue).jobs, 1)
+
+	// No temporary file is left behind.
+	entries, err := os.ReadDir(s.cfg.UploadPath)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+// TestSniffMP4 tests that MP4 files are told from other content, leaving the
+// file rewound.
+func TestSniffMP4(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		data  []byte
+		isMP4 bool
+	}{
+		{name: "mp4", data: testMP4, isMP4: true},
+		{name: "text", data: []byte("not a video")},
+		{name: "webm", data: []byte{0x1a, 0x45, 0xdf, 0xa3, 0x01}},
+		{name: "empty", data: nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			file := bytes.NewReader(tc.data)
+
+			isMP4, err := sniffMP4(file)
+			require.NoError(t, err)
+			require.Equal(t, tc.isMP4, isMP4)
+
+			rest, err := io.ReadAll(file)
+			require.NoError(t, err)
+			require.Equal(t, len(tc.data), len(rest))
+		})
+	}
+}
+
+// TestProcess tests that MP4 uploads are moved to the storage and other
+// files fail their video, while storage errors are left to be retried.
+func TestProcess(t *testing.T) {
+	ctx := context.Background()
+	s, store, storage, _ := newTestService(t)
+
+	createUpload(t, s, store, "mp4", testMP4)
+	require.NoError(t, s.process(ctx, "mp4"))
+	require.Equal(t, StatusReady, store.videos["mp4"].Status)
+	require.EqualValues(t, len(testMP4), store.videos["mp4"].SizeInBytes)
+	require.Equal(t, testMP4, storage.files[videoKey("mp4")])
+	require.NoFileExists(t, s.uploadPath("mp4"))
+
+	createUpload(t, s, store, "text", []byte("not a video"))
+	require.NoError(t, s.process(ctx, "text"))
+	require.Equal(t, StatusError, store.videos["text"].Status)
+	require.Contains(t, store.videos["text"].StatusReason, "MP4")
+	require.NotContains(t, storage.files, videoKey("text"))
+	require.NoFileExists(t, s.uploadPath("text"))
+
+	// Storage failures keep the upload so the job can be retried.
+	storage.err = errors.New("storage unavailable")
+	createUpload(t, s, store, "retried", testMP4)
+	require.ErrorIs(t, s.process(ctx, "retried"), storage.err)
+	require.Equal(t, StatusProcessing, store.videos["retried"].Status)
+	require.FileExists(t, s.uploadPath("retried"))
+}
+
+// TestProcessTranscodeFailure tests that uploads ffmpeg can't transcode fail
+// their video.
+func TestProcessTranscodeFailure(t *testing.T) {
+	ffmpeg, err := os.CreateTemp(t.TempDir(), "ffmpeg")
+	require.NoError(t, err)
+	_, err = ffmpeg.WriteString("#!/bin/sh\nexit 1\n")
+	require.NoError(t, err)
+	require.NoError(t, ffmpeg.Close())
+	require.NoError(t, os.Chmod(ffmpeg.Name(), 0o755))
+
+	ctx := context.Background()
+	s, store, storage, _ := newTestService(t)
+	s.cfg.FFmpegPath = ffmpeg.Name()
+
+	createUpload(t, s, store, "video", testMP4)
+	require.NoError(t, s.process(ctx, "video"))
+	require.Equal(t, StatusError, store.videos["video"].Status)
+	require.Equal(t, "failed to transcode video",
+		store.videos["video"].StatusReason)
+	require.Empty(t, storage.files)
+	require.NoFileExists(t, s.uploadPath("video"))
+}
+
+// TestDeadJobs tests that the jobs run for the configured timeout and that
+// the videos of the jobs that run out of attempts are marked as failed.
+func TestDeadJobs(t *testing.T) {
+	ctx := context.Background()
+	s, store, _, _ := newTestService(t)
+	queue := s.queue.(*memoryQueue)
+
+	for _, kind := range []string{processJobKind, importJobKind} {
+		require.Equal(t, s.cfg.JobTimeout, queue.opts[kind].Timeout)
+		require.NotNil(t, queue.opts[kind].OnDead)
+	}
+
+	createUpload(t, s, store, "video", testMP4)
+	queue.opts[importJobKind].OnDead(ctx, &jobs.Job{
+		Kind:    importJobKind,
+		Payload: `{"external_id":"video","source_url":"https://example.com"}`,
+	}, context.DeadlineExceeded)
+
+	require.Equal(t, StatusError, store.videos["video"].Status)
+	require.Equal(t, "failed to process video",
+		store.videos["video"].StatusReason)
+	require.NoFileExists(t, s.uploadPath("video"))
+
+	// Videos that finished meanwhile are left alone.
+	store.videos["ready"] = &Video{ExternalID: "ready", Status: StatusReady}
+	queue.opts[processJobKind].OnDead(ctx, &jobs.Job{
+		Kind:    processJobKind,
+		Payload: `{"external_id":"ready"}`,
+	}, errors.New("failed"))
+	require.Equal(t, StatusReady, store.videos["ready"].Status)
+}
@@ -0,0 +1,79 @@
+document.addEventListener('DOMContentLoaded', () => {
+    initLibrary();
+});
+
+// signRequest signs the "domain:action:timestamp" message with the Nostr
+// extension of the browser, as expected by the signed endpoints.
+async function signRequest(action) {
+    if (!window.nostr || !window.nostr.signSchnorr) {
+        throw new Error('A Nostr extension able to sign messages is required');
+    }
+
+    const pubKey = await window.nostr.getPublicKey();
+    const domain = window.location.host;
+    const timestamp = Math.floor(Date.now() / 1000);
+
+    const message = new TextEncoder().encode(`${domain}:${action}:${timestamp}`);
+    const digest = await crypto.subtle.digest('SHA-256', message);
+    const hash = Array.from(new Uint8Array(digest))
+        .map((b) => b.toString(16).padStart(2, '0'))
+        .join('');
+    const signature = await window.nostr.signSchnorr(hash);
+
+    return { pubKey, domain, timestamp, signature };
+}
+
+function displayLibrary(videos) {
+    const libraryList = document.getElementById('libraryList');
+    if (!Array.isArray(videos) || videos.length === 0) {
+        libraryList.innerHTML = '<p class="text-gray-500">You haven\'t purchased any videos yet.</p>';
+        return;
+    }
+
+    libraryList.innerHTML = videos.map((video) => `
+        <div class="bg-gray-100 rounded-lg shadow-md overflow-hidden">
+            <img src="${video.cover_variants ? video.cover_variants.card_url : video.cover_url}" alt="${video.title}" class="w-full h-40 object-cover">
+            <div class="p-4">
+                <h4 class="text-lg font-semibold">${video.title}</h4>
+                <p class="text-xs text-gray-500">${video.service_type}${video.expiration_date ? ` · until ${new Date(video.expiration_date).toLocaleString()}` : ''}</p>
+                <div class="flex justify-between items-center mt-4">
+                    <a href="/video/${video.external_id}" class="text-indigo-600 hover:text-indigo-800 font-semibold">Watch</a>
+                    <button onclick="navigator.clipboard.writeText('${video.l402_url}')" class="text-sm text-gray-500 hover:text-indigo-600">Copy stream URL</button>
+                </div>
+            </div>
+        </div>
+    `).join('');
+}
+
+async function loadLibrary() {
+    try {
+        const { pubKey, domain, timestamp, signature } = await signRequest('library');
+        const response = await fetch('/library', {
+            headers: {
+                'X-Pub-Key': pubKey,
+                'X-Domain': domain,
+                'X-Timestamp': timestamp,
+                'X-Signature': signature,
+            },
+        });
+        const r = await response.json();
+        if (!response.ok) {
+            throw new Error(r.error || 'Failed to load library');
+        }
+
+        document.getElementById('libraryHint').classList.add('hidden');
+        displayLibrary(r.videos);
+    } catch (error) {
+        console.error('Error loading library:', error);
+        Swal.fire({
+            icon: 'error',
+            title: 'Error',
+            text: error.message,
+        });
+    }
+}
+
+function initLibrary() {
+    document.getElementById('loadLibraryButton')
+        .addEventListener('click', loadLibrary);
+}
@@ -21,6 +21,8 @@ async function initProfile() {
     const profileForm = document.getElementById('profileForm');
     const profileEmail = document.getElementById('profileEmail');
     const lightningAddress = document.getElementById('lightningAddress');
+    const subscriptionPrice = document.getElementById('subscriptionPrice');
+    const notificationPreference = document.getElementById('notificationPreference');
 
     // Check if user is authenticated
     const { user } = await checkAuth();
@@ -32,10 +34,13 @@ async function initProfile() {
     // Populate form with user data
     profileEmail.value = user.email;
     lightningAddress.value = user.lightning_address || '';
+    subscriptionPrice.value = user.subscription_price_in_cents ? (user.subscription_price_in_cents / 100).toFixed(2) : '';
+    notificationPreference.value = user.notification_preference || 'instant';
 
     profileForm.addEventListener('submit', async (e) => {
         e.preventDefault();
         const updatedLightningAddress = lightningAddress.value;
+        const updatedSubscriptionPrice = Math.round((parseFloat(subscriptionPrice.value) || 0) * 100);
 
         try {
             const response = await fetch('/auth/profile', {
@@ -43,7 +48,11 @@ async function initProfile() {
                 headers: {
                     'Content-Type': 'application/json',
                 },
-                body: JSON.stringify({ lightningAddress: updatedLightningAddress }),
+                body: JSON.stringify({
+                    lightningAddress: updatedLightningAddress,
+                    subscriptionPriceInCents: updatedSubscriptionPrice,
+                    notificationPreference: notificationPreference.value,
+                }),
             });
 
             if (response.ok) {
@@ -19,6 +19,28 @@ function showSwalNotification(message, type = 'success') {
     });
 }
 
+// pendingUploadKey identifies the resumable upload of a file across page
+// reloads.
+function pendingUploadKey(file) {
+    return `pendingUpload:${file.name}:${file.size}:${file.lastModified}`;
+}
+
+function loadPendingUpload(file) {
+    try {
+        return JSON.parse(localStorage.getItem(pendingUploadKey(file)));
+    } catch (error) {
+        return null;
+    }
+}
+
+function savePendingUpload(file, upload) {
+    localStorage.setItem(pendingUploadKey(file), JSON.stringify(upload));
+}
+
+function clearPendingUpload(file) {
+    localStorage.removeItem(pendingUploadKey(file));
+}
+
 export function initVideoUpload() {
     const uploadForm = document.getElementById('uploadForm');
     const uploadEmailInput = document.getElementById('email');
@@ -55,12 +77,45 @@ export function initVideoUpload() {
         }
     }
 
+    // uploadResumable uploads the file in chunks to a tus upload, resuming
+    // from the offset already uploaded and retrying on network errors.
+    function uploadResumable(file, uploadURL) {
+        return new Promise((resolve, reject) => {
+            const upload = new tus.Upload(file, {
+                uploadUrl: uploadURL,
+                chunkSize: 50 * 1024 * 1024,
+                retryDelays: [0, 3000, 5000, 10000, 20000],
+                storeFingerprintForResuming: false,
+                onProgress: (bytesUploaded, bytesTotal) => {
+                    const percentage = Math.floor(bytesUploaded / bytesTotal * 100);
+                    uploadButton.querySelector('span').textContent = `Uploading... ${percentage}%`;
+                },
+                onSuccess: resolve,
+                onError: (error) => {
+                    // Expired uploads can't be resumed, a new one is
+                    // created on the next attempt.
+                    const status = error.originalResponse && error.originalResponse.getStatus();
+                    if (status === 404 || status === 410) {
+                        clearPendingUpload(file);
+                    }
+                    reject(new Error('Upload interrupted, submit the same file again to resume it'));
+                },
+            });
+            upload.start();
+        });
+    }
+
     function setUploadingState(isUploading) {
         uploadButton.disabled = isUploading;
         uploadButton.querySelector('span').textContent = isUploading ? 'Uploading...' : 'Upload Video';
         uploadSpinner.classList.toggle('hidden', !isUploading);
     }
 
+    const payWhatYouWantInput = document.getElementById('pay_what_you_want');
+    payWhatYouWantInput.addEventListener('change', () => {
+        document.getElementById('suggestedPriceField').classList.toggle('hidden', !payWhatYouWantInput.checked);
+    });
+
     uploadForm.addEventListener('submit', async (e) => {
         e.preventDefault();
 
@@ -77,63 +132,85 @@ export function initVideoUpload() {
         formData.append('title', titleInput.value);
         formData.append('description', descriptionInput.value);
         formData.append('price_in_cents', Math.round(parseFloat(document.getElementById('price_in_usd').value) * 100));
+        if (payWhatYouWantInput.checked) {
+            formData.append('pay_what_you_want', 'true');
+            const suggestedPrice = document.getElementById('suggested_price_in_usd').value;
+            if (suggestedPrice) {
+                formData.append('suggested_price_in_cents', Math.round(parseFloat(suggestedPrice) * 100));
+            }
+        }
         formData.append('email', uploadEmailInput.value);
         formData.append('cover_image', document.getElementById('coverImageInput').files[0]);
 
+        const videoFile = document.getElementById('videoInput').files[0];
+        formData.append('resumable', 'true');
+        formData.append('size_in_bytes', videoFile.size);
+
         try {
-            // Step 1: Send form with upload video request (excluding video file)
-            const response = await fetch('/video/upload', {
-                method: 'POST',
-                body: formData,
-            });
-            const data = await response.json();
-            if (response.ok) {
+            // Step 1: Resume the interrupted upload of the same file, or send
+            // the form with the upload video request (excluding video file).
+            let pending = loadPendingUpload(videoFile);
+            if (!pending) {
+                const response = await fetch('/video/upload', {
+                    method: 'POST',
+                    body: formData,
+                });
+                const data = await response.json();
+                if (!response.ok) {
+                    throw new Error(data.error || 'Failed to initiate video upload');
+                }
+
                 // Step 2: Get the signed URL (uploadURL) from the response
-                const { upload_url: uploadURL, video_id: videoId } = data;
+                pending = {
+                    uploadURL: data.upload_url,
+                    videoId: data.video_id,
+                    resumable: data.resumable,
+                };
+            }
 
-                // Step 3: Upload the video file to the signed URL
-                const videoFile = formData.get('video');
+            // Step 3: Upload the video file to the signed URL
+            if (pending.resumable) {
+                savePendingUpload(videoFile, pending);
+                await uploadResumable(videoFile, pending.uploadURL);
+            } else {
                 const uploadFormData = new FormData();
-                uploadFormData.append('file', document.getElementById('videoInput').files[0]);
+                uploadFormData.append('file', videoFile);
 
-                const uploadResponse = await fetch(uploadURL, {
+                const uploadResponse = await fetch(pending.uploadURL, {
                     method: 'POST',
                     body: uploadFormData,
                 });
-
-                if (uploadResponse.ok) {
-                    const l402Uri = `l402://blockbuster.fewsats.com/video/info/${videoId}`;
-                    
-                    Swal.fire({
-                        title: 'Video uploaded successfully!',
-                        html: `Your L402 URI: <br><strong>${l402Uri}</strong>`,
-                        icon: 'success',
-                        showCancelButton: true,
-                        confirmButtonText: 'Copy L402 URI',
-                        cancelButtonText: 'Close'
-                    }).then((result) => {
-                        if (result.isConfirmed) {
-                            navigator.clipboard.writeText(l402Uri).then(() => {
-                                showSwalNotification('Copied!').then(() => {
-                                    setTimeout(() => window.location.reload(), 3000);
-                                });
-                            }).catch(err => {
-                                console.error('Failed to copy: ', err);
-                                showSwalNotification('Failed to copy', 'error').then(() => {
-                                    setTimeout(() => window.location.reload(), 3000);
-                                });
-                            });
-                        } else {
-                            window.location.reload();
-                        }
-                    });
-
-                } else {
+                if (!uploadResponse.ok) {
                     throw new Error('Failed to upload video to streams storage');
                 }
-            } else {
-                throw new Error(data.error || 'Failed to initiate video upload');
             }
+            clearPendingUpload(videoFile);
+
+            const l402Uri = `l402://blockbuster.fewsats.com/video/info/${pending.videoId}`;
+
+            Swal.fire({
+                title: 'Video uploaded successfully!',
+                html: `Your L402 URI: <br><strong>${l402Uri}</strong>`,
+                icon: 'success',
+                showCancelButton: true,
+                confirmButtonText: 'Copy L402 URI',
+                cancelButtonText: 'Close'
+            }).then((result) => {
+                if (result.isConfirmed) {
+                    navigator.clipboard.writeText(l402Uri).then(() => {
+                        showSwalNotification('Copied!').then(() => {
+                            setTimeout(() => window.location.reload(), 3000);
+                        });
+                    }).catch(err => {
+                        console.error('Failed to copy: ', err);
+                        showSwalNotification('Failed to copy', 'error').then(() => {
+                            setTimeout(() => window.location.reload(), 3000);
+                        });
+                    });
+                } else {
+                    window.location.reload();
+                }
+            });
         } catch (error) {
             Swal.fire('Error', error.message, 'error');
         } finally {
@@ -144,6 +221,22 @@ export function initVideoUpload() {
     populateEmailField();
 }
 
+// videoStatusLabel describes the videos that can't be streamed yet.
+function videoStatusLabel(video) {
+    switch (video.status) {
+        case 'awaiting_upload':
+            return '<p class="text-xs text-yellow-600">Awaiting upload</p>';
+        case 'processing':
+            return '<p class="text-xs text-yellow-600">Processing</p>';
+        case 'failed':
+            return `<p class="text-xs text-red-600" title="${video.status_reason || ''}">Processing failed</p>`;
+        case 'expired':
+            return '<p class="text-xs text-red-600">Upload expired</p>';
+        default:
+            return '';
+    }
+}
+
 export function initVideoList() {
     const videoList = document.getElementById('videoList');
 
@@ -175,7 +268,7 @@ export function initVideoList() {
         videoList.innerHTML = videos.map((video, index) => `
             <div class="bg-gray-100 rounded-lg shadow-md p-4 mb-4">
                 <div class="flex items-center space-x-4 cursor-pointer" onclick="toggleAccordion(${index})">
-                    <img src="${video.cover_url}" alt="${video.title}" class="w-16 h-16 rounded-md object-cover">
+                    <img src="${video.cover_variants ? video.cover_variants.card_url : video.cover_url}" alt="${video.title}" class="w-16 h-16 rounded-md object-cover">
                     <div class="flex-1 flex items-center">
                         <h4 class="text-lg font-semibold mr-2">${video.title}</h4>
                         <button onclick="event.stopPropagation(); copyL402Uri('${video.l402_info_uri}')" 
@@ -199,6 +292,7 @@ export function initVideoList() {
                         <p class="text-sm font-semibold">$${(video.price_in_cents / 100).toFixed(2)}</p>
                         <p class="text-xs text-gray-500">${video.total_views} views</p>
                         <p class="text-xs text-gray-500">${video.total_purchases} purchases</p>
+                        ${videoStatusLabel(video)}
                     </div>
                     <svg class="w-6 h-6 transform transition-transform duration-200" id="accordionIcon${index}" fill="none" stroke="currentColor" viewBox="0 0 24 24" xmlns="http://www.w3.org/2000/svg">
                         <path stroke-linecap="round" stroke-linejoin="round" stroke-width="2" d="M19 9l-7 7-7-7"></path>
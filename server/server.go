@@ -1,10 +1,13 @@
 package server
 
 import (
+	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"io/fs"
 	"net/http"
+	"time"
 
 	"log/slog"
 
@@ -14,12 +17,20 @@ import (
 
 	"github.com/fewsats/blockbuster/auth"
 	"github.com/fewsats/blockbuster/config"
+	"github.com/fewsats/blockbuster/orders"
+	"github.com/fewsats/blockbuster/selfhosted"
+	"github.com/fewsats/blockbuster/storage"
 	"github.com/fewsats/blockbuster/video"
+	"github.com/fewsats/blockbuster/webhooks"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
 )
 
+// shutdownTimeout is the time the in-flight requests have to finish once the
+// server is stopped.
+const shutdownTimeout = 30 * time.Second
+
 //go:embed frontend
 var frontendFS embed.FS
 
@@ -29,10 +40,14 @@ type Server struct {
 	cfg       *config.Config
 	auth      *auth.Controller
 	video     *video.Controller
+	orders    *orders.Controller
+	webhooks  *webhooks.Controller
+	storage   *storage.Controller
+	uploads   *selfhosted.Controller // nil unless self-hosting the videos
 	templates *template.Template
 }
 
-func NewServer(logger *slog.Logger, cfg *config.Config, authCtrl *auth.Controller, videoCtrl *video.Controller) (*Server, error) {
+func NewServer(logger *slog.Logger, cfg *config.Config, authCtrl *auth.Controller, videoCtrl *video.Controller, ordersCtrl *orders.Controller, webhooksCtrl *webhooks.Controller, storageCtrl *storage.Controller, uploadsCtrl *selfhosted.Controller) (*Server, error) {
 	router := gin.New()
 	router.Use(gin.Recovery())
 
@@ -41,7 +56,7 @@ func NewServer(logger *slog.Logger, cfg *config.Config, authCtrl *auth.Controlle
 	corsConfig := cors.DefaultConfig()
 	corsConfig.AllowAllOrigins = true
 	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
-	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With", video.PubKeyHeader, video.DomainHeader, video.TimestampHeader, video.SignatureHeader}
 	corsConfig.ExposeHeaders = []string{"Content-Length", "Content-Disposition", "Content-Type", "X-Requested-With", "Set-Cookie", "Www-authenticate"}
 	corsConfig.AllowCredentials = true
 	router.Use(cors.New(corsConfig))
@@ -62,6 +77,10 @@ func NewServer(logger *slog.Logger, cfg *config.Config, authCtrl *auth.Controlle
 		cfg:       cfg,
 		auth:      authCtrl,
 		video:     videoCtrl,
+		orders:    ordersCtrl,
+		webhooks:  webhooksCtrl,
+		storage:   storageCtrl,
+		uploads:   uploadsCtrl,
 		templates: tmpl,
 	}
 
@@ -97,9 +116,19 @@ func (s *Server) setupRoutes() {
 			"GoogleAnalyticsID": s.cfg.GoogleAnalyticsID,
 		})
 	})
+	s.router.GET("/my-library", func(c *gin.Context) {
+		c.HTML(http.StatusOK, "library.html", gin.H{
+			"GoogleAnalyticsID": s.cfg.GoogleAnalyticsID,
+		})
+	})
 
 	s.auth.RegisterPublicRoutes(s.router)
 	s.video.RegisterPublicRoutes(s.router)
+	s.orders.RegisterPublicRoutes(s.router)
+	s.storage.RegisterPublicRoutes(s.router)
+	if s.uploads != nil {
+		s.uploads.RegisterPublicRoutes(s.router)
+	}
 
 	s.video.RegisterL402Routes(s.router)
 
@@ -107,9 +136,41 @@ func (s *Server) setupRoutes() {
 	s.auth.RegisterAuthMiddleware(s.router)
 	s.auth.RegisterProtectedRoutes(s.router)
 	s.video.RegisterProtectedRoutes(s.router)
+	s.orders.RegisterProtectedRoutes(s.router)
+	s.webhooks.RegisterProtectedRoutes(s.router)
 
 }
 
-func (s *Server) Run() error {
-	return s.router.Run(fmt.Sprintf(":%d", s.cfg.Port))
+// Run serves the requests until the context is cancelled, then waits for the
+// in-flight requests to finish.
+func (s *Server) Run(ctx context.Context) error {
+	httpServer := &http.Server{
+		Addr:    fmt.Sprintf(":%d", s.cfg.Port),
+		Handler: s.router,
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errChan:
+		return err
+
+	case <-ctx.Done():
+	}
+
+	s.logger.Info("Shutting down server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(),
+		shutdownTimeout)
+	defer cancel()
+
+	err := httpServer.Shutdown(shutdownCtx)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
 }
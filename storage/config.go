@@ -0,0 +1,49 @@
+package storage
+
+const (
+	// ProviderS3 stores the files in S3 compatible buckets, e.g.
+	// Cloudflare R2.
+	ProviderS3 = "s3"
+
+	// ProviderLocal stores the files in the local filesystem and serves
+	// them from the server.
+	ProviderLocal = "local"
+)
+
+// DefaultConfig returns all default values for the Config struct.
+func DefaultConfig() *Config {
+	return &Config{
+		Provider: ProviderS3,
+		S3: S3Config{
+			Region: "auto",
+		},
+		Local: LocalConfig{
+			Path:    "storage",
+			BaseURL: "http://localhost:8080",
+		},
+	}
+}
+
+type Config struct {
+	Provider string `long:"provider" description:"Storage provider {s3, local}"`
+
+	S3    S3Config    `group:"s3" namespace:"s3"`
+	Local LocalConfig `group:"local" namespace:"local"`
+}
+
+type S3Config struct {
+	Endpoint        string `long:"endpoint" description:"S3 endpoint, e.g. the Cloudflare R2 endpoint"`
+	Region          string `long:"region" description:"S3 region, auto for Cloudflare R2"`
+	AccessKey       string `long:"access_key" description:"S3 access key ID"`
+	SecretAccessKey string `long:"secret_access_key" description:"S3 secret access key"`
+
+	PublicBucket  string `long:"public_bucket" description:"Bucket of the public files, e.g. the cover images"`
+	PublicBaseURL string `long:"public_base_url" description:"Base URL the public bucket is served from"`
+	PrivateBucket string `long:"private_bucket" description:"Bucket of the files only reachable through signed URLs"`
+}
+
+type LocalConfig struct {
+	Path       string `long:"path" description:"Directory where the files are stored"`
+	BaseURL    string `long:"base_url" description:"Base URL of the server, used to build the file URLs"`
+	SigningKey string `long:"signing_key" description:"Key signing the URLs of the private files. A random one is used if empty, invalidating the URLs on restart"`
+}
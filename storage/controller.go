@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Controller struct {
+	local []*LocalStorage
+
+	logger *slog.Logger
+}
+
+// NewController creates the controller serving the files of the local
+// storages among the given ones.
+func NewController(logger *slog.Logger, storages ...Storage) *Controller {
+	var local []*LocalStorage
+	for _, s := range storages {
+		if l, ok := s.(*LocalStorage); ok {
+			local = append(local, l)
+		}
+	}
+
+	return &Controller{
+		local:  local,
+		logger: logger,
+	}
+}
+
+func (c *Controller) RegisterPublicRoutes(router *gin.Engine) {
+	for _, s := range c.local {
+		route := s.route("*key")
+		router.GET(route, c.serveFile(s))
+		router.HEAD(route, c.serveFile(s))
+	}
+}
+
+// serveFile serves the files of a local storage, supporting range requests.
+// The files of private buckets require a valid signed URL.
+func (c *Controller) serveFile(s *LocalStorage) gin.HandlerFunc {
+	return func(gCtx *gin.Context) {
+		key := strings.TrimPrefix(gCtx.Param("key"), "/")
+
+		if !s.public {
+			err := s.Verify(key, gCtx.Query("expires"),
+				gCtx.Query("signature"))
+			if err != nil {
+				gCtx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		file, err := s.Open(key)
+		switch {
+		case errors.Is(err, ErrNotFound) || errors.Is(err, ErrInvalidKey):
+			gCtx.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+
+		case err != nil:
+			c.logger.Error("Failed to open file", "key", key, "error", err)
+			gCtx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file"})
+			return
+		}
+		defer file.Close()
+
+		info, err := file.Stat()
+		if err != nil || info.IsDir() {
+			gCtx.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+
+		http.ServeContent(gCtx.Writer, gCtx.Request, path.Base(key),
+			info.ModTime(), file)
+	}
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+const (
+	// BucketPublic holds the files anyone can download from their public
+	// URL, e.g. the cover images.
+	BucketPublic = "public"
+
+	// BucketPrivate holds the files that are only reachable through signed
+	// URLs.
+	BucketPrivate = "private"
+)
+
+var (
+	// ErrNotFound is returned when the file does not exist.
+	ErrNotFound = errors.New("file not found")
+
+	// ErrUnknownProvider is returned when the configured provider is not
+	// supported.
+	ErrUnknownProvider = errors.New("unknown storage provider")
+)
+
+// Object describes a stored file.
+type Object struct {
+	Key        string
+	ModifiedAt time.Time
+}
+
+// Storage is the interface of the object storage backends. Every Storage
+// stores the files of a single bucket.
+type Storage interface {
+	// Put stores the file under the given key, replacing any previous
+	// one.
+	Put(ctx context.Context, key string, body io.ReadSeeker,
+		contentType string) error
+
+	// Delete removes the file with the given key. Deleting a missing file
+	// is not an error.
+	Delete(ctx context.Context, key string) error
+
+	// List returns the files whose key starts with the given prefix.
+	List(ctx context.Context, prefix string) ([]Object, error)
+
+	// PublicURL returns the URL of the file in a public bucket.
+	PublicURL(key string) string
+
+	// SignedURL returns a URL to download the file that is valid for the
+	// given duration.
+	SignedURL(ctx context.Context, key string,
+		expiry time.Duration) (string, error)
+}
@@ -0,0 +1,251 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fewsats/blockbuster/utils"
+)
+
+var (
+	// ErrInvalidSignature is returned when a signed URL of the local
+	// storage is tampered with or expired.
+	ErrInvalidSignature = errors.New("invalid or expired signature")
+
+	// ErrInvalidKey is returned for keys that escape the storage
+	// directory.
+	ErrInvalidKey = errors.New("invalid file key")
+)
+
+// LocalStorage stores the files of a bucket in a directory of the local
+// filesystem. They are served by the Controller, public buckets to anyone
+// and private ones only through HMAC signed, expiring URLs.
+type LocalStorage struct {
+	root    string
+	bucket  string
+	public  bool
+	baseURL string
+
+	signingKey []byte
+	clock      utils.Clock
+}
+
+// NewLocalStorage creates a new local storage for the given bucket.
+func NewLocalStorage(cfg *LocalConfig, bucket string, public bool,
+	clock utils.Clock) (*LocalStorage, error) {
+
+	root := filepath.Join(cfg.Path, bucket)
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w",
+			err)
+	}
+
+	signingKey := []byte(cfg.SigningKey)
+	if len(signingKey) == 0 {
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			return nil, fmt.Errorf("failed to generate signing key: %w",
+				err)
+		}
+	}
+
+	return &LocalStorage{
+		root:    root,
+		bucket:  bucket,
+		public:  public,
+		baseURL: strings.TrimSuffix(cfg.BaseURL, "/"),
+
+		signingKey: signingKey,
+		clock:      clock,
+	}, nil
+}
+
+// Put writes the file atomically, so readers never see a partial file. The
+// content type is sniffed when the file is served.
+func (s *LocalStorage) Put(_ context.Context, key string, body io.ReadSeeker,
+	_ string) error {
+
+	filePath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+
+	return os.Rename(tmp.Name(), filePath)
+}
+
+// Delete removes the file.
+func (s *LocalStorage) Delete(_ context.Context, key string) error {
+	filePath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(filePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// List walks the storage directory for the files under the prefix, skipping
+// the ones still being written.
+func (s *LocalStorage) List(_ context.Context, prefix string) ([]Object,
+	error) {
+
+	var objects []Object
+	err := filepath.WalkDir(s.root, func(filePath string, entry fs.DirEntry,
+		err error) error {
+
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".upload-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, filePath)
+		if err != nil {
+			return err
+		}
+
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, Object{
+			Key:        key,
+			ModifiedAt: info.ModTime(),
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+	}
+
+	return objects, nil
+}
+
+// PublicURL returns the URL the file is served from.
+func (s *LocalStorage) PublicURL(key string) string {
+	return fmt.Sprintf("%s%s", s.baseURL, s.route(key))
+}
+
+// SignedURL returns the URL of the file with its expiration time and the
+// HMAC-SHA256 of both.
+func (s *LocalStorage) SignedURL(_ context.Context, key string,
+	expiry time.Duration) (string, error) {
+
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+
+	expires := strconv.FormatInt(s.clock.Now().Add(expiry).Unix(), 10)
+
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", s.sign(key, expires))
+
+	return fmt.Sprintf("%s?%s", s.PublicURL(key), query.Encode()), nil
+}
+
+// Verify checks the expiration time and the signature of a signed URL.
+func (s *LocalStorage) Verify(key, expires, signature string) error {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || s.clock.Now().Unix() > unix {
+		return ErrInvalidSignature
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	actual, _ := hex.DecodeString(s.sign(key, expires))
+	if !hmac.Equal(expected, actual) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// Open opens the file to be served.
+func (s *LocalStorage) Open(key string) (*os.File, error) {
+	filePath, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	return file, err
+}
+
+// route returns the path of the file in the server.
+func (s *LocalStorage) route(key string) string {
+	return fmt.Sprintf("/storage/%s/%s", s.bucket, key)
+}
+
+// sign returns the hex encoded HMAC-SHA256 of the bucket, key and
+// expiration time.
+func (s *LocalStorage) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	fmt.Fprintf(mac, "%s/%s:%s", s.bucket, key, expires)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// path returns the location of the file, rejecting the keys that would
+// escape the storage directory.
+func (s *LocalStorage) path(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if key == "" || cleaned != "/"+key {
+		return "", ErrInvalidKey
+	}
+
+	return filepath.Join(s.root, filepath.FromSlash(cleaned)), nil
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fewsats/blockbuster/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStorage(t *testing.T, bucket string,
+	clock utils.Clock) (*LocalStorage, *gin.Engine) {
+
+	cfg := &LocalConfig{
+		Path:       t.TempDir(),
+		BaseURL:    "http://localhost:8080/",
+		SigningKey: "secret",
+	}
+
+	s, err := NewLocalStorage(cfg, bucket, bucket == BucketPublic, clock)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	NewController(slog.Default(), s).RegisterPublicRoutes(router)
+
+	return s, router
+}
+
+func get(router *gin.Engine, rawURL string,
+	header http.Header) *httptest.ResponseRecorder {
+
+	u, _ := url.Parse(rawURL)
+	req := httptest.NewRequest(http.MethodGet, u.RequestURI(), nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestLocalStoragePublic(t *testing.T) {
+	ctx := context.Background()
+	s, router := newTestStorage(t, BucketPublic, utils.NewMockClock())
+
+	err := s.Put(ctx, "cover-images/abc", strings.NewReader("hello world"),
+		"text/plain")
+	require.NoError(t, err)
+
+	fileURL := s.PublicURL("cover-images/abc")
+	require.Equal(t,
+		"http://localhost:8080/storage/public/cover-images/abc", fileURL)
+
+	w := get(router, fileURL, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "hello world", w.Body.String())
+
+	// Range requests are supported.
+	w = get(router, fileURL, http.Header{"Range": {"bytes=6-"}})
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	require.Equal(t, "world", w.Body.String())
+
+	// Deleting twice is not an error.
+	require.NoError(t, s.Delete(ctx, "cover-images/abc"))
+	require.NoError(t, s.Delete(ctx, "cover-images/abc"))
+
+	w = get(router, fileURL, nil)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// Keys can not escape the storage directory.
+	err = s.Put(ctx, "../escape", strings.NewReader("x"), "")
+	require.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestLocalStorageSignedURL(t *testing.T) {
+	ctx := context.Background()
+	clock := utils.NewMockClock()
+	clock.SetMockClockTime(time.Unix(1_700_000_000, 0))
+
+	s, router := newTestStorage(t, BucketPrivate, clock)
+
+	err := s.Put(ctx, "videos/abc.mp4", bytes.NewReader([]byte("video")),
+		"video/mp4")
+	require.NoError(t, err)
+
+	// Private files are not served without a signature.
+	w := get(router, s.PublicURL("videos/abc.mp4"), nil)
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	signedURL, err := s.SignedURL(ctx, "videos/abc.mp4", time.Hour)
+	require.NoError(t, err)
+
+	w = get(router, signedURL, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "video", w.Body.String())
+
+	// The signature is bound to the key.
+	otherURL := strings.Replace(signedURL, "abc.mp4", "xyz.mp4", 1)
+	w = get(router, otherURL, nil)
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	// And to the expiration time.
+	extendedURL := strings.Replace(signedURL, "expires=1700003600",
+		"expires=1700007200", 1)
+	require.NotEqual(t, signedURL, extendedURL)
+	w = get(router, extendedURL, nil)
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	clock.SetMockClockTime(time.Unix(1_700_003_601, 0))
+	w = get(router, signedURL, nil)
+	require.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestLocalStorageList(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStorage(t, BucketPublic, utils.NewMockClock())
+
+	for _, key := range []string{"cover-images/abc", "cover-images/xyz",
+		"videos/abc.mp4"} {
+
+		err := s.Put(ctx, key, strings.NewReader(key), "")
+		require.NoError(t, err)
+	}
+
+	objects, err := s.List(ctx, "cover-images/")
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+	require.Equal(t, "cover-images/abc", objects[0].Key)
+	require.Equal(t, "cover-images/xyz", objects[1].Key)
+	require.False(t, objects[0].ModifiedAt.IsZero())
+
+	require.NoError(t, s.Delete(ctx, "cover-images/abc"))
+	objects, err = s.List(ctx, "cover-images/")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+}
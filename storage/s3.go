@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// S3Storage stores the files of a bucket in an S3 compatible service, e.g.
+// Cloudflare R2.
+type S3Storage struct {
+	s3     *s3.S3
+	bucket string
+
+	publicBaseURL string
+}
+
+// NewS3Storage creates a new S3 storage for the given bucket.
+func NewS3Storage(cfg *S3Config, bucket string) (*S3Storage, error) {
+	cred := credentials.NewStaticCredentials(cfg.AccessKey,
+		cfg.SecretAccessKey, "")
+
+	s3Config := &aws.Config{
+		Credentials:      cred,
+		Region:           aws.String(cfg.Region),
+		S3ForcePathStyle: aws.Bool(true),
+	}
+	if cfg.Endpoint != "" {
+		s3Config.Endpoint = aws.String(cfg.Endpoint)
+	}
+
+	sess, err := session.NewSession(s3Config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3Storage{
+		s3:     s3.New(sess),
+		bucket: bucket,
+
+		publicBaseURL: strings.TrimSuffix(cfg.PublicBaseURL, "/"),
+	}, nil
+}
+
+// Put uploads the file to the bucket.
+func (s *S3Storage) Put(ctx context.Context, key string, body io.ReadSeeker,
+	contentType string) error {
+
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   body,
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+
+	_, err := s.s3.PutObjectWithContext(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to put %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// Delete removes the file from the bucket.
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	_, err := s.s3.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// List returns the files of the bucket under the prefix, page by page.
+func (s *S3Storage) List(ctx context.Context, prefix string) ([]Object,
+	error) {
+
+	var objects []Object
+	err := s.s3.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, object := range page.Contents {
+			objects = append(objects, Object{
+				Key:        aws.StringValue(object.Key),
+				ModifiedAt: aws.TimeValue(object.LastModified),
+			})
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+	}
+
+	return objects, nil
+}
+
+// PublicURL returns the URL of the file under the public base URL.
+func (s *S3Storage) PublicURL(key string) string {
+	return fmt.Sprintf("%s/%s", s.publicBaseURL, key)
+}
+
+// SignedURL returns a presigned URL to download the file.
+func (s *S3Storage) SignedURL(_ context.Context, key string,
+	expiry time.Duration) (string, error) {
+
+	req, _ := s.s3.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+
+	signedURL, err := req.Presign(expiry)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign %s: %w", key, err)
+	}
+
+	return signedURL, nil
+}
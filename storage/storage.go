@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/fewsats/blockbuster/utils"
+)
+
+// New creates the storage of the given bucket with the configured provider.
+func New(cfg *Config, bucket string, clock utils.Clock) (Storage, error) {
+	switch cfg.Provider {
+	case ProviderS3:
+		name := cfg.S3.PublicBucket
+		if bucket == BucketPrivate {
+			name = cfg.S3.PrivateBucket
+		}
+
+		return NewS3Storage(&cfg.S3, name)
+
+	case ProviderLocal:
+		return NewLocalStorage(&cfg.Local, bucket, bucket == BucketPublic,
+			clock)
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, cfg.Provider)
+	}
+}
@@ -0,0 +1,276 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/fewsats/blockbuster/store/sqlc"
+	"github.com/fewsats/blockbuster/video"
+)
+
+// CreateBundle creates a new bundle with its videos in the given order.
+func (s *Store) CreateBundle(ctx context.Context,
+	params video.CreateBundleParams) (*video.Bundle, error) {
+
+	var bundle *video.Bundle
+	err := s.ExecTx(ctx, func(queries *sqlc.Queries) error {
+		b, err := queries.CreateBundle(ctx, sqlc.CreateBundleParams{
+			ExternalID:   params.ExternalID,
+			UserID:       params.UserID,
+			Title:        params.Title,
+			Description:  params.Description,
+			CoverUrl:     params.CoverURL,
+			PriceInCents: params.PriceInCents,
+			CreatedAt:    s.clock.Now(),
+		})
+		if err != nil {
+			return err
+		}
+
+		err = insertBundleVideos(ctx, queries, b.ID, params.VideoIDs)
+		if err != nil {
+			return err
+		}
+
+		bundle = bundleFromRow(b, params.VideoIDs)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return bundle, nil
+}
+
+// GetBundleByExternalID returns a bundle that has not been deleted with the
+// videos that have not been deleted either.
+func (s *Store) GetBundleByExternalID(ctx context.Context,
+	externalID string) (*video.Bundle, error) {
+
+	b, err := s.queries.GetBundleByExternalID(ctx, externalID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, video.ErrBundleNotFound
+		}
+		return nil, err
+	}
+
+	videoIDs, err := s.queries.ListBundleVideos(ctx, b.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bundleFromRow(b, videoIDs), nil
+}
+
+// GetPurchasedBundle returns a bundle, even if it has been deleted, with
+// all its videos in order, deleted ones included. It is used to find what
+// the buyers of the bundle have access to.
+func (s *Store) GetPurchasedBundle(ctx context.Context,
+	externalID string) (*video.Bundle, error) {
+
+	b, err := s.queries.GetPurchasedBundle(ctx, externalID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, video.ErrBundleNotFound
+		}
+		return nil, err
+	}
+
+	videoIDs, err := s.queries.ListBundleMembers(ctx, b.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bundleFromRow(b, videoIDs), nil
+}
+
+// ListUserBundles returns the bundles of a user with their videos.
+func (s *Store) ListUserBundles(ctx context.Context,
+	userID int64) ([]*video.Bundle, error) {
+
+	bundles, err := s.queries.ListUserBundles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*video.Bundle, 0, len(bundles))
+	for _, b := range bundles {
+		videoIDs, err := s.queries.ListBundleVideos(ctx, b.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, bundleFromRow(b, videoIDs))
+	}
+
+	return result, nil
+}
+
+// UpdateBundle updates the info of a bundle and replaces its videos when new
+// ones are given.
+func (s *Store) UpdateBundle(ctx context.Context, externalID string,
+	params *video.UpdateBundleParams) (*video.Bundle, error) {
+
+	var bundle *video.Bundle
+	err := s.ExecTx(ctx, func(queries *sqlc.Queries) error {
+		b, err := queries.UpdateBundleInfo(ctx, sqlc.UpdateBundleInfoParams{
+			ExternalID: externalID,
+			Title: sql.NullString{
+				String: params.Title,
+				Valid:  params.Title != "",
+			},
+			Description: sql.NullString{
+				String: params.Description,
+				Valid:  params.Description != "",
+			},
+			PriceInCents: sql.NullInt64{
+				Int64: params.PriceInCents,
+				Valid: params.PriceInCents != 0,
+			},
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return video.ErrBundleNotFound
+			}
+			return err
+		}
+
+		if params.VideoIDs != nil {
+			err = checkBundleRemovals(ctx, queries, b, params.VideoIDs)
+			if err != nil {
+				return err
+			}
+
+			err = queries.DeleteBundleVideos(ctx, b.ID)
+			if err != nil {
+				return err
+			}
+
+			err = insertBundleVideos(ctx, queries, b.ID, params.VideoIDs)
+			if err != nil {
+				return err
+			}
+		}
+
+		videoIDs, err := queries.ListBundleVideos(ctx, b.ID)
+		if err != nil {
+			return err
+		}
+
+		bundle = bundleFromRow(b, videoIDs)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return bundle, nil
+}
+
+// DeleteBundle marks a bundle as deleted so it is no longer sold. The users
+// that already bought it keep access to its videos.
+func (s *Store) DeleteBundle(ctx context.Context, externalID string) error {
+	return s.queries.DeleteBundle(ctx, externalID)
+}
+
+// ListBundleExternalIDs returns the external IDs of every bundle, including
+// the deleted ones.
+func (s *Store) ListBundleExternalIDs(ctx context.Context) ([]string, error) {
+	return s.queries.ListBundleExternalIDs(ctx)
+}
+
+// IsBundleVideo returns whether the video is part of the bundle, even if the
+// bundle has been deleted.
+func (s *Store) IsBundleVideo(ctx context.Context, bundleExternalID,
+	externalID string) (bool, error) {
+
+	isMember, err := s.queries.IsBundleVideo(ctx, sqlc.IsBundleVideoParams{
+		BundleExternalID: bundleExternalID,
+		ExternalID:       externalID,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return isMember != 0, nil
+}
+
+// checkBundleRemovals returns ErrBundleHasSales if the new videos of the
+// bundle leave out any of its current ones and the bundle has already been
+// offered. Its buyers are granted access by its current videos, so removing
+// one would take it away from them.
+func checkBundleRemovals(ctx context.Context, queries *sqlc.Queries,
+	bundle sqlc.Bundle, videoIDs []string) error {
+
+	members, err := queries.ListBundleMembers(ctx, bundle.ID)
+	if err != nil {
+		return err
+	}
+
+	kept := make(map[string]bool, len(videoIDs))
+	for _, id := range videoIDs {
+		kept[id] = true
+	}
+
+	removes := false
+	for _, id := range members {
+		if !kept[id] {
+			removes = true
+			break
+		}
+	}
+	if !removes {
+		return nil
+	}
+
+	// Every purchase comes from an offer and the unpaid offers can still
+	// be paid until they expire, so both count as sales.
+	hasOffers, err := queries.HasBundleOffers(ctx, bundle.ExternalID)
+	if err != nil {
+		return err
+	}
+
+	if hasOffers != 0 {
+		return video.ErrBundleHasSales
+	}
+
+	return nil
+}
+
+// insertBundleVideos stores the videos of a bundle in the given order.
+func insertBundleVideos(ctx context.Context, queries *sqlc.Queries,
+	bundleID int64, videoIDs []string) error {
+
+	for i, externalID := range videoIDs {
+		err := queries.InsertBundleVideo(ctx, sqlc.InsertBundleVideoParams{
+			BundleID:   bundleID,
+			ExternalID: externalID,
+			Position:   int64(i),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func bundleFromRow(b sqlc.Bundle, videoIDs []string) *video.Bundle {
+	if videoIDs == nil {
+		videoIDs = []string{}
+	}
+
+	return &video.Bundle{
+		ID:           b.ID,
+		ExternalID:   b.ExternalID,
+		UserID:       b.UserID,
+		Title:        b.Title,
+		Description:  b.Description,
+		CoverURL:     b.CoverUrl,
+		PriceInCents: b.PriceInCents,
+		VideoIDs:     videoIDs,
+		CreatedAt:    b.CreatedAt,
+	}
+}
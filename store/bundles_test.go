@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fewsats/blockbuster/orders"
+	"github.com/fewsats/blockbuster/video"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBundles tests that bundles keep their videos in order, that the videos
+// of a sold bundle can't be removed and that the deleted bundles and their
+// members are still found for their buyers.
+func TestBundles(t *testing.T) {
+	ctx := context.Background()
+	s, clock := newTestStore(t)
+	clock.SetMockClockTime(time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC))
+
+	userID, err := s.CreateUser(ctx, "creator@fewsats.com")
+	require.NoError(t, err)
+
+	for _, externalID := range []string{"video1", "video2", "video3"} {
+		_, err := s.CreateVideo(ctx, video.CreateVideoParams{
+			ExternalID:   externalID,
+			UserID:       userID,
+			Title:        externalID,
+			CoverURL:     "cover_url",
+			PriceInCents: 100,
+		})
+		require.NoError(t, err)
+	}
+
+	bundle, err := s.CreateBundle(ctx, video.CreateBundleParams{
+		ExternalID:   "bundleID",
+		UserID:       userID,
+		Title:        "bundle",
+		CoverURL:     "cover_url",
+		PriceInCents: 250,
+		VideoIDs:     []string{"video3", "video1"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"video3", "video1"}, bundle.VideoIDs)
+
+	bundle, err = s.GetBundleByExternalID(ctx, "bundleID")
+	require.NoError(t, err)
+	require.Equal(t, userID, bundle.UserID)
+	require.Equal(t, []string{"video3", "video1"}, bundle.VideoIDs)
+
+	// Updating the info without videos keeps the current ones.
+	bundle, err = s.UpdateBundle(ctx, "bundleID", &video.UpdateBundleParams{
+		PriceInCents: 300,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(300), bundle.PriceInCents)
+	require.Equal(t, "bundle", bundle.Title)
+	require.Equal(t, []string{"video3", "video1"}, bundle.VideoIDs)
+
+	bundle, err = s.UpdateBundle(ctx, "bundleID", &video.UpdateBundleParams{
+		VideoIDs: []string{"video1", "video2"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"video1", "video2"}, bundle.VideoIDs)
+
+	// Once offered, the videos of the bundle can be reordered but not
+	// removed.
+	_, err = s.InsertOffer(ctx, &orders.Offer{
+		UserID:       uint64(userID),
+		ExternalID:   "bundleID",
+		PaymentHash:  "bundleHash",
+		PriceInCents: 300,
+		Currency:     "USD",
+	})
+	require.NoError(t, err)
+
+	_, err = s.UpdateBundle(ctx, "bundleID", &video.UpdateBundleParams{
+		VideoIDs: []string{"video1"},
+	})
+	require.ErrorIs(t, err, video.ErrBundleHasSales)
+
+	bundle, err = s.UpdateBundle(ctx, "bundleID", &video.UpdateBundleParams{
+		VideoIDs: []string{"video2", "video1"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"video2", "video1"}, bundle.VideoIDs)
+
+	// Deleted videos are no longer listed.
+	require.NoError(t, s.DeleteVideo(ctx, "video2", time.Now()))
+	bundle, err = s.GetBundleByExternalID(ctx, "bundleID")
+	require.NoError(t, err)
+	require.Equal(t, []string{"video1"}, bundle.VideoIDs)
+
+	// Deleted bundles are not sold anymore but still grant access.
+	require.NoError(t, s.DeleteBundle(ctx, "bundleID"))
+	_, err = s.GetBundleByExternalID(ctx, "bundleID")
+	require.ErrorIs(t, err, video.ErrBundleNotFound)
+
+	bundles, err := s.ListUserBundles(ctx, userID)
+	require.NoError(t, err)
+	require.Empty(t, bundles)
+
+	// Its buyers still find it with all its videos, deleted ones included.
+	bundle, err = s.GetPurchasedBundle(ctx, "bundleID")
+	require.NoError(t, err)
+	require.Equal(t, "bundle", bundle.Title)
+	require.Equal(t, []string{"video2", "video1"}, bundle.VideoIDs)
+
+	isMember, err := s.IsBundleVideo(ctx, "bundleID", "video1")
+	require.NoError(t, err)
+	require.True(t, isMember)
+
+	isMember, err = s.IsBundleVideo(ctx, "bundleID", "video3")
+	require.NoError(t, err)
+	require.False(t, isMember)
+}
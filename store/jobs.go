@@ -0,0 +1,159 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fewsats/blockbuster/jobs"
+	"github.com/fewsats/blockbuster/store/sqlc"
+)
+
+// InsertJob stores a new pending job and sets its ID.
+func (s *Store) InsertJob(ctx context.Context, job *jobs.Job) error {
+	timestamp := s.clock.Now()
+
+	id, err := s.queries.InsertJob(ctx, sqlc.InsertJobParams{
+		Kind:        job.Kind,
+		Payload:     job.Payload,
+		MaxAttempts: job.MaxAttempts,
+		RunAt:       job.RunAt,
+		CreatedAt:   timestamp,
+		UpdatedAt:   timestamp,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to insert job: %w", err)
+	}
+
+	job.ID = id
+	job.CreatedAt = timestamp
+	job.UpdatedAt = timestamp
+
+	return nil
+}
+
+// InsertUniqueJob stores a new pending job and sets its ID, unless there is
+// already a pending job of its kind.
+func (s *Store) InsertUniqueJob(ctx context.Context, job *jobs.Job) (bool,
+	error) {
+
+	timestamp := s.clock.Now()
+
+	id, err := s.queries.InsertUniqueJob(ctx, sqlc.InsertUniqueJobParams{
+		Kind:        job.Kind,
+		Payload:     job.Payload,
+		MaxAttempts: job.MaxAttempts,
+		RunAt:       job.RunAt,
+		CreatedAt:   timestamp,
+		UpdatedAt:   timestamp,
+	})
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return false, nil
+
+	case err != nil:
+		return false, fmt.Errorf("failed to insert job: %w", err)
+	}
+
+	job.ID = id
+	job.CreatedAt = timestamp
+	job.UpdatedAt = timestamp
+
+	return true, nil
+}
+
+// LeaseJob marks the next due job, either pending or running with an
+// expired lease, as running until lockedUntil.
+func (s *Store) LeaseJob(ctx context.Context, now,
+	lockedUntil time.Time) (*jobs.Job, error) {
+
+	row, err := s.queries.LeaseJob(ctx, sqlc.LeaseJobParams{
+		LockedUntil: lockedUntil,
+		Now:         now,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, jobs.ErrNoJobs
+		}
+
+		return nil, fmt.Errorf("failed to lease job: %w", err)
+	}
+
+	return &jobs.Job{
+		ID:          row.ID,
+		Kind:        row.Kind,
+		Payload:     row.Payload,
+		Status:      row.Status,
+		Attempts:    row.Attempts,
+		MaxAttempts: row.MaxAttempts,
+		RunAt:       row.RunAt,
+		LastError:   row.LastError,
+		CreatedAt:   row.CreatedAt,
+		UpdatedAt:   row.UpdatedAt,
+	}, nil
+}
+
+// CompleteJob removes a job that succeeded while its lease was still held.
+func (s *Store) CompleteJob(ctx context.Context, job *jobs.Job) error {
+	deleted, err := s.queries.DeleteLeasedJob(ctx, sqlc.DeleteLeasedJobParams{
+		ID:       job.ID,
+		Attempts: job.Attempts,
+	})
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return jobs.ErrLeaseLost
+	}
+
+	return nil
+}
+
+// ExtendJobLease moves the end of the lease of a running job while it is
+// still held.
+func (s *Store) ExtendJobLease(ctx context.Context, job *jobs.Job,
+	lockedUntil time.Time) error {
+
+	updated, err := s.queries.ExtendJobLease(ctx, sqlc.ExtendJobLeaseParams{
+		RunAt:     lockedUntil,
+		UpdatedAt: s.clock.Now(),
+		ID:        job.ID,
+		Attempts:  job.Attempts,
+	})
+	if err != nil {
+		return err
+	}
+
+	if updated == 0 {
+		return jobs.ErrLeaseLost
+	}
+
+	return nil
+}
+
+// ReleaseJob stores the result of a failed job while its lease was still
+// held.
+func (s *Store) ReleaseJob(ctx context.Context, job *jobs.Job) error {
+	updated, err := s.queries.ReleaseLeasedJob(ctx,
+		sqlc.ReleaseLeasedJobParams{
+			Status:    job.Status,
+			RunAt:     job.RunAt,
+			LastError: job.LastError,
+			UpdatedAt: s.clock.Now(),
+			ID:        job.ID,
+			Attempts:  job.Attempts,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if updated == 0 {
+		return jobs.ErrLeaseLost
+	}
+
+	return nil
+}
@@ -0,0 +1,231 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/fewsats/blockbuster/jobs"
+	"github.com/stretchr/testify/require"
+)
+
+// TestJobQueue tests that the jobs are retried with backoff, moved to the
+// dead-letter state once they run out of attempts and run again when the
+// lease of their worker expires.
+func TestJobQueue(t *testing.T) {
+	ctx := context.Background()
+	s, clock := newTestStore(t)
+	now := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	clock.SetMockClockTime(now)
+
+	cfg := jobs.DefaultConfig()
+	cfg.MaxAttempts = 2
+	queue := jobs.NewQueue(s, cfg, clock, slog.Default())
+
+	type payload struct {
+		ExternalID string `json:"external_id"`
+	}
+
+	var (
+		failing = true
+		ran     []string
+	)
+	queue.Register("test", func(ctx context.Context, job *jobs.Job) error {
+		var p payload
+		require.NoError(t, job.Decode(&p))
+		ran = append(ran, p.ExternalID)
+
+		if failing {
+			return errors.New("failed")
+		}
+
+		return nil
+	})
+
+	_, err := queue.Enqueue(ctx, "unknown", nil)
+	require.ErrorIs(t, err, jobs.ErrUnknownKind)
+
+	_, err = queue.Enqueue(ctx, "test", &payload{ExternalID: "first"})
+	require.NoError(t, err)
+	_, err = queue.EnqueueAt(ctx, "test", &payload{ExternalID: "second"},
+		now.Add(time.Hour))
+	require.NoError(t, err)
+
+	// The first attempt fails and the job is retried after the backoff.
+	ok, err := queue.RunNext(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = queue.RunNext(ctx)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// The second attempt is the last one, so the job is dead.
+	clock.SetMockClockTime(now.Add(cfg.RetryBackoff))
+	ok, err = queue.RunNext(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, []string{"first", "first"}, ran)
+
+	// The delayed job is leased by a worker that never reports back, so
+	// it is run again once the lease expires.
+	clock.SetMockClockTime(now.Add(time.Hour))
+	leased, err := s.LeaseJob(ctx, clock.Now(),
+		clock.Now().Add(cfg.LeaseTimeout))
+	require.NoError(t, err)
+	require.EqualValues(t, 1, leased.Attempts)
+
+	ok, err = queue.RunNext(ctx)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	failing = false
+	clock.SetMockClockTime(now.Add(time.Hour + cfg.LeaseTimeout))
+	ok, err = queue.RunNext(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, []string{"first", "first", "second"}, ran)
+
+	// The expired worker can no longer report its result.
+	require.ErrorIs(t, s.CompleteJob(ctx, leased), jobs.ErrLeaseLost)
+
+	// Only the dead job is left.
+	clock.SetMockClockTime(now.Add(24 * time.Hour))
+	ok, err = queue.RunNext(ctx)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	var status string
+	err = s.db.QueryRowContext(ctx, "SELECT status FROM jobs").Scan(&status)
+	require.NoError(t, err)
+	require.Equal(t, jobs.StatusDead, status)
+}
+
+// TestJobLeaseExtension tests that the lease of a running job is extended
+// until it finishes, that its attempts get the timeout of their kind and
+// that the kind is told when a job is dead.
+func TestJobLeaseExtension(t *testing.T) {
+	ctx := context.Background()
+	s, clock := newTestStore(t)
+	now := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	clock.SetMockClockTime(now)
+
+	cfg := jobs.DefaultConfig()
+	cfg.LeaseTimeout = 30 * time.Millisecond
+	cfg.MaxAttempts = 1
+	queue := jobs.NewQueue(s, cfg, clock, slog.Default())
+
+	leaseEnd := func() time.Time {
+		var runAt time.Time
+		err := s.db.QueryRowContext(ctx, "SELECT run_at FROM jobs").
+			Scan(&runAt)
+		require.NoError(t, err)
+
+		return runAt
+	}
+
+	var dead []string
+	queue.RegisterWithOptions("long", func(ctx context.Context,
+		job *jobs.Job) error {
+
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		require.Greater(t, time.Until(deadline), time.Minute)
+
+		// The job outlives its first lease, which is extended instead of
+		// being given to another worker.
+		clock.SetMockClockTime(now.Add(time.Hour))
+		require.Eventually(t, func() bool {
+			return leaseEnd().After(clock.Now())
+		}, time.Second, 5*time.Millisecond)
+
+		_, err := s.LeaseJob(ctx, clock.Now(),
+			clock.Now().Add(cfg.LeaseTimeout))
+		require.ErrorIs(t, err, jobs.ErrNoJobs)
+
+		return errors.New("still failing")
+	}, jobs.Options{
+		Timeout: time.Hour,
+		OnDead: func(ctx context.Context, job *jobs.Job, err error) {
+			dead = append(dead, job.Kind+": "+err.Error())
+		},
+	})
+
+	_, err := queue.Enqueue(ctx, "long", nil)
+	require.NoError(t, err)
+
+	ok, err := queue.RunNext(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, []string{"long: still failing"}, dead)
+}
+
+// TestRecurringJobs tests that recurring jobs keep a single pending run that
+// survives restarts, and that failed runs don't stop the recurrence.
+func TestRecurringJobs(t *testing.T) {
+	ctx := context.Background()
+	s, clock := newTestStore(t)
+	now := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	clock.SetMockClockTime(now)
+
+	var runs []time.Time
+	newQueue := func() *jobs.Queue {
+		queue := jobs.NewQueue(s, jobs.DefaultConfig(), clock,
+			slog.Default())
+		queue.RegisterRecurring("digest", time.Hour,
+			func(context.Context) error {
+				runs = append(runs, clock.Now())
+				return errors.New("failed")
+			})
+
+		return queue
+	}
+
+	pending := func() []time.Time {
+		rows, err := s.db.QueryContext(ctx, "SELECT run_at FROM jobs "+
+			"WHERE status = 'pending' ORDER BY run_at")
+		require.NoError(t, err)
+		defer rows.Close()
+
+		var runAts []time.Time
+		for rows.Next() {
+			var runAt time.Time
+			require.NoError(t, rows.Scan(&runAt))
+			runAts = append(runAts, runAt.UTC())
+		}
+		require.NoError(t, rows.Err())
+
+		return runAts
+	}
+
+	queue := newQueue()
+	require.NoError(t, queue.ScheduleRecurring(ctx))
+	require.NoError(t, queue.ScheduleRecurring(ctx))
+	require.Equal(t, []time.Time{now}, pending())
+
+	// Every run, even a failed one, schedules the next one.
+	ok, err := queue.RunNext(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, []time.Time{now}, runs)
+	require.Equal(t, []time.Time{now.Add(time.Hour)}, pending())
+
+	ok, err = queue.RunNext(ctx)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// A restart keeps the pending run.
+	queue = newQueue()
+	clock.SetMockClockTime(now.Add(30 * time.Minute))
+	require.NoError(t, queue.ScheduleRecurring(ctx))
+	require.Equal(t, []time.Time{now.Add(time.Hour)}, pending())
+
+	clock.SetMockClockTime(now.Add(time.Hour))
+	ok, err = queue.RunNext(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, []time.Time{now, now.Add(time.Hour)}, runs)
+	require.Equal(t, []time.Time{now.Add(2 * time.Hour)}, pending())
+}
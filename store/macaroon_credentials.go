@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/fewsats/blockbuster/l402"
 	"github.com/fewsats/blockbuster/store/sqlc"
 )
 
@@ -36,7 +37,8 @@ func (s *Store) CreateRootKey(ctx context.Context, identifier string,
 	return nil
 }
 
-// GetRootKey retrieves the root key for a given token ID.
+// GetRootKey retrieves the root key for a given token ID. It returns
+// l402.ErrRevokedCredentials if the credentials were disabled.
 func (s *Store) GetRootKey(ctx context.Context, identifier string) (string,
 	error) {
 
@@ -46,13 +48,68 @@ func (s *Store) GetRootKey(ctx context.Context, identifier string) (string,
 		if err != nil {
 			return err
 		}
-		rootKey = row
+		if row.Disabled {
+			return l402.ErrRevokedCredentials
+		}
+		rootKey = row.RootKey
 		return nil
 	}
 
 	if err := s.ExecTx(ctx, txBody); err != nil {
-		return "", fmt.Errorf("failed to get root key: %v", err)
+		return "", fmt.Errorf("failed to get root key: %w", err)
 	}
 
 	return rootKey, nil
 }
+
+// GetEncodedMacaroon retrieves the base64 encoded macaroon minted for a given
+// token ID.
+func (s *Store) GetEncodedMacaroon(ctx context.Context,
+	identifier string) (string, error) {
+
+	var encodedMacaroon string
+	txBody := func(queries *sqlc.Queries) error {
+		row, err := queries.GetEncodedMacaroonByIdentifier(ctx, identifier)
+		if err != nil {
+			return err
+		}
+		encodedMacaroon = row
+		return nil
+	}
+
+	if err := s.ExecTx(ctx, txBody); err != nil {
+		return "", fmt.Errorf("failed to get macaroon: %v", err)
+	}
+
+	return encodedMacaroon, nil
+}
+
+// ListPaidCredentials returns the credentials minted for the given public key
+// whose invoice is known to be settled, most recent first.
+func (s *Store) ListPaidCredentials(ctx context.Context,
+	pubKeyHex string) ([]*l402.StoredCredentials, error) {
+
+	var credentials []*l402.StoredCredentials
+	txBody := func(queries *sqlc.Queries) error {
+		rows, err := queries.ListPaidCredentialsByPubKey(ctx, pubKeyHex)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			credentials = append(credentials, &l402.StoredCredentials{
+				PaymentHash: row.PaymentHash,
+				Macaroon:    row.EncodedBaseMacaroon,
+				Preimage:    row.Preimage.String,
+			})
+		}
+
+		return nil
+	}
+
+	if err := s.ExecTx(ctx, txBody); err != nil {
+		return nil, fmt.Errorf("failed to list paid credentials: %v", err)
+	}
+
+	return credentials, nil
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestListPaidCredentials tests that only the credentials of the public key
+// with a settled invoice are listed.
+func TestListPaidCredentials(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+
+	pubKey := strings.Repeat("a", 64)
+	otherPubKey := strings.Repeat("b", 64)
+	paidHash := strings.Repeat("1", 64)
+	unpaidHash := strings.Repeat("2", 64)
+
+	credentials := []struct {
+		paymentHash string
+		pubKey      string
+	}{
+		{paidHash, pubKey},
+		{unpaidHash, pubKey},
+		{paidHash, otherPubKey},
+	}
+	for _, c := range credentials {
+		identifier := "0000" + c.paymentHash + c.pubKey
+		err := s.CreateRootKey(ctx, identifier, "rootKey",
+			"macaroon-"+c.pubKey[:1])
+		require.NoError(t, err)
+	}
+
+	_, err := s.UpsertInvoiceStatus(ctx, paidHash, "preimage", true)
+	require.NoError(t, err)
+	_, err = s.UpsertInvoiceStatus(ctx, unpaidHash, "", false)
+	require.NoError(t, err)
+
+	paid, err := s.ListPaidCredentials(ctx, pubKey)
+	require.NoError(t, err)
+	require.Len(t, paid, 1)
+	require.Equal(t, paidHash, paid[0].PaymentHash)
+	require.Equal(t, "macaroon-a", paid[0].Macaroon)
+	require.Equal(t, "preimage", paid[0].Preimage)
+}
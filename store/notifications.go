@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/fewsats/blockbuster/auth"
+	"github.com/fewsats/blockbuster/email"
+	"github.com/fewsats/blockbuster/store/sqlc"
+	"github.com/fewsats/blockbuster/video"
+)
+
+// GetNotificationRecipient returns the email and the notification preference
+// of a user.
+func (s *Store) GetNotificationRecipient(ctx context.Context,
+	userID int64) (*email.Recipient, error) {
+
+	user, err := s.queries.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, auth.ErrUserNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get user by ID: %w", err)
+	}
+
+	return &email.Recipient{
+		Email:      user.Email,
+		Preference: user.NotificationPreference,
+	}, nil
+}
+
+// GetPurchaseTitle returns the title of the video, bundle or subscription
+// sold with the given external ID, even if it has been deleted since.
+func (s *Store) GetPurchaseTitle(ctx context.Context, serviceType,
+	externalID string) (string, error) {
+
+	switch serviceType {
+	case video.ServiceTypeSubscriptions:
+		return video.SubscriptionTitle, nil
+
+	case video.ServiceTypeBundles:
+		bundle, err := s.GetPurchasedBundle(ctx, externalID)
+		if err != nil {
+			return "", err
+		}
+
+		return bundle.Title, nil
+	}
+
+	v, err := s.GetVideoByExternalID(ctx, externalID)
+	if errors.Is(err, video.ErrVideoNotFound) {
+		v, err = s.GetTrashedVideo(ctx, externalID)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return v.Title, nil
+}
+
+// UpdateUserNotificationPreference sets how a user is notified of new
+// purchases and uploads.
+func (s *Store) UpdateUserNotificationPreference(ctx context.Context,
+	id int64, preference string) error {
+
+	return s.queries.UpdateUserNotificationPreference(ctx,
+		sqlc.UpdateUserNotificationPreferenceParams{
+			ID:                     id,
+			NotificationPreference: preference,
+		},
+	)
+}
+
+// AddPendingNotification stores an event for the digest of a user.
+func (s *Store) AddPendingNotification(ctx context.Context, userID int64,
+	event *email.Event) error {
+
+	return s.queries.InsertPendingNotification(ctx,
+		sqlc.InsertPendingNotificationParams{
+			UserID:       userID,
+			EventType:    event.Type,
+			ExternalID:   event.ExternalID,
+			PaymentHash:  event.PaymentHash,
+			Title:        event.Title,
+			PriceInCents: int64(event.PriceInCents),
+			Currency:     event.Currency,
+			CreatedAt:    event.CreatedAt,
+		},
+	)
+}
+
+// ListPendingDigests returns the pending events grouped by user, oldest
+// first.
+func (s *Store) ListPendingDigests(ctx context.Context) ([]*email.Digest,
+	error) {
+
+	rows, err := s.queries.ListPendingNotifications(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending notifications: %w",
+			err)
+	}
+
+	var digests []*email.Digest
+	for _, row := range rows {
+		// Rows are sorted by user, so a new user starts a new digest.
+		if len(digests) == 0 || digests[len(digests)-1].UserID != row.UserID {
+			digests = append(digests, &email.Digest{
+				UserID: row.UserID,
+				Recipient: email.Recipient{
+					Email:      row.Email,
+					Preference: row.NotificationPreference,
+				},
+			})
+		}
+
+		digest := digests[len(digests)-1]
+		digest.Events = append(digest.Events, &email.Event{
+			Type:         row.EventType,
+			ExternalID:   row.ExternalID,
+			PaymentHash:  row.PaymentHash,
+			Title:        row.Title,
+			PriceInCents: uint64(row.PriceInCents),
+			Currency:     row.Currency,
+			CreatedAt:    row.CreatedAt,
+		})
+		digest.LastID = row.ID
+	}
+
+	return digests, nil
+}
+
+// DeletePendingNotifications removes the pending events of a user up to the
+// given ID.
+func (s *Store) DeletePendingNotifications(ctx context.Context, userID,
+	lastID int64) error {
+
+	return s.queries.DeletePendingNotifications(ctx,
+		sqlc.DeletePendingNotificationsParams{
+			UserID: userID,
+			ID:     lastID,
+		},
+	)
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fewsats/blockbuster/email"
+	"github.com/fewsats/blockbuster/video"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPendingDigests tests that the pending events are grouped by user and
+// removed once sent.
+func TestPendingDigests(t *testing.T) {
+	ctx := context.Background()
+	s, clock := newTestStore(t)
+	clock.SetMockClockTime(time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC))
+
+	first, err := s.CreateUser(ctx, "first@fewsats.com")
+	require.NoError(t, err)
+	second, err := s.CreateUser(ctx, "second@fewsats.com")
+	require.NoError(t, err)
+
+	recipient, err := s.GetNotificationRecipient(ctx, first)
+	require.NoError(t, err)
+	require.Equal(t, email.NotificationInstant, recipient.Preference)
+
+	err = s.UpdateUserNotificationPreference(ctx, first,
+		email.NotificationDaily)
+	require.NoError(t, err)
+
+	for _, userID := range []int64{second, first, first} {
+		err := s.AddPendingNotification(ctx, userID, &email.Event{
+			Type:       email.EventVideoUpload,
+			ExternalID: "externalID",
+			CreatedAt:  clock.Now(),
+		})
+		require.NoError(t, err)
+	}
+
+	purchase := &email.Event{
+		Type:         email.EventPurchase,
+		ExternalID:   "externalID",
+		PaymentHash:  "paymentHash",
+		Title:        "title",
+		PriceInCents: 550,
+		Currency:     "USD",
+		CreatedAt:    clock.Now(),
+	}
+	require.NoError(t, s.AddPendingNotification(ctx, first, purchase))
+
+	digests, err := s.ListPendingDigests(ctx)
+	require.NoError(t, err)
+	require.Len(t, digests, 2)
+	require.Equal(t, first, digests[0].UserID)
+	require.Equal(t, email.NotificationDaily, digests[0].Recipient.Preference)
+	require.Len(t, digests[0].Events, 3)
+	require.Len(t, digests[1].Events, 1)
+
+	// The purchases keep their title and amount.
+	stored := digests[0].Events[2]
+	stored.CreatedAt = stored.CreatedAt.UTC()
+	require.Equal(t, purchase, stored)
+
+	err = s.DeletePendingNotifications(ctx, first, digests[0].LastID)
+	require.NoError(t, err)
+
+	digests, err = s.ListPendingDigests(ctx)
+	require.NoError(t, err)
+	require.Len(t, digests, 1)
+	require.Equal(t, second, digests[0].UserID)
+}
+
+// TestPurchaseTitle tests that the purchases are titled after their content,
+// even once deleted.
+func TestPurchaseTitle(t *testing.T) {
+	ctx := context.Background()
+	s, clock := newTestStore(t)
+	clock.SetMockClockTime(time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC))
+
+	userID, err := s.CreateUser(ctx, "creator@fewsats.com")
+	require.NoError(t, err)
+
+	_, err = s.CreateVideo(ctx, video.CreateVideoParams{
+		ExternalID:   "videoID",
+		UserID:       userID,
+		Title:        "video",
+		CoverURL:     "cover_url",
+		PriceInCents: 100,
+	})
+	require.NoError(t, err)
+
+	_, err = s.CreateBundle(ctx, video.CreateBundleParams{
+		ExternalID:   "bundleID",
+		UserID:       userID,
+		Title:        "bundle",
+		CoverURL:     "cover_url",
+		PriceInCents: 250,
+		VideoIDs:     []string{"videoID"},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, s.DeleteVideo(ctx, "videoID", clock.Now()))
+	require.NoError(t, s.DeleteBundle(ctx, "bundleID"))
+
+	title, err := s.GetPurchaseTitle(ctx, video.ServiceTypeVideos,
+		"videoID")
+	require.NoError(t, err)
+	require.Equal(t, "video", title)
+
+	title, err = s.GetPurchaseTitle(ctx, video.ServiceTypeBundles,
+		"bundleID")
+	require.NoError(t, err)
+	require.Equal(t, "bundle", title)
+
+	title, err = s.GetPurchaseTitle(ctx, video.ServiceTypeSubscriptions,
+		video.SubscriptionExternalID(userID))
+	require.NoError(t, err)
+	require.Equal(t, video.SubscriptionTitle, title)
+
+	_, err = s.GetPurchaseTitle(ctx, video.ServiceTypeVideos, "unknown")
+	require.ErrorIs(t, err, video.ErrVideoNotFound)
+}
@@ -18,10 +18,6 @@ func (s *Store) InsertOffer(ctx context.Context, offer *orders.Offer) (uint64, e
 	}
 
 	timestamp := s.clock.Now()
-	var expirationDate time.Time
-	if offer.ExpirationDate != nil {
-		expirationDate = *offer.ExpirationDate
-	}
 
 	params := sqlc.InsertOfferParams{
 		UserID:       int64(offer.UserID),
@@ -35,7 +31,21 @@ func (s *Store) InsertOffer(ctx context.Context, offer *orders.Offer) (uint64, e
 				return sql.NullTime{}
 			}
 			return sql.NullTime{Time: *t, Valid: true}
-		}(&expirationDate),
+		}(offer.ExpirationDate),
+		PromoCodeID: sql.NullInt64{
+			Int64: offer.PromoCodeID,
+			Valid: offer.PromoCodeID != 0,
+		},
+		PayerPubKey: sql.NullString{
+			String: offer.PayerPubKey,
+			Valid:  offer.PayerPubKey != "",
+		},
+		RecipientPubKey: sql.NullString{
+			String: offer.RecipientPubKey,
+			Valid:  offer.RecipientPubKey != "",
+		},
+		AmountInSats: int64(offer.AmountInSats),
+		ServiceType:  offer.ServiceType,
 	}
 
 	var id uint64
@@ -66,37 +76,160 @@ func (s *Store) GetOfferByPaymentHash(ctx context.Context,
 	txBody := func(queries *sqlc.Queries) error {
 		row, err := queries.GetOfferByPaymentHash(ctx, payreq)
 		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return orders.ErrNotFound
+			}
 			return err
 		}
 
-		offer = &orders.Offer{
-			ID:           uint64(row.ID),
-			UserID:       uint64(row.UserID),
-			ExternalID:   row.ExternalID,
-			PaymentHash:  row.PaymentHash,
-			PriceInCents: uint64(row.PriceInCents),
-			Currency:     row.Currency,
-
-			ExpirationDate: func(nt sql.NullTime) *time.Time {
-				if nt.Valid {
-					return &nt.Time
-				}
-				return nil
-			}(row.ExpirationDate),
-			CreatedAt: row.CreatedAt,
-		}
+		offer = offerFromRow(row)
 
 		return nil
 	}
 
 	if err := s.ExecTx(ctx, txBody); err != nil {
-		return nil, fmt.Errorf("failed to get offer by payment hash(%s): %v",
+		return nil, fmt.Errorf("failed to get offer by payment hash(%s): %w",
 			payreq, err)
 	}
 
 	return offer, nil
 }
 
+// ListRecipientOffers returns the most recent offers, up to limit, for the
+// given service whose credentials are bound to the recipient public key.
+func (s *Store) ListRecipientOffers(ctx context.Context, externalID,
+	recipientPubKey string, limit int64) ([]*orders.Offer, error) {
+
+	var offers []*orders.Offer
+	txBody := func(queries *sqlc.Queries) error {
+		rows, err := queries.ListRecipientOffers(ctx,
+			sqlc.ListRecipientOffersParams{
+				ExternalID: externalID,
+				RecipientPubKey: sql.NullString{
+					String: recipientPubKey,
+					Valid:  true,
+				},
+				Limit: limit,
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			offers = append(offers, offerFromRow(row))
+		}
+
+		return nil
+	}
+
+	if err := s.ExecTx(ctx, txBody); err != nil {
+		return nil, fmt.Errorf("failed to list recipient offers: %w", err)
+	}
+
+	return offers, nil
+}
+
+// ListExpiredUnpaidOffers returns up to limit offers created before the given
+// time that have neither a purchase nor a settled invoice, in ID order after
+// the given offer ID.
+func (s *Store) ListExpiredUnpaidOffers(ctx context.Context,
+	createdBefore time.Time, afterID uint64,
+	limit int64) ([]*orders.Offer, error) {
+
+	var offers []*orders.Offer
+	txBody := func(queries *sqlc.Queries) error {
+		rows, err := queries.ListExpiredUnpaidOffers(ctx,
+			sqlc.ListExpiredUnpaidOffersParams{
+				CreatedAt: createdBefore,
+				ID:        int64(afterID),
+				Limit:     limit,
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			offers = append(offers, offerFromRow(row))
+		}
+
+		return nil
+	}
+
+	if err := s.ExecTx(ctx, txBody); err != nil {
+		return nil, fmt.Errorf("failed to list expired unpaid offers: %w", err)
+	}
+
+	return offers, nil
+}
+
+// DeleteUnpaidOffer atomically deletes the offer for the given payment hash
+// and the root keys of its credentials, unless it has been purchased. It
+// returns whether the offer was deleted and the number of root keys removed.
+func (s *Store) DeleteUnpaidOffer(ctx context.Context,
+	paymentHash string) (bool, int64, error) {
+
+	var (
+		deleted  bool
+		rootKeys int64
+	)
+	txBody := func(queries *sqlc.Queries) error {
+		offers, err := queries.DeleteUnpaidOffer(ctx, paymentHash)
+		if err != nil {
+			return err
+		}
+
+		// The purchase was recorded in the meantime, keep the
+		// credentials.
+		if offers == 0 {
+			return nil
+		}
+
+		rootKeys, err = queries.DeleteMacaroonsByPaymentHash(ctx,
+			paymentHash)
+		if err != nil {
+			return err
+		}
+
+		deleted = true
+
+		return nil
+	}
+
+	if err := s.ExecTx(ctx, txBody); err != nil {
+		return false, 0, fmt.Errorf("failed to delete offer for payment "+
+			"hash(%s): %w", paymentHash, err)
+	}
+
+	return deleted, rootKeys, nil
+}
+
+// offerFromRow converts an offers row into an orders.Offer.
+func offerFromRow(row sqlc.Offer) *orders.Offer {
+	return &orders.Offer{
+		ID:           uint64(row.ID),
+		UserID:       uint64(row.UserID),
+		ExternalID:   row.ExternalID,
+		PaymentHash:  row.PaymentHash,
+		PriceInCents: uint64(row.PriceInCents),
+		Currency:     row.Currency,
+
+		ExpirationDate: func(nt sql.NullTime) *time.Time {
+			if nt.Valid {
+				return &nt.Time
+			}
+			return nil
+		}(row.ExpirationDate),
+		PromoCodeID:     row.PromoCodeID.Int64,
+		PayerPubKey:     row.PayerPubKey.String,
+		RecipientPubKey: row.RecipientPubKey.String,
+		AmountInSats:    uint64(row.AmountInSats),
+		ServiceType:     row.ServiceType,
+		CreatedAt:       row.CreatedAt,
+	}
+}
+
 // InsertPurchase inserts a new purchase into the store.
 func (s *Store) InsertPurchase(ctx context.Context, purchase *orders.Purchase) (uint64, error) {
 	if purchase.ID != 0 {
@@ -156,15 +289,7 @@ func (s *Store) GetPurchaseByPaymentHash(ctx context.Context,
 			return err
 		}
 
-		purchase = &orders.Purchase{
-			ID:           uint64(row.ID),
-			UserID:       uint64(row.UserID),
-			ExternalID:   row.ExternalID,
-			ServiceType:  row.ServiceType,
-			PaymentHash:  row.PaymentHash,
-			PriceInCents: uint64(row.PriceInCents),
-			Currency:     row.Currency,
-		}
+		purchase = purchaseFromRow(row)
 
 		return nil
 	}
@@ -177,3 +302,129 @@ func (s *Store) GetPurchaseByPaymentHash(ctx context.Context,
 
 	return purchase, nil
 }
+
+// ListRecipientPurchases returns the purchases made for the given public key,
+// most recent first.
+func (s *Store) ListRecipientPurchases(ctx context.Context,
+	recipientPubKey string) ([]*orders.Purchase, error) {
+
+	var purchases []*orders.Purchase
+	txBody := func(queries *sqlc.Queries) error {
+		rows, err := queries.ListRecipientPurchases(ctx, sql.NullString{
+			String: recipientPubKey,
+			Valid:  true,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			purchases = append(purchases, purchaseFromRow(row))
+		}
+
+		return nil
+	}
+
+	if err := s.ExecTx(ctx, txBody); err != nil {
+		return nil, fmt.Errorf("failed to list recipient purchases: %w", err)
+	}
+
+	return purchases, nil
+}
+
+// RecordPurchase creates the purchase for the offer linked to the given
+// payment hash unless it already exists, redeeming the promo code of the
+// offer and adding the sale to the ledger, and, if requested, increments
+// the views of the watched video. Everything runs in a single transaction
+// that starts with the insert so concurrent requests for the same payment
+// hash serialize on the write lock and record exactly one purchase.
+func (s *Store) RecordPurchase(ctx context.Context,
+	params *orders.RecordPurchaseParams) (*orders.Purchase, bool, error) {
+
+	timestamp := s.clock.Now()
+
+	var (
+		purchase *orders.Purchase
+		created  bool
+	)
+	txBody := func(queries *sqlc.Queries) error {
+		inserted, err := queries.InsertPurchaseFromOffer(ctx,
+			sqlc.InsertPurchaseFromOfferParams{
+				ServiceType: params.ServiceType,
+				CreatedAt:   timestamp,
+				PaymentHash: params.PaymentHash,
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		row, err := queries.GetPurchaseByPaymentHash(ctx, params.PaymentHash)
+		if err != nil {
+			// Nothing was inserted and there is no purchase, so there
+			// is no offer for this payment hash either.
+			if errors.Is(err, sql.ErrNoRows) {
+				return orders.ErrNotFound
+			}
+			return err
+		}
+
+		// Promo codes are only redeemed and sales only accounted once
+		// the purchase is recorded.
+		if inserted > 0 {
+			err = queries.IncrementPromoCodeRedemptions(ctx,
+				params.PaymentHash)
+			if err != nil {
+				return err
+			}
+
+			err = queries.InsertSaleLedgerEntry(ctx, params.PaymentHash)
+			if err != nil {
+				return err
+			}
+		}
+
+		if params.ViewedExternalID != "" {
+			err = queries.IncrementVideoViews(ctx, params.ViewedExternalID)
+			if err != nil {
+				return err
+			}
+		}
+
+		purchase = purchaseFromRow(row)
+		created = inserted > 0
+
+		return nil
+	}
+
+	if err := s.ExecTx(ctx, txBody); err != nil {
+		return nil, false, fmt.Errorf("failed to record purchase for payment "+
+			"hash(%s): %w", params.PaymentHash, err)
+	}
+
+	return purchase, created, nil
+}
+
+// purchaseFromRow converts a purchases row into an orders.Purchase.
+func purchaseFromRow(row sqlc.Purchase) *orders.Purchase {
+	return &orders.Purchase{
+		ID:           uint64(row.ID),
+		UserID:       uint64(row.UserID),
+		ExternalID:   row.ExternalID,
+		ServiceType:  row.ServiceType,
+		PaymentHash:  row.PaymentHash,
+		PriceInCents: uint64(row.PriceInCents),
+		Currency:     row.Currency,
+
+		ExpirationDate: func(nt sql.NullTime) *time.Time {
+			if nt.Valid {
+				return &nt.Time
+			}
+			return nil
+		}(row.ExpirationDate),
+		PayerPubKey:     row.PayerPubKey.String,
+		RecipientPubKey: row.RecipientPubKey.String,
+		AmountInSats:    uint64(row.AmountInSats),
+		CreatedAt:       row.CreatedAt,
+	}
+}
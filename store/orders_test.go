@@ -0,0 +1,245 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fewsats/blockbuster/orders"
+	"github.com/fewsats/blockbuster/store/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestOffer creates a user, a video and an offer for that video.
+func createTestOffer(t *testing.T, s *Store, externalID,
+	paymentHash string) *orders.Offer {
+
+	t.Helper()
+	ctx := context.Background()
+
+	userID, err := s.CreateUser(ctx, externalID+"@fewsats.com")
+	require.NoError(t, err)
+
+	_, err = s.queries.CreateVideo(ctx, sqlc.CreateVideoParams{
+		ExternalID:   externalID,
+		UserID:       userID,
+		Title:        "title",
+		Description:  "description",
+		CoverUrl:     "cover_url",
+		PriceInCents: 100,
+		CreatedAt:    s.clock.Now(),
+	})
+	require.NoError(t, err)
+
+	offer := &orders.Offer{
+		UserID:       uint64(userID),
+		ExternalID:   externalID,
+		PaymentHash:  paymentHash,
+		PriceInCents: 100,
+		Currency:     "USD",
+		ServiceType:  "videos",
+	}
+	_, err = s.InsertOffer(ctx, offer)
+	require.NoError(t, err)
+
+	return offer
+}
+
+// TestRecordPurchase tests that recording a purchase is idempotent.
+func TestRecordPurchase(t *testing.T) {
+	ctx := context.Background()
+	s, clock := newTestStore(t)
+	clock.SetMockClockTime(time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC))
+
+	offer := createTestOffer(t, s, "externalID", "paymentHash")
+
+	stored, err := s.GetOfferByPaymentHash(ctx, "paymentHash")
+	require.NoError(t, err)
+	require.Equal(t, "videos", stored.ServiceType)
+
+	_, err = s.GetOfferByPaymentHash(ctx, "unknown")
+	require.ErrorIs(t, err, orders.ErrNotFound)
+
+	params := &orders.RecordPurchaseParams{
+		PaymentHash:      "paymentHash",
+		ServiceType:      "videos",
+		ViewedExternalID: "externalID",
+	}
+
+	purchase, created, err := s.RecordPurchase(ctx, params)
+	require.NoError(t, err)
+	require.True(t, created)
+	require.Equal(t, offer.UserID, purchase.UserID)
+	require.Equal(t, offer.ExternalID, purchase.ExternalID)
+	require.Equal(t, offer.PriceInCents, purchase.PriceInCents)
+	require.Equal(t, "videos", purchase.ServiceType)
+
+	again, created, err := s.RecordPurchase(ctx, params)
+	require.NoError(t, err)
+	require.False(t, created)
+	require.Equal(t, purchase.ID, again.ID)
+
+	video, err := s.GetVideoByExternalID(ctx, "externalID")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, video.TotalPurchases)
+	require.EqualValues(t, 2, video.TotalViews)
+
+	// Unknown payment hashes have no offer to be purchased.
+	params.PaymentHash = "unknown"
+	_, _, err = s.RecordPurchase(ctx, params)
+	require.ErrorIs(t, err, orders.ErrNotFound)
+}
+
+// TestGiftPurchase tests that the payer and the recipient of a gift are kept
+// on the purchase and that the offer can be found by its recipient.
+func TestGiftPurchase(t *testing.T) {
+	ctx := context.Background()
+	s, clock := newTestStore(t)
+	clock.SetMockClockTime(time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC))
+
+	offer := createTestOffer(t, s, "externalID", "selfHash")
+	gift := &orders.Offer{
+		UserID:          offer.UserID,
+		ExternalID:      "externalID",
+		PaymentHash:     "giftHash",
+		PriceInCents:    100,
+		Currency:        "USD",
+		PayerPubKey:     "payer",
+		RecipientPubKey: "recipient",
+	}
+	_, err := s.InsertOffer(ctx, gift)
+	require.NoError(t, err)
+
+	offers, err := s.ListRecipientOffers(ctx, "externalID", "recipient", 10)
+	require.NoError(t, err)
+	require.Len(t, offers, 1)
+	require.Equal(t, "giftHash", offers[0].PaymentHash)
+	require.Equal(t, "payer", offers[0].PayerPubKey)
+
+	offers, err = s.ListRecipientOffers(ctx, "externalID", "payer", 10)
+	require.NoError(t, err)
+	require.Empty(t, offers)
+
+	purchase, _, err := s.RecordPurchase(ctx, &orders.RecordPurchaseParams{
+		PaymentHash: "giftHash",
+		ServiceType: "videos",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "payer", purchase.PayerPubKey)
+	require.Equal(t, "recipient", purchase.RecipientPubKey)
+}
+
+// TestRecordPurchaseConcurrent tests that concurrent stream requests with the
+// same payment hash record exactly one purchase and count every view.
+func TestRecordPurchaseConcurrent(t *testing.T) {
+	const numRequests = 20
+
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+	mgr := orders.NewManager(slog.Default(), s, nil, nil, nil, nil,
+		orders.DefaultConfig())
+
+	createTestOffer(t, s, "externalID", "paymentHash")
+
+	var (
+		wg      sync.WaitGroup
+		errChan = make(chan error, numRequests)
+	)
+	for i := 0; i < numRequests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			errChan <- mgr.RecordPurchaseAndView(ctx, "paymentHash",
+				"videos", "externalID")
+		}()
+	}
+	wg.Wait()
+	close(errChan)
+
+	for err := range errChan {
+		require.NoError(t, err)
+	}
+
+	video, err := s.GetVideoByExternalID(ctx, "externalID")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, video.TotalPurchases)
+	require.EqualValues(t, numRequests, video.TotalViews)
+}
+
+// TestDeleteUnpaidOffers tests that only the old offers without purchase nor
+// settled invoice are collected, together with their root keys.
+func TestDeleteUnpaidOffers(t *testing.T) {
+	ctx := context.Background()
+	s, clock := newTestStore(t)
+	clock.SetMockClockTime(time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC))
+
+	unpaidHash := strings.Repeat("1", 64)
+	purchasedHash := strings.Repeat("2", 64)
+	settledHash := strings.Repeat("3", 64)
+	recentHash := strings.Repeat("4", 64)
+
+	offer := createTestOffer(t, s, "externalID", unpaidHash)
+	for _, paymentHash := range []string{purchasedHash, settledHash} {
+		_, err := s.InsertOffer(ctx, &orders.Offer{
+			UserID:      offer.UserID,
+			ExternalID:  "externalID",
+			PaymentHash: paymentHash,
+			Currency:    "USD",
+		})
+		require.NoError(t, err)
+	}
+
+	identifier := "0000" + unpaidHash + strings.Repeat("a", 64)
+	err := s.CreateRootKey(ctx, identifier, "rootKey", "macaroon")
+	require.NoError(t, err)
+
+	_, _, err = s.RecordPurchase(ctx, &orders.RecordPurchaseParams{
+		PaymentHash: purchasedHash,
+		ServiceType: "videos",
+	})
+	require.NoError(t, err)
+
+	_, err = s.UpsertInvoiceStatus(ctx, settledHash, "preimage", true)
+	require.NoError(t, err)
+
+	clock.SetMockClockTime(time.Date(2024, 9, 2, 0, 0, 0, 0, time.UTC))
+	_, err = s.InsertOffer(ctx, &orders.Offer{
+		UserID:      offer.UserID,
+		ExternalID:  "externalID",
+		PaymentHash: recentHash,
+		Currency:    "USD",
+	})
+	require.NoError(t, err)
+
+	cutoff := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+	offers, err := s.ListExpiredUnpaidOffers(ctx, cutoff, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, offers, 1)
+	require.Equal(t, unpaidHash, offers[0].PaymentHash)
+
+	// The listing continues after the given offer.
+	offers, err = s.ListExpiredUnpaidOffers(ctx, cutoff, offers[0].ID, 10)
+	require.NoError(t, err)
+	require.Empty(t, offers)
+
+	deleted, rootKeys, err := s.DeleteUnpaidOffer(ctx, unpaidHash)
+	require.NoError(t, err)
+	require.True(t, deleted)
+	require.EqualValues(t, 1, rootKeys)
+
+	_, err = s.GetRootKey(ctx, identifier)
+	require.Error(t, err)
+
+	// Purchased offers are never deleted.
+	deleted, _, err = s.DeleteUnpaidOffer(ctx, purchasedHash)
+	require.NoError(t, err)
+	require.False(t, deleted)
+
+	offers, err = s.ListExpiredUnpaidOffers(ctx, cutoff, 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, offers)
+}
@@ -0,0 +1,162 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/fewsats/blockbuster/store/sqlc"
+	"github.com/fewsats/blockbuster/video"
+)
+
+// CreatePromoCode creates a new promo code scoped to the given videos.
+func (s *Store) CreatePromoCode(ctx context.Context,
+	params video.CreatePromoCodeParams) (*video.PromoCode, error) {
+
+	var promoCode *video.PromoCode
+	err := s.ExecTx(ctx, func(queries *sqlc.Queries) error {
+		p, err := queries.CreatePromoCode(ctx, sqlc.CreatePromoCodeParams{
+			UserID:        params.UserID,
+			Code:          params.Code,
+			DiscountType:  params.DiscountType,
+			DiscountValue: params.DiscountValue,
+			ExpiresAt: func(t *time.Time) sql.NullTime {
+				if t == nil {
+					return sql.NullTime{}
+				}
+				return sql.NullTime{Time: *t, Valid: true}
+			}(params.ExpiresAt),
+			MaxRedemptions: sql.NullInt64{
+				Int64: params.MaxRedemptions,
+				Valid: params.MaxRedemptions != 0,
+			},
+			CreatedAt: s.clock.Now(),
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, externalID := range params.VideoIDs {
+			err = queries.InsertPromoCodeVideo(ctx,
+				sqlc.InsertPromoCodeVideoParams{
+					PromoCodeID: p.ID,
+					ExternalID:  externalID,
+				},
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		promoCode = promoCodeFromRow(p, params.VideoIDs)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return promoCode, nil
+}
+
+// GetPromoCode returns the promo code of a user with its videos.
+func (s *Store) GetPromoCode(ctx context.Context, userID int64,
+	code string) (*video.PromoCode, error) {
+
+	p, err := s.queries.GetPromoCode(ctx, sqlc.GetPromoCodeParams{
+		UserID: userID,
+		Code:   code,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, video.ErrPromoCodeNotFound
+		}
+		return nil, err
+	}
+
+	videoIDs, err := s.queries.ListPromoCodeVideos(ctx, p.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return promoCodeFromRow(p, videoIDs), nil
+}
+
+// ListUserPromoCodes returns the promo codes of a user with their videos.
+func (s *Store) ListUserPromoCodes(ctx context.Context,
+	userID int64) ([]*video.PromoCode, error) {
+
+	promoCodes, err := s.queries.ListUserPromoCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*video.PromoCode, 0, len(promoCodes))
+	for _, p := range promoCodes {
+		videoIDs, err := s.queries.ListPromoCodeVideos(ctx, p.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, promoCodeFromRow(p, videoIDs))
+	}
+
+	return result, nil
+}
+
+// DeletePromoCode removes a promo code of a user.
+func (s *Store) DeletePromoCode(ctx context.Context, userID int64,
+	code string) error {
+
+	return s.ExecTx(ctx, func(queries *sqlc.Queries) error {
+		err := queries.DeletePromoCodeVideos(ctx,
+			sqlc.DeletePromoCodeVideosParams{
+				UserID: userID,
+				Code:   code,
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		deleted, err := queries.DeletePromoCode(ctx,
+			sqlc.DeletePromoCodeParams{
+				UserID: userID,
+				Code:   code,
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		if deleted == 0 {
+			return video.ErrPromoCodeNotFound
+		}
+
+		return nil
+	})
+}
+
+func promoCodeFromRow(p sqlc.PromoCode, videoIDs []string) *video.PromoCode {
+	if videoIDs == nil {
+		videoIDs = []string{}
+	}
+
+	return &video.PromoCode{
+		ID:            p.ID,
+		UserID:        p.UserID,
+		Code:          p.Code,
+		DiscountType:  p.DiscountType,
+		DiscountValue: p.DiscountValue,
+		ExpiresAt: func(nt sql.NullTime) *time.Time {
+			if nt.Valid {
+				return &nt.Time
+			}
+			return nil
+		}(p.ExpiresAt),
+		MaxRedemptions: p.MaxRedemptions.Int64,
+		Redemptions:    p.Redemptions,
+		VideoIDs:       videoIDs,
+		CreatedAt:      p.CreatedAt,
+	}
+}
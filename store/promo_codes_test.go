@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fewsats/blockbuster/orders"
+	"github.com/fewsats/blockbuster/video"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPromoCodeRedemptions tests that promo codes are only redeemed once the
+// purchase of an offer using them is recorded, and only once per purchase.
+func TestPromoCodeRedemptions(t *testing.T) {
+	ctx := context.Background()
+	s, clock := newTestStore(t)
+	clock.SetMockClockTime(time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC))
+
+	offer := createTestOffer(t, s, "externalID", "paymentHash")
+	userID := int64(offer.UserID)
+
+	promoCode, err := s.CreatePromoCode(ctx, video.CreatePromoCodeParams{
+		UserID:         userID,
+		Code:           "LAUNCH",
+		DiscountType:   "percentage",
+		DiscountValue:  20,
+		MaxRedemptions: 5,
+		VideoIDs:       []string{"externalID"},
+	})
+	require.NoError(t, err)
+
+	_, err = s.InsertOffer(ctx, &orders.Offer{
+		UserID:       offer.UserID,
+		ExternalID:   "externalID",
+		PaymentHash:  "discountedHash",
+		PriceInCents: 80,
+		Currency:     "USD",
+		PromoCodeID:  promoCode.ID,
+	})
+	require.NoError(t, err)
+
+	discounted, err := s.GetOfferByPaymentHash(ctx, "discountedHash")
+	require.NoError(t, err)
+	require.Equal(t, promoCode.ID, discounted.PromoCodeID)
+
+	// Creating the offer does not redeem the code.
+	promoCode, err = s.GetPromoCode(ctx, userID, "LAUNCH")
+	require.NoError(t, err)
+	require.Zero(t, promoCode.Redemptions)
+	require.Equal(t, []string{"externalID"}, promoCode.VideoIDs)
+
+	// Purchases without the code do not redeem it either.
+	params := &orders.RecordPurchaseParams{
+		PaymentHash: "paymentHash",
+		ServiceType: "videos",
+	}
+	_, _, err = s.RecordPurchase(ctx, params)
+	require.NoError(t, err)
+
+	params.PaymentHash = "discountedHash"
+	for i := 0; i < 3; i++ {
+		_, _, err = s.RecordPurchase(ctx, params)
+		require.NoError(t, err)
+	}
+
+	promoCode, err = s.GetPromoCode(ctx, userID, "LAUNCH")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, promoCode.Redemptions)
+
+	require.NoError(t, s.DeletePromoCode(ctx, userID, "LAUNCH"))
+	_, err = s.GetPromoCode(ctx, userID, "LAUNCH")
+	require.ErrorIs(t, err, video.ErrPromoCodeNotFound)
+
+	err = s.DeletePromoCode(ctx, userID, "LAUNCH")
+	require.ErrorIs(t, err, video.ErrPromoCodeNotFound)
+}
@@ -0,0 +1,274 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fewsats/blockbuster/orders"
+	"github.com/fewsats/blockbuster/store/sqlc"
+)
+
+// CreateRefund stores a new refund, returning orders.ErrRefundExists if the
+// purchase already has one.
+func (s *Store) CreateRefund(ctx context.Context, refund *orders.Refund) error {
+	if refund.ID != 0 {
+		return fmt.Errorf("trying to insert a refund with an ID: %d",
+			refund.ID)
+	}
+
+	timestamp := s.clock.Now()
+
+	txBody := func(queries *sqlc.Queries) error {
+		_, err := queries.GetRefundByPaymentHash(ctx, refund.PaymentHash)
+		switch {
+		case err == nil:
+			return orders.ErrRefundExists
+
+		case !errors.Is(err, sql.ErrNoRows):
+			return err
+		}
+
+		row, err := queries.CreateRefund(ctx, sqlc.CreateRefundParams{
+			PaymentHash:     refund.PaymentHash,
+			UserID:          refund.UserID,
+			RequesterPubKey: refund.RequesterPubKey,
+			Reason:          refund.Reason,
+			PaymentRequest:  refund.PaymentRequest,
+			AmountInSats:    int64(refund.AmountInSats),
+			Status:          refund.Status,
+			CreatedAt:       timestamp,
+			UpdatedAt:       timestamp,
+		})
+		if err != nil {
+			return err
+		}
+
+		*refund = *refundFromRow(row)
+
+		return nil
+	}
+
+	if err := s.ExecTx(ctx, txBody); err != nil {
+		return fmt.Errorf("failed to create refund for payment hash(%s): %w",
+			refund.PaymentHash, err)
+	}
+
+	return nil
+}
+
+// GetRefund returns the refund with the given ID.
+func (s *Store) GetRefund(ctx context.Context, id int64) (*orders.Refund,
+	error) {
+
+	var refund *orders.Refund
+	txBody := func(queries *sqlc.Queries) error {
+		row, err := queries.GetRefund(ctx, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return orders.ErrNotFound
+			}
+			return err
+		}
+
+		refund = refundFromRow(row)
+
+		return nil
+	}
+
+	if err := s.ExecTx(ctx, txBody); err != nil {
+		return nil, fmt.Errorf("failed to get refund(%d): %w", id, err)
+	}
+
+	return refund, nil
+}
+
+// ListUserRefunds returns the refunds of the purchases sold by a user.
+func (s *Store) ListUserRefunds(ctx context.Context,
+	userID int64) ([]*orders.Refund, error) {
+
+	var refunds []*orders.Refund
+	txBody := func(queries *sqlc.Queries) error {
+		rows, err := queries.ListUserRefunds(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			refunds = append(refunds, refundFromRow(row))
+		}
+
+		return nil
+	}
+
+	if err := s.ExecTx(ctx, txBody); err != nil {
+		return nil, fmt.Errorf("failed to list refunds of user %d: %w",
+			userID, err)
+	}
+
+	return refunds, nil
+}
+
+// ListRefunds returns all the refunds.
+func (s *Store) ListRefunds(ctx context.Context) ([]*orders.Refund, error) {
+	var refunds []*orders.Refund
+	txBody := func(queries *sqlc.Queries) error {
+		rows, err := queries.ListRefunds(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			refunds = append(refunds, refundFromRow(row))
+		}
+
+		return nil
+	}
+
+	if err := s.ExecTx(ctx, txBody); err != nil {
+		return nil, fmt.Errorf("failed to list refunds: %w", err)
+	}
+
+	return refunds, nil
+}
+
+// UpdateRefundStatus moves a refund to a new status, returning
+// orders.ErrInvalidRefundStatus if it is not in the expected one.
+func (s *Store) UpdateRefundStatus(ctx context.Context,
+	params *orders.UpdateRefundStatusParams) error {
+
+	txBody := func(queries *sqlc.Queries) error {
+		return updateRefundStatus(ctx, queries, params, s.clock.Now())
+	}
+
+	if err := s.ExecTx(ctx, txBody); err != nil {
+		return fmt.Errorf("failed to update refund(%d): %w", params.ID, err)
+	}
+
+	return nil
+}
+
+// CompleteRefund atomically adds the reversal of the sale of a paid refund
+// to the ledger and disables the credentials minted for the purchase. It
+// can be called again if it failed, as both steps are idempotent.
+func (s *Store) CompleteRefund(ctx context.Context,
+	refund *orders.Refund) error {
+
+	timestamp := s.clock.Now()
+
+	txBody := func(queries *sqlc.Queries) error {
+		row, err := queries.GetRefund(ctx, refund.ID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return orders.ErrNotFound
+			}
+			return err
+		}
+
+		if row.Status != orders.RefundStatusPaid {
+			return orders.ErrInvalidRefundStatus
+		}
+
+		err = queries.InsertRefundLedgerEntry(ctx,
+			sqlc.InsertRefundLedgerEntryParams{
+				CreatedAt:   timestamp,
+				PaymentHash: row.PaymentHash,
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		return queries.DisableMacaroonsByPaymentHash(ctx, row.PaymentHash)
+	}
+
+	if err := s.ExecTx(ctx, txBody); err != nil {
+		return fmt.Errorf("failed to complete refund(%d): %w", refund.ID,
+			err)
+	}
+
+	return nil
+}
+
+// ListUserLedgerEntries returns the ledger of a user, most recent first.
+func (s *Store) ListUserLedgerEntries(ctx context.Context,
+	userID int64) ([]*orders.LedgerEntry, error) {
+
+	var entries []*orders.LedgerEntry
+	txBody := func(queries *sqlc.Queries) error {
+		rows, err := queries.ListUserLedgerEntries(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			entries = append(entries, &orders.LedgerEntry{
+				ID:            row.ID,
+				UserID:        row.UserID,
+				PaymentHash:   row.PaymentHash,
+				EntryType:     row.EntryType,
+				AmountInCents: row.AmountInCents,
+				AmountInSats:  row.AmountInSats,
+				Currency:      row.Currency,
+				CreatedAt:     row.CreatedAt,
+			})
+		}
+
+		return nil
+	}
+
+	if err := s.ExecTx(ctx, txBody); err != nil {
+		return nil, fmt.Errorf("failed to list ledger of user %d: %w",
+			userID, err)
+	}
+
+	return entries, nil
+}
+
+// updateRefundStatus moves a refund to a new status within a transaction.
+func updateRefundStatus(ctx context.Context, queries *sqlc.Queries,
+	params *orders.UpdateRefundStatusParams, timestamp time.Time) error {
+
+	updated, err := queries.UpdateRefundStatus(ctx,
+		sqlc.UpdateRefundStatusParams{
+			Status:     params.Status,
+			Resolution: params.Resolution,
+			ResolvedBy: sql.NullInt64{
+				Int64: params.ResolvedBy,
+				Valid: params.ResolvedBy != 0,
+			},
+			UpdatedAt:  timestamp,
+			ID:         params.ID,
+			FromStatus: params.FromStatus,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if updated == 0 {
+		return orders.ErrInvalidRefundStatus
+	}
+
+	return nil
+}
+
+// refundFromRow converts a refunds row into an orders.Refund.
+func refundFromRow(row sqlc.Refund) *orders.Refund {
+	return &orders.Refund{
+		ID:              row.ID,
+		PaymentHash:     row.PaymentHash,
+		UserID:          row.UserID,
+		RequesterPubKey: row.RequesterPubKey,
+		Reason:          row.Reason,
+		PaymentRequest:  row.PaymentRequest,
+		AmountInSats:    uint64(row.AmountInSats),
+		Status:          row.Status,
+		Resolution:      row.Resolution,
+		ResolvedBy:      row.ResolvedBy.Int64,
+		CreatedAt:       row.CreatedAt,
+		UpdatedAt:       row.UpdatedAt,
+	}
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fewsats/blockbuster/l402"
+	"github.com/fewsats/blockbuster/orders"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRefunds tests that a paid refund reverses the sale in the ledger and
+// revokes the credentials of the purchase.
+func TestRefunds(t *testing.T) {
+	ctx := context.Background()
+	s, clock := newTestStore(t)
+	clock.SetMockClockTime(time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC))
+
+	paymentHash := strings.Repeat("1", 64)
+	identifier := "0000" + paymentHash + strings.Repeat("a", 64)
+	err := s.CreateRootKey(ctx, identifier, "rootKey", "macaroon")
+	require.NoError(t, err)
+
+	offer := createTestOffer(t, s, "externalID", "selfHash")
+	_, err = s.InsertOffer(ctx, &orders.Offer{
+		UserID:       offer.UserID,
+		ExternalID:   "externalID",
+		PaymentHash:  paymentHash,
+		PriceInCents: 100,
+		Currency:     "USD",
+		AmountInSats: 1500,
+	})
+	require.NoError(t, err)
+
+	purchase, _, err := s.RecordPurchase(ctx, &orders.RecordPurchaseParams{
+		PaymentHash: paymentHash,
+		ServiceType: "videos",
+	})
+	require.NoError(t, err)
+	require.EqualValues(t, 1500, purchase.AmountInSats)
+
+	userID := int64(offer.UserID)
+	entries, err := s.ListUserLedgerEntries(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "sale", entries[0].EntryType)
+	require.EqualValues(t, 1500, entries[0].AmountInSats)
+
+	refund := &orders.Refund{
+		PaymentHash:    paymentHash,
+		UserID:         userID,
+		Reason:         "wrong video",
+		PaymentRequest: "lnbc10u1...",
+		AmountInSats:   1000,
+		Status:         orders.RefundStatusPending,
+	}
+	require.NoError(t, s.CreateRefund(ctx, refund))
+	require.NotZero(t, refund.ID)
+
+	// A purchase can only be refunded once.
+	err = s.CreateRefund(ctx, &orders.Refund{
+		PaymentHash: paymentHash,
+		UserID:      userID,
+		Status:      orders.RefundStatusPending,
+	})
+	require.ErrorIs(t, err, orders.ErrRefundExists)
+
+	// Only paid refunds can be completed.
+	err = s.CompleteRefund(ctx, refund)
+	require.ErrorIs(t, err, orders.ErrInvalidRefundStatus)
+
+	err = s.UpdateRefundStatus(ctx, &orders.UpdateRefundStatusParams{
+		ID:         refund.ID,
+		FromStatus: orders.RefundStatusPending,
+		Status:     orders.RefundStatusApproved,
+		ResolvedBy: userID,
+	})
+	require.NoError(t, err)
+	err = s.CompleteRefund(ctx, refund)
+	require.ErrorIs(t, err, orders.ErrInvalidRefundStatus)
+
+	err = s.UpdateRefundStatus(ctx, &orders.UpdateRefundStatusParams{
+		ID:         refund.ID,
+		FromStatus: orders.RefundStatusApproved,
+		Status:     orders.RefundStatusPaid,
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.CompleteRefund(ctx, refund))
+
+	// Completing it again, e.g. after a failure, doesn't reverse the sale
+	// twice.
+	require.NoError(t, s.CompleteRefund(ctx, refund))
+
+	refund, err = s.GetRefund(ctx, refund.ID)
+	require.NoError(t, err)
+	require.Equal(t, orders.RefundStatusPaid, refund.Status)
+	require.Equal(t, userID, refund.ResolvedBy)
+
+	entries, err = s.ListUserLedgerEntries(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	var balanceInSats, balanceInCents int64
+	for _, entry := range entries {
+		balanceInSats += entry.AmountInSats
+		balanceInCents += entry.AmountInCents
+	}
+	require.EqualValues(t, 500, balanceInSats)
+
+	// The partial refund only reverses its share of the price, rounded
+	// down.
+	require.EqualValues(t, 34, balanceInCents)
+
+	_, err = s.GetRootKey(ctx, identifier)
+	require.ErrorIs(t, err, l402.ErrRevokedCredentials)
+
+	refunds, err := s.ListUserRefunds(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, refunds, 1)
+
+	_, err = s.GetRefund(ctx, refund.ID+1)
+	require.ErrorIs(t, err, orders.ErrNotFound)
+}
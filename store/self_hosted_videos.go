@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/fewsats/blockbuster/selfhosted"
+	"github.com/fewsats/blockbuster/store/sqlc"
+)
+
+// CreateSelfHostedVideo stores a new video of the self-hosted backend.
+func (s *Store) CreateSelfHostedVideo(ctx context.Context,
+	video *selfhosted.Video) error {
+
+	timestamp := s.clock.Now()
+
+	err := s.queries.CreateSelfHostedVideo(ctx,
+		sqlc.CreateSelfHostedVideoParams{
+			ExternalID:      video.ExternalID,
+			Status:          video.Status,
+			UploadExpiresAt: video.UploadExpiresAt,
+			CreatedAt:       timestamp,
+			UpdatedAt:       timestamp,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create self-hosted video: %w", err)
+	}
+
+	video.CreatedAt = timestamp
+	video.UpdatedAt = timestamp
+
+	return nil
+}
+
+// GetSelfHostedVideo returns the video of the self-hosted backend with the
+// given external ID.
+func (s *Store) GetSelfHostedVideo(ctx context.Context,
+	externalID string) (*selfhosted.Video, error) {
+
+	row, err := s.queries.GetSelfHostedVideo(ctx, externalID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, selfhosted.ErrNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get self-hosted video: %w", err)
+	}
+
+	return selfHostedVideoFromRow(row), nil
+}
+
+// ListSelfHostedVideos returns every video of the self-hosted backend.
+func (s *Store) ListSelfHostedVideos(ctx context.Context) ([]*selfhosted.Video,
+	error) {
+
+	rows, err := s.queries.ListSelfHostedVideos(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list self-hosted videos: %w", err)
+	}
+
+	videos := make([]*selfhosted.Video, 0, len(rows))
+	for _, row := range rows {
+		videos = append(videos, selfHostedVideoFromRow(row))
+	}
+
+	return videos, nil
+}
+
+// DeleteSelfHostedVideo removes a video of the self-hosted backend.
+func (s *Store) DeleteSelfHostedVideo(ctx context.Context,
+	externalID string) error {
+
+	err := s.queries.DeleteSelfHostedVideo(ctx, externalID)
+	if err != nil {
+		return fmt.Errorf("failed to delete self-hosted video: %w", err)
+	}
+
+	return nil
+}
+
+// selfHostedVideoFromRow converts a self-hosted video row to a video.
+func selfHostedVideoFromRow(row sqlc.SelfHostedVideo) *selfhosted.Video {
+	return &selfhosted.Video{
+		ExternalID:      row.ExternalID,
+		Status:          row.Status,
+		StatusReason:    row.StatusReason,
+		SizeInBytes:     row.SizeInBytes,
+		UploadExpiresAt: row.UploadExpiresAt,
+		CreatedAt:       row.CreatedAt,
+		UpdatedAt:       row.UpdatedAt,
+	}
+}
+
+// UpdateSelfHostedVideoStatus moves a video of the self-hosted backend from
+// the current status to the given one.
+func (s *Store) UpdateSelfHostedVideoStatus(ctx context.Context, externalID,
+	currentStatus, status, reason string, sizeInBytes int64) error {
+
+	rows, err := s.queries.UpdateSelfHostedVideoStatus(ctx,
+		sqlc.UpdateSelfHostedVideoStatusParams{
+			Status:        status,
+			StatusReason:  reason,
+			SizeInBytes:   sizeInBytes,
+			UpdatedAt:     s.clock.Now(),
+			ExternalID:    externalID,
+			CurrentStatus: currentStatus,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update self-hosted video: %w", err)
+	}
+
+	if rows == 0 {
+		return selfhosted.ErrStatusChanged
+	}
+
+	return nil
+}
@@ -0,0 +1,254 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/fewsats/blockbuster/jobs"
+	"github.com/fewsats/blockbuster/selfhosted"
+	"github.com/fewsats/blockbuster/storage"
+	"github.com/fewsats/blockbuster/video"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// testMP4 starts with the ftyp box of an MP4 file.
+var testMP4 = append([]byte{
+	0x00, 0x00, 0x00, 0x18, 'f', 't', 'y', 'p', 'm', 'p', '4', '2',
+	0x00, 0x00, 0x00, 0x00, 'm', 'p', '4', '2', 'i', 's', 'o', 'm',
+}, []byte("video data")...)
+
+// TestSelfHostedVideos tests that the self-hosted backend receives the
+// uploads, processes them in the background and streams them through
+// signed URLs.
+func TestSelfHostedVideos(t *testing.T) {
+	ctx := context.Background()
+	s, clock := newTestStore(t)
+	clock.SetMockClockTime(time.Now())
+
+	private, err := storage.NewLocalStorage(&storage.LocalConfig{
+		Path:       t.TempDir(),
+		BaseURL:    "http://localhost:8080",
+		SigningKey: "storage-secret",
+	}, storage.BucketPrivate, false, clock)
+	require.NoError(t, err)
+
+	cfg := selfhosted.DefaultConfig()
+	cfg.UploadPath = t.TempDir()
+	cfg.MaxUploadSize = 1024
+	cfg.SigningKey = "upload-secret"
+
+	queue := jobs.NewQueue(s, jobs.DefaultConfig(), clock, slog.Default())
+	service, err := selfhosted.NewService(cfg, s, private, queue, clock,
+		slog.Default())
+	require.NoError(t, err)
+	service.RegisterJobs(nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	selfhosted.NewController(service, slog.Default()).
+		RegisterPublicRoutes(router)
+	storage.NewController(slog.Default(), private).RegisterPublicRoutes(router)
+
+	do := func(method, rawURL string, body []byte,
+		header http.Header) *httptest.ResponseRecorder {
+
+		u, err := url.Parse(rawURL)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(method, u.RequestURI(),
+			bytes.NewReader(body))
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	upload, err := service.GenerateVideoUploadURL(ctx, 1,
+		&video.UploadOptions{})
+	require.NoError(t, err)
+	require.False(t, upload.Resumable)
+	uploadURL, externalID := upload.URL, upload.ExternalID
+
+	info, err := service.GetStreamVideoInfo(ctx, externalID)
+	require.NoError(t, err)
+	require.Equal(t, selfhosted.StatusPendingUpload, info.Status.State)
+	require.False(t, info.ReadyToStream)
+
+	// Uploads need a valid signature and are limited in size.
+	w := do(http.MethodPost, uploadURL+"0", testMP4, nil)
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	w = do(http.MethodPost, uploadURL, make([]byte, 1025), nil)
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// The file is sent in a multipart form, like the direct uploads of
+	// Cloudflare Stream.
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	part, err := writer.CreateFormFile("file", "video.mp4")
+	require.NoError(t, err)
+	_, err = part.Write(testMP4)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	w = do(http.MethodPost, uploadURL, form.Bytes(), http.Header{
+		"Content-Type": {writer.FormDataContentType()},
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// A video is only uploaded once.
+	w = do(http.MethodPost, uploadURL, testMP4, nil)
+	require.Equal(t, http.StatusConflict, w.Code)
+
+	info, err = service.GetStreamVideoInfo(ctx, externalID)
+	require.NoError(t, err)
+	require.Equal(t, selfhosted.StatusProcessing, info.Status.State)
+
+	ok, err := queue.RunNext(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	info, err = service.GetStreamVideoInfo(ctx, externalID)
+	require.NoError(t, err)
+	require.True(t, info.ReadyToStream)
+	require.Equal(t, len(testMP4), info.Size)
+
+	// The video is streamed with range requests through a signed URL.
+	urls, err := service.GenerateStreamURL(ctx, externalID,
+		&video.StreamURLOptions{})
+	require.NoError(t, err)
+	require.Empty(t, urls.HLSURL)
+
+	w = do(http.MethodGet, urls.MP4URL, nil,
+		http.Header{"Range": {"bytes=24-"}})
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	require.Equal(t, "video/mp4", w.Header().Get("Content-Type"))
+	require.Equal(t, "video data", w.Body.String())
+
+	// Files other than MP4 fail to process.
+	upload, err = service.GenerateVideoUploadURL(ctx, 1,
+		&video.UploadOptions{})
+	require.NoError(t, err)
+	uploadURL, externalID = upload.URL, upload.ExternalID
+
+	w = do(http.MethodPost, uploadURL, []byte("not a video"), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	ok, err = queue.RunNext(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	info, err = service.GetStreamVideoInfo(ctx, externalID)
+	require.NoError(t, err)
+	require.Equal(t, selfhosted.StatusError, info.Status.State)
+	require.NotEmpty(t, info.Status.ErrorReasonText)
+}
+
+// TestSelfHostedImport tests that the self-hosted backend downloads the
+// imported videos and processes them like the uploads, refusing private
+// addresses unless allowed.
+func TestSelfHostedImport(t *testing.T) {
+	ctx := context.Background()
+	s, clock := newTestStore(t)
+	clock.SetMockClockTime(time.Now())
+
+	source := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/video.mp4":
+				w.Write(testMP4)
+
+			case "/large.mp4":
+				w.Write(make([]byte, 1025))
+
+			default:
+				http.NotFound(w, r)
+			}
+		},
+	))
+	defer source.Close()
+
+	newService := func(allowPrivate bool) (*selfhosted.Service,
+		*jobs.Queue) {
+
+		private, err := storage.NewLocalStorage(&storage.LocalConfig{
+			Path:       t.TempDir(),
+			BaseURL:    "http://localhost:8080",
+			SigningKey: "storage-secret",
+		}, storage.BucketPrivate, false, clock)
+		require.NoError(t, err)
+
+		cfg := selfhosted.DefaultConfig()
+		cfg.UploadPath = t.TempDir()
+		cfg.MaxUploadSize = 1024
+		cfg.ImportAllowPrivate = allowPrivate
+
+		queue := jobs.NewQueue(s, jobs.DefaultConfig(), clock,
+			slog.Default())
+		service, err := selfhosted.NewService(cfg, s, private, queue,
+			clock, slog.Default())
+		require.NoError(t, err)
+		service.RegisterJobs(nil)
+
+		return service, queue
+	}
+
+	importVideo := func(service *selfhosted.Service, queue *jobs.Queue,
+		path string) *cloudflare.StreamVideo {
+
+		externalID, err := service.ImportVideo(ctx, 1, source.URL+path,
+			"title")
+		require.NoError(t, err)
+
+		info, err := service.GetStreamVideoInfo(ctx, externalID)
+		require.NoError(t, err)
+		require.Equal(t, selfhosted.StatusProcessing, info.Status.State)
+
+		ok, err := queue.RunNext(ctx)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		info, err = service.GetStreamVideoInfo(ctx, externalID)
+		require.NoError(t, err)
+
+		return info
+	}
+
+	service, queue := newService(true)
+
+	info := importVideo(service, queue, "/video.mp4")
+	require.Equal(t, selfhosted.StatusReady, info.Status.State)
+	require.True(t, info.ReadyToStream)
+	require.Equal(t, len(testMP4), info.Size)
+
+	// Missing and oversized files fail the import.
+	info = importVideo(service, queue, "/missing.mp4")
+	require.Equal(t, selfhosted.StatusError, info.Status.State)
+	require.Contains(t, info.Status.ErrorReasonText, "404")
+
+	info = importVideo(service, queue, "/large.mp4")
+	require.Equal(t, selfhosted.StatusError, info.Status.State)
+	require.Contains(t, info.Status.ErrorReasonText,
+		selfhosted.ErrUploadTooLarge.Error())
+
+	// The source server listens on a loopback address.
+	service, queue = newService(false)
+
+	info = importVideo(service, queue, "/video.mp4")
+	require.Equal(t, selfhosted.StatusError, info.Status.State)
+	require.Contains(t, info.Status.ErrorReasonText,
+		selfhosted.ErrPrivateAddress.Error())
+}
@@ -7,19 +7,62 @@ package sqlc
 
 import (
 	"context"
+	"database/sql"
 )
 
+const deleteMacaroonsByPaymentHash = `-- name: DeleteMacaroonsByPaymentHash :execrows
+DELETE FROM macaroon_credentials
+WHERE substr(identifier, 5, 64) = ?1
+`
+
+func (q *Queries) DeleteMacaroonsByPaymentHash(ctx context.Context, paymentHash interface{}) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteMacaroonsByPaymentHash, paymentHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const disableMacaroonsByPaymentHash = `-- name: DisableMacaroonsByPaymentHash :exec
+UPDATE macaroon_credentials
+SET disabled = TRUE
+WHERE substr(identifier, 5, 64) = ?1
+`
+
+func (q *Queries) DisableMacaroonsByPaymentHash(ctx context.Context, paymentHash interface{}) error {
+	_, err := q.db.ExecContext(ctx, disableMacaroonsByPaymentHash, paymentHash)
+	return err
+}
+
+const getEncodedMacaroonByIdentifier = `-- name: GetEncodedMacaroonByIdentifier :one
+SELECT encoded_base_macaroon
+FROM macaroon_credentials
+WHERE identifier = ?
+`
+
+func (q *Queries) GetEncodedMacaroonByIdentifier(ctx context.Context, identifier string) (string, error) {
+	row := q.db.QueryRowContext(ctx, getEncodedMacaroonByIdentifier, identifier)
+	var encoded_base_macaroon string
+	err := row.Scan(&encoded_base_macaroon)
+	return encoded_base_macaroon, err
+}
+
 const getRootKeyByIdentifier = `-- name: GetRootKeyByIdentifier :one
-SELECT root_key
+SELECT root_key, disabled
 FROM macaroon_credentials
 WHERE identifier = ?
 `
 
-func (q *Queries) GetRootKeyByIdentifier(ctx context.Context, identifier string) (string, error) {
+type GetRootKeyByIdentifierRow struct {
+	RootKey  string
+	Disabled bool
+}
+
+func (q *Queries) GetRootKeyByIdentifier(ctx context.Context, identifier string) (GetRootKeyByIdentifierRow, error) {
 	row := q.db.QueryRowContext(ctx, getRootKeyByIdentifier, identifier)
-	var root_key string
-	err := row.Scan(&root_key)
-	return root_key, err
+	var i GetRootKeyByIdentifierRow
+	err := row.Scan(&i.RootKey, &i.Disabled)
+	return i, err
 }
 
 const insertMacaroonToken = `-- name: InsertMacaroonToken :one
@@ -50,3 +93,42 @@ func (q *Queries) InsertMacaroonToken(ctx context.Context, arg InsertMacaroonTok
 	err := row.Scan(&id)
 	return id, err
 }
+
+const listPaidCredentialsByPubKey = `-- name: ListPaidCredentialsByPubKey :many
+SELECT i.payment_hash, mc.encoded_base_macaroon, i.preimage
+FROM macaroon_credentials mc
+JOIN invoice_status i ON i.payment_hash = substr(mc.identifier, 5, 64)
+WHERE substr(mc.identifier, 69) = ?1
+  AND mc.disabled = FALSE
+  AND i.settled = TRUE
+ORDER BY mc.created_at DESC
+`
+
+type ListPaidCredentialsByPubKeyRow struct {
+	PaymentHash         string
+	EncodedBaseMacaroon string
+	Preimage            sql.NullString
+}
+
+func (q *Queries) ListPaidCredentialsByPubKey(ctx context.Context, pubKey interface{}) ([]ListPaidCredentialsByPubKeyRow, error) {
+	rows, err := q.db.QueryContext(ctx, listPaidCredentialsByPubKey, pubKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPaidCredentialsByPubKeyRow
+	for rows.Next() {
+		var i ListPaidCredentialsByPubKeyRow
+		if err := rows.Scan(&i.PaymentHash, &i.EncodedBaseMacaroon, &i.Preimage); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
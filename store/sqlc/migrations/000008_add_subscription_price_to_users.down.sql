@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN subscription_price_in_cents;
@@ -0,0 +1,3 @@
+-- subscription_price_in_cents is the monthly price a creator charges for
+-- access to all their videos. NULL means subscriptions are disabled.
+ALTER TABLE users ADD COLUMN subscription_price_in_cents INTEGER;
@@ -0,0 +1,19 @@
+-- video_rentals stores the time-limited access options that a creator
+-- offers for a video next to the full purchase.
+CREATE TABLE IF NOT EXISTS video_rentals (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+
+    -- external_id is the external ID of the rented video.
+    external_id TEXT NOT NULL,
+
+    -- duration_in_hours is the time the rental grants access to the video.
+    duration_in_hours INTEGER NOT NULL,
+
+    -- price_in_cents is the price of the rental.
+    price_in_cents INTEGER NOT NULL,
+
+    -- created_at is the timestamp when the rental option was created.
+    created_at DATETIME NOT NULL,
+
+    UNIQUE (external_id, duration_in_hours)
+);
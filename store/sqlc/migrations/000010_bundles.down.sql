@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS bundle_videos_external_id_idx;
+DROP TABLE IF EXISTS bundle_videos;
+DROP TABLE IF EXISTS bundles;
@@ -0,0 +1,38 @@
+-- bundles groups an ordered list of videos of a creator that are sold
+-- together as a single L402 product.
+CREATE TABLE IF NOT EXISTS bundles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    external_id TEXT NOT NULL UNIQUE,
+    user_id INTEGER NOT NULL,
+
+    -- l402 product info
+    title TEXT NOT NULL,
+    description TEXT NOT NULL,
+    cover_url TEXT NOT NULL,
+    price_in_cents INTEGER NOT NULL,
+
+    -- deleted bundles are no longer sold but keep granting access to the
+    -- users that already bought them.
+    deleted BOOLEAN NOT NULL DEFAULT FALSE,
+
+    created_at DATETIME NOT NULL,
+
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- bundle_videos stores the videos of a bundle in order.
+CREATE TABLE IF NOT EXISTS bundle_videos (
+    bundle_id INTEGER NOT NULL,
+
+    -- external_id is the external ID of the video in the bundle.
+    external_id TEXT NOT NULL,
+
+    -- position is the index of the video in the bundle, starting at 0.
+    position INTEGER NOT NULL,
+
+    PRIMARY KEY (bundle_id, external_id),
+    FOREIGN KEY (bundle_id) REFERENCES bundles(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS bundle_videos_external_id_idx
+    ON bundle_videos (external_id);
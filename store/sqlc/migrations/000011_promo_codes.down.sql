@@ -0,0 +1,3 @@
+ALTER TABLE offers DROP COLUMN promo_code_id;
+DROP TABLE IF EXISTS promo_code_videos;
+DROP TABLE IF EXISTS promo_codes;
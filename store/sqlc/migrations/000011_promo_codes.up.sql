@@ -0,0 +1,47 @@
+-- promo_codes stores the discount codes that creators offer on their videos.
+CREATE TABLE IF NOT EXISTS promo_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+
+    -- user_id is the user ID of the creator owning the code.
+    user_id INTEGER NOT NULL REFERENCES users(id),
+
+    -- code is the upper-cased code buyers pass when requesting a challenge.
+    code TEXT NOT NULL,
+
+    -- discount_type is either percentage or fixed.
+    discount_type TEXT NOT NULL,
+
+    -- discount_value is the percentage (1-100) or the amount in cents
+    -- discounted from the price.
+    discount_value INTEGER NOT NULL,
+
+    -- expires_at is the timestamp after which the code can not be used.
+    -- NULL means the code does not expire.
+    expires_at DATETIME,
+
+    -- max_redemptions is the number of purchases the code can be used for.
+    -- NULL means there is no limit.
+    max_redemptions INTEGER,
+
+    -- redemptions is the number of purchases recorded with the code.
+    redemptions INTEGER NOT NULL DEFAULT 0,
+
+    -- created_at is the timestamp when the code was created.
+    created_at DATETIME NOT NULL,
+
+    UNIQUE (user_id, code)
+);
+
+-- promo_code_videos stores the videos a promo code is scoped to. Codes
+-- without videos apply to all the videos of the creator.
+CREATE TABLE IF NOT EXISTS promo_code_videos (
+    promo_code_id INTEGER NOT NULL REFERENCES promo_codes(id),
+
+    -- external_id is the external ID of the discounted video.
+    external_id TEXT NOT NULL,
+
+    PRIMARY KEY (promo_code_id, external_id)
+);
+
+-- promo_code_id is the promo code applied to the price of the offer.
+ALTER TABLE offers ADD COLUMN promo_code_id INTEGER REFERENCES promo_codes(id);
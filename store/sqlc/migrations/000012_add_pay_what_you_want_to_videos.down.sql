@@ -0,0 +1,2 @@
+ALTER TABLE videos DROP COLUMN suggested_price_in_cents;
+ALTER TABLE videos DROP COLUMN pay_what_you_want;
@@ -0,0 +1,7 @@
+-- pay_what_you_want lets buyers choose the amount they pay for a video, with
+-- price_in_cents as the minimum.
+ALTER TABLE videos ADD COLUMN pay_what_you_want BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- suggested_price_in_cents is the amount suggested to the buyers of
+-- pay-what-you-want videos. NULL means the minimum is suggested.
+ALTER TABLE videos ADD COLUMN suggested_price_in_cents INTEGER;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS offers_recipient_pub_key_idx;
+
+ALTER TABLE purchases DROP COLUMN recipient_pub_key;
+ALTER TABLE purchases DROP COLUMN payer_pub_key;
+
+ALTER TABLE offers DROP COLUMN recipient_pub_key;
+ALTER TABLE offers DROP COLUMN payer_pub_key;
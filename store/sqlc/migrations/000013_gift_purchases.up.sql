@@ -0,0 +1,11 @@
+-- payer_pub_key is the public key that signed the challenge request and
+-- recipient_pub_key the one the credentials are bound to. They only differ
+-- for gifts.
+ALTER TABLE offers ADD COLUMN payer_pub_key TEXT;
+ALTER TABLE offers ADD COLUMN recipient_pub_key TEXT;
+
+ALTER TABLE purchases ADD COLUMN payer_pub_key TEXT;
+ALTER TABLE purchases ADD COLUMN recipient_pub_key TEXT;
+
+CREATE INDEX IF NOT EXISTS offers_recipient_pub_key_idx
+    ON offers (recipient_pub_key, external_id);
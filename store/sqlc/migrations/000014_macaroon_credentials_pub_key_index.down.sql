@@ -0,0 +1 @@
+DROP INDEX IF EXISTS macaroon_credentials_pub_key_idx;
@@ -0,0 +1,5 @@
+-- The hex identifier of a v0 macaroon is the version (4 chars), followed by
+-- the payment hash (64 chars) and the public key of the user. This index
+-- allows listing the credentials minted for a public key.
+CREATE INDEX IF NOT EXISTS macaroon_credentials_pub_key_idx
+    ON macaroon_credentials (substr(identifier, 69));
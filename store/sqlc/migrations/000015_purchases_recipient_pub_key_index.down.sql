@@ -0,0 +1 @@
+DROP INDEX IF EXISTS purchases_recipient_pub_key_idx;
@@ -0,0 +1,4 @@
+-- The recipient of a purchase is the buyer, except for gifts. This index
+-- allows listing the library of a public key.
+CREATE INDEX IF NOT EXISTS purchases_recipient_pub_key_idx
+    ON purchases (recipient_pub_key);
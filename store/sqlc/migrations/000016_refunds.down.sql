@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS refunds;
+
+ALTER TABLE purchases DROP COLUMN amount_in_sats;
+ALTER TABLE offers DROP COLUMN amount_in_sats;
@@ -0,0 +1,78 @@
+-- amount_in_sats is the amount of the invoice paid for the offer, used to
+-- cap refunds. Zero for the offers created before it was recorded.
+ALTER TABLE offers ADD COLUMN amount_in_sats BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE purchases ADD COLUMN amount_in_sats BIGINT NOT NULL DEFAULT 0;
+
+-- refunds stores the refund requests of the buyers.
+CREATE TABLE IF NOT EXISTS refunds (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+
+    -- payment_hash is the payment hash of the refunded purchase. A purchase
+    -- can only be refunded once.
+    payment_hash TEXT NOT NULL UNIQUE REFERENCES purchases(payment_hash),
+
+    -- user_id is the user ID of the creator that sold the purchase.
+    user_id BIGINT NOT NULL REFERENCES users(id),
+
+    -- requester_pub_key is the public key that signed the request.
+    requester_pub_key TEXT NOT NULL,
+
+    -- reason is the explanation given by the buyer.
+    reason TEXT NOT NULL,
+
+    -- payment_request is the invoice supplied by the buyer to be paid.
+    payment_request TEXT NOT NULL,
+
+    -- amount_in_sats is the amount of the invoice to be paid.
+    amount_in_sats BIGINT NOT NULL,
+
+    -- status is one of pending, approved, rejected, paid or failed.
+    status TEXT NOT NULL,
+
+    -- resolution is the reason of a rejection or of a failed payout.
+    resolution TEXT NOT NULL DEFAULT '',
+
+    -- resolved_by is the user ID of the creator or admin that approved or
+    -- rejected the refund.
+    resolved_by BIGINT REFERENCES users(id),
+
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS refunds_user_id_idx ON refunds (user_id);
+
+-- ledger_entries stores the movements of the balance of the creators. Sales
+-- add to it and refunds reverse them with negative amounts.
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+
+    -- user_id is the user ID of the creator.
+    user_id BIGINT NOT NULL REFERENCES users(id),
+
+    -- payment_hash is the payment hash of the purchase.
+    payment_hash TEXT NOT NULL,
+
+    -- entry_type is either sale or refund.
+    entry_type TEXT NOT NULL,
+
+    amount_in_cents BIGINT NOT NULL,
+    amount_in_sats BIGINT NOT NULL,
+    currency TEXT NOT NULL,
+
+    created_at DATETIME NOT NULL,
+
+    UNIQUE (payment_hash, entry_type)
+);
+
+CREATE INDEX IF NOT EXISTS ledger_entries_user_id_idx
+    ON ledger_entries (user_id);
+
+-- Every purchase recorded so far is a sale.
+INSERT INTO ledger_entries (
+    user_id, payment_hash, entry_type, amount_in_cents, amount_in_sats,
+    currency, created_at
+)
+SELECT user_id, payment_hash, 'sale', price_in_cents, amount_in_sats,
+    currency, created_at
+FROM purchases;
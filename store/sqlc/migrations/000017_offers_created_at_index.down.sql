@@ -0,0 +1 @@
+DROP INDEX IF EXISTS offers_created_at_idx;
@@ -0,0 +1,2 @@
+-- Unpaid offers are garbage collected by creation date.
+CREATE INDEX IF NOT EXISTS offers_created_at_idx ON offers (created_at);
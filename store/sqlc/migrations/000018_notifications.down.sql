@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS pending_notifications_user_id_idx;
+DROP TABLE IF EXISTS pending_notifications;
+ALTER TABLE users DROP COLUMN notification_preference;
@@ -0,0 +1,17 @@
+-- How a creator wants to be notified of new purchases and uploads: one of
+-- instant, daily or off.
+ALTER TABLE users ADD COLUMN notification_preference TEXT NOT NULL
+    DEFAULT 'instant';
+
+-- Events waiting for the daily digest of their user.
+CREATE TABLE IF NOT EXISTS pending_notifications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id BIGINT NOT NULL REFERENCES users(id),
+    event_type TEXT NOT NULL,
+    external_id TEXT NOT NULL,
+    payment_hash TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS pending_notifications_user_id_idx
+    ON pending_notifications (user_id);
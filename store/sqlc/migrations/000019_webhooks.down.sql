@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS webhook_deliveries_status_next_attempt_at_idx;
+DROP INDEX IF EXISTS webhook_deliveries_endpoint_id_idx;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP INDEX IF EXISTS webhook_endpoints_user_id_idx;
+DROP TABLE IF EXISTS webhook_endpoints;
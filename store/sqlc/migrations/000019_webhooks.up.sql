@@ -0,0 +1,35 @@
+-- Endpoints where the events of a creator are pushed. Events is a comma
+-- separated list of the subscribed event types, empty for all of them.
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id BIGINT NOT NULL REFERENCES users(id),
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS webhook_endpoints_user_id_idx
+    ON webhook_endpoints (user_id);
+
+-- Every event sent to an endpoint, with the result of its last attempt.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    endpoint_id BIGINT NOT NULL REFERENCES webhook_endpoints(id),
+    user_id BIGINT NOT NULL REFERENCES users(id),
+    event_type TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME NOT NULL,
+    last_status_code INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_endpoint_id_idx
+    ON webhook_deliveries (endpoint_id);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_status_next_attempt_at_idx
+    ON webhook_deliveries (status, next_attempt_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS jobs_status_run_at_idx;
+DROP TABLE IF EXISTS jobs;
@@ -0,0 +1,3 @@
+-- The reason Cloudflare Stream gave for failing to process the upload of a
+-- video, empty while it is processing or once it is ready.
+ALTER TABLE videos ADD COLUMN processing_error TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE videos DROP COLUMN status;
+ALTER TABLE videos RENAME COLUMN status_reason TO processing_error;
@@ -0,0 +1,8 @@
+-- The processing status of a video: awaiting_upload, processing, ready, failed
+-- or expired. The status reason explains the failed and expired ones.
+ALTER TABLE videos RENAME COLUMN processing_error TO status_reason;
+ALTER TABLE videos ADD COLUMN status TEXT NOT NULL DEFAULT 'awaiting_upload';
+
+UPDATE videos SET status = 'ready' WHERE ready_to_stream = TRUE;
+UPDATE videos SET status = 'failed'
+WHERE ready_to_stream = FALSE AND status_reason != '';
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS self_hosted_videos;
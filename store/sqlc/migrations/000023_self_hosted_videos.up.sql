@@ -0,0 +1,12 @@
+-- Videos uploaded to the self-hosted streaming backend. The status follows
+-- the states of Cloudflare Stream: pendingupload, inprogress, ready and
+-- error.
+CREATE TABLE IF NOT EXISTS self_hosted_videos (
+    external_id TEXT PRIMARY KEY,
+    status TEXT NOT NULL,
+    status_reason TEXT NOT NULL DEFAULT '',
+    size_in_bytes INTEGER NOT NULL DEFAULT 0,
+    upload_expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
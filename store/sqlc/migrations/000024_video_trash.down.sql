@@ -0,0 +1 @@
+DROP TABLE IF EXISTS video_trash;
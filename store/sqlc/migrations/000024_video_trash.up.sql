@@ -0,0 +1,9 @@
+-- Deleted videos are kept in the trash until purge_at, so their buyers can
+-- still stream them and their creators can restore them. Then their assets
+-- are deleted and purged_at is set.
+CREATE TABLE IF NOT EXISTS video_trash (
+    external_id TEXT PRIMARY KEY REFERENCES videos(external_id),
+    deleted_at DATETIME NOT NULL,
+    purge_at DATETIME NOT NULL,
+    purged_at DATETIME
+);
@@ -0,0 +1,3 @@
+ALTER TABLE videos DROP COLUMN cover_og_url;
+ALTER TABLE videos DROP COLUMN cover_hero_url;
+ALTER TABLE videos DROP COLUMN cover_card_url;
@@ -0,0 +1,6 @@
+-- The URLs of the variants of the cover image of a video, resized for the
+-- video cards, the hero of the video page and the OpenGraph previews. Empty
+-- for the videos created before the variants existed.
+ALTER TABLE videos ADD COLUMN cover_card_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE videos ADD COLUMN cover_hero_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE videos ADD COLUMN cover_og_url TEXT NOT NULL DEFAULT '';
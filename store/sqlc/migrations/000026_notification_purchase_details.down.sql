@@ -0,0 +1,3 @@
+ALTER TABLE pending_notifications DROP COLUMN currency;
+ALTER TABLE pending_notifications DROP COLUMN price_in_cents;
+ALTER TABLE pending_notifications DROP COLUMN title;
@@ -0,0 +1,7 @@
+-- The title and the amount of the purchases waiting for the daily digest of
+-- their creator. Empty for the other events.
+ALTER TABLE pending_notifications ADD COLUMN title TEXT NOT NULL DEFAULT '';
+ALTER TABLE pending_notifications ADD COLUMN price_in_cents BIGINT NOT NULL
+    DEFAULT 0;
+ALTER TABLE pending_notifications ADD COLUMN currency TEXT NOT NULL
+    DEFAULT '';
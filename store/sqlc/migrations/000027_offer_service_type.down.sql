@@ -0,0 +1 @@
+ALTER TABLE offers DROP COLUMN service_type;
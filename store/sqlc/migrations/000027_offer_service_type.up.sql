@@ -0,0 +1,9 @@
+-- The type of the service sold by an offer: videos, bundles or
+-- subscriptions, so a purchase can be recorded from the offer alone.
+ALTER TABLE offers ADD COLUMN service_type TEXT NOT NULL DEFAULT '';
+
+UPDATE offers SET service_type = CASE
+    WHEN external_id LIKE 'subscription-%' THEN 'subscriptions'
+    WHEN external_id IN (SELECT external_id FROM bundles) THEN 'bundles'
+    ELSE 'videos'
+END;
@@ -9,6 +9,24 @@ import (
 	"time"
 )
 
+type Bundle struct {
+	ID           int64
+	ExternalID   string
+	UserID       int64
+	Title        string
+	Description  string
+	CoverUrl     string
+	PriceInCents int64
+	Deleted      bool
+	CreatedAt    time.Time
+}
+
+type BundleVideo struct {
+	BundleID   int64
+	ExternalID string
+	Position   int64
+}
+
 type InvoiceStatus struct {
 	PaymentHash string
 	Settled     bool
@@ -17,6 +35,30 @@ type InvoiceStatus struct {
 	UpdatedAt   time.Time
 }
 
+type Job struct {
+	ID          int64
+	Kind        string
+	Payload     string
+	Status      string
+	Attempts    int64
+	MaxAttempts int64
+	RunAt       time.Time
+	LastError   string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type LedgerEntry struct {
+	ID            int64
+	UserID        int64
+	PaymentHash   string
+	EntryType     string
+	AmountInCents int64
+	AmountInSats  int64
+	Currency      string
+	CreatedAt     time.Time
+}
+
 type MacaroonCredential struct {
 	ID                  int64
 	Identifier          string
@@ -27,26 +69,88 @@ type MacaroonCredential struct {
 }
 
 type Offer struct {
+	ID              int64
+	UserID          int64
+	ExternalID      string
+	PaymentHash     string
+	PriceInCents    int64
+	Currency        string
+	ExpirationDate  sql.NullTime
+	CreatedAt       time.Time
+	PromoCodeID     sql.NullInt64
+	PayerPubKey     sql.NullString
+	RecipientPubKey sql.NullString
+	AmountInSats    int64
+	ServiceType     string
+}
+
+type PendingNotification struct {
+	ID           int64
+	UserID       int64
+	EventType    string
+	ExternalID   string
+	PaymentHash  string
+	CreatedAt    time.Time
+	Title        string
+	PriceInCents int64
+	Currency     string
+}
+
+type PromoCode struct {
 	ID             int64
 	UserID         int64
-	ExternalID     string
-	PaymentHash    string
-	PriceInCents   int64
-	Currency       string
-	ExpirationDate sql.NullTime
+	Code           string
+	DiscountType   string
+	DiscountValue  int64
+	ExpiresAt      sql.NullTime
+	MaxRedemptions sql.NullInt64
+	Redemptions    int64
 	CreatedAt      time.Time
 }
 
+type PromoCodeVideo struct {
+	PromoCodeID int64
+	ExternalID  string
+}
+
 type Purchase struct {
-	ID             int64
-	UserID         int64
-	ExternalID     string
-	ServiceType    string
-	PriceInCents   int64
-	Currency       string
-	ExpirationDate sql.NullTime
-	PaymentHash    string
-	CreatedAt      time.Time
+	ID              int64
+	UserID          int64
+	ExternalID      string
+	ServiceType     string
+	PriceInCents    int64
+	Currency        string
+	ExpirationDate  sql.NullTime
+	PaymentHash     string
+	CreatedAt       time.Time
+	PayerPubKey     sql.NullString
+	RecipientPubKey sql.NullString
+	AmountInSats    int64
+}
+
+type Refund struct {
+	ID              int64
+	PaymentHash     string
+	UserID          int64
+	RequesterPubKey string
+	Reason          string
+	PaymentRequest  string
+	AmountInSats    int64
+	Status          string
+	Resolution      string
+	ResolvedBy      sql.NullInt64
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+type SelfHostedVideo struct {
+	ExternalID      string
+	Status          string
+	StatusReason    string
+	SizeInBytes     int64
+	UploadExpiresAt time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
 }
 
 type Token struct {
@@ -58,30 +162,78 @@ type Token struct {
 }
 
 type User struct {
-	ID               int64
-	Email            string
-	LightningAddress sql.NullString
-	Verified         bool
-	CreatedAt        time.Time
+	ID                       int64
+	Email                    string
+	LightningAddress         sql.NullString
+	Verified                 bool
+	CreatedAt                time.Time
+	SubscriptionPriceInCents sql.NullInt64
+	NotificationPreference   string
 }
 
 type Video struct {
-	ID                int64
-	ExternalID        string
-	UserID            int64
-	Title             string
-	Description       string
-	CoverUrl          string
-	PriceInCents      int64
-	TotalViews        int64
-	ThumbnailUrl      sql.NullString
-	HlsUrl            sql.NullString
-	DashUrl           sql.NullString
-	DurationInSeconds sql.NullFloat64
-	SizeInBytes       sql.NullInt64
-	InputHeight       sql.NullInt64
-	InputWidth        sql.NullInt64
-	ReadyToStream     bool
-	CreatedAt         time.Time
-	Deleted           bool
+	ID                    int64
+	ExternalID            string
+	UserID                int64
+	Title                 string
+	Description           string
+	CoverUrl              string
+	PriceInCents          int64
+	TotalViews            int64
+	ThumbnailUrl          sql.NullString
+	HlsUrl                sql.NullString
+	DashUrl               sql.NullString
+	DurationInSeconds     sql.NullFloat64
+	SizeInBytes           sql.NullInt64
+	InputHeight           sql.NullInt64
+	InputWidth            sql.NullInt64
+	ReadyToStream         bool
+	CreatedAt             time.Time
+	Deleted               bool
+	PayWhatYouWant        bool
+	SuggestedPriceInCents sql.NullInt64
+	StatusReason          string
+	Status                string
+	CoverCardUrl          string
+	CoverHeroUrl          string
+	CoverOgUrl            string
+}
+
+type VideoRental struct {
+	ID              int64
+	ExternalID      string
+	DurationInHours int64
+	PriceInCents    int64
+	CreatedAt       time.Time
+}
+
+type VideoTrash struct {
+	ExternalID string
+	DeletedAt  time.Time
+	PurgeAt    time.Time
+	PurgedAt   sql.NullTime
+}
+
+type WebhookDelivery struct {
+	ID             int64
+	EndpointID     int64
+	UserID         int64
+	EventType      string
+	Payload        string
+	Status         string
+	Attempts       int64
+	NextAttemptAt  time.Time
+	LastStatusCode int64
+	LastError      string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type WebhookEndpoint struct {
+	ID        int64
+	UserID    int64
+	Url       string
+	Secret    string
+	Events    string
+	CreatedAt time.Time
 }
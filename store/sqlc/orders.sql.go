@@ -11,8 +11,24 @@ import (
 	"time"
 )
 
+const deleteUnpaidOffer = `-- name: DeleteUnpaidOffer :execrows
+DELETE FROM offers
+WHERE payment_hash = ?1
+    AND NOT EXISTS (
+        SELECT 1 FROM purchases WHERE payment_hash = ?1
+    )
+`
+
+func (q *Queries) DeleteUnpaidOffer(ctx context.Context, paymentHash string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUnpaidOffer, paymentHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getOfferByPaymentHash = `-- name: GetOfferByPaymentHash :one
-SELECT id, user_id, external_id, payment_hash, price_in_cents, currency, expiration_date, created_at
+SELECT id, user_id, external_id, payment_hash, price_in_cents, currency, expiration_date, created_at, promo_code_id, payer_pub_key, recipient_pub_key, amount_in_sats, service_type
 FROM offers
 WHERE payment_hash = ?
 `
@@ -29,12 +45,17 @@ func (q *Queries) GetOfferByPaymentHash(ctx context.Context, paymentHash string)
 		&i.Currency,
 		&i.ExpirationDate,
 		&i.CreatedAt,
+		&i.PromoCodeID,
+		&i.PayerPubKey,
+		&i.RecipientPubKey,
+		&i.AmountInSats,
+		&i.ServiceType,
 	)
 	return i, err
 }
 
 const getPurchaseByPaymentHash = `-- name: GetPurchaseByPaymentHash :one
-SELECT id, user_id, external_id, service_type, price_in_cents, currency, expiration_date, payment_hash, created_at
+SELECT id, user_id, external_id, service_type, price_in_cents, currency, expiration_date, payment_hash, created_at, payer_pub_key, recipient_pub_key, amount_in_sats
 FROM purchases
 WHERE payment_hash = ?
 `
@@ -52,6 +73,9 @@ func (q *Queries) GetPurchaseByPaymentHash(ctx context.Context, paymentHash stri
 		&i.ExpirationDate,
 		&i.PaymentHash,
 		&i.CreatedAt,
+		&i.PayerPubKey,
+		&i.RecipientPubKey,
+		&i.AmountInSats,
 	)
 	return i, err
 }
@@ -59,20 +83,26 @@ func (q *Queries) GetPurchaseByPaymentHash(ctx context.Context, paymentHash stri
 const insertOffer = `-- name: InsertOffer :one
 INSERT INTO offers (
     user_id, external_id, payment_hash, price_in_cents, currency, expiration_date,
-    created_at
+    promo_code_id, payer_pub_key, recipient_pub_key, amount_in_sats,
+    service_type, created_at
 ) VALUES (
-    ?, ?, ?, ?, ?, ?, ?
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
 ) RETURNING id
 `
 
 type InsertOfferParams struct {
-	UserID         int64
-	ExternalID     string
-	PaymentHash    string
-	PriceInCents   int64
-	Currency       string
-	ExpirationDate sql.NullTime
-	CreatedAt      time.Time
+	UserID          int64
+	ExternalID      string
+	PaymentHash     string
+	PriceInCents    int64
+	Currency        string
+	ExpirationDate  sql.NullTime
+	PromoCodeID     sql.NullInt64
+	PayerPubKey     sql.NullString
+	RecipientPubKey sql.NullString
+	AmountInSats    int64
+	ServiceType     string
+	CreatedAt       time.Time
 }
 
 func (q *Queries) InsertOffer(ctx context.Context, arg InsertOfferParams) (int64, error) {
@@ -83,6 +113,11 @@ func (q *Queries) InsertOffer(ctx context.Context, arg InsertOfferParams) (int64
 		arg.PriceInCents,
 		arg.Currency,
 		arg.ExpirationDate,
+		arg.PromoCodeID,
+		arg.PayerPubKey,
+		arg.RecipientPubKey,
+		arg.AmountInSats,
+		arg.ServiceType,
 		arg.CreatedAt,
 	)
 	var id int64
@@ -125,3 +160,183 @@ func (q *Queries) InsertPurchase(ctx context.Context, arg InsertPurchaseParams)
 	err := row.Scan(&id)
 	return id, err
 }
+
+const insertPurchaseFromOffer = `-- name: InsertPurchaseFromOffer :execrows
+INSERT INTO purchases (
+    user_id, external_id, service_type, price_in_cents, currency,
+    expiration_date, payment_hash, payer_pub_key, recipient_pub_key,
+    amount_in_sats, created_at
+)
+SELECT
+    o.user_id, o.external_id, ?1,
+    o.price_in_cents, o.currency, o.expiration_date, o.payment_hash,
+    o.payer_pub_key, o.recipient_pub_key, o.amount_in_sats,
+    ?2
+FROM offers o
+WHERE o.payment_hash = ?3
+ON CONFLICT (payment_hash) DO NOTHING
+`
+
+type InsertPurchaseFromOfferParams struct {
+	ServiceType string
+	CreatedAt   time.Time
+	PaymentHash string
+}
+
+func (q *Queries) InsertPurchaseFromOffer(ctx context.Context, arg InsertPurchaseFromOfferParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, insertPurchaseFromOffer, arg.ServiceType, arg.CreatedAt, arg.PaymentHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listRecipientPurchases = `-- name: ListRecipientPurchases :many
+SELECT id, user_id, external_id, service_type, price_in_cents, currency, expiration_date, payment_hash, created_at, payer_pub_key, recipient_pub_key, amount_in_sats
+FROM purchases
+WHERE recipient_pub_key = ?
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListRecipientPurchases(ctx context.Context, recipientPubKey sql.NullString) ([]Purchase, error) {
+	rows, err := q.db.QueryContext(ctx, listRecipientPurchases, recipientPubKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Purchase
+	for rows.Next() {
+		var i Purchase
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ExternalID,
+			&i.ServiceType,
+			&i.PriceInCents,
+			&i.Currency,
+			&i.ExpirationDate,
+			&i.PaymentHash,
+			&i.CreatedAt,
+			&i.PayerPubKey,
+			&i.RecipientPubKey,
+			&i.AmountInSats,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listExpiredUnpaidOffers = `-- name: ListExpiredUnpaidOffers :many
+SELECT o.id, o.user_id, o.external_id, o.payment_hash, o.price_in_cents, o.currency, o.expiration_date, o.created_at, o.promo_code_id, o.payer_pub_key, o.recipient_pub_key, o.amount_in_sats, o.service_type
+FROM offers o
+LEFT JOIN purchases p ON p.payment_hash = o.payment_hash
+LEFT JOIN invoice_status s ON s.payment_hash = o.payment_hash
+WHERE p.id IS NULL
+    AND (s.settled IS NULL OR s.settled = FALSE)
+    AND o.created_at < ?
+    AND o.id > ?
+ORDER BY o.id
+LIMIT ?
+`
+
+type ListExpiredUnpaidOffersParams struct {
+	CreatedAt time.Time
+	ID        int64
+	Limit     int64
+}
+
+func (q *Queries) ListExpiredUnpaidOffers(ctx context.Context, arg ListExpiredUnpaidOffersParams) ([]Offer, error) {
+	rows, err := q.db.QueryContext(ctx, listExpiredUnpaidOffers, arg.CreatedAt, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Offer
+	for rows.Next() {
+		var i Offer
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ExternalID,
+			&i.PaymentHash,
+			&i.PriceInCents,
+			&i.Currency,
+			&i.ExpirationDate,
+			&i.CreatedAt,
+			&i.PromoCodeID,
+			&i.PayerPubKey,
+			&i.RecipientPubKey,
+			&i.AmountInSats,
+			&i.ServiceType,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRecipientOffers = `-- name: ListRecipientOffers :many
+SELECT id, user_id, external_id, payment_hash, price_in_cents, currency, expiration_date, created_at, promo_code_id, payer_pub_key, recipient_pub_key, amount_in_sats, service_type
+FROM offers
+WHERE external_id = ? AND recipient_pub_key = ?
+ORDER BY created_at DESC
+LIMIT ?
+`
+
+type ListRecipientOffersParams struct {
+	ExternalID      string
+	RecipientPubKey sql.NullString
+	Limit           int64
+}
+
+func (q *Queries) ListRecipientOffers(ctx context.Context, arg ListRecipientOffersParams) ([]Offer, error) {
+	rows, err := q.db.QueryContext(ctx, listRecipientOffers, arg.ExternalID, arg.RecipientPubKey, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Offer
+	for rows.Next() {
+		var i Offer
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ExternalID,
+			&i.PaymentHash,
+			&i.PriceInCents,
+			&i.Currency,
+			&i.ExpirationDate,
+			&i.CreatedAt,
+			&i.PromoCodeID,
+			&i.PayerPubKey,
+			&i.RecipientPubKey,
+			&i.AmountInSats,
+			&i.ServiceType,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
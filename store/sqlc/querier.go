@@ -6,35 +6,113 @@ package sqlc
 
 import (
 	"context"
+	"database/sql"
 	"time"
 )
 
 type Querier interface {
+	CreateBundle(ctx context.Context, arg CreateBundleParams) (Bundle, error)
+	CreatePromoCode(ctx context.Context, arg CreatePromoCodeParams) (PromoCode, error)
+	CreateRefund(ctx context.Context, arg CreateRefundParams) (Refund, error)
+	CreateSelfHostedVideo(ctx context.Context, arg CreateSelfHostedVideoParams) error
 	CreateToken(ctx context.Context, arg CreateTokenParams) (Token, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (int64, error)
 	CreateVideo(ctx context.Context, arg CreateVideoParams) (Video, error)
-	DeleteExpiredTokens(ctx context.Context, expiration time.Time) error
+	CreateWebhookEndpoint(ctx context.Context, arg CreateWebhookEndpointParams) (WebhookEndpoint, error)
+	DeleteBundle(ctx context.Context, externalID string) error
+	DeleteBundleVideos(ctx context.Context, bundleID int64) error
+	DeleteEndpointWebhookDeliveries(ctx context.Context, endpointID int64) error
+	DeleteExpiredTokens(ctx context.Context, expiration time.Time) (int64, error)
+	DeleteLeasedJob(ctx context.Context, arg DeleteLeasedJobParams) (int64, error)
+	DeleteMacaroonsByPaymentHash(ctx context.Context, paymentHash interface{}) (int64, error)
+	DeletePendingNotifications(ctx context.Context, arg DeletePendingNotificationsParams) error
+	DeletePromoCode(ctx context.Context, arg DeletePromoCodeParams) (int64, error)
+	DeletePromoCodeVideos(ctx context.Context, arg DeletePromoCodeVideosParams) error
+	DeleteSelfHostedVideo(ctx context.Context, externalID string) error
 	DeleteToken(ctx context.Context, token string) error
+	DeleteUnpaidOffer(ctx context.Context, paymentHash string) (int64, error)
 	DeleteVideo(ctx context.Context, externalID string) error
+	DeleteVideoRental(ctx context.Context, arg DeleteVideoRentalParams) error
+	DeleteVideoTrash(ctx context.Context, externalID string) error
+	DeleteWebhookEndpoint(ctx context.Context, arg DeleteWebhookEndpointParams) (int64, error)
+	DisableMacaroonsByPaymentHash(ctx context.Context, paymentHash interface{}) error
+	ExtendJobLease(ctx context.Context, arg ExtendJobLeaseParams) (int64, error)
+	GetBundleByExternalID(ctx context.Context, externalID string) (Bundle, error)
+	GetDeletedVideoByExternalID(ctx context.Context, externalID string) (GetDeletedVideoByExternalIDRow, error)
+	GetEncodedMacaroonByIdentifier(ctx context.Context, identifier string) (string, error)
 	GetInvoiceStatus(ctx context.Context, paymentHash string) (InvoiceStatus, error)
 	GetOfferByPaymentHash(ctx context.Context, paymentHash string) (Offer, error)
+	GetPromoCode(ctx context.Context, arg GetPromoCodeParams) (PromoCode, error)
 	GetPurchaseByPaymentHash(ctx context.Context, paymentHash string) (Purchase, error)
-	GetRootKeyByIdentifier(ctx context.Context, identifier string) (string, error)
+	GetPurchasedBundle(ctx context.Context, externalID string) (Bundle, error)
+	GetRefund(ctx context.Context, id int64) (Refund, error)
+	GetRefundByPaymentHash(ctx context.Context, paymentHash string) (Refund, error)
+	GetRootKeyByIdentifier(ctx context.Context, identifier string) (GetRootKeyByIdentifierRow, error)
+	GetSelfHostedVideo(ctx context.Context, externalID string) (SelfHostedVideo, error)
 	GetToken(ctx context.Context, token string) (Token, error)
 	GetUserByID(ctx context.Context, id int64) (User, error)
 	GetUserIDByEmail(ctx context.Context, email string) (int64, error)
 	GetVideoByExternalID(ctx context.Context, externalID string) (GetVideoByExternalIDRow, error)
+	GetVideoTrash(ctx context.Context, externalID string) (VideoTrash, error)
+	GetWebhookEndpoint(ctx context.Context, id int64) (WebhookEndpoint, error)
+	HasBundleOffers(ctx context.Context, externalID string) (int64, error)
+	IncrementPromoCodeRedemptions(ctx context.Context, paymentHash string) error
 	IncrementVideoViews(ctx context.Context, externalID string) error
+	InsertBundleVideo(ctx context.Context, arg InsertBundleVideoParams) error
+	InsertJob(ctx context.Context, arg InsertJobParams) (int64, error)
 	InsertMacaroonToken(ctx context.Context, arg InsertMacaroonTokenParams) (int64, error)
 	InsertOffer(ctx context.Context, arg InsertOfferParams) (int64, error)
+	InsertPendingNotification(ctx context.Context, arg InsertPendingNotificationParams) error
+	InsertPromoCodeVideo(ctx context.Context, arg InsertPromoCodeVideoParams) error
 	InsertPurchase(ctx context.Context, arg InsertPurchaseParams) (int64, error)
+	InsertPurchaseFromOffer(ctx context.Context, arg InsertPurchaseFromOfferParams) (int64, error)
+	InsertRefundLedgerEntry(ctx context.Context, arg InsertRefundLedgerEntryParams) error
+	InsertSaleLedgerEntry(ctx context.Context, paymentHash string) error
+	InsertUniqueJob(ctx context.Context, arg InsertUniqueJobParams) (int64, error)
+	InsertWebhookDelivery(ctx context.Context, arg InsertWebhookDeliveryParams) error
+	IsBundleVideo(ctx context.Context, arg IsBundleVideoParams) (int64, error)
+	LeaseJob(ctx context.Context, arg LeaseJobParams) (Job, error)
+	ListBundleExternalIDs(ctx context.Context) ([]string, error)
+	ListBundleMembers(ctx context.Context, bundleID int64) ([]string, error)
+	ListBundleVideos(ctx context.Context, bundleID int64) ([]string, error)
+	ListDueWebhookDeliveries(ctx context.Context, arg ListDueWebhookDeliveriesParams) ([]ListDueWebhookDeliveriesRow, error)
+	ListEndpointWebhookDeliveries(ctx context.Context, arg ListEndpointWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	ListExpiredUnpaidOffers(ctx context.Context, arg ListExpiredUnpaidOffersParams) ([]Offer, error)
+	ListPaidCredentialsByPubKey(ctx context.Context, pubKey interface{}) ([]ListPaidCredentialsByPubKeyRow, error)
+	ListPendingNotifications(ctx context.Context) ([]ListPendingNotificationsRow, error)
+	ListPromoCodeVideos(ctx context.Context, promoCodeID int64) ([]string, error)
+	ListRecipientOffers(ctx context.Context, arg ListRecipientOffersParams) ([]Offer, error)
+	ListRecipientPurchases(ctx context.Context, recipientPubKey sql.NullString) ([]Purchase, error)
+	ListRefunds(ctx context.Context) ([]Refund, error)
+	ListSelfHostedVideos(ctx context.Context) ([]SelfHostedVideo, error)
+	ListStaleUploads(ctx context.Context, createdAt time.Time) ([]Video, error)
+	ListUserBundles(ctx context.Context, userID int64) ([]Bundle, error)
+	ListUserLedgerEntries(ctx context.Context, userID int64) ([]LedgerEntry, error)
+	ListUserPromoCodes(ctx context.Context, userID int64) ([]PromoCode, error)
+	ListUserRefunds(ctx context.Context, userID int64) ([]Refund, error)
+	ListUserTrashedVideos(ctx context.Context, userID int64) ([]string, error)
 	ListUserVideos(ctx context.Context, userID int64) ([]ListUserVideosRow, error)
+	ListUserWebhookEndpoints(ctx context.Context, userID int64) ([]WebhookEndpoint, error)
+	ListVideoRentals(ctx context.Context, externalID string) ([]VideoRental, error)
+	ListVideoStates(ctx context.Context) ([]ListVideoStatesRow, error)
+	MarkVideoPurged(ctx context.Context, arg MarkVideoPurgedParams) (int64, error)
+	RedeliverWebhookDelivery(ctx context.Context, arg RedeliverWebhookDeliveryParams) (int64, error)
+	ReleaseLeasedJob(ctx context.Context, arg ReleaseLeasedJobParams) (int64, error)
+	RestoreVideo(ctx context.Context, arg RestoreVideoParams) (int64, error)
 	SearchVideos(ctx context.Context, arg SearchVideosParams) ([]Video, error)
+	UpdateBundleInfo(ctx context.Context, arg UpdateBundleInfoParams) (Bundle, error)
 	UpdateCloudflareInfo(ctx context.Context, arg UpdateCloudflareInfoParams) (Video, error)
+	UpdateRefundStatus(ctx context.Context, arg UpdateRefundStatusParams) (int64, error)
+	UpdateSelfHostedVideoStatus(ctx context.Context, arg UpdateSelfHostedVideoStatusParams) (int64, error)
 	UpdateUserLightningAddress(ctx context.Context, arg UpdateUserLightningAddressParams) error
+	UpdateUserNotificationPreference(ctx context.Context, arg UpdateUserNotificationPreferenceParams) error
+	UpdateUserSubscriptionPrice(ctx context.Context, arg UpdateUserSubscriptionPriceParams) error
 	UpdateUserVerified(ctx context.Context, arg UpdateUserVerifiedParams) error
 	UpdateVideoInfo(ctx context.Context, arg UpdateVideoInfoParams) (Video, error)
+	UpdateWebhookDeliveryAttempt(ctx context.Context, arg UpdateWebhookDeliveryAttemptParams) error
 	UpsertInvoiceStatus(ctx context.Context, arg UpsertInvoiceStatusParams) (InvoiceStatus, error)
+	UpsertVideoRental(ctx context.Context, arg UpsertVideoRentalParams) (VideoRental, error)
+	UpsertVideoTrash(ctx context.Context, arg UpsertVideoTrashParams) error
 	VerifyToken(ctx context.Context, arg VerifyTokenParams) (string, error)
 }
 
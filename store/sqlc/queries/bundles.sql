@@ -0,0 +1,69 @@
+-- name: CreateBundle :one
+INSERT INTO bundles (external_id, user_id, title, description, cover_url, price_in_cents, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetBundleByExternalID :one
+SELECT * FROM bundles
+WHERE external_id = ? AND deleted = FALSE
+LIMIT 1;
+
+-- name: GetPurchasedBundle :one
+SELECT * FROM bundles
+WHERE external_id = ?
+LIMIT 1;
+
+-- name: ListUserBundles :many
+SELECT * FROM bundles
+WHERE user_id = ? AND deleted = FALSE
+ORDER BY created_at DESC;
+
+-- name: UpdateBundleInfo :one
+UPDATE bundles
+SET
+  title = COALESCE(sqlc.narg(title), title),
+  description = COALESCE(sqlc.narg(description), description),
+  price_in_cents = COALESCE(sqlc.narg(price_in_cents), price_in_cents)
+WHERE external_id = sqlc.arg(external_id) AND deleted = FALSE
+RETURNING *;
+
+-- name: DeleteBundle :exec
+UPDATE bundles
+SET deleted = TRUE
+WHERE external_id = ?;
+
+-- name: InsertBundleVideo :exec
+INSERT INTO bundle_videos (bundle_id, external_id, position)
+VALUES (?, ?, ?);
+
+-- name: DeleteBundleVideos :exec
+DELETE FROM bundle_videos
+WHERE bundle_id = ?;
+
+-- name: ListBundleVideos :many
+SELECT bv.external_id FROM bundle_videos bv
+JOIN videos v ON v.external_id = bv.external_id
+WHERE bv.bundle_id = ? AND v.deleted = FALSE
+ORDER BY bv.position ASC;
+
+-- name: ListBundleMembers :many
+SELECT external_id FROM bundle_videos
+WHERE bundle_id = ?
+ORDER BY position ASC;
+
+-- name: HasBundleOffers :one
+SELECT EXISTS (
+    SELECT 1 FROM offers
+    WHERE external_id = ?
+) AS has_offers;
+
+-- name: IsBundleVideo :one
+SELECT EXISTS (
+    SELECT 1 FROM bundle_videos bv
+    JOIN bundles b ON b.id = bv.bundle_id
+    WHERE b.external_id = sqlc.arg(bundle_external_id)
+      AND bv.external_id = sqlc.arg(external_id)
+) AS is_member;
+
+-- name: ListBundleExternalIDs :many
+SELECT external_id FROM bundles;
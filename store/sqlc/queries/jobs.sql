@@ -0,0 +1,54 @@
+-- name: InsertJob :one
+INSERT INTO jobs (
+    kind, payload, status, max_attempts, run_at, created_at, updated_at
+) VALUES (
+    ?, ?, 'pending', ?, ?, ?, ?
+)
+RETURNING id;
+
+-- name: InsertUniqueJob :one
+INSERT INTO jobs (
+    kind, payload, status, max_attempts, run_at, created_at, updated_at
+)
+SELECT
+    sqlc.arg(kind), sqlc.arg(payload), 'pending', sqlc.arg(max_attempts),
+    sqlc.arg(run_at), sqlc.arg(created_at), sqlc.arg(updated_at)
+WHERE NOT EXISTS (
+    SELECT 1
+    FROM jobs
+    WHERE kind = sqlc.arg(kind) AND status = 'pending'
+)
+RETURNING id;
+
+-- name: LeaseJob :one
+UPDATE jobs
+SET status = 'running',
+    attempts = attempts + 1,
+    run_at = sqlc.arg(locked_until),
+    updated_at = sqlc.arg(now)
+WHERE id = (
+    SELECT id
+    FROM jobs
+    WHERE status IN ('pending', 'running') AND run_at <= sqlc.arg(now)
+    ORDER BY run_at, id
+    LIMIT 1
+)
+RETURNING *;
+
+-- name: ExtendJobLease :execrows
+UPDATE jobs
+SET run_at = ?,
+    updated_at = ?
+WHERE id = ? AND attempts = ? AND status = 'running';
+
+-- name: DeleteLeasedJob :execrows
+DELETE FROM jobs
+WHERE id = ? AND attempts = ? AND status = 'running';
+
+-- name: ReleaseLeasedJob :execrows
+UPDATE jobs
+SET status = ?,
+    run_at = ?,
+    last_error = ?,
+    updated_at = ?
+WHERE id = ? AND attempts = ? AND status = 'running';
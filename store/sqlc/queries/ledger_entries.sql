@@ -0,0 +1,32 @@
+-- name: InsertSaleLedgerEntry :exec
+INSERT INTO ledger_entries (
+    user_id, payment_hash, entry_type, amount_in_cents, amount_in_sats,
+    currency, created_at
+)
+SELECT p.user_id, p.payment_hash, 'sale', p.price_in_cents, p.amount_in_sats,
+    p.currency, p.created_at
+FROM purchases p
+WHERE p.payment_hash = ?
+ON CONFLICT (payment_hash, entry_type) DO NOTHING;
+
+-- name: InsertRefundLedgerEntry :exec
+INSERT INTO ledger_entries (
+    user_id, payment_hash, entry_type, amount_in_cents, amount_in_sats,
+    currency, created_at
+)
+SELECT p.user_id, p.payment_hash, 'refund',
+    CASE
+        WHEN p.amount_in_sats = 0 THEN -p.price_in_cents
+        ELSE -(p.price_in_cents * r.amount_in_sats / p.amount_in_sats)
+    END,
+    -r.amount_in_sats, p.currency, sqlc.arg(created_at)
+FROM purchases p
+JOIN refunds r ON r.payment_hash = p.payment_hash
+WHERE p.payment_hash = sqlc.arg(payment_hash)
+ON CONFLICT (payment_hash, entry_type) DO NOTHING;
+
+-- name: ListUserLedgerEntries :many
+SELECT *
+FROM ledger_entries
+WHERE user_id = ?
+ORDER BY created_at DESC, id DESC;
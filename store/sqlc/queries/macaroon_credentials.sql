@@ -5,7 +5,30 @@ INSERT INTO macaroon_credentials (
     ?, ?, ?, ?, ?
 ) RETURNING id;
 
+-- name: GetEncodedMacaroonByIdentifier :one
+SELECT encoded_base_macaroon
+FROM macaroon_credentials
+WHERE identifier = ?;
+
+-- name: DeleteMacaroonsByPaymentHash :execrows
+DELETE FROM macaroon_credentials
+WHERE substr(identifier, 5, 64) = ?1;
+
 -- name: GetRootKeyByIdentifier :one
-SELECT root_key
+SELECT root_key, disabled
 FROM macaroon_credentials
 WHERE identifier = ?;
+
+-- name: ListPaidCredentialsByPubKey :many
+SELECT i.payment_hash, mc.encoded_base_macaroon, i.preimage
+FROM macaroon_credentials mc
+JOIN invoice_status i ON i.payment_hash = substr(mc.identifier, 5, 64)
+WHERE substr(mc.identifier, 69) = sqlc.arg(pub_key)
+  AND mc.disabled = FALSE
+  AND i.settled = TRUE
+ORDER BY mc.created_at DESC;
+
+-- name: DisableMacaroonsByPaymentHash :exec
+UPDATE macaroon_credentials
+SET disabled = TRUE
+WHERE substr(identifier, 5, 64) = sqlc.arg(payment_hash);
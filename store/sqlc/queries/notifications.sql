@@ -0,0 +1,19 @@
+-- name: InsertPendingNotification :exec
+INSERT INTO pending_notifications (
+    user_id, event_type, external_id, payment_hash, title, price_in_cents,
+    currency, created_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?
+);
+
+-- name: ListPendingNotifications :many
+SELECT n.id, n.user_id, n.event_type, n.external_id, n.payment_hash,
+    n.title, n.price_in_cents, n.currency, n.created_at, u.email,
+    u.notification_preference
+FROM pending_notifications n
+JOIN users u ON u.id = n.user_id
+ORDER BY n.user_id, n.id;
+
+-- name: DeletePendingNotifications :exec
+DELETE FROM pending_notifications
+WHERE user_id = ? AND id <= ?;
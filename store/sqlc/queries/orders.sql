@@ -1,9 +1,10 @@
 -- name: InsertOffer :one
 INSERT INTO offers (
     user_id, external_id, payment_hash, price_in_cents, currency, expiration_date,
-    created_at
+    promo_code_id, payer_pub_key, recipient_pub_key, amount_in_sats,
+    service_type, created_at
 ) VALUES (
-    ?, ?, ?, ?, ?, ?, ?
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
 ) RETURNING id;
 
 -- name: GetOfferByPaymentHash :one
@@ -11,6 +12,32 @@ SELECT *
 FROM offers
 WHERE payment_hash = ?;
 
+-- name: ListRecipientOffers :many
+SELECT *
+FROM offers
+WHERE external_id = ? AND recipient_pub_key = ?
+ORDER BY created_at DESC
+LIMIT ?;
+
+-- name: ListExpiredUnpaidOffers :many
+SELECT o.*
+FROM offers o
+LEFT JOIN purchases p ON p.payment_hash = o.payment_hash
+LEFT JOIN invoice_status s ON s.payment_hash = o.payment_hash
+WHERE p.id IS NULL
+    AND (s.settled IS NULL OR s.settled = FALSE)
+    AND o.created_at < ?
+    AND o.id > ?
+ORDER BY o.id
+LIMIT ?;
+
+-- name: DeleteUnpaidOffer :execrows
+DELETE FROM offers
+WHERE payment_hash = ?1
+    AND NOT EXISTS (
+        SELECT 1 FROM purchases WHERE payment_hash = ?1
+    );
+
 
 -- name: InsertPurchase :one
 INSERT INTO purchases (
@@ -24,3 +51,24 @@ INSERT INTO purchases (
 SELECT *
 FROM purchases
 WHERE payment_hash = ?;
+
+-- name: ListRecipientPurchases :many
+SELECT *
+FROM purchases
+WHERE recipient_pub_key = ?
+ORDER BY created_at DESC;
+
+-- name: InsertPurchaseFromOffer :execrows
+INSERT INTO purchases (
+    user_id, external_id, service_type, price_in_cents, currency,
+    expiration_date, payment_hash, payer_pub_key, recipient_pub_key,
+    amount_in_sats, created_at
+)
+SELECT
+    o.user_id, o.external_id, sqlc.arg(service_type),
+    o.price_in_cents, o.currency, o.expiration_date, o.payment_hash,
+    o.payer_pub_key, o.recipient_pub_key, o.amount_in_sats,
+    sqlc.arg(created_at)
+FROM offers o
+WHERE o.payment_hash = sqlc.arg(payment_hash)
+ON CONFLICT (payment_hash) DO NOTHING;
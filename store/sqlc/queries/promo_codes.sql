@@ -0,0 +1,42 @@
+-- name: CreatePromoCode :one
+INSERT INTO promo_codes (
+    user_id, code, discount_type, discount_value, expires_at,
+    max_redemptions, created_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?
+) RETURNING *;
+
+-- name: InsertPromoCodeVideo :exec
+INSERT INTO promo_code_videos (promo_code_id, external_id)
+VALUES (?, ?);
+
+-- name: GetPromoCode :one
+SELECT * FROM promo_codes
+WHERE user_id = ? AND code = ?;
+
+-- name: ListUserPromoCodes :many
+SELECT * FROM promo_codes
+WHERE user_id = ?
+ORDER BY created_at DESC;
+
+-- name: ListPromoCodeVideos :many
+SELECT external_id FROM promo_code_videos
+WHERE promo_code_id = ?
+ORDER BY external_id ASC;
+
+-- name: DeletePromoCodeVideos :exec
+DELETE FROM promo_code_videos
+WHERE promo_code_id IN (
+    SELECT id FROM promo_codes WHERE user_id = ? AND code = ?
+);
+
+-- name: DeletePromoCode :execrows
+DELETE FROM promo_codes
+WHERE user_id = ? AND code = ?;
+
+-- name: IncrementPromoCodeRedemptions :exec
+UPDATE promo_codes
+SET redemptions = redemptions + 1
+WHERE id = (
+    SELECT promo_code_id FROM offers WHERE payment_hash = ?
+);
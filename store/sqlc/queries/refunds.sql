@@ -0,0 +1,36 @@
+-- name: CreateRefund :one
+INSERT INTO refunds (
+    payment_hash, user_id, requester_pub_key, reason, payment_request,
+    amount_in_sats, status, created_at, updated_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?
+) RETURNING *;
+
+-- name: GetRefund :one
+SELECT *
+FROM refunds
+WHERE id = ?;
+
+-- name: GetRefundByPaymentHash :one
+SELECT *
+FROM refunds
+WHERE payment_hash = ?;
+
+-- name: ListUserRefunds :many
+SELECT *
+FROM refunds
+WHERE user_id = ?
+ORDER BY created_at DESC;
+
+-- name: ListRefunds :many
+SELECT *
+FROM refunds
+ORDER BY created_at DESC;
+
+-- name: UpdateRefundStatus :execrows
+UPDATE refunds
+SET status = sqlc.arg(status),
+    resolution = sqlc.arg(resolution),
+    resolved_by = COALESCE(sqlc.narg(resolved_by), resolved_by),
+    updated_at = sqlc.arg(updated_at)
+WHERE id = sqlc.arg(id) AND status = sqlc.arg(from_status);
@@ -0,0 +1,29 @@
+-- name: CreateSelfHostedVideo :exec
+INSERT INTO self_hosted_videos (
+    external_id, status, upload_expires_at, created_at, updated_at
+) VALUES (
+    ?, ?, ?, ?, ?
+);
+
+-- name: DeleteSelfHostedVideo :exec
+DELETE FROM self_hosted_videos
+WHERE external_id = ?;
+
+-- name: GetSelfHostedVideo :one
+SELECT *
+FROM self_hosted_videos
+WHERE external_id = ?;
+
+-- name: UpdateSelfHostedVideoStatus :execrows
+UPDATE self_hosted_videos
+SET status = sqlc.arg(status),
+    status_reason = sqlc.arg(status_reason),
+    size_in_bytes = sqlc.arg(size_in_bytes),
+    updated_at = sqlc.arg(updated_at)
+WHERE external_id = sqlc.arg(external_id)
+    AND status = sqlc.arg(current_status);
+
+-- name: ListSelfHostedVideos :many
+SELECT *
+FROM self_hosted_videos
+ORDER BY created_at;
@@ -11,7 +11,7 @@ WHERE token = ? LIMIT 1;
 DELETE FROM tokens
 WHERE token = ?;
 
--- name: DeleteExpiredTokens :exec
+-- name: DeleteExpiredTokens :execrows
 DELETE FROM tokens
 WHERE expiration < ?;
 
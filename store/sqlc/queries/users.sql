@@ -21,3 +21,13 @@ UPDATE users
 SET lightning_address = ?
 WHERE id = ?;
 
+
+-- name: UpdateUserSubscriptionPrice :exec
+UPDATE users
+SET subscription_price_in_cents = ?
+WHERE id = ?;
+
+-- name: UpdateUserNotificationPreference :exec
+UPDATE users
+SET notification_preference = ?
+WHERE id = ?;
@@ -0,0 +1,15 @@
+-- name: UpsertVideoRental :one
+INSERT INTO video_rentals (external_id, duration_in_hours, price_in_cents, created_at)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (external_id, duration_in_hours) DO UPDATE SET
+    price_in_cents = EXCLUDED.price_in_cents
+RETURNING *;
+
+-- name: ListVideoRentals :many
+SELECT * FROM video_rentals
+WHERE external_id = ?
+ORDER BY duration_in_hours ASC;
+
+-- name: DeleteVideoRental :exec
+DELETE FROM video_rentals
+WHERE external_id = ? AND duration_in_hours = ?;
@@ -0,0 +1,28 @@
+-- name: UpsertVideoTrash :exec
+INSERT INTO video_trash (external_id, deleted_at, purge_at)
+VALUES (?, ?, ?)
+ON CONFLICT (external_id) DO UPDATE
+SET deleted_at = excluded.deleted_at,
+    purge_at = excluded.purge_at,
+    purged_at = NULL;
+
+-- name: GetVideoTrash :one
+SELECT *
+FROM video_trash
+WHERE external_id = ?;
+
+-- name: ListUserTrashedVideos :many
+SELECT v.external_id
+FROM videos v
+JOIN video_trash t ON t.external_id = v.external_id
+WHERE v.user_id = ? AND v.deleted = TRUE AND t.purged_at IS NULL
+ORDER BY v.created_at DESC;
+
+-- name: DeleteVideoTrash :exec
+DELETE FROM video_trash
+WHERE external_id = ?;
+
+-- name: MarkVideoPurged :execrows
+UPDATE video_trash
+SET purged_at = ?
+WHERE external_id = ? AND purged_at IS NULL;
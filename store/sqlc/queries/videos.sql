@@ -1,6 +1,6 @@
 -- name: CreateVideo :one
-INSERT INTO videos (external_id, user_id, title, description, cover_url, price_in_cents, created_at)
-VALUES (?, ?, ?, ?, ?, ?, ?)
+INSERT INTO videos (external_id, user_id, title, description, cover_url, cover_card_url, cover_hero_url, cover_og_url, price_in_cents, pay_what_you_want, suggested_price_in_cents, status, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 RETURNING *;
 
 -- name: GetVideoByExternalID :one
@@ -41,7 +41,9 @@ SET
   size_in_bytes = COALESCE(sqlc.narg(size_in_bytes), size_in_bytes),
   input_height = COALESCE(sqlc.narg(input_height), input_height),
   input_width = COALESCE(sqlc.narg(input_width), input_width),
-  ready_to_stream = sqlc.arg(ready_to_stream)
+  ready_to_stream = sqlc.arg(ready_to_stream),
+  status = sqlc.arg(status),
+  status_reason = sqlc.arg(status_reason)
 WHERE external_id = sqlc.arg(external_id)
 RETURNING *;
 
@@ -50,11 +52,38 @@ UPDATE videos
 SET 
   title = COALESCE(sqlc.narg(title), title),
   description = COALESCE(sqlc.narg(description), description),
-  price_in_cents = COALESCE(sqlc.narg(price_in_cents), price_in_cents)
+  price_in_cents = COALESCE(sqlc.narg(price_in_cents), price_in_cents),
+  pay_what_you_want = COALESCE(sqlc.narg(pay_what_you_want), pay_what_you_want),
+  suggested_price_in_cents = COALESCE(sqlc.narg(suggested_price_in_cents), suggested_price_in_cents)
 WHERE external_id = sqlc.arg(external_id)
 RETURNING *;
 
 -- name: DeleteVideo :exec
 UPDATE videos
 SET deleted = TRUE
-WHERE external_id = ?
+WHERE external_id = ?;
+
+-- name: GetDeletedVideoByExternalID :one
+SELECT v.*, COUNT(p.id) as total_purchases
+FROM videos v
+LEFT JOIN purchases p ON v.external_id = p.external_id
+WHERE v.external_id = ? AND v.deleted = TRUE
+GROUP BY v.id
+LIMIT 1;
+
+-- name: RestoreVideo :execrows
+UPDATE videos
+SET deleted = FALSE
+WHERE external_id = ? AND user_id = ? AND deleted = TRUE;
+
+-- name: ListStaleUploads :many
+SELECT *
+FROM videos
+WHERE deleted = FALSE AND status = 'awaiting_upload' AND created_at < ?
+ORDER BY created_at;
+
+-- name: ListVideoStates :many
+SELECT v.external_id, v.status, v.deleted, t.purged_at
+FROM videos v
+LEFT JOIN video_trash t ON v.external_id = t.external_id
+ORDER BY v.id;
@@ -0,0 +1,66 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (user_id, url, secret, events, created_at)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT *
+FROM webhook_endpoints
+WHERE id = ?;
+
+-- name: ListUserWebhookEndpoints :many
+SELECT *
+FROM webhook_endpoints
+WHERE user_id = ?
+ORDER BY id;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = ? AND user_id = ?;
+
+-- name: DeleteEndpointWebhookDeliveries :exec
+DELETE FROM webhook_deliveries
+WHERE endpoint_id = ?;
+
+-- name: InsertWebhookDelivery :exec
+INSERT INTO webhook_deliveries (
+    endpoint_id, user_id, event_type, payload, status, next_attempt_at,
+    created_at, updated_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?
+);
+
+-- name: ListDueWebhookDeliveries :many
+SELECT d.id, d.endpoint_id, d.user_id, d.event_type, d.payload, d.status,
+    d.attempts, d.next_attempt_at, d.last_status_code, d.last_error,
+    d.created_at, d.updated_at, e.url, e.secret
+FROM webhook_deliveries d
+JOIN webhook_endpoints e ON e.id = d.endpoint_id
+WHERE d.status = 'pending' AND d.next_attempt_at <= ?
+ORDER BY d.next_attempt_at
+LIMIT ?;
+
+-- name: ListEndpointWebhookDeliveries :many
+SELECT *
+FROM webhook_deliveries
+WHERE endpoint_id = ?
+ORDER BY id DESC
+LIMIT ?;
+
+-- name: UpdateWebhookDeliveryAttempt :exec
+UPDATE webhook_deliveries
+SET status = ?,
+    attempts = ?,
+    next_attempt_at = ?,
+    last_status_code = ?,
+    last_error = ?,
+    updated_at = ?
+WHERE id = ?;
+
+-- name: RedeliverWebhookDelivery :execrows
+UPDATE webhook_deliveries
+SET status = 'pending',
+    attempts = 0,
+    next_attempt_at = ?,
+    updated_at = ?
+WHERE id = ? AND user_id = ?;
@@ -41,14 +41,17 @@ func (q *Queries) CreateToken(ctx context.Context, arg CreateTokenParams) (Token
 	return i, err
 }
 
-const deleteExpiredTokens = `-- name: DeleteExpiredTokens :exec
+const deleteExpiredTokens = `-- name: DeleteExpiredTokens :execrows
 DELETE FROM tokens
 WHERE expiration < ?
 `
 
-func (q *Queries) DeleteExpiredTokens(ctx context.Context, expiration time.Time) error {
-	_, err := q.db.ExecContext(ctx, deleteExpiredTokens, expiration)
-	return err
+func (q *Queries) DeleteExpiredTokens(ctx context.Context, expiration time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredTokens, expiration)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 const deleteToken = `-- name: DeleteToken :exec
@@ -30,7 +30,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (int64,
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, email, lightning_address, verified, created_at FROM users
+SELECT id, email, lightning_address, verified, created_at, subscription_price_in_cents, notification_preference FROM users
 WHERE id = ? LIMIT 1
 `
 
@@ -43,6 +43,8 @@ func (q *Queries) GetUserByID(ctx context.Context, id int64) (User, error) {
 		&i.LightningAddress,
 		&i.Verified,
 		&i.CreatedAt,
+		&i.SubscriptionPriceInCents,
+		&i.NotificationPreference,
 	)
 	return i, err
 }
@@ -90,3 +92,35 @@ func (q *Queries) UpdateUserVerified(ctx context.Context, arg UpdateUserVerified
 	_, err := q.db.ExecContext(ctx, updateUserVerified, arg.Verified, arg.Email)
 	return err
 }
+
+const updateUserSubscriptionPrice = `-- name: UpdateUserSubscriptionPrice :exec
+UPDATE users
+SET subscription_price_in_cents = ?
+WHERE id = ?
+`
+
+type UpdateUserSubscriptionPriceParams struct {
+	SubscriptionPriceInCents sql.NullInt64
+	ID                       int64
+}
+
+func (q *Queries) UpdateUserSubscriptionPrice(ctx context.Context, arg UpdateUserSubscriptionPriceParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserSubscriptionPrice, arg.SubscriptionPriceInCents, arg.ID)
+	return err
+}
+
+const updateUserNotificationPreference = `-- name: UpdateUserNotificationPreference :exec
+UPDATE users
+SET notification_preference = ?
+WHERE id = ?
+`
+
+type UpdateUserNotificationPreferenceParams struct {
+	NotificationPreference string
+	ID                     int64
+}
+
+func (q *Queries) UpdateUserNotificationPreference(ctx context.Context, arg UpdateUserNotificationPreferenceParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserNotificationPreference, arg.NotificationPreference, arg.ID)
+	return err
+}
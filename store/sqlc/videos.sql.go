@@ -12,19 +12,25 @@ import (
 )
 
 const createVideo = `-- name: CreateVideo :one
-INSERT INTO videos (external_id, user_id, title, description, cover_url, price_in_cents, created_at)
-VALUES (?, ?, ?, ?, ?, ?, ?)
-RETURNING id, external_id, user_id, title, description, cover_url, price_in_cents, total_views, thumbnail_url, hls_url, dash_url, duration_in_seconds, size_in_bytes, input_height, input_width, ready_to_stream, created_at, deleted
+INSERT INTO videos (external_id, user_id, title, description, cover_url, cover_card_url, cover_hero_url, cover_og_url, price_in_cents, pay_what_you_want, suggested_price_in_cents, status, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING id, external_id, user_id, title, description, cover_url, price_in_cents, total_views, thumbnail_url, hls_url, dash_url, duration_in_seconds, size_in_bytes, input_height, input_width, ready_to_stream, created_at, deleted, pay_what_you_want, suggested_price_in_cents, status_reason, status, cover_card_url, cover_hero_url, cover_og_url
 `
 
 type CreateVideoParams struct {
-	ExternalID   string
-	UserID       int64
-	Title        string
-	Description  string
-	CoverUrl     string
-	PriceInCents int64
-	CreatedAt    time.Time
+	ExternalID            string
+	UserID                int64
+	Title                 string
+	Description           string
+	CoverUrl              string
+	CoverCardUrl          string
+	CoverHeroUrl          string
+	CoverOgUrl            string
+	PriceInCents          int64
+	PayWhatYouWant        bool
+	SuggestedPriceInCents sql.NullInt64
+	Status                string
+	CreatedAt             time.Time
 }
 
 func (q *Queries) CreateVideo(ctx context.Context, arg CreateVideoParams) (Video, error) {
@@ -34,7 +40,13 @@ func (q *Queries) CreateVideo(ctx context.Context, arg CreateVideoParams) (Video
 		arg.Title,
 		arg.Description,
 		arg.CoverUrl,
+		arg.CoverCardUrl,
+		arg.CoverHeroUrl,
+		arg.CoverOgUrl,
 		arg.PriceInCents,
+		arg.PayWhatYouWant,
+		arg.SuggestedPriceInCents,
+		arg.Status,
 		arg.CreatedAt,
 	)
 	var i Video
@@ -57,6 +69,13 @@ func (q *Queries) CreateVideo(ctx context.Context, arg CreateVideoParams) (Video
 		&i.ReadyToStream,
 		&i.CreatedAt,
 		&i.Deleted,
+		&i.PayWhatYouWant,
+		&i.SuggestedPriceInCents,
+		&i.StatusReason,
+		&i.Status,
+		&i.CoverCardUrl,
+		&i.CoverHeroUrl,
+		&i.CoverOgUrl,
 	)
 	return i, err
 }
@@ -72,8 +91,80 @@ func (q *Queries) DeleteVideo(ctx context.Context, externalID string) error {
 	return err
 }
 
+const getDeletedVideoByExternalID = `-- name: GetDeletedVideoByExternalID :one
+SELECT v.id, v.external_id, v.user_id, v.title, v.description, v.cover_url, v.price_in_cents, v.total_views, v.thumbnail_url, v.hls_url, v.dash_url, v.duration_in_seconds, v.size_in_bytes, v.input_height, v.input_width, v.ready_to_stream, v.created_at, v.deleted, v.pay_what_you_want, v.suggested_price_in_cents, v.status_reason, v.status, v.cover_card_url, v.cover_hero_url, v.cover_og_url, COUNT(p.id) as total_purchases
+FROM videos v
+LEFT JOIN purchases p ON v.external_id = p.external_id
+WHERE v.external_id = ? AND v.deleted = TRUE
+GROUP BY v.id
+LIMIT 1
+`
+
+type GetDeletedVideoByExternalIDRow struct {
+	ID                    int64
+	ExternalID            string
+	UserID                int64
+	Title                 string
+	Description           string
+	CoverUrl              string
+	PriceInCents          int64
+	TotalViews            int64
+	ThumbnailUrl          sql.NullString
+	HlsUrl                sql.NullString
+	DashUrl               sql.NullString
+	DurationInSeconds     sql.NullFloat64
+	SizeInBytes           sql.NullInt64
+	InputHeight           sql.NullInt64
+	InputWidth            sql.NullInt64
+	ReadyToStream         bool
+	CreatedAt             time.Time
+	Deleted               bool
+	PayWhatYouWant        bool
+	SuggestedPriceInCents sql.NullInt64
+	StatusReason          string
+	Status                string
+	CoverCardUrl          string
+	CoverHeroUrl          string
+	CoverOgUrl            string
+	TotalPurchases        int64
+}
+
+func (q *Queries) GetDeletedVideoByExternalID(ctx context.Context, externalID string) (GetDeletedVideoByExternalIDRow, error) {
+	row := q.db.QueryRowContext(ctx, getDeletedVideoByExternalID, externalID)
+	var i GetDeletedVideoByExternalIDRow
+	err := row.Scan(
+		&i.ID,
+		&i.ExternalID,
+		&i.UserID,
+		&i.Title,
+		&i.Description,
+		&i.CoverUrl,
+		&i.PriceInCents,
+		&i.TotalViews,
+		&i.ThumbnailUrl,
+		&i.HlsUrl,
+		&i.DashUrl,
+		&i.DurationInSeconds,
+		&i.SizeInBytes,
+		&i.InputHeight,
+		&i.InputWidth,
+		&i.ReadyToStream,
+		&i.CreatedAt,
+		&i.Deleted,
+		&i.PayWhatYouWant,
+		&i.SuggestedPriceInCents,
+		&i.StatusReason,
+		&i.Status,
+		&i.CoverCardUrl,
+		&i.CoverHeroUrl,
+		&i.CoverOgUrl,
+		&i.TotalPurchases,
+	)
+	return i, err
+}
+
 const getVideoByExternalID = `-- name: GetVideoByExternalID :one
-SELECT v.id, v.external_id, v.user_id, v.title, v.description, v.cover_url, v.price_in_cents, v.total_views, v.thumbnail_url, v.hls_url, v.dash_url, v.duration_in_seconds, v.size_in_bytes, v.input_height, v.input_width, v.ready_to_stream, v.created_at, v.deleted, COUNT(p.id) as total_purchases
+SELECT v.id, v.external_id, v.user_id, v.title, v.description, v.cover_url, v.price_in_cents, v.total_views, v.thumbnail_url, v.hls_url, v.dash_url, v.duration_in_seconds, v.size_in_bytes, v.input_height, v.input_width, v.ready_to_stream, v.created_at, v.deleted, v.pay_what_you_want, v.suggested_price_in_cents, v.status_reason, v.status, v.cover_card_url, v.cover_hero_url, v.cover_og_url, COUNT(p.id) as total_purchases
 FROM videos v
 LEFT JOIN purchases p ON v.external_id = p.external_id
 WHERE v.external_id = ? AND v.deleted = FALSE
@@ -82,25 +173,32 @@ LIMIT 1
 `
 
 type GetVideoByExternalIDRow struct {
-	ID                int64
-	ExternalID        string
-	UserID            int64
-	Title             string
-	Description       string
-	CoverUrl          string
-	PriceInCents      int64
-	TotalViews        int64
-	ThumbnailUrl      sql.NullString
-	HlsUrl            sql.NullString
-	DashUrl           sql.NullString
-	DurationInSeconds sql.NullFloat64
-	SizeInBytes       sql.NullInt64
-	InputHeight       sql.NullInt64
-	InputWidth        sql.NullInt64
-	ReadyToStream     bool
-	CreatedAt         time.Time
-	Deleted           bool
-	TotalPurchases    int64
+	ID                    int64
+	ExternalID            string
+	UserID                int64
+	Title                 string
+	Description           string
+	CoverUrl              string
+	PriceInCents          int64
+	TotalViews            int64
+	ThumbnailUrl          sql.NullString
+	HlsUrl                sql.NullString
+	DashUrl               sql.NullString
+	DurationInSeconds     sql.NullFloat64
+	SizeInBytes           sql.NullInt64
+	InputHeight           sql.NullInt64
+	InputWidth            sql.NullInt64
+	ReadyToStream         bool
+	CreatedAt             time.Time
+	Deleted               bool
+	PayWhatYouWant        bool
+	SuggestedPriceInCents sql.NullInt64
+	StatusReason          string
+	Status                string
+	CoverCardUrl          string
+	CoverHeroUrl          string
+	CoverOgUrl            string
+	TotalPurchases        int64
 }
 
 func (q *Queries) GetVideoByExternalID(ctx context.Context, externalID string) (GetVideoByExternalIDRow, error) {
@@ -125,6 +223,13 @@ func (q *Queries) GetVideoByExternalID(ctx context.Context, externalID string) (
 		&i.ReadyToStream,
 		&i.CreatedAt,
 		&i.Deleted,
+		&i.PayWhatYouWant,
+		&i.SuggestedPriceInCents,
+		&i.StatusReason,
+		&i.Status,
+		&i.CoverCardUrl,
+		&i.CoverHeroUrl,
+		&i.CoverOgUrl,
 		&i.TotalPurchases,
 	)
 	return i, err
@@ -141,8 +246,64 @@ func (q *Queries) IncrementVideoViews(ctx context.Context, externalID string) er
 	return err
 }
 
+const listStaleUploads = `-- name: ListStaleUploads :many
+SELECT id, external_id, user_id, title, description, cover_url, price_in_cents, total_views, thumbnail_url, hls_url, dash_url, duration_in_seconds, size_in_bytes, input_height, input_width, ready_to_stream, created_at, deleted, pay_what_you_want, suggested_price_in_cents, status_reason, status, cover_card_url, cover_hero_url, cover_og_url
+FROM videos
+WHERE deleted = FALSE AND status = 'awaiting_upload' AND created_at < ?
+ORDER BY created_at
+`
+
+func (q *Queries) ListStaleUploads(ctx context.Context, createdAt time.Time) ([]Video, error) {
+	rows, err := q.db.QueryContext(ctx, listStaleUploads, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Video
+	for rows.Next() {
+		var i Video
+		if err := rows.Scan(
+			&i.ID,
+			&i.ExternalID,
+			&i.UserID,
+			&i.Title,
+			&i.Description,
+			&i.CoverUrl,
+			&i.PriceInCents,
+			&i.TotalViews,
+			&i.ThumbnailUrl,
+			&i.HlsUrl,
+			&i.DashUrl,
+			&i.DurationInSeconds,
+			&i.SizeInBytes,
+			&i.InputHeight,
+			&i.InputWidth,
+			&i.ReadyToStream,
+			&i.CreatedAt,
+			&i.Deleted,
+			&i.PayWhatYouWant,
+			&i.SuggestedPriceInCents,
+			&i.StatusReason,
+			&i.Status,
+			&i.CoverCardUrl,
+			&i.CoverHeroUrl,
+			&i.CoverOgUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listUserVideos = `-- name: ListUserVideos :many
-SELECT v.id, v.external_id, v.user_id, v.title, v.description, v.cover_url, v.price_in_cents, v.total_views, v.thumbnail_url, v.hls_url, v.dash_url, v.duration_in_seconds, v.size_in_bytes, v.input_height, v.input_width, v.ready_to_stream, v.created_at, v.deleted, COUNT(p.id) as total_purchases
+SELECT v.id, v.external_id, v.user_id, v.title, v.description, v.cover_url, v.price_in_cents, v.total_views, v.thumbnail_url, v.hls_url, v.dash_url, v.duration_in_seconds, v.size_in_bytes, v.input_height, v.input_width, v.ready_to_stream, v.created_at, v.deleted, v.pay_what_you_want, v.suggested_price_in_cents, v.status_reason, v.status, v.cover_card_url, v.cover_hero_url, v.cover_og_url, COUNT(p.id) as total_purchases
 FROM videos v
 LEFT JOIN purchases p ON v.external_id = p.external_id
 WHERE v.user_id = ? AND v.deleted = FALSE
@@ -151,25 +312,32 @@ ORDER BY v.created_at DESC
 `
 
 type ListUserVideosRow struct {
-	ID                int64
-	ExternalID        string
-	UserID            int64
-	Title             string
-	Description       string
-	CoverUrl          string
-	PriceInCents      int64
-	TotalViews        int64
-	ThumbnailUrl      sql.NullString
-	HlsUrl            sql.NullString
-	DashUrl           sql.NullString
-	DurationInSeconds sql.NullFloat64
-	SizeInBytes       sql.NullInt64
-	InputHeight       sql.NullInt64
-	InputWidth        sql.NullInt64
-	ReadyToStream     bool
-	CreatedAt         time.Time
-	Deleted           bool
-	TotalPurchases    int64
+	ID                    int64
+	ExternalID            string
+	UserID                int64
+	Title                 string
+	Description           string
+	CoverUrl              string
+	PriceInCents          int64
+	TotalViews            int64
+	ThumbnailUrl          sql.NullString
+	HlsUrl                sql.NullString
+	DashUrl               sql.NullString
+	DurationInSeconds     sql.NullFloat64
+	SizeInBytes           sql.NullInt64
+	InputHeight           sql.NullInt64
+	InputWidth            sql.NullInt64
+	ReadyToStream         bool
+	CreatedAt             time.Time
+	Deleted               bool
+	PayWhatYouWant        bool
+	SuggestedPriceInCents sql.NullInt64
+	StatusReason          string
+	Status                string
+	CoverCardUrl          string
+	CoverHeroUrl          string
+	CoverOgUrl            string
+	TotalPurchases        int64
 }
 
 func (q *Queries) ListUserVideos(ctx context.Context, userID int64) ([]ListUserVideosRow, error) {
@@ -200,6 +368,13 @@ func (q *Queries) ListUserVideos(ctx context.Context, userID int64) ([]ListUserV
 			&i.ReadyToStream,
 			&i.CreatedAt,
 			&i.Deleted,
+			&i.PayWhatYouWant,
+			&i.SuggestedPriceInCents,
+			&i.StatusReason,
+			&i.Status,
+			&i.CoverCardUrl,
+			&i.CoverHeroUrl,
+			&i.CoverOgUrl,
 			&i.TotalPurchases,
 		); err != nil {
 			return nil, err
@@ -215,8 +390,69 @@ func (q *Queries) ListUserVideos(ctx context.Context, userID int64) ([]ListUserV
 	return items, nil
 }
 
+const listVideoStates = `-- name: ListVideoStates :many
+SELECT v.external_id, v.status, v.deleted, t.purged_at
+FROM videos v
+LEFT JOIN video_trash t ON v.external_id = t.external_id
+ORDER BY v.id
+`
+
+type ListVideoStatesRow struct {
+	ExternalID string
+	Status     string
+	Deleted    bool
+	PurgedAt   sql.NullTime
+}
+
+func (q *Queries) ListVideoStates(ctx context.Context) ([]ListVideoStatesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listVideoStates)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListVideoStatesRow
+	for rows.Next() {
+		var i ListVideoStatesRow
+		if err := rows.Scan(
+			&i.ExternalID,
+			&i.Status,
+			&i.Deleted,
+			&i.PurgedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const restoreVideo = `-- name: RestoreVideo :execrows
+UPDATE videos
+SET deleted = FALSE
+WHERE external_id = ? AND user_id = ? AND deleted = TRUE
+`
+
+type RestoreVideoParams struct {
+	ExternalID string
+	UserID     int64
+}
+
+func (q *Queries) RestoreVideo(ctx context.Context, arg RestoreVideoParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, restoreVideo, arg.ExternalID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const searchVideos = `-- name: SearchVideos :many
-SELECT id, external_id, user_id, title, description, cover_url, price_in_cents, total_views, thumbnail_url, hls_url, dash_url, duration_in_seconds, size_in_bytes, input_height, input_width, ready_to_stream, created_at, deleted FROM videos
+SELECT id, external_id, user_id, title, description, cover_url, price_in_cents, total_views, thumbnail_url, hls_url, dash_url, duration_in_seconds, size_in_bytes, input_height, input_width, ready_to_stream, created_at, deleted, pay_what_you_want, suggested_price_in_cents, status_reason, status, cover_card_url, cover_hero_url, cover_og_url FROM videos
 WHERE (title LIKE ? OR description LIKE ?) AND deleted = FALSE
 ORDER BY created_at DESC
 LIMIT ? OFFSET ?
@@ -262,6 +498,13 @@ func (q *Queries) SearchVideos(ctx context.Context, arg SearchVideosParams) ([]V
 			&i.ReadyToStream,
 			&i.CreatedAt,
 			&i.Deleted,
+			&i.PayWhatYouWant,
+			&i.SuggestedPriceInCents,
+			&i.StatusReason,
+			&i.Status,
+			&i.CoverCardUrl,
+			&i.CoverHeroUrl,
+			&i.CoverOgUrl,
 		); err != nil {
 			return nil, err
 		}
@@ -286,9 +529,11 @@ SET
   size_in_bytes = COALESCE(?5, size_in_bytes),
   input_height = COALESCE(?6, input_height),
   input_width = COALESCE(?7, input_width),
-  ready_to_stream = ?8
-WHERE external_id = ?9
-RETURNING id, external_id, user_id, title, description, cover_url, price_in_cents, total_views, thumbnail_url, hls_url, dash_url, duration_in_seconds, size_in_bytes, input_height, input_width, ready_to_stream, created_at, deleted
+  ready_to_stream = ?8,
+  status = ?9,
+  status_reason = ?10
+WHERE external_id = ?11
+RETURNING id, external_id, user_id, title, description, cover_url, price_in_cents, total_views, thumbnail_url, hls_url, dash_url, duration_in_seconds, size_in_bytes, input_height, input_width, ready_to_stream, created_at, deleted, pay_what_you_want, suggested_price_in_cents, status_reason, status, cover_card_url, cover_hero_url, cover_og_url
 `
 
 type UpdateCloudflareInfoParams struct {
@@ -300,6 +545,8 @@ type UpdateCloudflareInfoParams struct {
 	InputHeight       sql.NullInt64
 	InputWidth        sql.NullInt64
 	ReadyToStream     bool
+	Status            string
+	StatusReason      string
 	ExternalID        string
 }
 
@@ -313,6 +560,8 @@ func (q *Queries) UpdateCloudflareInfo(ctx context.Context, arg UpdateCloudflare
 		arg.InputHeight,
 		arg.InputWidth,
 		arg.ReadyToStream,
+		arg.Status,
+		arg.StatusReason,
 		arg.ExternalID,
 	)
 	var i Video
@@ -335,6 +584,13 @@ func (q *Queries) UpdateCloudflareInfo(ctx context.Context, arg UpdateCloudflare
 		&i.ReadyToStream,
 		&i.CreatedAt,
 		&i.Deleted,
+		&i.PayWhatYouWant,
+		&i.SuggestedPriceInCents,
+		&i.StatusReason,
+		&i.Status,
+		&i.CoverCardUrl,
+		&i.CoverHeroUrl,
+		&i.CoverOgUrl,
 	)
 	return i, err
 }
@@ -344,16 +600,20 @@ UPDATE videos
 SET 
   title = COALESCE(?1, title),
   description = COALESCE(?2, description),
-  price_in_cents = COALESCE(?3, price_in_cents)
-WHERE external_id = ?4
-RETURNING id, external_id, user_id, title, description, cover_url, price_in_cents, total_views, thumbnail_url, hls_url, dash_url, duration_in_seconds, size_in_bytes, input_height, input_width, ready_to_stream, created_at, deleted
+  price_in_cents = COALESCE(?3, price_in_cents),
+  pay_what_you_want = COALESCE(?4, pay_what_you_want),
+  suggested_price_in_cents = COALESCE(?5, suggested_price_in_cents)
+WHERE external_id = ?6
+RETURNING id, external_id, user_id, title, description, cover_url, price_in_cents, total_views, thumbnail_url, hls_url, dash_url, duration_in_seconds, size_in_bytes, input_height, input_width, ready_to_stream, created_at, deleted, pay_what_you_want, suggested_price_in_cents, status_reason, status, cover_card_url, cover_hero_url, cover_og_url
 `
 
 type UpdateVideoInfoParams struct {
-	Title        sql.NullString
-	Description  sql.NullString
-	PriceInCents sql.NullInt64
-	ExternalID   string
+	Title                 sql.NullString
+	Description           sql.NullString
+	PriceInCents          sql.NullInt64
+	PayWhatYouWant        sql.NullBool
+	SuggestedPriceInCents sql.NullInt64
+	ExternalID            string
 }
 
 func (q *Queries) UpdateVideoInfo(ctx context.Context, arg UpdateVideoInfoParams) (Video, error) {
@@ -361,6 +621,8 @@ func (q *Queries) UpdateVideoInfo(ctx context.Context, arg UpdateVideoInfoParams
 		arg.Title,
 		arg.Description,
 		arg.PriceInCents,
+		arg.PayWhatYouWant,
+		arg.SuggestedPriceInCents,
 		arg.ExternalID,
 	)
 	var i Video
@@ -383,6 +645,13 @@ func (q *Queries) UpdateVideoInfo(ctx context.Context, arg UpdateVideoInfoParams
 		&i.ReadyToStream,
 		&i.CreatedAt,
 		&i.Deleted,
+		&i.PayWhatYouWant,
+		&i.SuggestedPriceInCents,
+		&i.StatusReason,
+		&i.Status,
+		&i.CoverCardUrl,
+		&i.CoverHeroUrl,
+		&i.CoverOgUrl,
 	)
 	return i, err
 }
@@ -0,0 +1,29 @@
+package store
+
+import (
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/fewsats/blockbuster/utils"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStore creates a new store backed by a fresh SQLite database in a
+// temporary directory with all the migrations applied.
+func newTestStore(t *testing.T) (*Store, *utils.MockClock) {
+	t.Helper()
+
+	cfg := DefaultConfig()
+	cfg.ConnectionString = filepath.Join(t.TempDir(), "blockbuster.db")
+
+	clock := utils.NewMockClock()
+	store, err := NewStore(slog.Default(), cfg, clock)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		require.NoError(t, store.Close())
+	})
+
+	return store, clock
+}
@@ -28,6 +28,12 @@ func (s *Store) StoreToken(ctx context.Context, email, token string, expiration
 	return err
 }
 
+// DeleteExpiredTokens removes the login tokens that already expired and
+// returns how many were removed.
+func (s *Store) DeleteExpiredTokens(ctx context.Context) (int64, error) {
+	return s.queries.DeleteExpiredTokens(ctx, s.clock.Now())
+}
+
 func (s *Store) ExecTx(ctx context.Context, txBody func(*sqlc.Queries) error) error {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -74,6 +74,9 @@ func (s *Store) GetUserByID(ctx context.Context, id int64) (auth.User, error) {
 		Email:            user.Email,
 		Verified:         user.Verified,
 		LightningAddress: user.LightningAddress.String,
+
+		SubscriptionPriceInCents: user.SubscriptionPriceInCents.Int64,
+		NotificationPreference:   user.NotificationPreference,
 	}, nil
 }
 
@@ -88,3 +91,34 @@ func (s *Store) UpdateUserLightningAddress(ctx context.Context,
 		},
 	})
 }
+
+// UpdateUserSubscriptionPrice sets the monthly subscription price of a
+// creator. A zero price disables subscriptions.
+func (s *Store) UpdateUserSubscriptionPrice(ctx context.Context,
+	id int64, priceInCents int64) error {
+
+	return s.queries.UpdateUserSubscriptionPrice(ctx, sqlc.UpdateUserSubscriptionPriceParams{
+		ID: id,
+		SubscriptionPriceInCents: sql.NullInt64{
+			Int64: priceInCents,
+			Valid: priceInCents != 0,
+		},
+	})
+}
+
+// GetUserSubscriptionPrice returns the monthly subscription price of a
+// creator or zero if subscriptions are disabled.
+func (s *Store) GetUserSubscriptionPrice(ctx context.Context,
+	id int64) (int64, error) {
+
+	user, err := s.queries.GetUserByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, auth.ErrUserNotFound
+		}
+
+		return 0, fmt.Errorf("failed to get user by ID: %w", err)
+	}
+
+	return user.SubscriptionPriceInCents.Int64, nil
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+
+	"github.com/fewsats/blockbuster/store/sqlc"
+	"github.com/fewsats/blockbuster/video"
+)
+
+// UpsertVideoRental creates or updates the rental option of a video with the
+// given duration.
+func (s *Store) UpsertVideoRental(ctx context.Context, externalID string,
+	durationInHours, priceInCents int64) (*video.Rental, error) {
+
+	r, err := s.queries.UpsertVideoRental(ctx, sqlc.UpsertVideoRentalParams{
+		ExternalID:      externalID,
+		DurationInHours: durationInHours,
+		PriceInCents:    priceInCents,
+		CreatedAt:       s.clock.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &video.Rental{
+		DurationInHours: r.DurationInHours,
+		PriceInCents:    r.PriceInCents,
+	}, nil
+}
+
+// ListVideoRentals returns the rental options of a video sorted by duration.
+func (s *Store) ListVideoRentals(ctx context.Context,
+	externalID string) ([]*video.Rental, error) {
+
+	rentals, err := s.queries.ListVideoRentals(ctx, externalID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*video.Rental, 0, len(rentals))
+	for _, r := range rentals {
+		result = append(result, &video.Rental{
+			DurationInHours: r.DurationInHours,
+			PriceInCents:    r.PriceInCents,
+		})
+	}
+
+	return result, nil
+}
+
+// DeleteVideoRental removes the rental option of a video with the given
+// duration.
+func (s *Store) DeleteVideoRental(ctx context.Context, externalID string,
+	durationInHours int64) error {
+
+	return s.queries.DeleteVideoRental(ctx, sqlc.DeleteVideoRentalParams{
+		ExternalID:      externalID,
+		DurationInHours: durationInHours,
+	})
+}
@@ -3,13 +3,26 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"time"
 
+	"github.com/fewsats/blockbuster/reconcile"
 	"github.com/fewsats/blockbuster/store/sqlc"
 	"github.com/fewsats/blockbuster/video"
 )
 
 // Video methods
 func (s *Store) CreateVideo(ctx context.Context, params video.CreateVideoParams) (*video.Video, error) {
+	status := params.Status
+	if status == "" {
+		status = video.StatusAwaitingUpload
+	}
+
+	cover := params.CoverVariants
+	if cover == nil {
+		cover = &video.CoverVariants{}
+	}
+
 	v, err := s.queries.CreateVideo(ctx, sqlc.CreateVideoParams{
 		ExternalID:   params.ExternalID,
 		UserID:       params.UserID,
@@ -17,7 +30,18 @@ func (s *Store) CreateVideo(ctx context.Context, params video.CreateVideoParams)
 		Description:  params.Description,
 		CoverUrl:     params.CoverURL,
 		PriceInCents: params.PriceInCents,
-		CreatedAt:    s.clock.Now(),
+
+		CoverCardUrl: cover.CardURL,
+		CoverHeroUrl: cover.HeroURL,
+		CoverOgUrl:   cover.OGURL,
+
+		PayWhatYouWant: params.PayWhatYouWant,
+		SuggestedPriceInCents: sql.NullInt64{
+			Int64: params.SuggestedPriceInCents,
+			Valid: params.SuggestedPriceInCents != 0,
+		},
+		Status:    status,
+		CreatedAt: s.clock.Now(),
 	})
 
 	if err != nil {
@@ -29,8 +53,22 @@ func (s *Store) CreateVideo(ctx context.Context, params video.CreateVideoParams)
 	}, nil
 }
 
-func (s *Store) DeleteVideo(ctx context.Context, externalID string) error {
-	return s.queries.DeleteVideo(ctx, externalID)
+// DeleteVideo moves the video to the trash until purgeAt.
+func (s *Store) DeleteVideo(ctx context.Context, externalID string,
+	purgeAt time.Time) error {
+
+	return s.ExecTx(ctx, func(queries *sqlc.Queries) error {
+		err := queries.DeleteVideo(ctx, externalID)
+		if err != nil {
+			return err
+		}
+
+		return queries.UpsertVideoTrash(ctx, sqlc.UpsertVideoTrashParams{
+			ExternalID: externalID,
+			DeletedAt:  s.clock.Now(),
+			PurgeAt:    purgeAt,
+		})
+	})
 }
 
 func (s *Store) GetVideoByExternalID(ctx context.Context,
@@ -38,9 +76,116 @@ func (s *Store) GetVideoByExternalID(ctx context.Context,
 
 	v, err := s.queries.GetVideoByExternalID(ctx, externalID)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, video.ErrVideoNotFound
+		}
 		return nil, err
 	}
 
+	return videoFromRow(v), nil
+}
+
+// GetTrashedVideo returns the deleted video with the given external ID, or
+// video.ErrVideoNotFound if it is not in the trash.
+func (s *Store) GetTrashedVideo(ctx context.Context,
+	externalID string) (*video.Video, error) {
+
+	v, err := s.queries.GetDeletedVideoByExternalID(ctx, externalID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, video.ErrVideoNotFound
+		}
+		return nil, err
+	}
+
+	trash, err := s.queries.GetVideoTrash(ctx, externalID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, video.ErrVideoNotFound
+		}
+		return nil, err
+	}
+
+	result := videoFromRow(sqlc.GetVideoByExternalIDRow(v))
+	result.DeletedAt = &trash.DeletedAt
+	result.PurgeAt = &trash.PurgeAt
+	if trash.PurgedAt.Valid {
+		result.PurgedAt = &trash.PurgedAt.Time
+	}
+
+	return result, nil
+}
+
+// ListUserTrashedVideos returns the external IDs of the deleted videos of a
+// user whose assets have not been purged yet, most recent first.
+func (s *Store) ListUserTrashedVideos(ctx context.Context,
+	userID int64) ([]string, error) {
+
+	return s.queries.ListUserTrashedVideos(ctx, userID)
+}
+
+// RestoreVideo moves the video of the given user out of the trash, returning
+// video.ErrVideoNotFound if it is not there or its assets were purged.
+func (s *Store) RestoreVideo(ctx context.Context, userID int64,
+	externalID string) error {
+
+	return s.ExecTx(ctx, func(queries *sqlc.Queries) error {
+		trash, err := queries.GetVideoTrash(ctx, externalID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return video.ErrVideoNotFound
+			}
+			return err
+		}
+
+		if trash.PurgedAt.Valid || !s.clock.Now().Before(trash.PurgeAt) {
+			return video.ErrVideoNotFound
+		}
+
+		rows, err := queries.RestoreVideo(ctx, sqlc.RestoreVideoParams{
+			ExternalID: externalID,
+			UserID:     userID,
+		})
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return video.ErrVideoNotFound
+		}
+
+		return queries.DeleteVideoTrash(ctx, externalID)
+	})
+}
+
+// MarkVideoPurged records that the assets of a trashed video were deleted.
+func (s *Store) MarkVideoPurged(ctx context.Context, externalID string) error {
+	_, err := s.queries.MarkVideoPurged(ctx, sqlc.MarkVideoPurgedParams{
+		PurgedAt: sql.NullTime{
+			Time:  s.clock.Now(),
+			Valid: true,
+		},
+		ExternalID: externalID,
+	})
+
+	return err
+}
+
+// coverVariants returns the cover variants of a video, nil for the videos
+// created before they existed.
+func coverVariants(cardURL, heroURL, ogURL string) *video.CoverVariants {
+	if cardURL == "" {
+		return nil
+	}
+
+	return &video.CoverVariants{
+		CardURL: cardURL,
+		HeroURL: heroURL,
+		OGURL:   ogURL,
+	}
+}
+
+// videoFromRow converts a video row to a video.
+func videoFromRow(v sqlc.GetVideoByExternalIDRow) *video.Video {
 	return &video.Video{
 		ID:                v.ID,
 		ExternalID:        v.ExternalID,
@@ -57,8 +202,16 @@ func (s *Store) GetVideoByExternalID(ctx context.Context,
 		InputHeight:       int32(v.InputHeight.Int64),
 		InputWidth:        int32(v.InputWidth.Int64),
 		ReadyToStream:     v.ReadyToStream,
-		CreatedAt:         v.CreatedAt,
-	}, nil
+		Status:            v.Status,
+		StatusReason:      v.StatusReason,
+
+		CoverVariants: coverVariants(v.CoverCardUrl, v.CoverHeroUrl,
+			v.CoverOgUrl),
+
+		PayWhatYouWant:        v.PayWhatYouWant,
+		SuggestedPriceInCents: v.SuggestedPriceInCents.Int64,
+		CreatedAt:             v.CreatedAt,
+	}
 }
 
 func (s *Store) ListUserVideos(ctx context.Context,
@@ -90,6 +243,14 @@ func (s *Store) ListUserVideos(ctx context.Context,
 			InputHeight:       int32(v.InputHeight.Int64),
 			InputWidth:        int32(v.InputWidth.Int64),
 			ReadyToStream:     v.ReadyToStream,
+			Status:            v.Status,
+			StatusReason:      v.StatusReason,
+
+			CoverVariants: coverVariants(v.CoverCardUrl, v.CoverHeroUrl,
+				v.CoverOgUrl),
+
+			PayWhatYouWant:        v.PayWhatYouWant,
+			SuggestedPriceInCents: v.SuggestedPriceInCents.Int64,
 
 			CreatedAt: v.CreatedAt,
 		})
@@ -132,6 +293,8 @@ func (s *Store) UpdateCloudflareInfo(ctx context.Context, externalID string,
 			Valid:  params.HLSURL != "",
 		},
 		ReadyToStream: params.ReadyToStream,
+		Status:        params.Status,
+		StatusReason:  params.StatusReason,
 	})
 	if err != nil {
 		return nil, err
@@ -153,10 +316,77 @@ func (s *Store) UpdateCloudflareInfo(ctx context.Context, externalID string,
 		InputHeight:       int32(v.InputHeight.Int64),
 		InputWidth:        int32(v.InputWidth.Int64),
 		ReadyToStream:     v.ReadyToStream,
-		CreatedAt:         v.CreatedAt,
+		Status:            v.Status,
+		StatusReason:      v.StatusReason,
+
+		CoverVariants: coverVariants(v.CoverCardUrl, v.CoverHeroUrl,
+			v.CoverOgUrl),
+
+		PayWhatYouWant:        v.PayWhatYouWant,
+		SuggestedPriceInCents: v.SuggestedPriceInCents.Int64,
+		CreatedAt:             v.CreatedAt,
 	}, nil
 }
 
+// ListStaleUploads returns the videos created before the given time that are
+// still awaiting their upload.
+func (s *Store) ListStaleUploads(ctx context.Context,
+	createdBefore time.Time) ([]*video.Video, error) {
+
+	videos, err := s.queries.ListStaleUploads(ctx, createdBefore)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*video.Video, 0, len(videos))
+	for _, v := range videos {
+		result = append(result, &video.Video{
+			ID:           v.ID,
+			ExternalID:   v.ExternalID,
+			UserID:       v.UserID,
+			Title:        v.Title,
+			Description:  v.Description,
+			CoverURL:     v.CoverUrl,
+			PriceInCents: v.PriceInCents,
+			TotalViews:   v.TotalViews,
+			Status:       v.Status,
+			StatusReason: v.StatusReason,
+
+			CoverVariants: coverVariants(v.CoverCardUrl, v.CoverHeroUrl,
+				v.CoverOgUrl),
+
+			PayWhatYouWant:        v.PayWhatYouWant,
+			SuggestedPriceInCents: v.SuggestedPriceInCents.Int64,
+			CreatedAt:             v.CreatedAt,
+		})
+	}
+
+	return result, nil
+}
+
+// ListVideoStates returns the state of every video, including the deleted
+// ones.
+func (s *Store) ListVideoStates(ctx context.Context) ([]*reconcile.VideoState,
+	error) {
+
+	rows, err := s.queries.ListVideoStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]*reconcile.VideoState, 0, len(rows))
+	for _, row := range rows {
+		states = append(states, &reconcile.VideoState{
+			ExternalID: row.ExternalID,
+			Status:     row.Status,
+			Deleted:    row.Deleted,
+			Purged:     row.PurgedAt.Valid,
+		})
+	}
+
+	return states, nil
+}
+
 func (s *Store) IncrementVideoViews(ctx context.Context, externalID string) error {
 	return s.queries.IncrementVideoViews(ctx, externalID)
 }
@@ -176,6 +406,16 @@ func (s *Store) UpdateVideoInfo(ctx context.Context, externalID string, params *
 			Int64: params.PriceInCents,
 			Valid: params.PriceInCents != 0,
 		},
+		PayWhatYouWant: func(b *bool) sql.NullBool {
+			if b == nil {
+				return sql.NullBool{}
+			}
+			return sql.NullBool{Bool: *b, Valid: true}
+		}(params.PayWhatYouWant),
+		SuggestedPriceInCents: sql.NullInt64{
+			Int64: params.SuggestedPriceInCents,
+			Valid: params.SuggestedPriceInCents != 0,
+		},
 	})
 	if err != nil {
 		return nil, err
@@ -198,6 +438,14 @@ func (s *Store) UpdateVideoInfo(ctx context.Context, externalID string, params *
 		InputHeight:       int32(v.InputHeight.Int64),
 		InputWidth:        int32(v.InputWidth.Int64),
 		ReadyToStream:     v.ReadyToStream,
-		CreatedAt:         v.CreatedAt,
+		Status:            v.Status,
+		StatusReason:      v.StatusReason,
+
+		CoverVariants: coverVariants(v.CoverCardUrl, v.CoverHeroUrl,
+			v.CoverOgUrl),
+
+		PayWhatYouWant:        v.PayWhatYouWant,
+		SuggestedPriceInCents: v.SuggestedPriceInCents.Int64,
+		CreatedAt:             v.CreatedAt,
 	}, nil
 }
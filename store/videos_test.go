@@ -0,0 +1,245 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/fewsats/blockbuster/jobs"
+	"github.com/fewsats/blockbuster/reconcile"
+	"github.com/fewsats/blockbuster/selfhosted"
+	"github.com/fewsats/blockbuster/storage"
+	"github.com/fewsats/blockbuster/video"
+	"github.com/stretchr/testify/require"
+)
+
+// TestVideoStatus tests that new videos await their upload unless created in
+// another status, and that the status reported by Cloudflare is listed with
+// its reason.
+func TestVideoStatus(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(t)
+
+	userID, err := s.CreateUser(ctx, "creator@fewsats.com")
+	require.NoError(t, err)
+
+	_, err = s.CreateVideo(ctx, video.CreateVideoParams{
+		ExternalID:   "externalID",
+		UserID:       userID,
+		Title:        "title",
+		CoverURL:     "cover_url",
+		PriceInCents: 100,
+	})
+	require.NoError(t, err)
+
+	created, err := s.GetVideoByExternalID(ctx, "externalID")
+	require.NoError(t, err)
+	require.Equal(t, video.StatusAwaitingUpload, created.Status)
+
+	updated, err := s.UpdateCloudflareInfo(ctx, "externalID",
+		&video.CloudflareVideoInfo{
+			Status:       video.StatusFailed,
+			StatusReason: "The video is too long",
+		},
+	)
+	require.NoError(t, err)
+	require.False(t, updated.ReadyToStream)
+	require.Equal(t, video.StatusFailed, updated.Status)
+
+	videos, err := s.ListUserVideos(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, videos, 1)
+	require.Equal(t, video.StatusFailed, videos[0].Status)
+	require.Equal(t, "The video is too long", videos[0].StatusReason)
+
+	// Imported videos are processing from the start.
+	_, err = s.CreateVideo(ctx, video.CreateVideoParams{
+		ExternalID:   "imported",
+		UserID:       userID,
+		Title:        "title",
+		CoverURL:     "cover_url",
+		PriceInCents: 100,
+		Status:       video.StatusProcessing,
+		CoverVariants: &video.CoverVariants{
+			CardURL: "card_url",
+			HeroURL: "hero_url",
+			OGURL:   "og_url",
+		},
+	})
+	require.NoError(t, err)
+
+	imported, err := s.GetVideoByExternalID(ctx, "imported")
+	require.NoError(t, err)
+	require.Equal(t, video.StatusProcessing, imported.Status)
+	require.Equal(t, &video.CoverVariants{
+		CardURL: "card_url",
+		HeroURL: "hero_url",
+		OGURL:   "og_url",
+	}, imported.CoverVariants)
+
+	// The videos created before the variants existed have none.
+	require.Nil(t, created.CoverVariants)
+}
+
+// TestVideoTrash tests that deleted videos stay in the trash during their
+// grace period, where they can be restored, and that their assets are purged
+// in the background once it is over.
+func TestVideoTrash(t *testing.T) {
+	ctx := context.Background()
+	s, clock := newTestStore(t)
+	start := time.Now().UTC()
+	clock.SetMockClockTime(start)
+
+	userID, err := s.CreateUser(ctx, "creator@fewsats.com")
+	require.NoError(t, err)
+
+	_, err = s.CreateVideo(ctx, video.CreateVideoParams{
+		ExternalID:   "externalID",
+		UserID:       userID,
+		Title:        "title",
+		CoverURL:     "cover_url",
+		PriceInCents: 100,
+	})
+	require.NoError(t, err)
+
+	public, err := storage.NewLocalStorage(&storage.LocalConfig{
+		Path:    t.TempDir(),
+		BaseURL: "http://localhost:8080",
+	}, storage.BucketPublic, true, clock)
+	require.NoError(t, err)
+	err = public.Put(ctx, "cover-images/externalID",
+		bytes.NewReader([]byte("cover")), "image/png")
+	require.NoError(t, err)
+
+	private, err := storage.NewLocalStorage(&storage.LocalConfig{
+		Path:    t.TempDir(),
+		BaseURL: "http://localhost:8080",
+	}, storage.BucketPrivate, false, clock)
+	require.NoError(t, err)
+
+	queue := jobs.NewQueue(s, jobs.DefaultConfig(), clock, slog.Default())
+	backendCfg := selfhosted.DefaultConfig()
+	backendCfg.UploadPath = t.TempDir()
+	backend, err := selfhosted.NewService(backendCfg, s, private, queue,
+		clock, slog.Default())
+	require.NoError(t, err)
+
+	cfg := video.DefaultConfig()
+	cfg.DeletionGracePeriod = 24 * time.Hour
+	manager := video.NewManager(nil, backend, public, nil, nil, nil, queue, s,
+		slog.Default(), clock, cfg)
+
+	// A deleted video is no longer listed but can still be streamed.
+	require.NoError(t, manager.DeleteVideo(ctx, userID, "externalID"))
+	_, err = s.GetVideoByExternalID(ctx, "externalID")
+	require.ErrorIs(t, err, video.ErrVideoNotFound)
+
+	trashed, err := s.GetTrashedVideo(ctx, "externalID")
+	require.NoError(t, err)
+	require.Equal(t, start, trashed.DeletedAt.UTC())
+	require.Equal(t, start.Add(24*time.Hour), trashed.PurgeAt.UTC())
+	require.Nil(t, trashed.PurgedAt)
+
+	trashedIDs, err := s.ListUserTrashedVideos(ctx, userID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"externalID"}, trashedIDs)
+
+	// Only its creator restores it.
+	err = s.RestoreVideo(ctx, userID+1, "externalID")
+	require.ErrorIs(t, err, video.ErrVideoNotFound)
+
+	restored, err := manager.RestoreVideo(ctx, userID, "externalID")
+	require.NoError(t, err)
+	require.Equal(t, "externalID", restored.ExternalID)
+	_, err = s.GetTrashedVideo(ctx, "externalID")
+	require.ErrorIs(t, err, video.ErrVideoNotFound)
+
+	// Deleting it again postpones its purge, so the job of the first
+	// deletion leaves it untouched.
+	clock.SetMockClockTime(start.Add(time.Hour))
+	require.NoError(t, manager.DeleteVideo(ctx, userID, "externalID"))
+
+	clock.SetMockClockTime(start.Add(24 * time.Hour))
+	ok, err := queue.RunNext(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	cover, err := public.Open("cover-images/externalID")
+	require.NoError(t, err)
+	require.NoError(t, cover.Close())
+
+	// Once the grace period is over, it can't be restored and its assets
+	// are purged.
+	clock.SetMockClockTime(start.Add(25 * time.Hour))
+	err = s.RestoreVideo(ctx, userID, "externalID")
+	require.ErrorIs(t, err, video.ErrVideoNotFound)
+
+	ok, err = queue.RunNext(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	_, err = public.Open("cover-images/externalID")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	trashed, err = s.GetTrashedVideo(ctx, "externalID")
+	require.NoError(t, err)
+	require.NotNil(t, trashed.PurgedAt)
+
+	trashedIDs, err = s.ListUserTrashedVideos(ctx, userID)
+	require.NoError(t, err)
+	require.Empty(t, trashedIDs)
+}
+
+// TestStaleUploads tests that the videos never uploaded are listed once their
+// upload window is over and that expiring them is reflected in their state.
+func TestStaleUploads(t *testing.T) {
+	ctx := context.Background()
+	s, clock := newTestStore(t)
+	start := time.Now().UTC()
+	clock.SetMockClockTime(start)
+
+	userID, err := s.CreateUser(ctx, "creator@fewsats.com")
+	require.NoError(t, err)
+
+	for _, externalID := range []string{"stale", "deleted", "recent"} {
+		if externalID == "recent" {
+			clock.SetMockClockTime(start.Add(3 * time.Hour))
+		}
+
+		_, err = s.CreateVideo(ctx, video.CreateVideoParams{
+			ExternalID:   externalID,
+			UserID:       userID,
+			Title:        "title",
+			CoverURL:     "cover_url",
+			PriceInCents: 100,
+		})
+		require.NoError(t, err)
+	}
+	require.NoError(t, s.DeleteVideo(ctx, "deleted", start.Add(time.Hour)))
+
+	uploads, err := s.ListStaleUploads(ctx, start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, uploads, 1)
+	require.Equal(t, "stale", uploads[0].ExternalID)
+
+	manager := video.NewManager(nil, nil, nil, nil, nil, nil, nil, s,
+		slog.Default(), clock, video.DefaultConfig())
+	expired, err := manager.ExpireUpload(ctx, uploads[0], nil)
+	require.NoError(t, err)
+	require.Equal(t, video.StatusExpired, expired.Status)
+
+	uploads, err = s.ListStaleUploads(ctx, start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Empty(t, uploads)
+
+	states, err := s.ListVideoStates(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []*reconcile.VideoState{
+		{ExternalID: "stale", Status: video.StatusExpired},
+		{ExternalID: "deleted", Status: video.StatusAwaitingUpload,
+			Deleted: true},
+		{ExternalID: "recent", Status: video.StatusAwaitingUpload},
+	}, states)
+}
@@ -0,0 +1,278 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fewsats/blockbuster/store/sqlc"
+	"github.com/fewsats/blockbuster/webhooks"
+)
+
+// CreateWebhookEndpoint stores a new webhook endpoint.
+func (s *Store) CreateWebhookEndpoint(ctx context.Context,
+	endpoint *webhooks.Endpoint) error {
+
+	row, err := s.queries.CreateWebhookEndpoint(ctx,
+		sqlc.CreateWebhookEndpointParams{
+			UserID:    endpoint.UserID,
+			Url:       endpoint.URL,
+			Secret:    endpoint.Secret,
+			Events:    strings.Join(endpoint.Events, ","),
+			CreatedAt: s.clock.Now(),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook endpoint: %w", err)
+	}
+
+	*endpoint = *endpointFromRow(row)
+
+	return nil
+}
+
+// GetWebhookEndpoint returns the webhook endpoint with the given ID.
+func (s *Store) GetWebhookEndpoint(ctx context.Context,
+	id int64) (*webhooks.Endpoint, error) {
+
+	row, err := s.queries.GetWebhookEndpoint(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, webhooks.ErrNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get webhook endpoint: %w", err)
+	}
+
+	return endpointFromRow(row), nil
+}
+
+// ListUserWebhookEndpoints returns the webhook endpoints of a user.
+func (s *Store) ListUserWebhookEndpoints(ctx context.Context,
+	userID int64) ([]*webhooks.Endpoint, error) {
+
+	rows, err := s.queries.ListUserWebhookEndpoints(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook endpoints: %w", err)
+	}
+
+	endpoints := make([]*webhooks.Endpoint, 0, len(rows))
+	for _, row := range rows {
+		endpoints = append(endpoints, endpointFromRow(row))
+	}
+
+	return endpoints, nil
+}
+
+// DeleteWebhookEndpoint atomically removes a webhook endpoint of a user and
+// its deliveries.
+func (s *Store) DeleteWebhookEndpoint(ctx context.Context, userID,
+	id int64) error {
+
+	txBody := func(queries *sqlc.Queries) error {
+		deleted, err := queries.DeleteWebhookEndpoint(ctx,
+			sqlc.DeleteWebhookEndpointParams{
+				ID:     id,
+				UserID: userID,
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		if deleted == 0 {
+			return webhooks.ErrNotFound
+		}
+
+		return queries.DeleteEndpointWebhookDeliveries(ctx, id)
+	}
+
+	if err := s.ExecTx(ctx, txBody); err != nil {
+		return fmt.Errorf("failed to delete webhook endpoint(%d): %w", id,
+			err)
+	}
+
+	return nil
+}
+
+// InsertWebhookDeliveries atomically stores the deliveries of an event.
+func (s *Store) InsertWebhookDeliveries(ctx context.Context,
+	deliveries []*webhooks.Delivery) error {
+
+	timestamp := s.clock.Now()
+
+	txBody := func(queries *sqlc.Queries) error {
+		for _, delivery := range deliveries {
+			err := queries.InsertWebhookDelivery(ctx,
+				sqlc.InsertWebhookDeliveryParams{
+					EndpointID:    delivery.EndpointID,
+					UserID:        delivery.UserID,
+					EventType:     delivery.EventType,
+					Payload:       delivery.Payload,
+					Status:        delivery.Status,
+					NextAttemptAt: delivery.NextAttemptAt,
+					CreatedAt:     timestamp,
+					UpdatedAt:     timestamp,
+				},
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := s.ExecTx(ctx, txBody); err != nil {
+		return fmt.Errorf("failed to insert webhook deliveries: %w", err)
+	}
+
+	return nil
+}
+
+// ListDueWebhookDeliveries returns up to limit pending deliveries whose next
+// attempt is due, with the URL and secret of their endpoint.
+func (s *Store) ListDueWebhookDeliveries(ctx context.Context, now time.Time,
+	limit int64) ([]*webhooks.DueDelivery, error) {
+
+	rows, err := s.queries.ListDueWebhookDeliveries(ctx,
+		sqlc.ListDueWebhookDeliveriesParams{
+			NextAttemptAt: now,
+			Limit:         limit,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due webhook deliveries: %w",
+			err)
+	}
+
+	deliveries := make([]*webhooks.DueDelivery, 0, len(rows))
+	for _, row := range rows {
+		deliveries = append(deliveries, &webhooks.DueDelivery{
+			Delivery: deliveryFromRow(sqlc.WebhookDelivery{
+				ID:             row.ID,
+				EndpointID:     row.EndpointID,
+				UserID:         row.UserID,
+				EventType:      row.EventType,
+				Payload:        row.Payload,
+				Status:         row.Status,
+				Attempts:       row.Attempts,
+				NextAttemptAt:  row.NextAttemptAt,
+				LastStatusCode: row.LastStatusCode,
+				LastError:      row.LastError,
+				CreatedAt:      row.CreatedAt,
+				UpdatedAt:      row.UpdatedAt,
+			}),
+			URL:    row.Url,
+			Secret: row.Secret,
+		})
+	}
+
+	return deliveries, nil
+}
+
+// ListEndpointWebhookDeliveries returns the most recent deliveries of an
+// endpoint.
+func (s *Store) ListEndpointWebhookDeliveries(ctx context.Context,
+	endpointID, limit int64) ([]*webhooks.Delivery, error) {
+
+	rows, err := s.queries.ListEndpointWebhookDeliveries(ctx,
+		sqlc.ListEndpointWebhookDeliveriesParams{
+			EndpointID: endpointID,
+			Limit:      limit,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+
+	deliveries := make([]*webhooks.Delivery, 0, len(rows))
+	for _, row := range rows {
+		deliveries = append(deliveries, deliveryFromRow(row))
+	}
+
+	return deliveries, nil
+}
+
+// UpdateWebhookDeliveryAttempt stores the result of a delivery attempt.
+func (s *Store) UpdateWebhookDeliveryAttempt(ctx context.Context,
+	attempt *webhooks.DeliveryAttempt) error {
+
+	return s.queries.UpdateWebhookDeliveryAttempt(ctx,
+		sqlc.UpdateWebhookDeliveryAttemptParams{
+			Status:         attempt.Status,
+			Attempts:       attempt.Attempts,
+			NextAttemptAt:  attempt.NextAttemptAt,
+			LastStatusCode: attempt.LastStatusCode,
+			LastError:      attempt.LastError,
+			UpdatedAt:      s.clock.Now(),
+			ID:             attempt.ID,
+		},
+	)
+}
+
+// RedeliverWebhookDelivery schedules a delivery of a user to be sent again
+// right away with a fresh set of attempts.
+func (s *Store) RedeliverWebhookDelivery(ctx context.Context, userID,
+	id int64) error {
+
+	timestamp := s.clock.Now()
+
+	updated, err := s.queries.RedeliverWebhookDelivery(ctx,
+		sqlc.RedeliverWebhookDeliveryParams{
+			NextAttemptAt: timestamp,
+			UpdatedAt:     timestamp,
+			ID:            id,
+			UserID:        userID,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to redeliver webhook delivery(%d): %w",
+			id, err)
+	}
+
+	if updated == 0 {
+		return webhooks.ErrNotFound
+	}
+
+	return nil
+}
+
+// endpointFromRow converts a webhook_endpoints row into a webhooks.Endpoint.
+func endpointFromRow(row sqlc.WebhookEndpoint) *webhooks.Endpoint {
+	events := []string{}
+	if row.Events != "" {
+		events = strings.Split(row.Events, ",")
+	}
+
+	return &webhooks.Endpoint{
+		ID:        row.ID,
+		UserID:    row.UserID,
+		URL:       row.Url,
+		Secret:    row.Secret,
+		Events:    events,
+		CreatedAt: row.CreatedAt,
+	}
+}
+
+// deliveryFromRow converts a webhook_deliveries row into a
+// webhooks.Delivery.
+func deliveryFromRow(row sqlc.WebhookDelivery) *webhooks.Delivery {
+	return &webhooks.Delivery{
+		ID:             row.ID,
+		EndpointID:     row.EndpointID,
+		UserID:         row.UserID,
+		EventType:      row.EventType,
+		Payload:        row.Payload,
+		Status:         row.Status,
+		Attempts:       row.Attempts,
+		NextAttemptAt:  row.NextAttemptAt,
+		LastStatusCode: row.LastStatusCode,
+		LastError:      row.LastError,
+		CreatedAt:      row.CreatedAt,
+		UpdatedAt:      row.UpdatedAt,
+	}
+}
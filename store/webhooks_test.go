@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fewsats/blockbuster/webhooks"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWebhookDeliveries tests that the due deliveries are listed with their
+// endpoint and removed along with it.
+func TestWebhookDeliveries(t *testing.T) {
+	ctx := context.Background()
+	s, clock := newTestStore(t)
+	now := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	clock.SetMockClockTime(now)
+
+	userID, err := s.CreateUser(ctx, "creator@fewsats.com")
+	require.NoError(t, err)
+
+	endpoint := &webhooks.Endpoint{
+		UserID: userID,
+		URL:    "https://example.com/webhook",
+		Secret: "secret",
+		Events: []string{webhooks.EventPurchaseCreated},
+	}
+	require.NoError(t, s.CreateWebhookEndpoint(ctx, endpoint))
+	require.NotZero(t, endpoint.ID)
+
+	endpoints, err := s.ListUserWebhookEndpoints(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, endpoints, 1)
+	require.Equal(t, endpoint.Events, endpoints[0].Events)
+
+	err = s.InsertWebhookDeliveries(ctx, []*webhooks.Delivery{
+		{
+			EndpointID:    endpoint.ID,
+			UserID:        userID,
+			EventType:     webhooks.EventPurchaseCreated,
+			Payload:       "{}",
+			Status:        webhooks.DeliveryStatusPending,
+			NextAttemptAt: now,
+		},
+		{
+			EndpointID:    endpoint.ID,
+			UserID:        userID,
+			EventType:     webhooks.EventPurchaseCreated,
+			Payload:       "{}",
+			Status:        webhooks.DeliveryStatusPending,
+			NextAttemptAt: now.Add(time.Hour),
+		},
+	})
+	require.NoError(t, err)
+
+	due, err := s.ListDueWebhookDeliveries(ctx, now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	require.Equal(t, endpoint.URL, due[0].URL)
+	require.Equal(t, endpoint.Secret, due[0].Secret)
+	deliveryID := due[0].ID
+
+	err = s.UpdateWebhookDeliveryAttempt(ctx, &webhooks.DeliveryAttempt{
+		ID:             deliveryID,
+		Status:         webhooks.DeliveryStatusFailed,
+		Attempts:       1,
+		NextAttemptAt:  now,
+		LastStatusCode: 500,
+		LastError:      "unexpected status code: 500",
+	})
+	require.NoError(t, err)
+
+	due, err = s.ListDueWebhookDeliveries(ctx, now, 10)
+	require.NoError(t, err)
+	require.Empty(t, due)
+
+	// Only the owner can redeliver.
+	err = s.RedeliverWebhookDelivery(ctx, userID+1, deliveryID)
+	require.ErrorIs(t, err, webhooks.ErrNotFound)
+	require.NoError(t, s.RedeliverWebhookDelivery(ctx, userID, deliveryID))
+
+	due, err = s.ListDueWebhookDeliveries(ctx, now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	require.Zero(t, due[0].Attempts)
+
+	err = s.DeleteWebhookEndpoint(ctx, userID+1, endpoint.ID)
+	require.ErrorIs(t, err, webhooks.ErrNotFound)
+	require.NoError(t, s.DeleteWebhookEndpoint(ctx, userID, endpoint.ID))
+
+	due, err = s.ListDueWebhookDeliveries(ctx, now.Add(time.Hour), 10)
+	require.NoError(t, err)
+	require.Empty(t, due)
+}
@@ -1,6 +1,9 @@
 package utils
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 // Clock is the interface for getting the current time.
 type Clock interface {
@@ -20,18 +23,26 @@ func NewRealClock() *RealClock {
 	return &RealClock{}
 }
 
-// MockClock is a mock implementation of the Clock interface.
+// MockClock is a mock implementation of the Clock interface. It can be used
+// from several goroutines.
 type MockClock struct {
+	mu   sync.RWMutex
 	Time time.Time
 }
 
 // SetMockClockTime sets the time of the mock clock.
 func (m *MockClock) SetMockClockTime(t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.Time = t
 }
 
 // Now returns the current time in UTC.
 func (m *MockClock) Now() time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	return m.Time
 }
 
@@ -0,0 +1,14 @@
+package utils
+
+import "expvar"
+
+// MetricValue returns the current value of an integer counter of the expvar
+// map, zero if it was never set.
+func MetricValue(metrics *expvar.Map, key string) int64 {
+	value, ok := metrics.Get(key).(*expvar.Int)
+	if !ok {
+		return 0
+	}
+
+	return value.Value()
+}
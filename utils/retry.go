@@ -0,0 +1,30 @@
+package utils
+
+import "time"
+
+// maxErrorLength is the maximum length of the error stored for the last
+// attempt of a retried task.
+const maxErrorLength = 512
+
+// Backoff returns the delay before the next attempt of a task that already
+// ran the given attempts, doubling the initial delay on every attempt up to
+// the maximum delay.
+func Backoff(attempts int64, initial, maximum time.Duration) time.Duration {
+	delay := initial
+	for i := int64(1); i < attempts && delay < maximum; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maximum)
+}
+
+// LastError returns the message of the error of the last attempt of a
+// retried task, truncated to be stored.
+func LastError(err error) string {
+	msg := err.Error()
+	if len(msg) > maxErrorLength {
+		msg = msg[:maxErrorLength]
+	}
+
+	return msg
+}
@@ -1,14 +1,49 @@
 package video
 
+import "time"
+
+const (
+	// StreamingProviderCloudflare hosts the videos on Cloudflare Stream.
+	StreamingProviderCloudflare = "cloudflare"
+
+	// StreamingProviderSelfHosted hosts the videos in the storage and
+	// streams them from the server.
+	StreamingProviderSelfHosted = "selfhosted"
+)
+
 // DefaultConfig returns all default values for the Config struct.
 func DefaultConfig() *Config {
 	return &Config{
 		L402BaseURL: "http://localhost:8080/video/stream",
 		L402InfoURI: "http://localhost:8080/video/info",
+
+		L402SubscriptionURL: "http://localhost:8080/video/subscribe",
+
+		L402BundleBaseURL: "http://localhost:8080/bundle/stream",
+		L402BundleInfoURI: "http://localhost:8080/bundle/info",
+
+		StreamingProvider: StreamingProviderCloudflare,
+
+		DeletionGracePeriod: 7 * 24 * time.Hour,
+
+		MaxCoverImageSize:      10 << 20, // 10 MiB
+		MaxCoverImageDimension: 8192,
 	}
 }
 
 type Config struct {
 	L402BaseURL string `long:"l402_base_url" description:"L402 base URL"`
 	L402InfoURI string `long:"l402_info_uri" description:"L402 info URI"`
+
+	L402SubscriptionURL string `long:"l402_subscription_url" description:"L402 base URL to subscribe to the creator of a video"`
+
+	L402BundleBaseURL string `long:"l402_bundle_base_url" description:"L402 base URL of video bundles"`
+	L402BundleInfoURI string `long:"l402_bundle_info_uri" description:"L402 info URI of video bundles"`
+
+	StreamingProvider string `long:"streaming_provider" description:"Backend hosting the videos {cloudflare, selfhosted}"`
+
+	DeletionGracePeriod time.Duration `long:"deletion_grace_period" description:"Time deleted videos stay in the trash, still streamable by their buyers and restorable by their creators, before their assets are deleted"`
+
+	MaxCoverImageSize      int64 `long:"max_cover_image_size" description:"Maximum size of the cover images in bytes"`
+	MaxCoverImageDimension int   `long:"max_cover_image_dimension" description:"Maximum width and height of the cover images in pixels"`
 }
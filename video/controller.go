@@ -5,8 +5,11 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,6 +22,50 @@ import (
 const (
 	// ExpirationTime is the time after which a macaroon expires.
 	ExpirationTime = 24 * time.Hour * 30 * 12 // 12 months
+
+	// SubscriptionPeriod is the time a subscription to a creator lasts.
+	SubscriptionPeriod = 24 * time.Hour * 30 // 1 month
+
+	// CaveatExternalID is the caveat granting access to a single video.
+	CaveatExternalID = "external_id"
+
+	// CaveatCreatorID is the caveat granting access to all the videos of a
+	// creator.
+	CaveatCreatorID = "creator_id"
+
+	// CaveatBundleID is the caveat granting access to all the videos of a
+	// bundle.
+	CaveatBundleID = "bundle_id"
+
+	// ServiceTypeVideos is the service type of single video purchases.
+	ServiceTypeVideos = "videos"
+
+	// ServiceTypeSubscriptions is the service type of creator subscriptions.
+	ServiceTypeSubscriptions = "subscriptions"
+
+	// ServiceTypeBundles is the service type of bundle purchases.
+	ServiceTypeBundles = "bundles"
+
+	// PricingOptionBuy is the key of the pricing option to buy a video.
+	PricingOptionBuy = "buy"
+
+	// PricingTypePurchase is the type of the pricing option to buy a video.
+	PricingTypePurchase = "purchase"
+
+	// PricingTypeRental is the type of the pricing options to rent a video.
+	PricingTypeRental = "rental"
+
+	// DiscountTypePercentage is the type of the promo codes discounting a
+	// percentage of the price.
+	DiscountTypePercentage = "percentage"
+
+	// DiscountTypeFixed is the type of the promo codes discounting a fixed
+	// amount from the price.
+	DiscountTypeFixed = "fixed"
+
+	// MaxRecoverableOffers is the number of most recent offers checked when
+	// recovering the credentials bound to a public key.
+	MaxRecoverableOffers = 20
 )
 
 type Controller struct {
@@ -47,36 +94,92 @@ func (c *Controller) RegisterPublicRoutes(router *gin.Engine) {
 	router.POST("/video/upload", c.UploadVideo)
 	router.GET("/video/info/:id", c.GetVideoInfo)
 	router.GET("/video/:id", c.handleVideoPage)
+	router.GET("/bundle/info/:id", c.GetBundleInfo)
+	router.POST("/video/cloudflare/webhook", c.CloudflareWebhook)
 }
 
 func (c *Controller) RegisterL402Routes(router *gin.Engine) {
 	router.POST("/video/stream/:id", c.StreamVideo)
 	router.GET("/video/stream/:id", c.StreamVideoGET)
+	router.POST("/video/subscribe/:id", c.Subscribe)
+	router.GET("/video/subscribe/:id", c.SubscribeGET)
+	router.POST("/bundle/stream/:id", c.StreamBundle)
+	router.GET("/bundle/stream/:id", c.StreamBundleGET)
+	router.POST("/video/credentials/:id", c.RecoverCredentials)
+	router.POST("/credentials/restore", c.RestoreCredentials)
+	router.GET("/library", c.GetLibrary)
 }
 
 func (c *Controller) RegisterProtectedRoutes(router *gin.Engine) {
 	router.GET("/user/videos", c.ListUserVideos)
 	router.PUT("/video/:id", c.UpdateVideoInfo)
+	router.POST("/video/import", c.ImportVideo)
 	router.DELETE("/video/:id", c.DeleteVideo) // Add this line
+	router.POST("/video/:id/restore", c.RestoreVideo)
+	router.PUT("/video/:id/rentals", c.SetVideoRental)
+	router.DELETE("/video/:id/rentals/:hours", c.DeleteVideoRental)
+	router.POST("/bundle", c.CreateBundle)
+	router.GET("/user/bundles", c.ListUserBundles)
+	router.PUT("/bundle/:id", c.UpdateBundle)
+	router.DELETE("/bundle/:id", c.DeleteBundle)
+	router.POST("/promo-code", c.CreatePromoCode)
+	router.GET("/user/promo-codes", c.ListUserPromoCodes)
+	router.DELETE("/promo-code/:code", c.DeletePromoCode)
 }
 
-type UploadVideoRequest struct {
-	Email        string                `form:"email" binding:"required,email"`
+// VideoMetadata is the metadata of a new video, uploaded or imported.
+type VideoMetadata struct {
 	Title        string                `form:"title" binding:"required"`
 	Description  string                `form:"description"`
 	PriceInCents int64                 `form:"price_in_cents" binding:"required,min=0"`
 	CoverImage   *multipart.FileHeader `form:"cover_image"`
+
+	// PayWhatYouWant makes PriceInCents the minimum price of the video.
+	PayWhatYouWant        bool  `form:"pay_what_you_want"`
+	SuggestedPriceInCents int64 `form:"suggested_price_in_cents" binding:"min=0"`
+}
+
+func (r *VideoMetadata) Validate() error {
+	if r.CoverImage == nil {
+		return fmt.Errorf("cover image is required")
+	}
+	if r.SuggestedPriceInCents != 0 && !r.PayWhatYouWant {
+		return fmt.Errorf("suggested price requires pay what you want")
+	}
+	if r.SuggestedPriceInCents != 0 &&
+		r.SuggestedPriceInCents < r.PriceInCents {
+
+		return fmt.Errorf("suggested price must not be below the minimum")
+	}
+	return nil
+}
+
+type UploadVideoRequest struct {
+	Email string `form:"email" binding:"required,email"`
+	VideoMetadata
+
+	// Resumable requests a tus upload of a file of SizeInBytes bytes,
+	// which can be resumed after an interruption.
+	Resumable   bool  `form:"resumable"`
+	SizeInBytes int64 `form:"size_in_bytes" binding:"min=0"`
 }
 
 // UploadFileResponse represents a response to uploading a file.
 type UploadVideoResponse struct {
 	VideoID   string `json:"video_id"`
 	UploadURL string `json:"upload_url"`
+
+	// Resumable is set when UploadURL is a tus upload rather than a URL
+	// to POST the file to.
+	Resumable bool `json:"resumable"`
 }
 
 func (r *UploadVideoRequest) Validate() error {
-	if r.CoverImage == nil {
-		return fmt.Errorf("cover image is required")
+	if err := r.VideoMetadata.Validate(); err != nil {
+		return err
+	}
+	if r.Resumable && r.SizeInBytes == 0 {
+		return fmt.Errorf("resumable uploads require the size of the file")
 	}
 	return nil
 }
@@ -105,8 +208,13 @@ func (c *Controller) UploadVideo(gCtx *gin.Context) {
 		return
 	}
 
-	uploadURL, externalID, err := c.videos.PrepareVideoUpload(gCtx, userID, req)
-	if err != nil {
+	upload, err := c.videos.PrepareVideoUpload(gCtx, userID, req)
+	switch {
+	case errors.Is(err, ErrInvalidCoverImage):
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+
+	case err != nil:
 		c.logger.Error("Failed to prepare video upload", "error", err)
 		gCtx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -115,8 +223,81 @@ func (c *Controller) UploadVideo(gCtx *gin.Context) {
 	gCtx.JSON(
 		http.StatusOK,
 		UploadVideoResponse{
-			VideoID:   externalID,
-			UploadURL: uploadURL,
+			VideoID:   upload.ExternalID,
+			UploadURL: upload.URL,
+			Resumable: upload.Resumable,
+		},
+	)
+}
+
+// ImportVideoRequest is a request to import a video from a URL instead of
+// uploading it.
+type ImportVideoRequest struct {
+	SourceURL string `form:"source_url" binding:"required"`
+	VideoMetadata
+}
+
+// ImportVideoResponse is the response to importing a video.
+type ImportVideoResponse struct {
+	VideoID string `json:"video_id"`
+	Status  string `json:"status"`
+}
+
+func (r *ImportVideoRequest) Validate() error {
+	if err := r.VideoMetadata.Validate(); err != nil {
+		return err
+	}
+
+	sourceURL, err := url.Parse(r.SourceURL)
+	if err != nil || sourceURL.Host == "" ||
+		(sourceURL.Scheme != "http" && sourceURL.Scheme != "https") {
+
+		return fmt.Errorf("source URL must be an http or https URL")
+	}
+	return nil
+}
+
+// ImportVideo creates a video of the user copied from a URL by the streaming
+// backend. The video is processing until the copy is ready to stream.
+func (c *Controller) ImportVideo(gCtx *gin.Context) {
+	userID := gCtx.GetInt64("user_id")
+	if userID == 0 {
+		gCtx.JSON(http.StatusForbidden,
+			gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	var req ImportVideoRequest
+	if err := gCtx.ShouldBind(&req); err != nil {
+		c.logger.Error("Invalid request", "error", err)
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		c.logger.Error("Invalid request", "error", err)
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	externalID, err := c.videos.ImportVideo(gCtx, userID, req)
+	switch {
+	case errors.Is(err, ErrInvalidCoverImage):
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+
+	case err != nil:
+		c.logger.Error("Failed to import video", "error", err)
+		gCtx.JSON(http.StatusInternalServerError,
+			gin.H{"error": "Failed to import video"})
+		return
+	}
+
+	gCtx.JSON(
+		http.StatusOK,
+		ImportVideoResponse{
+			VideoID: externalID,
+			Status:  StatusProcessing,
 		},
 	)
 }
@@ -163,10 +344,47 @@ type StreamVideoRequest struct {
 	Domain    string `json:"domain" binding:"required"`
 	Timestamp int64  `json:"timestamp" binding:"required"`
 	Signature string `json:"signature" binding:"required"`
+
+	// Option is the pricing option to pay for, defaults to buying the
+	// video.
+	Option string `json:"option"`
+
+	// PromoCode is an optional promo code of the creator to discount the
+	// price.
+	PromoCode string `json:"promo_code"`
+
+	// Amount is the price in cents to pay for pay-what-you-want videos. The
+	// suggested price is used when empty.
+	Amount int64 `json:"amount" binding:"min=0"`
+
+	// RecipientPubKey is the public key the video is gifted to. The
+	// credentials are bound to the signer when empty.
+	RecipientPubKey string `json:"recipient_pub_key"`
+}
+
+// ChallengeRequest holds the validated parameters to create an L402
+// challenge.
+type ChallengeRequest struct {
+	// PubKey is the public key the credentials are bound to.
+	PubKey string
+
+	// Option is the pricing option to pay for.
+	Option string
+
+	// PromoCode is the promo code to apply to the price, if any.
+	PromoCode string
+
+	// AmountInCents is the amount chosen for pay-what-you-want options,
+	// zero for the suggested one.
+	AmountInCents int64
+
+	// RecipientPubKey is the public key the credentials are bound to when
+	// the video is a gift, PubKey being the one of the payer.
+	RecipientPubKey string
 }
 
 // ValidatorFunc is a function type for request validation
-type ValidatorFunc func(gCtx *gin.Context) (string, error)
+type ValidatorFunc func(gCtx *gin.Context) (*ChallengeRequest, error)
 
 func (c *Controller) handleStreamVideo(gCtx *gin.Context, validator ValidatorFunc) {
 	ctx := gCtx.Request.Context()
@@ -185,8 +403,33 @@ func (c *Controller) handleStreamVideo(gCtx *gin.Context, validator ValidatorFun
 	}
 
 	// Step 0: Check if the video is ready to stream
-	err = c.videos.IsVideoReady(ctx, externalID)
-	if err != nil {
+	video, err := c.videos.IsVideoReady(ctx, externalID)
+	switch {
+	case errors.Is(err, ErrVideoNotFound):
+		gCtx.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+
+	case errors.Is(err, ErrVideoExpired):
+		gCtx.JSON(http.StatusNotFound, gin.H{"error": "Video was never " +
+			"uploaded, its upload URL expired"})
+		return
+
+	case errors.Is(err, ErrVideoAwaitingUpload):
+		gCtx.JSON(http.StatusConflict, gin.H{"error": "Video has not " +
+			"been uploaded yet"})
+		return
+
+	case errors.Is(err, ErrVideoProcessing):
+		gCtx.JSON(http.StatusConflict, gin.H{"error": "Video is still " +
+			"processing, try again in a few minutes"})
+		return
+
+	case errors.Is(err, ErrVideoFailed):
+		gCtx.JSON(http.StatusConflict, gin.H{"error": "Video can't be " +
+			"streamed, " + err.Error()})
+		return
+
+	case err != nil:
 		c.logger.Error("Video is not ready to stream", "error", err)
 		gCtx.JSON(
 			http.StatusInternalServerError,
@@ -195,15 +438,20 @@ func (c *Controller) handleStreamVideo(gCtx *gin.Context, validator ValidatorFun
 		return
 	}
 
-	// Step 1: Check if the user has provided valid L402 credentials
+	// Step 1: Check if the user has provided valid L402 credentials for
+	// this video, a bundle that contains it or a subscription to its
+	// creator.
+	var access Access
 	autHeader := gCtx.GetHeader("Authorization")
-	paymentHash, err := c.authenticator.ValidateL402Credentials(ctx, autHeader)
+	paymentHash, err := c.authenticator.ValidateL402Credentials(ctx, autHeader,
+		c.videos.AccessCheckers(ctx, video, &access))
 	switch {
 	// Step 1.1: A set of valid L402 credentials was provided so we will record the sale
 	// and allow the user to stream the video.
 	case err == nil:
 		// Valid L402 credentials provided
-		err = c.videos.RecordPurchaseAndView(ctx, externalID, paymentHash, "videos")
+		err = c.videos.RecordPurchaseAndView(ctx, externalID, paymentHash,
+			access.ServiceType)
 		if err != nil {
 			c.logger.Error(
 				"failed to record purchase",
@@ -212,17 +460,21 @@ func (c *Controller) handleStreamVideo(gCtx *gin.Context, validator ValidatorFun
 			)
 		}
 
-		HLSURL, DashURL, err := c.videos.GenerateStreamURL(gCtx, externalID)
+		urls, err := c.videos.GenerateStreamURL(gCtx, externalID, &access,
+			gCtx.ClientIP())
 		if err != nil {
 			c.logger.Error("Failed to generate stream URL", "error", err)
 			gCtx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate stream URL"})
 			return
 		}
 
-		gCtx.JSON(http.StatusOK, gin.H{"hls_url": HLSURL, "dash_url": DashURL})
+		gCtx.JSON(http.StatusOK, urls)
 		return
 
-	case !errors.Is(err, l402.ErrMissingAuthorizationHeader) && !errors.Is(err, l402.ErrInvalidPreimage):
+	case !errors.Is(err, l402.ErrMissingAuthorizationHeader) &&
+		!errors.Is(err, l402.ErrInvalidPreimage) &&
+		!errors.Is(err, l402.ErrInvalidCaveat) &&
+		!errors.Is(err, l402.ErrRevokedCredentials):
 		// Step 1.2 Unexpected error with L402 credentials, we will fail instead of returning a L402
 		c.logger.Debug(
 			"unable to extract L402 credentials",
@@ -237,26 +489,44 @@ func (c *Controller) handleStreamVideo(gCtx *gin.Context, validator ValidatorFun
 		return
 	}
 
+	// Deleted videos are only streamed by the users that already bought
+	// them, they are not sold anymore.
+	if video.DeletedAt != nil {
+		gCtx.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
+
 	// Step 2: No L402 credentials have been provided if:
 	// - GET request -> return a challenge
 	// - POST request -> let's check if the request is from a valid
 	// user and contains all the required params to send back a challenge
 	// This step is reached when:
-	//   err == ErrMissingAuthorizationHeader || ErrInvalidPreimage
+	//   err == ErrMissingAuthorizationHeader || ErrInvalidPreimage ||
+	//   ErrInvalidCaveat (e.g. expired credentials) ||
+	//   ErrRevokedCredentials (e.g. refunded purchase)
 	c.logger.Debug(
 		"missing Authorization header",
 		"error", err,
 	)
 
-	pubKey, err := validator(gCtx)
+	challengeReq, err := validator(gCtx)
 	if err != nil {
 		c.logger.Error("Invalid request", "error", err)
 		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	challenge, err := c.videos.CreateL402Challenge(ctx, pubKey, externalID)
-	if err != nil {
+	challenge, err := c.videos.CreateL402Challenge(ctx, externalID,
+		challengeReq)
+	switch {
+	case errors.Is(err, ErrUnknownPricingOption),
+		errors.Is(err, ErrInvalidPromoCode),
+		errors.Is(err, ErrInvalidAmount):
+
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+
+	case err != nil:
 		c.logger.Error("failed to create challenge",
 			"file_id", externalID,
 			"error", err,
@@ -268,6 +538,13 @@ func (c *Controller) handleStreamVideo(gCtx *gin.Context, validator ValidatorFun
 		return
 	}
 
+	c.writeChallenge(gCtx, challenge)
+}
+
+// writeChallenge responds with a 402 containing the given L402 challenge.
+func (c *Controller) writeChallenge(gCtx *gin.Context,
+	challenge *l402.Challenge) {
+
 	headerKey := challenge.HeaderKey()
 	headerValue, err := challenge.HeaderValue()
 	if err != nil {
@@ -284,177 +561,552 @@ func (c *Controller) handleStreamVideo(gCtx *gin.Context, validator ValidatorFun
 }
 
 func (c *Controller) StreamVideo(gCtx *gin.Context) {
-	validator := func(gCtx *gin.Context) (string, error) {
-		var req StreamVideoRequest
-		if err := gCtx.ShouldBindJSON(&req); err != nil {
-			return "", fmt.Errorf("invalid request: %w", err)
-		}
+	c.handleStreamVideo(gCtx, c.signedRequestValidator)
+}
 
-		err := c.authenticator.ValidateSignature(req.PubKey, req.Signature,
-			req.Domain, req.Timestamp)
-		if err != nil {
-			return "", fmt.Errorf("invalid signature: %w", err)
-		}
+func (c *Controller) StreamVideoGET(gCtx *gin.Context) {
+	c.handleStreamVideo(gCtx, randomPubKeyValidator)
+}
 
-		return req.PubKey, nil
+// signedRequestValidator validates a challenge request signed by the user.
+func (c *Controller) signedRequestValidator(
+	gCtx *gin.Context) (*ChallengeRequest, error) {
+
+	var req StreamVideoRequest
+	if err := gCtx.ShouldBindJSON(&req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
 	}
 
-	c.handleStreamVideo(gCtx, validator)
-}
+	err := c.authenticator.ValidateSignature(req.PubKey, req.Signature,
+		req.Domain, req.Timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
 
-func (c *Controller) StreamVideoGET(gCtx *gin.Context) {
-	validator := func(gCtx *gin.Context) (string, error) {
-		randomPubKey, err := generateRandomPubKey()
+	if req.RecipientPubKey != "" {
+		err := l402.ValidatePubKey(req.RecipientPubKey)
 		if err != nil {
-			return "", fmt.Errorf("failed to generate random public key: %w", err)
+			return nil, fmt.Errorf("invalid recipient: %w", err)
 		}
-		return randomPubKey, nil
 	}
 
-	c.handleStreamVideo(gCtx, validator)
+	return &ChallengeRequest{
+		PubKey:          req.PubKey,
+		Option:          req.Option,
+		PromoCode:       req.PromoCode,
+		AmountInCents:   req.Amount,
+		RecipientPubKey: req.RecipientPubKey,
+	}, nil
 }
 
-func generateRandomPubKey() (string, error) {
-	key := make([]byte, 32)
-	_, err := rand.Read(key)
-	if err != nil {
-		return "", err
-	}
-	return hex.EncodeToString(key), nil
+const (
+	// RecoverCredentialsAction is the action signed, with the external ID
+	// of the video, to recover the credentials of a video.
+	RecoverCredentialsAction = "recover_credentials"
+
+	// RestoreCredentialsAction is the action signed to restore every
+	// credential of a public key.
+	RestoreCredentialsAction = "restore_credentials"
+
+	// LibraryAction is the action signed to list the library of a public
+	// key.
+	LibraryAction = "library"
+)
+
+// RecoverCredentialsRequest is a request signed by a public key to recover
+// the credentials bound to it. It is used to recover gifts and to restore
+// every purchase, each signing its own action so the signatures can't be
+// replayed for the other.
+type RecoverCredentialsRequest struct {
+	PubKey    string `json:"pub_key" binding:"required"`
+	Domain    string `json:"domain" binding:"required"`
+	Timestamp int64  `json:"timestamp" binding:"required"`
+	Signature string `json:"signature" binding:"required"`
 }
 
-// GetVideoInfo returns the L402 stream information for a given video
-func (c *Controller) GetVideoInfo(gCtx *gin.Context) {
+// RecoverCredentials returns the paid credentials for the video bound to the
+// public key that signed the request, e.g. the ones of a gift.
+func (c *Controller) RecoverCredentials(gCtx *gin.Context) {
+	ctx := gCtx.Request.Context()
 	externalID, err := extractExternalVideoID(gCtx)
 	if err != nil {
-		c.logger.Debug("invalid video ID", "error", err)
 		gCtx.JSON(http.StatusBadRequest, gin.H{"error": "invalid video ID"})
 		return
 	}
 
-	video, err := c.store.GetVideoByExternalID(gCtx, externalID)
-	if err != nil {
-		c.logger.Error("Failed to fetch video info", "error", err)
-		gCtx.JSON(
-			http.StatusInternalServerError,
-			gin.H{"error": "Failed to fetch video info"},
-		)
+	var req RecoverCredentialsRequest
+	if err := gCtx.ShouldBindJSON(&req); err != nil {
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if video == nil {
-		gCtx.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+	err = c.authenticator.ValidateActionSignature(req.PubKey, req.Signature,
+		req.Domain, req.Timestamp, RecoverCredentialsAction, externalID)
+	if err != nil {
+		c.logger.Debug("invalid signature", "error", err)
+		gCtx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid signature"})
 		return
 	}
 
-	info := gin.H{
-		"version":      "1.0",
-		"name":         video.Title,
-		"description":  video.Description,
-		"cover_url":    video.CoverURL,
-		"content_type": "video",
-		"pricing": []gin.H{
-			{
-				"amount":   video.PriceInCents,
-				"currency": "USD",
-			},
-		},
-		"access": gin.H{
-			"endpoint": fmt.Sprintf("%s/%s", c.cfg.L402BaseURL, video.ExternalID),
-			"method":   "POST",
-			"authentication": gin.H{
-				"protocol": "L402",
-				"header":   "Authorization",
-				"format":   "L402 {credentials}:{proof_of_payment}",
-			},
-		},
+	credentials, err := c.videos.RecoverCredentials(ctx, externalID,
+		req.PubKey)
+	if err != nil {
+		c.logger.Error("failed to recover credentials",
+			"external_id", externalID,
+			"error", err,
+		)
+		gCtx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "failed to recover credentials"},
+		)
+		return
 	}
 
-	gCtx.JSON(http.StatusOK, info)
-}
-
-// Add this new method to the Controller
-func (c *Controller) HandleMethodNotAllowed(gCtx *gin.Context) {
-	gCtx.JSON(http.StatusMethodNotAllowed, gin.H{"error": "Method Not Allowed"})
+	gCtx.JSON(http.StatusOK, gin.H{"credentials": credentials})
 }
 
-func (c *Controller) handleVideoPage(gCtx *gin.Context) {
-	videoID := gCtx.Param("id")
+const (
+	// PubKeyHeader is the header of the public key signing a GET request.
+	PubKeyHeader = "X-Pub-Key"
 
-	// Fetch video details from the database
-	video, err := c.store.GetVideoByExternalID(gCtx, videoID)
-	if err != nil {
-		gCtx.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
-		return
-	}
+	// DomainHeader is the header of the domain of a signed GET request.
+	DomainHeader = "X-Domain"
 
-	// Prepare data for the template
-	data := gin.H{
-		"Title":        video.Title,
-		"Description":  video.Description,
-		"VideoURL":     fmt.Sprintf("https://blockbuster.fewsats.com/video/stream/%s", video.ExternalID),
-		"CoverURL":     video.CoverURL,
-		"PriceInCents": float64(video.PriceInCents) / 100,
-	}
+	// TimestampHeader is the header of the timestamp of a signed GET
+	// request.
+	TimestampHeader = "X-Timestamp"
 
-	// Render the video page template
-	gCtx.HTML(http.StatusOK, "video.html", data)
-}
+	// SignatureHeader is the header of the signature of a GET request.
+	SignatureHeader = "X-Signature"
+)
 
-type UpdateVideoInfoRequest struct {
-	Title        string `json:"title"`
-	Description  string `json:"description"`
-	PriceInCents int64  `json:"price_in_cents"`
+// LibraryRequest is a request signed by a public key to list the videos it
+// has purchased. It is sent in the headers so the signature doesn't end up
+// in the logs or the browser history.
+type LibraryRequest struct {
+	PubKey    string `header:"X-Pub-Key" binding:"required"`
+	Domain    string `header:"X-Domain" binding:"required"`
+	Timestamp int64  `header:"X-Timestamp" binding:"required"`
+	Signature string `header:"X-Signature" binding:"required"`
 }
 
-func (c *Controller) UpdateVideoInfo(gCtx *gin.Context) {
-	externalID := gCtx.Param("id")
+// GetLibrary returns the videos purchased by the public key that signed the
+// request with the endpoints to stream them.
+func (c *Controller) GetLibrary(gCtx *gin.Context) {
+	ctx := gCtx.Request.Context()
 
-	_, err := c.validateVideoOwnership(gCtx, externalID)
-	if err != nil {
-		c.logger.Error("Failed to validate video ownership", "error", err)
-		gCtx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	var req LibraryRequest
+	if err := gCtx.ShouldBindHeader(&req); err != nil {
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	var req UpdateVideoInfoRequest
-	if err := gCtx.ShouldBindJSON(&req); err != nil {
-		c.logger.Error("Invalid request", "error", err)
-		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	err := c.authenticator.ValidateActionSignature(req.PubKey, req.Signature,
+		req.Domain, req.Timestamp, LibraryAction)
+	if err != nil {
+		c.logger.Debug("invalid signature", "error", err)
+		gCtx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid signature"})
 		return
 	}
 
-	updatedVideo, err := c.videos.UpdateVideoInfo(gCtx, externalID, req)
+	library, err := c.videos.Library(ctx, req.PubKey)
 	if err != nil {
-		c.logger.Error("Failed to update video info", "error", err)
-		gCtx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update video info"})
+		c.logger.Error("failed to list library", "error", err)
+		gCtx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "failed to list library"},
+		)
 		return
 	}
 
-	gCtx.JSON(http.StatusOK, updatedVideo)
+	for _, v := range library {
+		v.L402URL = fmt.Sprintf("%s/%s", c.cfg.L402BaseURL, v.ExternalID)
+		v.L402InfoURI = fmt.Sprintf("%s/%s", c.cfg.L402InfoURI, v.ExternalID)
+	}
+
+	gCtx.JSON(http.StatusOK, gin.H{"videos": library})
 }
 
-func (c *Controller) DeleteVideo(gCtx *gin.Context) {
-	externalID := gCtx.Param("id")
+// RestoreCredentials returns every paid and unexpired credential bound to the
+// public key that signed the request, so buyers that lost them do not need to
+// pay again.
+func (c *Controller) RestoreCredentials(gCtx *gin.Context) {
+	ctx := gCtx.Request.Context()
 
-	_, err := c.validateVideoOwnership(gCtx, externalID)
+	var req RecoverCredentialsRequest
+	if err := gCtx.ShouldBindJSON(&req); err != nil {
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := c.authenticator.ValidateActionSignature(req.PubKey, req.Signature,
+		req.Domain, req.Timestamp, RestoreCredentialsAction)
 	if err != nil {
-		c.logger.Error("Failed to validate video ownership", "error", err)
-		gCtx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		c.logger.Debug("invalid signature", "error", err)
+		gCtx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid signature"})
 		return
 	}
 
-	err = c.videos.DeleteVideo(gCtx, externalID)
+	credentials, err := c.videos.RestoreCredentials(ctx, req.PubKey)
 	if err != nil {
-		c.logger.Error("Failed to delete video", "error", err)
-		gCtx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete video"})
+		c.logger.Error("failed to restore credentials", "error", err)
+		gCtx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "failed to restore credentials"},
+		)
 		return
 	}
 
-	gCtx.JSON(http.StatusOK, gin.H{"message": "Video deleted successfully"})
+	gCtx.JSON(http.StatusOK, gin.H{"credentials": credentials})
 }
 
-func (c *Controller) validateVideoOwnership(gCtx *gin.Context, externalID string) (*Video, error) {
+// randomPubKeyValidator builds a challenge request with a random public key
+// for anonymous requests. The pricing option, the promo code and the amount
+// are read from the query string.
+func randomPubKeyValidator(gCtx *gin.Context) (*ChallengeRequest, error) {
+	randomPubKey, err := generateRandomPubKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate random public key: %w", err)
+	}
+
+	var amount int64
+	if value := gCtx.Query("amount"); value != "" {
+		amount, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || amount < 0 {
+			return nil, fmt.Errorf("invalid amount: %s", value)
+		}
+	}
+
+	return &ChallengeRequest{
+		PubKey:        randomPubKey,
+		Option:        gCtx.Query("option"),
+		PromoCode:     gCtx.Query("promo_code"),
+		AmountInCents: amount,
+	}, nil
+}
+
+// handleSubscribe returns a new L402 challenge for a monthly subscription to
+// all the videos of the creator of the requested video.
+func (c *Controller) handleSubscribe(gCtx *gin.Context, validator ValidatorFunc) {
+	ctx := gCtx.Request.Context()
+	externalID, err := extractExternalVideoID(gCtx)
+	if err != nil {
+		c.logger.Debug("invalid video ID", "error", err)
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": "invalid video ID"})
+		return
+	}
+
+	challengeReq, err := validator(gCtx)
+	if err != nil {
+		c.logger.Error("Invalid request", "error", err)
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	challenge, err := c.videos.CreateSubscriptionChallenge(ctx,
+		challengeReq.PubKey, externalID)
+	switch {
+	case errors.Is(err, ErrSubscriptionsDisabled):
+		gCtx.JSON(
+			http.StatusNotFound,
+			gin.H{"error": "Creator does not offer subscriptions"},
+		)
+		return
+
+	case err != nil:
+		c.logger.Error("failed to create subscription challenge",
+			"external_id", externalID,
+			"error", err,
+		)
+		gCtx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "failed to create L402 challenge"},
+		)
+		return
+	}
+
+	c.writeChallenge(gCtx, challenge)
+}
+
+func (c *Controller) Subscribe(gCtx *gin.Context) {
+	c.handleSubscribe(gCtx, c.signedRequestValidator)
+}
+
+func (c *Controller) SubscribeGET(gCtx *gin.Context) {
+	c.handleSubscribe(gCtx, randomPubKeyValidator)
+}
+
+func generateRandomPubKey() (string, error) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// GetVideoInfo returns the L402 stream information for a given video
+func (c *Controller) GetVideoInfo(gCtx *gin.Context) {
+	externalID, err := extractExternalVideoID(gCtx)
+	if err != nil {
+		c.logger.Debug("invalid video ID", "error", err)
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": "invalid video ID"})
+		return
+	}
+
+	video, err := c.store.GetVideoByExternalID(gCtx, externalID)
+	if err != nil {
+		c.logger.Error("Failed to fetch video info", "error", err)
+		gCtx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "Failed to fetch video info"},
+		)
+		return
+	}
+
+	if video == nil {
+		gCtx.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
+
+	subscriptionPrice, err := c.store.GetUserSubscriptionPrice(gCtx,
+		video.UserID)
+	if err != nil {
+		c.logger.Error("Failed to fetch subscription price", "error", err)
+		gCtx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "Failed to fetch video info"},
+		)
+		return
+	}
+
+	options, err := c.videos.PricingOptions(gCtx, video)
+	if err != nil {
+		c.logger.Error("Failed to fetch pricing options", "error", err)
+		gCtx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "Failed to fetch video info"},
+		)
+		return
+	}
+
+	var pricing []gin.H
+	for _, option := range options {
+		entry := gin.H{
+			"type":     option.Type,
+			"option":   option.Key,
+			"amount":   option.PriceInCents,
+			"currency": "USD",
+		}
+		if option.Type == PricingTypeRental {
+			entry["duration_in_hours"] = int64(option.Duration / time.Hour)
+		}
+		if option.PayWhatYouWant {
+			entry["pay_what_you_want"] = true
+			entry["min_amount"] = option.MinPriceInCents
+			entry["suggested_amount"] = option.PriceInCents
+		}
+
+		pricing = append(pricing, entry)
+	}
+	if subscriptionPrice > 0 {
+		pricing = append(pricing, gin.H{
+			"type":     "subscription",
+			"amount":   subscriptionPrice,
+			"currency": "USD",
+			"period":   "monthly",
+			"endpoint": fmt.Sprintf("%s/%s", c.cfg.L402SubscriptionURL,
+				video.ExternalID),
+		})
+	}
+
+	info := gin.H{
+		"version":      "1.0",
+		"name":         video.Title,
+		"description":  video.Description,
+		"cover_url":    video.CoverURL,
+		"content_type": "video",
+		"pricing":      pricing,
+		"access": gin.H{
+			"endpoint": fmt.Sprintf("%s/%s", c.cfg.L402BaseURL, video.ExternalID),
+			"method":   "POST",
+			"authentication": gin.H{
+				"protocol": "L402",
+				"header":   "Authorization",
+				"format":   "L402 {credentials}:{proof_of_payment}",
+			},
+		},
+	}
+
+	gCtx.JSON(http.StatusOK, info)
+}
+
+// Add this new method to the Controller
+func (c *Controller) HandleMethodNotAllowed(gCtx *gin.Context) {
+	gCtx.JSON(http.StatusMethodNotAllowed, gin.H{"error": "Method Not Allowed"})
+}
+
+func (c *Controller) handleVideoPage(gCtx *gin.Context) {
+	videoID := gCtx.Param("id")
+
+	// Fetch video details from the database
+	video, err := c.store.GetVideoByExternalID(gCtx, videoID)
+	if err != nil {
+		gCtx.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
+
+	// Prepare data for the template. Videos without cover variants show
+	// the original cover everywhere.
+	heroURL, ogImageURL := video.CoverURL, video.CoverURL
+	if video.CoverVariants != nil {
+		heroURL = video.CoverVariants.HeroURL
+		ogImageURL = video.CoverVariants.OGURL
+	}
+
+	data := gin.H{
+		"Title":        video.Title,
+		"Description":  video.Description,
+		"VideoURL":     fmt.Sprintf("https://blockbuster.fewsats.com/video/stream/%s", video.ExternalID),
+		"CoverURL":     heroURL,
+		"OGImageURL":   ogImageURL,
+		"PriceInCents": float64(video.PriceInCents) / 100,
+	}
+
+	// Render the video page template
+	gCtx.HTML(http.StatusOK, "video.html", data)
+}
+
+type UpdateVideoInfoRequest struct {
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	PriceInCents int64  `json:"price_in_cents"`
+
+	PayWhatYouWant        *bool `json:"pay_what_you_want"`
+	SuggestedPriceInCents int64 `json:"suggested_price_in_cents" binding:"min=0"`
+}
+
+func (c *Controller) UpdateVideoInfo(gCtx *gin.Context) {
+	externalID := gCtx.Param("id")
+
+	_, err := c.validateVideoOwnership(gCtx, externalID)
+	if err != nil {
+		c.logger.Error("Failed to validate video ownership", "error", err)
+		gCtx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req UpdateVideoInfoRequest
+	if err := gCtx.ShouldBindJSON(&req); err != nil {
+		c.logger.Error("Invalid request", "error", err)
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updatedVideo, err := c.videos.UpdateVideoInfo(gCtx, externalID, req)
+	if err != nil {
+		c.logger.Error("Failed to update video info", "error", err)
+		gCtx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update video info"})
+		return
+	}
+
+	gCtx.JSON(http.StatusOK, updatedVideo)
+}
+
+func (c *Controller) DeleteVideo(gCtx *gin.Context) {
+	externalID := gCtx.Param("id")
+
+	video, err := c.validateVideoOwnership(gCtx, externalID)
+	if err != nil {
+		c.logger.Error("Failed to validate video ownership", "error", err)
+		gCtx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = c.videos.DeleteVideo(gCtx, video.UserID, externalID)
+	if err != nil {
+		c.logger.Error("Failed to delete video", "error", err)
+		gCtx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete video"})
+		return
+	}
+
+	gCtx.JSON(http.StatusOK, gin.H{"message": "Video deleted successfully"})
+}
+
+// RestoreVideo moves a deleted video of the user out of the trash while its
+// grace period lasts.
+func (c *Controller) RestoreVideo(gCtx *gin.Context) {
+	userID := gCtx.GetInt64("user_id")
+	if userID == 0 {
+		gCtx.JSON(http.StatusForbidden,
+			gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	video, err := c.videos.RestoreVideo(gCtx, userID, gCtx.Param("id"))
+	switch {
+	case errors.Is(err, ErrVideoNotFound):
+		gCtx.JSON(http.StatusNotFound, gin.H{"error": "Video not found " +
+			"in the trash"})
+		return
+
+	case err != nil:
+		c.logger.Error("Failed to restore video", "error", err)
+		gCtx.JSON(http.StatusInternalServerError,
+			gin.H{"error": "Failed to restore video"})
+		return
+	}
+
+	video.L402URL = fmt.Sprintf("%s/%s", c.cfg.L402BaseURL, video.ExternalID)
+	video.L402InfoURI = fmt.Sprintf("%s/%s", c.cfg.L402InfoURI,
+		video.ExternalID)
+
+	gCtx.JSON(http.StatusOK, video)
+}
+
+// maxWebhookBodySize is the maximum size of the body of a Stream webhook.
+const maxWebhookBodySize = 1 << 20
+
+// CloudflareWebhook receives the Stream notifications sent when a video
+// finishes processing, either ready to stream or failed.
+func (c *Controller) CloudflareWebhook(gCtx *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(gCtx.Writer,
+		gCtx.Request.Body, maxWebhookBodySize))
+	if err != nil {
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid body"})
+		return
+	}
+
+	video, err := c.videos.HandleStreamWebhook(gCtx.Request.Context(),
+		gCtx.Request.Header, body)
+	switch {
+	case errors.Is(err, ErrInvalidWebhookSignature):
+		c.logger.Warn("Rejected Stream webhook", "error", err)
+		gCtx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		return
+
+	// Stream retries the webhooks that are not acknowledged, which would
+	// never succeed for the videos created outside of the service.
+	case errors.Is(err, ErrVideoNotFound):
+		c.logger.Warn("Ignoring Stream webhook of unknown video",
+			"error", err)
+		gCtx.JSON(http.StatusOK, gin.H{"message": "Video not found"})
+		return
+
+	case err != nil:
+		c.logger.Error("Failed to handle Stream webhook", "error", err)
+		gCtx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to handle webhook"})
+		return
+	}
+
+	c.logger.Info("Stream webhook handled",
+		"externalID", video.ExternalID,
+		"readyToStream", video.ReadyToStream)
+
+	gCtx.JSON(http.StatusOK, gin.H{"message": "Webhook handled"})
+}
+
+func (c *Controller) validateVideoOwnership(gCtx *gin.Context, externalID string) (*Video, error) {
 	userID := gCtx.GetInt64("user_id")
 	if userID == 0 {
 		return nil, errors.New("user not authenticated")
@@ -471,3 +1123,572 @@ func (c *Controller) validateVideoOwnership(gCtx *gin.Context, externalID string
 
 	return video, nil
 }
+
+// SetVideoRentalRequest is the request to create or update a rental option.
+type SetVideoRentalRequest struct {
+	DurationInHours int64 `json:"duration_in_hours" binding:"required"`
+	PriceInCents    int64 `json:"price_in_cents" binding:"min=0"`
+}
+
+func (r *SetVideoRentalRequest) Validate() error {
+	maxHours := int64(ExpirationTime / time.Hour)
+	if r.DurationInHours <= 0 || r.DurationInHours > maxHours {
+		return fmt.Errorf("rental duration must be between 1 and %d hours",
+			maxHours)
+	}
+
+	return nil
+}
+
+func (c *Controller) SetVideoRental(gCtx *gin.Context) {
+	externalID := gCtx.Param("id")
+
+	_, err := c.validateVideoOwnership(gCtx, externalID)
+	if err != nil {
+		c.logger.Error("Failed to validate video ownership", "error", err)
+		gCtx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req SetVideoRentalRequest
+	if err := gCtx.ShouldBindJSON(&req); err != nil {
+		c.logger.Error("Invalid request", "error", err)
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		c.logger.Error("Invalid request", "error", err)
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rental, err := c.videos.SetVideoRental(gCtx, externalID, req)
+	if err != nil {
+		c.logger.Error("Failed to set video rental", "error", err)
+		gCtx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set video rental"})
+		return
+	}
+
+	gCtx.JSON(http.StatusOK, rental)
+}
+
+func (c *Controller) DeleteVideoRental(gCtx *gin.Context) {
+	externalID := gCtx.Param("id")
+
+	_, err := c.validateVideoOwnership(gCtx, externalID)
+	if err != nil {
+		c.logger.Error("Failed to validate video ownership", "error", err)
+		gCtx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	hours, err := strconv.ParseInt(gCtx.Param("hours"), 10, 64)
+	if err != nil {
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": "invalid rental duration"})
+		return
+	}
+
+	err = c.store.DeleteVideoRental(gCtx, externalID, hours)
+	if err != nil {
+		c.logger.Error("Failed to delete video rental", "error", err)
+		gCtx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete video rental"})
+		return
+	}
+
+	gCtx.JSON(http.StatusOK, gin.H{"message": "Video rental deleted successfully"})
+}
+
+type CreateBundleRequest struct {
+	Title        string                `form:"title" binding:"required"`
+	Description  string                `form:"description"`
+	PriceInCents int64                 `form:"price_in_cents" binding:"required,min=0"`
+	VideoIDs     []string              `form:"video_ids" binding:"required"`
+	CoverImage   *multipart.FileHeader `form:"cover_image"`
+}
+
+func (r *CreateBundleRequest) Validate() error {
+	if r.CoverImage == nil {
+		return fmt.Errorf("cover image is required")
+	}
+	return nil
+}
+
+type UpdateBundleRequest struct {
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	PriceInCents int64  `json:"price_in_cents"`
+
+	// VideoIDs replaces the videos of the bundle in the given order when
+	// present.
+	VideoIDs []string `json:"video_ids"`
+}
+
+func (c *Controller) CreateBundle(gCtx *gin.Context) {
+	userID := gCtx.GetInt64("user_id")
+	if userID == 0 {
+		gCtx.JSON(
+			http.StatusUnauthorized,
+			gin.H{"error": "User not authenticated"},
+		)
+		return
+	}
+
+	var req CreateBundleRequest
+	if err := gCtx.ShouldBind(&req); err != nil {
+		c.logger.Error("Invalid request", "error", err)
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		c.logger.Error("Invalid request", "error", err)
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bundle, err := c.videos.CreateBundle(gCtx, userID, req)
+	switch {
+	case errors.Is(err, ErrInvalidBundleVideos),
+		errors.Is(err, ErrInvalidCoverImage):
+
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+
+	case err != nil:
+		c.logger.Error("Failed to create bundle", "error", err)
+		gCtx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create bundle"})
+		return
+	}
+
+	c.setBundleURLs(bundle)
+	gCtx.JSON(http.StatusOK, bundle)
+}
+
+func (c *Controller) ListUserBundles(gCtx *gin.Context) {
+	userID := gCtx.GetInt64("user_id")
+	if userID == 0 {
+		gCtx.JSON(
+			http.StatusUnauthorized,
+			gin.H{"error": "User not authenticated"},
+		)
+		return
+	}
+
+	bundles, err := c.store.ListUserBundles(gCtx, userID)
+	if err != nil {
+		c.logger.Error("Failed to list user bundles", "error", err)
+		gCtx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user bundles"})
+		return
+	}
+
+	for _, b := range bundles {
+		c.setBundleURLs(b)
+	}
+
+	gCtx.JSON(http.StatusOK, gin.H{"bundles": bundles})
+}
+
+func (c *Controller) UpdateBundle(gCtx *gin.Context) {
+	externalID := gCtx.Param("id")
+
+	bundle, err := c.validateBundleOwnership(gCtx, externalID)
+	if err != nil {
+		c.logger.Error("Failed to validate bundle ownership", "error", err)
+		gCtx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req UpdateBundleRequest
+	if err := gCtx.ShouldBindJSON(&req); err != nil {
+		c.logger.Error("Invalid request", "error", err)
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := c.videos.UpdateBundle(gCtx, bundle, req)
+	switch {
+	case errors.Is(err, ErrInvalidBundleVideos):
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+
+	case errors.Is(err, ErrBundleHasSales):
+		gCtx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+
+	case err != nil:
+		c.logger.Error("Failed to update bundle", "error", err)
+		gCtx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bundle"})
+		return
+	}
+
+	c.setBundleURLs(updated)
+	gCtx.JSON(http.StatusOK, updated)
+}
+
+func (c *Controller) DeleteBundle(gCtx *gin.Context) {
+	externalID := gCtx.Param("id")
+
+	_, err := c.validateBundleOwnership(gCtx, externalID)
+	if err != nil {
+		c.logger.Error("Failed to validate bundle ownership", "error", err)
+		gCtx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = c.videos.DeleteBundle(gCtx, externalID)
+	if err != nil {
+		c.logger.Error("Failed to delete bundle", "error", err)
+		gCtx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete bundle"})
+		return
+	}
+
+	gCtx.JSON(http.StatusOK, gin.H{"message": "Bundle deleted successfully"})
+}
+
+func (c *Controller) validateBundleOwnership(gCtx *gin.Context,
+	externalID string) (*Bundle, error) {
+
+	userID := gCtx.GetInt64("user_id")
+	if userID == 0 {
+		return nil, errors.New("user not authenticated")
+	}
+
+	bundle, err := c.store.GetBundleByExternalID(gCtx, externalID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bundle: %w", err)
+	}
+
+	if bundle.UserID != userID {
+		return nil, errors.New("user does not own this bundle")
+	}
+
+	return bundle, nil
+}
+
+// setBundleURLs fills in the L402 URLs of a bundle.
+func (c *Controller) setBundleURLs(bundle *Bundle) {
+	bundle.L402URL = fmt.Sprintf("%s/%s", c.cfg.L402BundleBaseURL,
+		bundle.ExternalID)
+	bundle.L402InfoURI = fmt.Sprintf("%s/%s", c.cfg.L402BundleInfoURI,
+		bundle.ExternalID)
+}
+
+// GetBundleInfo returns the L402 information for a given bundle.
+func (c *Controller) GetBundleInfo(gCtx *gin.Context) {
+	externalID, err := extractExternalVideoID(gCtx)
+	if err != nil {
+		c.logger.Debug("invalid bundle ID", "error", err)
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": "invalid bundle ID"})
+		return
+	}
+
+	bundle, err := c.store.GetBundleByExternalID(gCtx, externalID)
+	switch {
+	case errors.Is(err, ErrBundleNotFound):
+		gCtx.JSON(http.StatusNotFound, gin.H{"error": "Bundle not found"})
+		return
+
+	case err != nil:
+		c.logger.Error("Failed to fetch bundle info", "error", err)
+		gCtx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "Failed to fetch bundle info"},
+		)
+		return
+	}
+
+	videos, err := c.videos.BundleVideos(gCtx, bundle)
+	if err != nil {
+		c.logger.Error("Failed to fetch bundle videos", "error", err)
+		gCtx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "Failed to fetch bundle info"},
+		)
+		return
+	}
+
+	items := make([]gin.H, 0, len(videos))
+	for _, v := range videos {
+		items = append(items, gin.H{
+			"external_id": v.ExternalID,
+			"title":       v.Title,
+			"info_uri": fmt.Sprintf("%s/%s", c.cfg.L402InfoURI,
+				v.ExternalID),
+		})
+	}
+
+	info := gin.H{
+		"version":      "1.0",
+		"name":         bundle.Title,
+		"description":  bundle.Description,
+		"cover_url":    bundle.CoverURL,
+		"content_type": "bundle",
+		"videos":       items,
+		"pricing": []gin.H{
+			{
+				"type":     PricingTypePurchase,
+				"amount":   bundle.PriceInCents,
+				"currency": "USD",
+			},
+		},
+		"access": gin.H{
+			"endpoint": fmt.Sprintf("%s/%s", c.cfg.L402BundleBaseURL,
+				bundle.ExternalID),
+			"method": "POST",
+			"authentication": gin.H{
+				"protocol": "L402",
+				"header":   "Authorization",
+				"format":   "L402 {credentials}:{proof_of_payment}",
+			},
+		},
+	}
+
+	gCtx.JSON(http.StatusOK, info)
+}
+
+func (c *Controller) StreamBundle(gCtx *gin.Context) {
+	c.handleStreamBundle(gCtx, c.signedRequestValidator)
+}
+
+func (c *Controller) StreamBundleGET(gCtx *gin.Context) {
+	c.handleStreamBundle(gCtx, randomPubKeyValidator)
+}
+
+// handleStreamBundle returns the videos of a bundle when valid L402
+// credentials for it are provided or a new challenge to buy it otherwise. The
+// same credentials are then accepted by the stream endpoint of every video in
+// the bundle.
+func (c *Controller) handleStreamBundle(gCtx *gin.Context,
+	validator ValidatorFunc) {
+
+	ctx := gCtx.Request.Context()
+	externalID, err := extractExternalVideoID(gCtx)
+	if err != nil {
+		c.logger.Debug("invalid bundle ID", "error", err)
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": "invalid bundle ID"})
+		return
+	}
+
+	bundle, err := c.store.GetBundleByExternalID(ctx, externalID)
+	switch {
+	case errors.Is(err, ErrBundleNotFound):
+		gCtx.JSON(http.StatusNotFound, gin.H{"error": "Bundle not found"})
+		return
+
+	case err != nil:
+		c.logger.Error("Failed to fetch bundle", "error", err)
+		gCtx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "Failed to fetch bundle"},
+		)
+		return
+	}
+
+	autHeader := gCtx.GetHeader("Authorization")
+	paymentHash, err := c.authenticator.ValidateL402Credentials(ctx, autHeader,
+		c.videos.BundleAccessCheckers(bundle))
+	switch {
+	case err == nil:
+		err = c.videos.RecordBundlePurchase(ctx, paymentHash)
+		if err != nil {
+			c.logger.Error(
+				"failed to record bundle purchase",
+				"bundle_id", externalID,
+				"error", err,
+			)
+		}
+
+		videos, err := c.videos.BundleVideos(ctx, bundle)
+		if err != nil {
+			c.logger.Error("Failed to fetch bundle videos", "error", err)
+			gCtx.JSON(
+				http.StatusInternalServerError,
+				gin.H{"error": "Failed to fetch bundle videos"},
+			)
+			return
+		}
+
+		items := make([]gin.H, 0, len(videos))
+		for _, v := range videos {
+			items = append(items, gin.H{
+				"external_id": v.ExternalID,
+				"title":       v.Title,
+				"endpoint": fmt.Sprintf("%s/%s", c.cfg.L402BaseURL,
+					v.ExternalID),
+			})
+		}
+
+		gCtx.JSON(http.StatusOK, gin.H{"videos": items})
+		return
+
+	case !errors.Is(err, l402.ErrMissingAuthorizationHeader) &&
+		!errors.Is(err, l402.ErrInvalidPreimage) &&
+		!errors.Is(err, l402.ErrInvalidCaveat) &&
+		!errors.Is(err, l402.ErrRevokedCredentials):
+
+		c.logger.Debug(
+			"unable to extract L402 credentials",
+			"header", autHeader,
+			"error", err,
+		)
+		gCtx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "unable to extract L402 credentials"},
+		)
+		return
+	}
+
+	challengeReq, err := validator(gCtx)
+	if err != nil {
+		c.logger.Error("Invalid request", "error", err)
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	challenge, err := c.videos.CreateBundleChallenge(ctx, bundle,
+		challengeReq.PubKey)
+	if err != nil {
+		c.logger.Error("failed to create bundle challenge",
+			"bundle_id", externalID,
+			"error", err,
+		)
+		gCtx.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "failed to create L402 challenge"},
+		)
+		return
+	}
+
+	c.writeChallenge(gCtx, challenge)
+}
+
+// CreatePromoCodeRequest is the request to create a new promo code.
+type CreatePromoCodeRequest struct {
+	Code          string `json:"code" binding:"required"`
+	DiscountType  string `json:"discount_type" binding:"required"`
+	DiscountValue int64  `json:"discount_value" binding:"required"`
+
+	// ExpiresAt is optional, codes without it do not expire.
+	ExpiresAt *time.Time `json:"expires_at"`
+
+	// MaxRedemptions is optional, zero means there is no limit.
+	MaxRedemptions int64 `json:"max_redemptions" binding:"min=0"`
+
+	// VideoIDs scopes the code to some videos, all the videos of the
+	// creator when empty.
+	VideoIDs []string `json:"video_ids"`
+}
+
+func (r *CreatePromoCodeRequest) Validate() error {
+	if NormalizePromoCode(r.Code) == "" {
+		return fmt.Errorf("promo code is required")
+	}
+
+	switch r.DiscountType {
+	case DiscountTypePercentage:
+		if r.DiscountValue <= 0 || r.DiscountValue > 100 {
+			return fmt.Errorf("percentage discount must be between 1 " +
+				"and 100")
+		}
+
+	case DiscountTypeFixed:
+		if r.DiscountValue <= 0 {
+			return fmt.Errorf("fixed discount must be positive")
+		}
+
+	default:
+		return fmt.Errorf("discount type must be %s or %s",
+			DiscountTypePercentage, DiscountTypeFixed)
+	}
+
+	return nil
+}
+
+func (c *Controller) CreatePromoCode(gCtx *gin.Context) {
+	userID := gCtx.GetInt64("user_id")
+	if userID == 0 {
+		gCtx.JSON(
+			http.StatusUnauthorized,
+			gin.H{"error": "User not authenticated"},
+		)
+		return
+	}
+
+	var req CreatePromoCodeRequest
+	if err := gCtx.ShouldBindJSON(&req); err != nil {
+		c.logger.Error("Invalid request", "error", err)
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		c.logger.Error("Invalid request", "error", err)
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promoCode, err := c.videos.CreatePromoCode(gCtx, userID, req)
+	switch {
+	case errors.Is(err, ErrPromoCodeExists):
+		gCtx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+
+	case errors.Is(err, ErrInvalidPromoCodeVideos):
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+
+	case err != nil:
+		c.logger.Error("Failed to create promo code", "error", err)
+		gCtx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create promo code"})
+		return
+	}
+
+	gCtx.JSON(http.StatusOK, promoCode)
+}
+
+func (c *Controller) ListUserPromoCodes(gCtx *gin.Context) {
+	userID := gCtx.GetInt64("user_id")
+	if userID == 0 {
+		gCtx.JSON(
+			http.StatusUnauthorized,
+			gin.H{"error": "User not authenticated"},
+		)
+		return
+	}
+
+	promoCodes, err := c.store.ListUserPromoCodes(gCtx, userID)
+	if err != nil {
+		c.logger.Error("Failed to list user promo codes", "error", err)
+		gCtx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user promo codes"})
+		return
+	}
+
+	gCtx.JSON(http.StatusOK, gin.H{"promo_codes": promoCodes})
+}
+
+func (c *Controller) DeletePromoCode(gCtx *gin.Context) {
+	userID := gCtx.GetInt64("user_id")
+	if userID == 0 {
+		gCtx.JSON(
+			http.StatusUnauthorized,
+			gin.H{"error": "User not authenticated"},
+		)
+		return
+	}
+
+	code := NormalizePromoCode(gCtx.Param("code"))
+	err := c.store.DeletePromoCode(gCtx, userID, code)
+	switch {
+	case errors.Is(err, ErrPromoCodeNotFound):
+		gCtx.JSON(http.StatusNotFound, gin.H{"error": "Promo code not found"})
+		return
+
+	case err != nil:
+		c.logger.Error("Failed to delete promo code", "error", err)
+		gCtx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete promo code"})
+		return
+	}
+
+	gCtx.JSON(http.StatusOK, gin.H{"message": "Promo code deleted successfully"})
+}
@@ -5,15 +5,17 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"io"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/cloudflare/cloudflare-go"
 	"github.com/fewsats/blockbuster/l402"
 	"github.com/fewsats/blockbuster/lightning"
+	"github.com/fewsats/blockbuster/orders"
 	"github.com/fewsats/blockbuster/utils"
 	"github.com/fewsats/blockbuster/video"
 	"github.com/gin-gonic/gin"
@@ -36,6 +38,11 @@ func (m *MockStore) GetOrCreateUserByEmail(ctx context.Context, email string) (i
 	return args.Get(0).(int64), args.Error(1)
 }
 
+func (m *MockStore) GetUserSubscriptionPrice(ctx context.Context, userID int64) (int64, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockStore) CreateVideo(ctx context.Context, params video.CreateVideoParams) (*video.Video, error) {
 	args := m.Called(ctx, params)
 	return args.Get(0).(*video.Video), args.Error(1)
@@ -51,11 +58,6 @@ func (m *MockStore) GetVideoByExternalID(ctx context.Context, externalID string)
 	return args.Get(0).(*video.Video), args.Error(1)
 }
 
-func (m *MockStore) IncrementVideoViews(ctx context.Context, externalID string) error {
-	args := m.Called(ctx, externalID)
-	return args.Error(0)
-}
-
 func (m *MockStore) UpdateCloudflareInfo(ctx context.Context,
 	externalID string, params *video.CloudflareVideoInfo) (*video.Video, error) {
 
@@ -70,10 +72,136 @@ func (m *MockStore) UpdateVideoInfo(ctx context.Context,
 	return args.Get(0).(*video.Video), args.Error(1)
 }
 
-func (m *MockStore) DeleteVideo(ctx context.Context, externalID string) error {
+func (m *MockStore) DeleteVideo(ctx context.Context, externalID string,
+	purgeAt time.Time) error {
+
+	args := m.Called(ctx, externalID, purgeAt)
+	return args.Error(0)
+}
+
+func (m *MockStore) GetTrashedVideo(ctx context.Context,
+	externalID string) (*video.Video, error) {
+
+	args := m.Called(ctx, externalID)
+	return args.Get(0).(*video.Video), args.Error(1)
+}
+
+func (m *MockStore) ListUserTrashedVideos(ctx context.Context,
+	userID int64) ([]string, error) {
+
+	args := m.Called(ctx, userID)
+	externalIDs, _ := args.Get(0).([]string)
+	return externalIDs, args.Error(1)
+}
+
+func (m *MockStore) RestoreVideo(ctx context.Context, userID int64,
+	externalID string) error {
+
+	args := m.Called(ctx, userID, externalID)
+	return args.Error(0)
+}
+
+func (m *MockStore) MarkVideoPurged(ctx context.Context,
+	externalID string) error {
+
+	args := m.Called(ctx, externalID)
+	return args.Error(0)
+}
+
+func (m *MockStore) UpsertVideoRental(ctx context.Context, externalID string,
+	durationInHours, priceInCents int64) (*video.Rental, error) {
+
+	args := m.Called(ctx, externalID, durationInHours, priceInCents)
+	return args.Get(0).(*video.Rental), args.Error(1)
+}
+
+func (m *MockStore) ListVideoRentals(ctx context.Context, externalID string) ([]*video.Rental, error) {
+	args := m.Called(ctx, externalID)
+	return args.Get(0).([]*video.Rental), args.Error(1)
+}
+
+func (m *MockStore) DeleteVideoRental(ctx context.Context, externalID string,
+	durationInHours int64) error {
+
+	args := m.Called(ctx, externalID, durationInHours)
+	return args.Error(0)
+}
+
+func (m *MockStore) CreateBundle(ctx context.Context,
+	params video.CreateBundleParams) (*video.Bundle, error) {
+
+	args := m.Called(ctx, params)
+	return args.Get(0).(*video.Bundle), args.Error(1)
+}
+
+func (m *MockStore) GetBundleByExternalID(ctx context.Context,
+	externalID string) (*video.Bundle, error) {
+
+	args := m.Called(ctx, externalID)
+	bundle, _ := args.Get(0).(*video.Bundle)
+	return bundle, args.Error(1)
+}
+
+func (m *MockStore) GetPurchasedBundle(ctx context.Context,
+	externalID string) (*video.Bundle, error) {
+
+	args := m.Called(ctx, externalID)
+	bundle, _ := args.Get(0).(*video.Bundle)
+	return bundle, args.Error(1)
+}
+
+func (m *MockStore) ListUserBundles(ctx context.Context, userID int64) ([]*video.Bundle, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*video.Bundle), args.Error(1)
+}
+
+func (m *MockStore) UpdateBundle(ctx context.Context, externalID string,
+	params *video.UpdateBundleParams) (*video.Bundle, error) {
+
+	args := m.Called(ctx, externalID, params)
+	return args.Get(0).(*video.Bundle), args.Error(1)
+}
+
+func (m *MockStore) DeleteBundle(ctx context.Context, externalID string) error {
 	args := m.Called(ctx, externalID)
 	return args.Error(0)
-}	
+}
+
+func (m *MockStore) IsBundleVideo(ctx context.Context, bundleExternalID,
+	externalID string) (bool, error) {
+
+	args := m.Called(ctx, bundleExternalID, externalID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockStore) CreatePromoCode(ctx context.Context,
+	params video.CreatePromoCodeParams) (*video.PromoCode, error) {
+
+	args := m.Called(ctx, params)
+	return args.Get(0).(*video.PromoCode), args.Error(1)
+}
+
+func (m *MockStore) GetPromoCode(ctx context.Context, userID int64,
+	code string) (*video.PromoCode, error) {
+
+	args := m.Called(ctx, userID, code)
+	promoCode, _ := args.Get(0).(*video.PromoCode)
+	return promoCode, args.Error(1)
+}
+
+func (m *MockStore) ListUserPromoCodes(ctx context.Context,
+	userID int64) ([]*video.PromoCode, error) {
+
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*video.PromoCode), args.Error(1)
+}
+
+func (m *MockStore) DeletePromoCode(ctx context.Context, userID int64,
+	code string) error {
+
+	args := m.Called(ctx, userID, code)
+	return args.Error(0)
+}
 
 type MockAuthenticator struct {
 	mock.Mock
@@ -84,8 +212,17 @@ func (m *MockAuthenticator) ValidateSignature(pubKey, signature, domain string,
 	return args.Error(0)
 }
 
-func (m *MockAuthenticator) ValidateL402Credentials(ctx context.Context, authHeader string) (string, error) {
-	args := m.Called(ctx, authHeader)
+func (m *MockAuthenticator) ValidateActionSignature(pubKey, signature,
+	domain string, timestamp int64, action string, params ...string) error {
+
+	args := m.Called(pubKey, signature, domain, timestamp, action, params)
+	return args.Error(0)
+}
+
+func (m *MockAuthenticator) ValidateL402Credentials(ctx context.Context, authHeader string,
+	checkers map[string]l402.CaveatChecker) (string, error) {
+
+	args := m.Called(ctx, authHeader, checkers)
 	return args.Get(0).(string), args.Error(1)
 }
 
@@ -95,13 +232,27 @@ func (m *MockAuthenticator) NewChallenge(ctx context.Context, domain, pubKeyHex
 	return args.Get(0).(*l402.Challenge), args.Error(1)
 }
 
+func (m *MockAuthenticator) PaidCredentials(ctx context.Context, paymentHash,
+	pubKeyHex string) (string, string, error) {
+
+	args := m.Called(ctx, paymentHash, pubKeyHex)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+func (m *MockAuthenticator) RestoreCredentials(ctx context.Context,
+	pubKeyHex string) ([]*l402.StoredCredentials, error) {
+
+	args := m.Called(ctx, pubKeyHex)
+	return args.Get(0).([]*l402.StoredCredentials), args.Error(1)
+}
+
 type MockManager struct {
 	mock.Mock
 }
 
-func (m *MockManager) IsVideoReady(ctx context.Context, externalID string) error {
+func (m *MockManager) IsVideoReady(ctx context.Context, externalID string) (*video.Video, error) {
 	args := m.Called(ctx, externalID)
-	return args.Error(0)
+	return args.Get(0).(*video.Video), args.Error(1)
 }
 
 func (m *MockManager) RecordPurchase(ctx context.Context, externalID,
@@ -111,43 +262,83 @@ func (m *MockManager) RecordPurchase(ctx context.Context, externalID,
 	return args.Error(0)
 }
 
-func (m *MockManager) GenerateStreamURL(ctx context.Context, externalID string) (string, string, error) {
-	args := m.Called(ctx, externalID)
-	return args.Get(0).(string), args.Get(1).(string), args.Error(2)
+func (m *MockManager) GenerateStreamURL(ctx context.Context,
+	externalID string, access *video.Access,
+	clientIP string) (*video.StreamURLs, error) {
+
+	args := m.Called(ctx, externalID, access, clientIP)
+	return args.Get(0).(*video.StreamURLs), args.Error(1)
 }
 
 type MockOrdersMgr struct {
 	mock.Mock
 }
 
-func (m *MockOrdersMgr) CreateOffer(ctx context.Context, userID int64, priceInCents uint64, externalID, paymentHash string) error {
-	args := m.Called(ctx, userID, priceInCents, externalID, paymentHash)
+func (m *MockOrdersMgr) CreateOffer(ctx context.Context,
+	params *orders.CreateOfferParams) error {
+
+	args := m.Called(ctx, params)
 	return args.Error(0)
 }
 
-func (m *MockOrdersMgr) RecordPurchase(ctx context.Context, payHash, serviceType string) error {
+func (m *MockOrdersMgr) ListRecipientOffers(ctx context.Context, externalID,
+	recipientPubKey string, limit int64) ([]*orders.Offer, error) {
+
+	args := m.Called(ctx, externalID, recipientPubKey, limit)
+	return args.Get(0).([]*orders.Offer), args.Error(1)
+}
+
+func (m *MockOrdersMgr) ListRecipientPurchases(ctx context.Context,
+	recipientPubKey string) ([]*orders.Purchase, error) {
+
+	args := m.Called(ctx, recipientPubKey)
+	return args.Get(0).([]*orders.Purchase), args.Error(1)
+}
+
+func (m *MockOrdersMgr) RecordPurchase(ctx context.Context, payHash,
+	serviceType string) error {
+
 	args := m.Called(ctx, payHash, serviceType)
 	return args.Error(0)
 }
 
+func (m *MockOrdersMgr) RecordPurchaseAndView(ctx context.Context, payHash,
+	serviceType, externalID string) error {
+
+	args := m.Called(ctx, payHash, serviceType, externalID)
+	return args.Error(0)
+}
+
 type MockCloudflareService struct {
 	mock.Mock
 }
 
-func (m *MockCloudflareService) GenerateStreamURL(ctx context.Context, externalID string) (string, string, error) {
+func (m *MockCloudflareService) GenerateStreamURL(ctx context.Context,
+	externalID string, opts *video.StreamURLOptions) (*video.StreamURLs,
+	error) {
+
+	args := m.Called(ctx, externalID, opts)
+	return args.Get(0).(*video.StreamURLs), args.Error(1)
+}
+
+func (m *MockCloudflareService) DeleteVideo(ctx context.Context,
+	externalID string) error {
+
 	args := m.Called(ctx, externalID)
-	return args.String(0), args.String(1), args.Error(2)
+	return args.Error(0)
 }
 
-func (m *MockCloudflareService) GenerateVideoUploadURL(ctx context.Context) (string, string, error) {
-	args := m.Called(ctx)
-	return args.String(0), args.String(1), args.Error(2)
+func (m *MockCloudflareService) GenerateVideoUploadURL(ctx context.Context,
+	userID int64, opts *video.UploadOptions) (*video.Upload, error) {
+
+	args := m.Called(ctx, userID, opts)
+	return args.Get(0).(*video.Upload), args.Error(1)
 }
 
-func (m *MockCloudflareService) UploadPublicFile(ctx context.Context, key, prefix string,
-	reader io.ReadSeeker) (string, error) {
+func (m *MockCloudflareService) ImportVideo(ctx context.Context,
+	userID int64, sourceURL, name string) (string, error) {
 
-	args := m.Called(ctx, key, prefix, reader)
+	args := m.Called(ctx, userID, sourceURL, name)
 	return args.String(0), args.Error(1)
 }
 
@@ -156,9 +347,27 @@ func (m *MockCloudflareService) GetStreamVideoInfo(ctx context.Context, external
 	return args.Get(0).(*cloudflare.StreamVideo), args.Error(1)
 }
 
+func (m *MockCloudflareService) VerifyWebhookSignature(headers http.Header,
+	body []byte) error {
+
+	args := m.Called(headers.Get("Webhook-Signature"), body)
+	return args.Error(0)
+}
+
+func (m *MockCloudflareService) MaxDuration(userID int64) time.Duration {
+	args := m.Called(userID)
+	return args.Get(0).(time.Duration)
+}
+
 func TestStreamVideo(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
+	rentalExpiration := time.Unix(0, 0).UTC().Add(48 * time.Hour)
+	promoExpiration := time.Unix(0, 0).UTC()
+	giftPubKey := "384b61fb5cc7fae5cb849ebd69a66c4f8535fa95cba90346a0204c034301bb3d"
+	deletedAt := time.Unix(0, 0).UTC()
+	purgeAt := deletedAt.Add(time.Hour)
+
 	testCases := []struct {
 		name           string
 		authHeader     string
@@ -178,7 +387,7 @@ func TestStreamVideo(t *testing.T) {
 				mockCloudflare *MockCloudflareService,
 			) {
 				mockStore.On("GetVideoByExternalID", mock.Anything,
-					"externalID").Return(&video.Video{ReadyToStream: false}, nil)
+					"externalID").Return(&video.Video{ExternalID: "externalID"}, nil)
 				mockCloudflare.On(
 					"GetStreamVideoInfo", mock.Anything, "externalID",
 				).Return(&cloudflare.StreamVideo{}, errors.New("video not ready"))
@@ -186,6 +395,200 @@ func TestStreamVideo(t *testing.T) {
 			expectedStatus: http.StatusInternalServerError,
 			expectedBody:   "Video does not exist or is not ready to stream",
 		},
+		{
+			name:       "video not found",
+			authHeader: "validAuthHeader",
+			reqBody:    nil,
+			setupMocks: func(
+				mockStore *MockStore,
+				mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr,
+				mockCloudflare *MockCloudflareService,
+			) {
+				mockStore.On("GetVideoByExternalID", mock.Anything,
+					"externalID").Return((*video.Video)(nil),
+					video.ErrVideoNotFound)
+				mockStore.On("GetTrashedVideo", mock.Anything,
+					"externalID").Return((*video.Video)(nil),
+					video.ErrVideoNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "Video not found",
+		},
+		{
+			name:       "deleted video, valid credentials",
+			authHeader: "validAuthHeader",
+			reqBody:    nil,
+			setupMocks: func(mockStore *MockStore,
+				mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr,
+				mockCloudflare *MockCloudflareService,
+			) {
+				mockStore.On("GetVideoByExternalID", mock.Anything,
+					"externalID").Return((*video.Video)(nil),
+					video.ErrVideoNotFound)
+				mockStore.On("GetTrashedVideo", mock.Anything,
+					"externalID").Return(&video.Video{
+					ReadyToStream: true,
+					DeletedAt:     &deletedAt,
+					PurgeAt:       &purgeAt,
+				}, nil)
+				mockAuthenticator.On(
+					"ValidateL402Credentials", mock.Anything, "validAuthHeader",
+					mock.Anything,
+				).Return("paymentHash", nil)
+				mockOrdersMgr.On(
+					"RecordPurchaseAndView", mock.Anything, "paymentHash",
+					"videos", "externalID",
+				).Return(nil)
+				mockCloudflare.On(
+					"GenerateStreamURL", mock.Anything, "externalID",
+					mock.Anything,
+				).Return(&video.StreamURLs{
+					HLSURL: "http://hls_stream.url",
+				}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "http://hls_stream.url",
+		},
+		{
+			name:       "deleted video, payment required",
+			authHeader: "",
+			reqBody:    nil,
+			setupMocks: func(mockStore *MockStore,
+				mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr,
+				mockCloudflare *MockCloudflareService,
+			) {
+				mockStore.On("GetVideoByExternalID", mock.Anything,
+					"externalID").Return((*video.Video)(nil),
+					video.ErrVideoNotFound)
+				mockStore.On("GetTrashedVideo", mock.Anything,
+					"externalID").Return(&video.Video{
+					ReadyToStream: true,
+					DeletedAt:     &deletedAt,
+					PurgeAt:       &purgeAt,
+				}, nil)
+				mockAuthenticator.On(
+					"ValidateL402Credentials", mock.Anything, "",
+					mock.Anything,
+				).Return("", l402.ErrMissingAuthorizationHeader)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "Video not found",
+		},
+		{
+			name:       "deleted video, grace period over",
+			authHeader: "validAuthHeader",
+			reqBody:    nil,
+			setupMocks: func(mockStore *MockStore,
+				mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr,
+				mockCloudflare *MockCloudflareService,
+			) {
+				mockStore.On("GetVideoByExternalID", mock.Anything,
+					"externalID").Return((*video.Video)(nil),
+					video.ErrVideoNotFound)
+				mockStore.On("GetTrashedVideo", mock.Anything,
+					"externalID").Return(&video.Video{
+					ReadyToStream: true,
+					DeletedAt:     &deletedAt,
+					PurgeAt:       &deletedAt,
+				}, nil)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "Video not found",
+		},
+		{
+			name:       "video still processing",
+			authHeader: "validAuthHeader",
+			reqBody:    nil,
+			setupMocks: func(
+				mockStore *MockStore,
+				mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr,
+				mockCloudflare *MockCloudflareService,
+			) {
+				processing := &video.Video{
+					ExternalID: "externalID",
+					Status:     video.StatusProcessing,
+				}
+				mockStore.On("GetVideoByExternalID", mock.Anything,
+					"externalID").Return(processing, nil)
+				mockCloudflare.On(
+					"GetStreamVideoInfo", mock.Anything, "externalID",
+				).Return(&cloudflare.StreamVideo{
+					Status: cloudflare.StreamVideoStatus{
+						State: "inprogress",
+					},
+				}, nil)
+				mockStore.On("UpdateCloudflareInfo", mock.Anything,
+					"externalID", mock.Anything,
+				).Return(processing, nil)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   "Video is still processing",
+		},
+		{
+			name:       "video failed to process",
+			authHeader: "validAuthHeader",
+			reqBody:    nil,
+			setupMocks: func(
+				mockStore *MockStore,
+				mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr,
+				mockCloudflare *MockCloudflareService,
+			) {
+				// Failed videos are final, so Cloudflare is not
+				// checked again.
+				mockStore.On("GetVideoByExternalID", mock.Anything,
+					"externalID").Return(&video.Video{
+					ExternalID:   "externalID",
+					Status:       video.StatusFailed,
+					StatusReason: "The video is too long",
+				}, nil)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   "video processing failed: The video is too long",
+		},
+		{
+			name:       "video upload expired",
+			authHeader: "validAuthHeader",
+			reqBody:    nil,
+			setupMocks: func(
+				mockStore *MockStore,
+				mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr,
+				mockCloudflare *MockCloudflareService,
+			) {
+				expiry := time.Unix(0, 0).Add(-time.Hour)
+				mockStore.On("GetVideoByExternalID", mock.Anything,
+					"externalID").Return(&video.Video{
+					ExternalID: "externalID",
+					Status:     video.StatusAwaitingUpload,
+				}, nil)
+				mockCloudflare.On(
+					"GetStreamVideoInfo", mock.Anything, "externalID",
+				).Return(&cloudflare.StreamVideo{
+					UploadExpiry: &expiry,
+					Status: cloudflare.StreamVideoStatus{
+						State: "pendingupload",
+					},
+				}, nil)
+				mockStore.On("UpdateCloudflareInfo", mock.Anything,
+					"externalID", &video.CloudflareVideoInfo{
+						Status: video.StatusExpired,
+						StatusReason: "The upload URL expired " +
+							"before the upload completed",
+					},
+				).Return(&video.Video{
+					ExternalID: "externalID",
+					Status:     video.StatusExpired,
+				}, nil)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "upload URL expired",
+		},
 		{
 			name:       "first video access, video ready in cf, valid credentials",
 			authHeader: "validAuthHeader",
@@ -197,7 +600,7 @@ func TestStreamVideo(t *testing.T) {
 			) {
 				mockStore.On(
 					"GetVideoByExternalID", mock.Anything, "externalID",
-				).Return(&video.Video{ReadyToStream: false}, nil)
+				).Return(&video.Video{ExternalID: "externalID"}, nil)
 				mockCloudflare.On("GetStreamVideoInfo", mock.Anything,
 					"externalID",
 				).Return(&cloudflare.StreamVideo{ReadyToStream: true}, nil)
@@ -207,16 +610,19 @@ func TestStreamVideo(t *testing.T) {
 
 				mockAuthenticator.On(
 					"ValidateL402Credentials", mock.Anything, "validAuthHeader",
+					mock.Anything,
 				).Return("paymentHash", nil)
 				mockOrdersMgr.On(
-					"RecordPurchase", mock.Anything, "paymentHash", "videos",
-				).Return(nil)
-				mockStore.On(
-					"IncrementVideoViews", mock.Anything, "externalID",
+					"RecordPurchaseAndView", mock.Anything, "paymentHash",
+					"videos", "externalID",
 				).Return(nil)
 				mockCloudflare.On(
 					"GenerateStreamURL", mock.Anything, "externalID",
-				).Return("http://hls_stream.url", "http://dash_stream.url", nil)
+					mock.Anything,
+				).Return(&video.StreamURLs{
+					HLSURL:  "http://hls_stream.url",
+					DashURL: "http://dash_stream.url",
+				}, nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   "http://hls_stream.url",
@@ -235,16 +641,19 @@ func TestStreamVideo(t *testing.T) {
 				).Return(&video.Video{ReadyToStream: true}, nil)
 				mockAuthenticator.On(
 					"ValidateL402Credentials", mock.Anything, "validAuthHeader",
+					mock.Anything,
 				).Return("paymentHash", nil)
 				mockOrdersMgr.On(
-					"RecordPurchase", mock.Anything, "paymentHash", "videos",
-				).Return(nil)
-				mockStore.On(
-					"IncrementVideoViews", mock.Anything, "externalID",
+					"RecordPurchaseAndView", mock.Anything, "paymentHash",
+					"videos", "externalID",
 				).Return(nil)
 				mockCloudflare.On(
 					"GenerateStreamURL", mock.Anything, "externalID",
-				).Return("http://hls_stream.url", "http://dash_stream.url", nil)
+					mock.Anything,
+				).Return(&video.StreamURLs{
+					HLSURL:  "http://hls_stream.url",
+					DashURL: "http://dash_stream.url",
+				}, nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   "http://hls_stream.url",
@@ -263,6 +672,7 @@ func TestStreamVideo(t *testing.T) {
 				).Return(&video.Video{ReadyToStream: true}, nil)
 				mockAuthenticator.On(
 					"ValidateL402Credentials", mock.Anything, "validAuthHeader",
+					mock.Anything,
 				).Return("", errors.New("unrecoverable formatting error in credentials"))
 
 			},
@@ -288,6 +698,7 @@ func TestStreamVideo(t *testing.T) {
 				).Return(&video.Video{ReadyToStream: true}, nil)
 				mockAuthenticator.On(
 					"ValidateL402Credentials", mock.Anything, "validAuthHeader",
+					mock.Anything,
 				).Return("", l402.ErrMissingAuthorizationHeader)
 				mockAuthenticator.On(
 					"ValidateSignature", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
@@ -318,6 +729,7 @@ func TestStreamVideo(t *testing.T) {
 					UserID: 661}, nil)
 				mockAuthenticator.On(
 					"ValidateL402Credentials", mock.Anything, "validAuthHeader",
+					mock.Anything,
 				).Return("", l402.ErrMissingAuthorizationHeader)
 				mockAuthenticator.On(
 					"ValidateSignature", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
@@ -332,30 +744,875 @@ func TestStreamVideo(t *testing.T) {
 					Macaroon: mac,
 				}, nil)
 				mockOrdersMgr.On(
-					"CreateOffer", mock.Anything, int64(661), uint64(1), "externalID", "paymentHash",
+					"CreateOffer", mock.Anything, &orders.CreateOfferParams{
+						UserID:          661,
+						PriceInCents:    1,
+						ExternalID:      "externalID",
+						PaymentHash:     "paymentHash",
+						PayerPubKey:     "validPubkey",
+						RecipientPubKey: "validPubkey",
+						ServiceType:     video.ServiceTypeVideos,
+					},
 				).Return(nil)
+				mockStore.On(
+					"ListVideoRentals", mock.Anything, "externalID",
+				).Return([]*video.Rental{}, nil)
 
 			},
 			expectedStatus: http.StatusPaymentRequired,
 			expectedBody:   "Payment Required",
 		},
-	}
-
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			mockStore := new(MockStore)
-			mockAuthenticator := new(MockAuthenticator)
-			mockOrdersMgr := new(MockOrdersMgr)
-			mockCloudflare := new(MockCloudflareService)
-			mockLogger := slog.Default()
+		{
+			name:       "expired credentials",
+			authHeader: "validAuthHeader",
+			reqBody: &video.StreamVideoRequest{
+				Signature: "validSignature",
+				Domain:    "validDomain",
+				Timestamp: 1234567890,
+				PubKey:    "validPubkey",
+			},
+			setupMocks: func(mockStore *MockStore,
+				mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr,
+				mockCloudflare *MockCloudflareService,
+			) {
+				mockStore.On(
+					"GetVideoByExternalID", mock.Anything, "externalID",
+				).Return(&video.Video{PriceInCents: 1, Title: "title",
+					ExternalID: "externalID", ReadyToStream: true,
+					UserID: 661}, nil)
+				mockAuthenticator.On(
+					"ValidateL402Credentials", mock.Anything, "validAuthHeader",
+					mock.Anything,
+				).Return("", fmt.Errorf("unable to validate credentials: %w",
+					l402.ErrInvalidCaveat))
+				mockAuthenticator.On(
+					"ValidateSignature", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+				).Return(nil)
+				mockAuthenticator.On(
+					"NewChallenge", mock.Anything, "title", "validPubkey", uint64(1), mock.Anything,
+				).Return(&l402.Challenge{
+					Invoice: &lightning.LNInvoice{
+						PaymentHash:    "paymentHash",
+						PaymentRequest: "paymentRequest",
+					},
+					Macaroon: mac,
+				}, nil)
+				mockOrdersMgr.On(
+					"CreateOffer", mock.Anything, &orders.CreateOfferParams{
+						UserID:          661,
+						PriceInCents:    1,
+						ExternalID:      "externalID",
+						PaymentHash:     "paymentHash",
+						PayerPubKey:     "validPubkey",
+						RecipientPubKey: "validPubkey",
+						ServiceType:     video.ServiceTypeVideos,
+					},
+				).Return(nil)
+				mockStore.On(
+					"ListVideoRentals", mock.Anything, "externalID",
+				).Return([]*video.Rental{}, nil)
+
+			},
+			expectedStatus: http.StatusPaymentRequired,
+			expectedBody:   "Payment Required",
+		},
+		{
+			name:       "rental option",
+			authHeader: "",
+			reqBody: &video.StreamVideoRequest{
+				Signature: "validSignature",
+				Domain:    "validDomain",
+				Timestamp: 1234567890,
+				PubKey:    "validPubkey",
+				Option:    "rent_48h",
+			},
+			setupMocks: func(mockStore *MockStore,
+				mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr,
+				mockCloudflare *MockCloudflareService,
+			) {
+				mockStore.On(
+					"GetVideoByExternalID", mock.Anything, "externalID",
+				).Return(&video.Video{PriceInCents: 1000, Title: "title",
+					ExternalID: "externalID", ReadyToStream: true,
+					UserID: 661}, nil)
+				mockAuthenticator.On(
+					"ValidateL402Credentials", mock.Anything, "",
+					mock.Anything,
+				).Return("", l402.ErrMissingAuthorizationHeader)
+				mockAuthenticator.On(
+					"ValidateSignature", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+				).Return(nil)
+				mockStore.On(
+					"ListVideoRentals", mock.Anything, "externalID",
+				).Return([]*video.Rental{
+					{DurationInHours: 48, PriceInCents: 100},
+				}, nil)
+				mockAuthenticator.On(
+					"NewChallenge", mock.Anything, "title", "validPubkey", uint64(100),
+					map[string]string{
+						video.CaveatExternalID: "externalID",
+						l402.CaveatExpiresAt:   "1970-01-03T00:00:00Z",
+					},
+				).Return(&l402.Challenge{
+					Invoice: &lightning.LNInvoice{
+						PaymentHash:    "paymentHash",
+						PaymentRequest: "paymentRequest",
+					},
+					Macaroon: mac,
+				}, nil)
+				mockOrdersMgr.On(
+					"CreateOffer", mock.Anything, &orders.CreateOfferParams{
+						UserID:          661,
+						PriceInCents:    100,
+						ExternalID:      "externalID",
+						PaymentHash:     "paymentHash",
+						ExpirationDate:  &rentalExpiration,
+						PayerPubKey:     "validPubkey",
+						RecipientPubKey: "validPubkey",
+						ServiceType:     video.ServiceTypeVideos,
+					},
+				).Return(nil)
+			},
+			expectedStatus: http.StatusPaymentRequired,
+			expectedBody:   "Payment Required",
+		},
+		{
+			name:       "unknown pricing option",
+			authHeader: "",
+			reqBody: &video.StreamVideoRequest{
+				Signature: "validSignature",
+				Domain:    "validDomain",
+				Timestamp: 1234567890,
+				PubKey:    "validPubkey",
+				Option:    "rent_1h",
+			},
+			setupMocks: func(mockStore *MockStore,
+				mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr,
+				mockCloudflare *MockCloudflareService,
+			) {
+				mockStore.On(
+					"GetVideoByExternalID", mock.Anything, "externalID",
+				).Return(&video.Video{PriceInCents: 1000, Title: "title",
+					ExternalID: "externalID", ReadyToStream: true,
+					UserID: 661}, nil)
+				mockAuthenticator.On(
+					"ValidateL402Credentials", mock.Anything, "",
+					mock.Anything,
+				).Return("", l402.ErrMissingAuthorizationHeader)
+				mockAuthenticator.On(
+					"ValidateSignature", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+				).Return(nil)
+				mockStore.On(
+					"ListVideoRentals", mock.Anything, "externalID",
+				).Return([]*video.Rental{
+					{DurationInHours: 48, PriceInCents: 100},
+				}, nil)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "unknown pricing option",
+		},
+		{
+			name:       "promo code",
+			authHeader: "",
+			reqBody: &video.StreamVideoRequest{
+				Signature: "validSignature",
+				Domain:    "validDomain",
+				Timestamp: 1234567890,
+				PubKey:    "validPubkey",
+				PromoCode: "launch",
+			},
+			setupMocks: func(mockStore *MockStore,
+				mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr,
+				mockCloudflare *MockCloudflareService,
+			) {
+				mockStore.On(
+					"GetVideoByExternalID", mock.Anything, "externalID",
+				).Return(&video.Video{PriceInCents: 1000, Title: "title",
+					ExternalID: "externalID", ReadyToStream: true,
+					UserID: 661}, nil)
+				mockAuthenticator.On(
+					"ValidateL402Credentials", mock.Anything, "",
+					mock.Anything,
+				).Return("", l402.ErrMissingAuthorizationHeader)
+				mockAuthenticator.On(
+					"ValidateSignature", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+				).Return(nil)
+				mockStore.On(
+					"ListVideoRentals", mock.Anything, "externalID",
+				).Return([]*video.Rental{}, nil)
+				mockStore.On(
+					"GetPromoCode", mock.Anything, int64(661), "LAUNCH",
+				).Return(&video.PromoCode{
+					ID:             7,
+					Code:           "LAUNCH",
+					DiscountType:   video.DiscountTypePercentage,
+					DiscountValue:  25,
+					MaxRedemptions: 10,
+					Redemptions:    9,
+					VideoIDs:       []string{"otherID", "externalID"},
+				}, nil)
+				mockAuthenticator.On(
+					"NewChallenge", mock.Anything, "title", "validPubkey",
+					uint64(750), mock.Anything,
+				).Return(&l402.Challenge{
+					Invoice: &lightning.LNInvoice{
+						PaymentHash:    "paymentHash",
+						PaymentRequest: "paymentRequest",
+					},
+					Macaroon: mac,
+				}, nil)
+				mockOrdersMgr.On(
+					"CreateOffer", mock.Anything, &orders.CreateOfferParams{
+						UserID:          661,
+						PriceInCents:    750,
+						ExternalID:      "externalID",
+						PaymentHash:     "paymentHash",
+						PromoCodeID:     7,
+						PayerPubKey:     "validPubkey",
+						RecipientPubKey: "validPubkey",
+						ServiceType:     video.ServiceTypeVideos,
+					},
+				).Return(nil)
+			},
+			expectedStatus: http.StatusPaymentRequired,
+			expectedBody:   "Payment Required",
+		},
+		{
+			name:       "expired promo code",
+			authHeader: "",
+			reqBody: &video.StreamVideoRequest{
+				Signature: "validSignature",
+				Domain:    "validDomain",
+				Timestamp: 1234567890,
+				PubKey:    "validPubkey",
+				PromoCode: "LAUNCH",
+			},
+			setupMocks: func(mockStore *MockStore,
+				mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr,
+				mockCloudflare *MockCloudflareService,
+			) {
+				mockStore.On(
+					"GetVideoByExternalID", mock.Anything, "externalID",
+				).Return(&video.Video{PriceInCents: 1000, Title: "title",
+					ExternalID: "externalID", ReadyToStream: true,
+					UserID: 661}, nil)
+				mockAuthenticator.On(
+					"ValidateL402Credentials", mock.Anything, "",
+					mock.Anything,
+				).Return("", l402.ErrMissingAuthorizationHeader)
+				mockAuthenticator.On(
+					"ValidateSignature", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+				).Return(nil)
+				mockStore.On(
+					"ListVideoRentals", mock.Anything, "externalID",
+				).Return([]*video.Rental{}, nil)
+				mockStore.On(
+					"GetPromoCode", mock.Anything, int64(661), "LAUNCH",
+				).Return(&video.PromoCode{
+					ID:            7,
+					Code:          "LAUNCH",
+					DiscountType:  video.DiscountTypeFixed,
+					DiscountValue: 100,
+					ExpiresAt:     &promoExpiration,
+				}, nil)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "has expired",
+		},
+		{
+			name:       "pay what you want amount",
+			authHeader: "",
+			reqBody: &video.StreamVideoRequest{
+				Signature: "validSignature",
+				Domain:    "validDomain",
+				Timestamp: 1234567890,
+				PubKey:    "validPubkey",
+				Amount:    800,
+			},
+			setupMocks: func(mockStore *MockStore,
+				mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr,
+				mockCloudflare *MockCloudflareService,
+			) {
+				mockStore.On(
+					"GetVideoByExternalID", mock.Anything, "externalID",
+				).Return(&video.Video{PriceInCents: 500, Title: "title",
+					ExternalID: "externalID", ReadyToStream: true,
+					UserID: 661, PayWhatYouWant: true,
+					SuggestedPriceInCents: 1000}, nil)
+				mockAuthenticator.On(
+					"ValidateL402Credentials", mock.Anything, "",
+					mock.Anything,
+				).Return("", l402.ErrMissingAuthorizationHeader)
+				mockAuthenticator.On(
+					"ValidateSignature", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+				).Return(nil)
+				mockStore.On(
+					"ListVideoRentals", mock.Anything, "externalID",
+				).Return([]*video.Rental{}, nil)
+				mockAuthenticator.On(
+					"NewChallenge", mock.Anything, "title", "validPubkey",
+					uint64(800), mock.Anything,
+				).Return(&l402.Challenge{
+					Invoice: &lightning.LNInvoice{
+						PaymentHash:    "paymentHash",
+						PaymentRequest: "paymentRequest",
+					},
+					Macaroon: mac,
+				}, nil)
+				mockOrdersMgr.On(
+					"CreateOffer", mock.Anything, &orders.CreateOfferParams{
+						UserID:          661,
+						PriceInCents:    800,
+						ExternalID:      "externalID",
+						PaymentHash:     "paymentHash",
+						PayerPubKey:     "validPubkey",
+						RecipientPubKey: "validPubkey",
+						ServiceType:     video.ServiceTypeVideos,
+					},
+				).Return(nil)
+			},
+			expectedStatus: http.StatusPaymentRequired,
+			expectedBody:   "Payment Required",
+		},
+		{
+			name:       "pay what you want below minimum",
+			authHeader: "",
+			reqBody: &video.StreamVideoRequest{
+				Signature: "validSignature",
+				Domain:    "validDomain",
+				Timestamp: 1234567890,
+				PubKey:    "validPubkey",
+				Amount:    499,
+			},
+			setupMocks: func(mockStore *MockStore,
+				mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr,
+				mockCloudflare *MockCloudflareService,
+			) {
+				mockStore.On(
+					"GetVideoByExternalID", mock.Anything, "externalID",
+				).Return(&video.Video{PriceInCents: 500, Title: "title",
+					ExternalID: "externalID", ReadyToStream: true,
+					UserID: 661, PayWhatYouWant: true,
+					SuggestedPriceInCents: 1000}, nil)
+				mockAuthenticator.On(
+					"ValidateL402Credentials", mock.Anything, "",
+					mock.Anything,
+				).Return("", l402.ErrMissingAuthorizationHeader)
+				mockAuthenticator.On(
+					"ValidateSignature", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+				).Return(nil)
+				mockStore.On(
+					"ListVideoRentals", mock.Anything, "externalID",
+				).Return([]*video.Rental{}, nil)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "the minimum is 500 cents",
+		},
+		{
+			name:       "gift to another public key",
+			authHeader: "",
+			reqBody: &video.StreamVideoRequest{
+				Signature:       "validSignature",
+				Domain:          "validDomain",
+				Timestamp:       1234567890,
+				PubKey:          "validPubkey",
+				RecipientPubKey: giftPubKey,
+			},
+			setupMocks: func(mockStore *MockStore,
+				mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr,
+				mockCloudflare *MockCloudflareService,
+			) {
+				mockStore.On(
+					"GetVideoByExternalID", mock.Anything, "externalID",
+				).Return(&video.Video{PriceInCents: 500, Title: "title",
+					ExternalID: "externalID", ReadyToStream: true,
+					UserID: 661}, nil)
+				mockAuthenticator.On(
+					"ValidateL402Credentials", mock.Anything, "",
+					mock.Anything,
+				).Return("", l402.ErrMissingAuthorizationHeader)
+				mockAuthenticator.On(
+					"ValidateSignature", "validPubkey", mock.Anything,
+					mock.Anything, mock.Anything,
+				).Return(nil)
+				mockStore.On(
+					"ListVideoRentals", mock.Anything, "externalID",
+				).Return([]*video.Rental{}, nil)
+				mockAuthenticator.On(
+					"NewChallenge", mock.Anything, "title", giftPubKey,
+					uint64(500), mock.Anything,
+				).Return(&l402.Challenge{
+					Invoice: &lightning.LNInvoice{
+						PaymentHash:    "paymentHash",
+						PaymentRequest: "paymentRequest",
+					},
+					Macaroon: mac,
+				}, nil)
+				mockOrdersMgr.On(
+					"CreateOffer", mock.Anything, &orders.CreateOfferParams{
+						UserID:          661,
+						PriceInCents:    500,
+						ExternalID:      "externalID",
+						PaymentHash:     "paymentHash",
+						PayerPubKey:     "validPubkey",
+						RecipientPubKey: giftPubKey,
+						ServiceType:     video.ServiceTypeVideos,
+					},
+				).Return(nil)
+			},
+			expectedStatus: http.StatusPaymentRequired,
+			expectedBody:   "Payment Required",
+		},
+		{
+			name:       "gift to an invalid public key",
+			authHeader: "",
+			reqBody: &video.StreamVideoRequest{
+				Signature:       "validSignature",
+				Domain:          "validDomain",
+				Timestamp:       1234567890,
+				PubKey:          "validPubkey",
+				RecipientPubKey: "invalid",
+			},
+			setupMocks: func(mockStore *MockStore,
+				mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr,
+				mockCloudflare *MockCloudflareService,
+			) {
+				mockStore.On(
+					"GetVideoByExternalID", mock.Anything, "externalID",
+				).Return(&video.Video{PriceInCents: 500, Title: "title",
+					ExternalID: "externalID", ReadyToStream: true,
+					UserID: 661}, nil)
+				mockAuthenticator.On(
+					"ValidateL402Credentials", mock.Anything, "",
+					mock.Anything,
+				).Return("", l402.ErrMissingAuthorizationHeader)
+				mockAuthenticator.On(
+					"ValidateSignature", mock.Anything, mock.Anything,
+					mock.Anything, mock.Anything,
+				).Return(nil)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid recipient",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			mockAuthenticator := new(MockAuthenticator)
+			mockOrdersMgr := new(MockOrdersMgr)
+			mockCloudflare := new(MockCloudflareService)
+			mockCloudflare.On("MaxDuration", mock.Anything).Return(
+				time.Duration(0),
+			).Maybe()
+			mockLogger := slog.Default()
+
+			clock := utils.NewMockClock()
+			clock.SetMockClockTime(time.Unix(0, 0).UTC())
+
+			manager := video.NewManager(
+				mockOrdersMgr,
+				mockCloudflare,
+				nil,
+				mockAuthenticator,
+				nil,
+				nil,
+				nil,
+				mockStore,
+				mockLogger,
+				clock,
+				video.DefaultConfig(),
+			)
+			controller := video.NewController(
+				manager,
+				mockAuthenticator,
+				mockStore,
+				mockLogger,
+				video.DefaultConfig(),
+			)
+
+			router := gin.New()
+			router.POST("/video/stream/:id", controller.StreamVideo)
+
+			tc.setupMocks(
+				mockStore,
+				mockAuthenticator,
+				mockOrdersMgr,
+				mockCloudflare,
+			)
+
+			body, err := json.Marshal(tc.reqBody)
+			require.NoError(t, err)
+			req, err := http.NewRequest(
+				http.MethodPost,
+				"/video/stream/externalID",
+				bytes.NewBuffer(body),
+			)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", tc.authHeader)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tc.expectedBody)
+		})
+	}
+}
+
+func TestRecoverCredentials(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	reqBody := &video.RecoverCredentialsRequest{
+		PubKey:    "recipientPubKey",
+		Domain:    "validDomain",
+		Timestamp: 1234567890,
+		Signature: "validSignature",
+	}
+
+	testCases := []struct {
+		name           string
+		setupMocks     func(*MockAuthenticator, *MockOrdersMgr)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "invalid signature",
+			setupMocks: func(mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr) {
+
+				mockAuthenticator.On(
+					"ValidateActionSignature", "recipientPubKey",
+					"validSignature", "validDomain", int64(1234567890),
+					video.RecoverCredentialsAction,
+					[]string{"externalID"},
+				).Return(errors.New("invalid signature"))
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "invalid signature",
+		},
+		{
+			name: "paid gift and unpaid offer",
+			setupMocks: func(mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr) {
+
+				mockAuthenticator.On(
+					"ValidateActionSignature", "recipientPubKey",
+					"validSignature", "validDomain", int64(1234567890),
+					video.RecoverCredentialsAction,
+					[]string{"externalID"},
+				).Return(nil)
+				mockOrdersMgr.On(
+					"ListRecipientOffers", mock.Anything, "externalID",
+					"recipientPubKey", int64(video.MaxRecoverableOffers),
+				).Return([]*orders.Offer{
+					{PaymentHash: "unpaidHash"},
+					{PaymentHash: "paidHash", PayerPubKey: "payerPubKey"},
+				}, nil)
+				mockAuthenticator.On(
+					"PaidCredentials", mock.Anything, "unpaidHash",
+					"recipientPubKey",
+				).Return("", "", l402.ErrInvoiceNotPaid)
+				mockAuthenticator.On(
+					"PaidCredentials", mock.Anything, "paidHash",
+					"recipientPubKey",
+				).Return("macaroon", "preimage", nil)
+				mockOrdersMgr.On(
+					"RecordPurchase", mock.Anything, "paidHash",
+					video.ServiceTypeVideos,
+				).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"credentials":[{"payment_hash":"paidHash",` +
+				`"macaroon":"macaroon","preimage":"preimage",` +
+				`"payer_pub_key":"payerPubKey","expiration_date":null}]}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			mockAuthenticator := new(MockAuthenticator)
+			mockOrdersMgr := new(MockOrdersMgr)
+			mockCloudflare := new(MockCloudflareService)
+			mockLogger := slog.Default()
+
+			manager := video.NewManager(
+				mockOrdersMgr,
+				mockCloudflare,
+				nil,
+				mockAuthenticator,
+				nil,
+				nil,
+				nil,
+				mockStore,
+				mockLogger,
+				utils.NewMockClock(),
+				video.DefaultConfig(),
+			)
+			controller := video.NewController(
+				manager,
+				mockAuthenticator,
+				mockStore,
+				mockLogger,
+				video.DefaultConfig(),
+			)
+
+			router := gin.New()
+			router.POST("/video/credentials/:id", controller.RecoverCredentials)
+
+			tc.setupMocks(mockAuthenticator, mockOrdersMgr)
+
+			body, err := json.Marshal(reqBody)
+			require.NoError(t, err)
+			req, err := http.NewRequest(
+				http.MethodPost,
+				"/video/credentials/externalID",
+				bytes.NewBuffer(body),
+			)
+			require.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tc.expectedBody)
+			mockOrdersMgr.AssertExpectations(t)
+		})
+	}
+}
+
+func TestLibrary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	now := time.Unix(1000, 0).UTC()
+	expired := now.Add(-time.Hour)
+	active := now.Add(time.Hour)
+
+	mockStore := new(MockStore)
+	mockAuthenticator := new(MockAuthenticator)
+	mockOrdersMgr := new(MockOrdersMgr)
+	mockLogger := slog.Default()
+
+	clock := utils.NewMockClock()
+	clock.SetMockClockTime(now)
+
+	manager := video.NewManager(mockOrdersMgr, new(MockCloudflareService),
+		nil, mockAuthenticator, nil, nil, nil, mockStore, mockLogger, clock,
+		video.DefaultConfig())
+	controller := video.NewController(manager, mockAuthenticator, mockStore,
+		mockLogger, video.DefaultConfig())
+
+	router := gin.New()
+	router.GET("/library", controller.GetLibrary)
+
+	mockAuthenticator.On(
+		"ValidateActionSignature", "buyer", "signature", "domain", int64(1),
+		video.LibraryAction, []string(nil),
+	).Return(nil)
+	mockOrdersMgr.On("ListRecipientPurchases", mock.Anything, "buyer").Return(
+		[]*orders.Purchase{
+			{ExternalID: "rented", ServiceType: video.ServiceTypeVideos,
+				ExpirationDate: &expired},
+			{ExternalID: "bought", ServiceType: video.ServiceTypeVideos},
+			{ExternalID: "deleted", ServiceType: video.ServiceTypeVideos},
+			{ExternalID: "trashed", ServiceType: video.ServiceTypeVideos},
+			{ExternalID: "bundle", ServiceType: video.ServiceTypeBundles},
+			{ExternalID: "deletedBundle",
+				ServiceType: video.ServiceTypeBundles},
+			{ExternalID: video.SubscriptionExternalID(7),
+				ServiceType:    video.ServiceTypeSubscriptions,
+				ExpirationDate: &active},
+		}, nil,
+	)
+	mockStore.On("GetVideoByExternalID", mock.Anything, "bought").Return(
+		&video.Video{ExternalID: "bought"}, nil)
+	mockStore.On("GetVideoByExternalID", mock.Anything, "deleted").Return(
+		(*video.Video)(nil), video.ErrVideoNotFound)
+	mockStore.On("GetTrashedVideo", mock.Anything, "deleted").Return(
+		(*video.Video)(nil), video.ErrVideoNotFound)
+
+	// The deleted videos are listed until they are purged, as they can
+	// still be streamed.
+	mockStore.On("GetVideoByExternalID", mock.Anything, "trashed").Return(
+		(*video.Video)(nil), video.ErrVideoNotFound)
+	mockStore.On("GetTrashedVideo", mock.Anything, "trashed").Return(
+		&video.Video{ExternalID: "trashed", PurgeAt: &active}, nil)
+	mockStore.On("GetVideoByExternalID", mock.Anything,
+		"expiredTrash").Return((*video.Video)(nil), video.ErrVideoNotFound)
+	mockStore.On("GetTrashedVideo", mock.Anything, "expiredTrash").Return(
+		&video.Video{ExternalID: "expiredTrash", PurgeAt: &expired}, nil)
+	mockStore.On("GetPurchasedBundle", mock.Anything, "bundle").Return(
+		&video.Bundle{ExternalID: "bundle",
+			VideoIDs: []string{"bought", "inBundle", "deleted"}}, nil)
+	mockStore.On("GetVideoByExternalID", mock.Anything, "inBundle").Return(
+		&video.Video{ExternalID: "inBundle"}, nil)
+
+	// The bundles deleted after the purchase keep their videos.
+	mockStore.On("GetPurchasedBundle", mock.Anything,
+		"deletedBundle").Return(&video.Bundle{ExternalID: "deletedBundle",
+		VideoIDs: []string{"inDeletedBundle"}}, nil)
+	mockStore.On("GetVideoByExternalID", mock.Anything,
+		"inDeletedBundle").Return(
+		&video.Video{ExternalID: "inDeletedBundle"}, nil)
+	mockStore.On("ListUserVideos", mock.Anything, int64(7)).Return(
+		[]*video.Video{{ExternalID: "fromCreator"}}, nil)
+	mockStore.On("ListUserTrashedVideos", mock.Anything, int64(7)).Return(
+		[]string{"trashed", "expiredTrash"}, nil)
+
+	// The signature is required.
+	req, err := http.NewRequest(http.MethodGet, "/library", nil)
+	require.NoError(t, err)
+	req.Header.Set(video.PubKeyHeader, "buyer")
+	req.Header.Set(video.DomainHeader, "domain")
+	req.Header.Set(video.TimestampHeader, "1")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	req.Header.Set(video.SignatureHeader, "signature")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Videos []struct {
+			ExternalID  string `json:"external_id"`
+			L402URL     string `json:"l402_url"`
+			ServiceType string `json:"service_type"`
+		} `json:"videos"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Videos, 5)
+
+	require.Equal(t, "bought", resp.Videos[0].ExternalID)
+	require.Equal(t, video.ServiceTypeVideos, resp.Videos[0].ServiceType)
+	require.Equal(t, video.DefaultConfig().L402BaseURL+"/bought",
+		resp.Videos[0].L402URL)
+	require.Equal(t, "trashed", resp.Videos[1].ExternalID)
+	require.Equal(t, video.ServiceTypeVideos, resp.Videos[1].ServiceType)
+	require.Equal(t, "inBundle", resp.Videos[2].ExternalID)
+	require.Equal(t, video.ServiceTypeBundles, resp.Videos[2].ServiceType)
+	require.Equal(t, "inDeletedBundle", resp.Videos[3].ExternalID)
+	require.Equal(t, video.ServiceTypeBundles, resp.Videos[3].ServiceType)
+	require.Equal(t, "fromCreator", resp.Videos[4].ExternalID)
+}
+
+func TestSubscribe(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	reqBody := &video.StreamVideoRequest{
+		Signature: "validSignature",
+		Domain:    "validDomain",
+		Timestamp: 1234567890,
+		PubKey:    "validPubkey",
+	}
+
+	testCases := []struct {
+		name           string
+		setupMocks     func(*MockStore, *MockAuthenticator, *MockOrdersMgr)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "subscriptions disabled",
+			setupMocks: func(mockStore *MockStore,
+				mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr,
+			) {
+				mockStore.On(
+					"GetVideoByExternalID", mock.Anything, "externalID",
+				).Return(&video.Video{ExternalID: "externalID",
+					UserID: 661}, nil)
+				mockStore.On(
+					"GetUserSubscriptionPrice", mock.Anything, int64(661),
+				).Return(int64(0), nil)
+				mockAuthenticator.On(
+					"ValidateSignature", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+				).Return(nil)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "Creator does not offer subscriptions",
+		},
+		{
+			name: "payment required",
+			setupMocks: func(mockStore *MockStore,
+				mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr,
+			) {
+				mockStore.On(
+					"GetVideoByExternalID", mock.Anything, "externalID",
+				).Return(&video.Video{ExternalID: "externalID",
+					UserID: 661}, nil)
+				mockStore.On(
+					"GetUserSubscriptionPrice", mock.Anything, int64(661),
+				).Return(int64(500), nil)
+				mockAuthenticator.On(
+					"ValidateSignature", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+				).Return(nil)
+				mockAuthenticator.On(
+					"NewChallenge", mock.Anything, mock.Anything, "validPubkey", uint64(500),
+					map[string]string{
+						video.CaveatCreatorID: "661",
+						l402.CaveatExpiresAt:  "1970-01-31T00:00:00Z",
+					},
+				).Return(&l402.Challenge{
+					Invoice: &lightning.LNInvoice{
+						PaymentHash:    "paymentHash",
+						PaymentRequest: "paymentRequest",
+					},
+					Macaroon: mac,
+				}, nil)
+				mockOrdersMgr.On(
+					"CreateOffer", mock.Anything,
+					mock.MatchedBy(func(p *orders.CreateOfferParams) bool {
+						return p.UserID == 661 &&
+							p.PriceInCents == 500 &&
+							p.ExternalID == "subscription-661" &&
+							p.PaymentHash == "paymentHash" &&
+							p.ExpirationDate != nil
+					}),
+				).Return(nil)
+			},
+			expectedStatus: http.StatusPaymentRequired,
+			expectedBody:   "Payment Required",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			mockAuthenticator := new(MockAuthenticator)
+			mockOrdersMgr := new(MockOrdersMgr)
+			mockCloudflare := new(MockCloudflareService)
+			mockLogger := slog.Default()
+
+			clock := utils.NewMockClock()
+			clock.SetMockClockTime(time.Unix(0, 0).UTC())
 
 			manager := video.NewManager(
 				mockOrdersMgr,
 				mockCloudflare,
+				nil,
 				mockAuthenticator,
+				nil,
+				nil,
+				nil,
 				mockStore,
 				mockLogger,
-				utils.NewMockClock(),
+				clock,
+				video.DefaultConfig(),
 			)
 			controller := video.NewController(
 				manager,
@@ -366,20 +1623,237 @@ func TestStreamVideo(t *testing.T) {
 			)
 
 			router := gin.New()
-			router.POST("/video/stream/:id", controller.StreamVideo)
+			router.POST("/video/subscribe/:id", controller.Subscribe)
 
-			tc.setupMocks(
+			tc.setupMocks(mockStore, mockAuthenticator, mockOrdersMgr)
+
+			body, err := json.Marshal(reqBody)
+			require.NoError(t, err)
+			req, err := http.NewRequest(
+				http.MethodPost,
+				"/video/subscribe/externalID",
+				bytes.NewBuffer(body),
+			)
+			require.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tc.expectedBody)
+			mockOrdersMgr.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAccessCheckers(t *testing.T) {
+	mockStore := new(MockStore)
+	mockStore.On(
+		"IsBundleVideo", mock.Anything, "bundleID", "externalID",
+	).Return(true, nil)
+	mockStore.On(
+		"IsBundleVideo", mock.Anything, "otherBundleID", "externalID",
+	).Return(false, nil)
+	mockStore.On(
+		"IsBundleVideo", mock.Anything, "failingBundleID", "externalID",
+	).Return(false, errors.New("database is down"))
+
+	manager := video.NewManager(nil, nil, nil, nil, nil, nil, nil, mockStore,
+		slog.Default(), utils.NewMockClock(), video.DefaultConfig())
+	v := &video.Video{ExternalID: "externalID", UserID: 661}
+
+	var access video.Access
+	checkers := manager.AccessCheckers(context.Background(), v, &access)
+	require.NoError(t, checkers[video.CaveatExternalID]("externalID"))
+	require.Error(t, checkers[video.CaveatExternalID]("otherID"))
+	require.Equal(t, video.ServiceTypeVideos, access.ServiceType)
+
+	require.Error(t, checkers[video.CaveatCreatorID]("662"))
+	require.NoError(t, checkers[video.CaveatCreatorID]("661"))
+	require.Equal(t, video.ServiceTypeSubscriptions, access.ServiceType)
+
+	err := checkers[video.CaveatBundleID]("otherBundleID")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, l402.ErrCaveatCheckFailed)
+
+	// Failing to look up the bundle doesn't invalidate the credentials.
+	err = checkers[video.CaveatBundleID]("failingBundleID")
+	require.ErrorIs(t, err, l402.ErrCaveatCheckFailed)
+
+	require.NoError(t, checkers[video.CaveatBundleID]("bundleID"))
+	require.Equal(t, video.ServiceTypeBundles, access.ServiceType)
+
+	require.Nil(t, access.ExpiresAt)
+	require.NoError(t, checkers[l402.CaveatExpiresAt]("2024-09-02T00:00:00Z"))
+	require.Equal(t, time.Date(2024, 9, 2, 0, 0, 0, 0, time.UTC),
+		access.ExpiresAt.UTC())
+
+	// A later expires_at caveat can't extend the access, an earlier one
+	// shortens it.
+	require.NoError(t, checkers[l402.CaveatExpiresAt]("2024-09-03T00:00:00Z"))
+	require.Equal(t, time.Date(2024, 9, 2, 0, 0, 0, 0, time.UTC),
+		access.ExpiresAt.UTC())
+
+	require.NoError(t, checkers[l402.CaveatExpiresAt]("2024-09-01T12:00:00Z"))
+	require.Equal(t, time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC),
+		access.ExpiresAt.UTC())
+}
+
+func TestStreamBundle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	bundle := &video.Bundle{
+		ExternalID:   "bundleID",
+		UserID:       661,
+		Title:        "bundle",
+		PriceInCents: 2000,
+		VideoIDs:     []string{"video1", "video2"},
+	}
+
+	testCases := []struct {
+		name           string
+		authHeader     string
+		setupMocks     func(*MockStore, *MockAuthenticator, *MockOrdersMgr)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "bundle not found",
+			setupMocks: func(mockStore *MockStore,
+				mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr,
+			) {
+				mockStore.On(
+					"GetBundleByExternalID", mock.Anything, "bundleID",
+				).Return(nil, video.ErrBundleNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "Bundle not found",
+		},
+		{
+			name: "payment required",
+			setupMocks: func(mockStore *MockStore,
+				mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr,
+			) {
+				mockStore.On(
+					"GetBundleByExternalID", mock.Anything, "bundleID",
+				).Return(bundle, nil)
+				mockAuthenticator.On(
+					"ValidateL402Credentials", mock.Anything, "",
+					mock.Anything,
+				).Return("", l402.ErrMissingAuthorizationHeader)
+				mockAuthenticator.On(
+					"ValidateSignature", mock.Anything, mock.Anything,
+					mock.Anything, mock.Anything,
+				).Return(nil)
+				mockAuthenticator.On(
+					"NewChallenge", mock.Anything, "bundle", "validPubkey",
+					uint64(2000), map[string]string{
+						video.CaveatBundleID: "bundleID",
+						l402.CaveatExpiresAt: time.Unix(0, 0).UTC().
+							Add(video.ExpirationTime).Format(time.RFC3339),
+					},
+				).Return(&l402.Challenge{
+					Invoice: &lightning.LNInvoice{
+						PaymentHash:    "paymentHash",
+						PaymentRequest: "paymentRequest",
+					},
+					Macaroon: mac,
+				}, nil)
+				mockOrdersMgr.On(
+					"CreateOffer", mock.Anything, &orders.CreateOfferParams{
+						UserID:          661,
+						PriceInCents:    2000,
+						ExternalID:      "bundleID",
+						PaymentHash:     "paymentHash",
+						PayerPubKey:     "validPubkey",
+						RecipientPubKey: "validPubkey",
+						ServiceType:     video.ServiceTypeBundles,
+					},
+				).Return(nil)
+			},
+			expectedStatus: http.StatusPaymentRequired,
+			expectedBody:   "Payment Required",
+		},
+		{
+			name:       "valid credentials",
+			authHeader: "L402 validToken",
+			setupMocks: func(mockStore *MockStore,
+				mockAuthenticator *MockAuthenticator,
+				mockOrdersMgr *MockOrdersMgr,
+			) {
+				mockStore.On(
+					"GetBundleByExternalID", mock.Anything, "bundleID",
+				).Return(bundle, nil)
+				mockAuthenticator.On(
+					"ValidateL402Credentials", mock.Anything,
+					"L402 validToken", mock.Anything,
+				).Return("paymentHash", nil)
+				mockOrdersMgr.On(
+					"RecordPurchase", mock.Anything, "paymentHash",
+					video.ServiceTypeBundles,
+				).Return(nil)
+				mockStore.On(
+					"GetVideoByExternalID", mock.Anything, "video1",
+				).Return(&video.Video{ExternalID: "video1",
+					Title: "first"}, nil)
+				mockStore.On(
+					"GetVideoByExternalID", mock.Anything, "video2",
+				).Return(&video.Video{ExternalID: "video2",
+					Title: "second"}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "/video/stream/video2",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			mockAuthenticator := new(MockAuthenticator)
+			mockOrdersMgr := new(MockOrdersMgr)
+			mockLogger := slog.Default()
+
+			clock := utils.NewMockClock()
+			clock.SetMockClockTime(time.Unix(0, 0).UTC())
+
+			manager := video.NewManager(
+				mockOrdersMgr,
+				new(MockCloudflareService),
+				nil,
+				mockAuthenticator,
+				nil,
+				nil,
+				nil,
 				mockStore,
+				mockLogger,
+				clock,
+				video.DefaultConfig(),
+			)
+			controller := video.NewController(
+				manager,
 				mockAuthenticator,
-				mockOrdersMgr,
-				mockCloudflare,
+				mockStore,
+				mockLogger,
+				video.DefaultConfig(),
 			)
 
-			body, err := json.Marshal(tc.reqBody)
+			router := gin.New()
+			router.POST("/bundle/stream/:id", controller.StreamBundle)
+
+			tc.setupMocks(mockStore, mockAuthenticator, mockOrdersMgr)
+
+			body, err := json.Marshal(&video.StreamVideoRequest{
+				Signature: "validSignature",
+				Domain:    "validDomain",
+				Timestamp: 1234567890,
+				PubKey:    "validPubkey",
+			})
 			require.NoError(t, err)
 			req, err := http.NewRequest(
 				http.MethodPost,
-				"/video/stream/externalID",
+				"/bundle/stream/bundleID",
 				bytes.NewBuffer(body),
 			)
 			require.NoError(t, err)
@@ -390,6 +1864,216 @@ func TestStreamVideo(t *testing.T) {
 
 			assert.Equal(t, tc.expectedStatus, w.Code)
 			assert.Contains(t, w.Body.String(), tc.expectedBody)
+			mockOrdersMgr.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDiscountedPrice(t *testing.T) {
+	testCases := []struct {
+		name          string
+		discountType  string
+		discountValue int64
+		price         int64
+		expected      int64
+	}{
+		{"percentage", video.DiscountTypePercentage, 20, 1000, 800},
+		{"percentage rounds down the discount", video.DiscountTypePercentage,
+			33, 10, 7},
+		{"full percentage", video.DiscountTypePercentage, 100, 1000, 1},
+		{"fixed", video.DiscountTypeFixed, 250, 1000, 750},
+		{"fixed above price", video.DiscountTypeFixed, 2000, 1000, 1},
+		{"free video", video.DiscountTypeFixed, 100, 0, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			promoCode := &video.PromoCode{
+				DiscountType:  tc.discountType,
+				DiscountValue: tc.discountValue,
+			}
+			require.Equal(t, tc.expected,
+				video.DiscountedPrice(promoCode, tc.price))
+		})
+	}
+}
+
+type MockWebhookDispatcher struct {
+	mock.Mock
+}
+
+func (m *MockWebhookDispatcher) Enqueue(ctx context.Context, userID int64,
+	eventType string, data any) error {
+
+	args := m.Called(ctx, userID, eventType, data)
+	return args.Error(0)
+}
+
+func TestCloudflareWebhook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	processing := &video.Video{ExternalID: "externalID", UserID: 1}
+	ready := &video.Video{
+		ExternalID:    "externalID",
+		UserID:        1,
+		ReadyToStream: true,
+	}
+
+	testCases := []struct {
+		name           string
+		body           string
+		setupMocks     func(*MockStore, *MockCloudflareService, *MockWebhookDispatcher)
+		expectedStatus int
+	}{
+		{
+			name: "invalid signature",
+			body: `{"uid":"externalID","readyToStream":true}`,
+			setupMocks: func(mockStore *MockStore,
+				mockCloudflare *MockCloudflareService,
+				mockWebhooks *MockWebhookDispatcher) {
+
+				mockCloudflare.On("VerifyWebhookSignature", "signature",
+					mock.Anything).Return(errors.New("invalid"))
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "ready to stream",
+			body: `{"uid":"externalID","readyToStream":true,` +
+				`"status":{"state":"ready"},"duration":12.5}`,
+			setupMocks: func(mockStore *MockStore,
+				mockCloudflare *MockCloudflareService,
+				mockWebhooks *MockWebhookDispatcher) {
+
+				mockCloudflare.On("VerifyWebhookSignature", "signature",
+					mock.Anything).Return(nil)
+				mockCloudflare.On("MaxDuration", int64(1)).Return(
+					time.Minute)
+				mockStore.On("GetVideoByExternalID", mock.Anything,
+					"externalID").Return(processing, nil)
+				mockStore.On("UpdateCloudflareInfo", mock.Anything,
+					"externalID", &video.CloudflareVideoInfo{
+						DurationInSeconds: 12.5,
+						ReadyToStream:     true,
+						Status:            video.StatusReady,
+					}).Return(ready, nil)
+				mockWebhooks.On("Enqueue", mock.Anything, int64(1),
+					"video.ready", ready).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			// Imports are not limited by Stream, so their duration is
+			// checked once processed.
+			name: "over max duration",
+			body: `{"uid":"externalID","readyToStream":true,` +
+				`"status":{"state":"ready"},"duration":90}`,
+			setupMocks: func(mockStore *MockStore,
+				mockCloudflare *MockCloudflareService,
+				mockWebhooks *MockWebhookDispatcher) {
+
+				mockCloudflare.On("VerifyWebhookSignature", "signature",
+					mock.Anything).Return(nil)
+				mockCloudflare.On("MaxDuration", int64(1)).Return(
+					time.Minute)
+				mockStore.On("GetVideoByExternalID", mock.Anything,
+					"externalID").Return(processing, nil)
+				mockStore.On("UpdateCloudflareInfo", mock.Anything,
+					"externalID", &video.CloudflareVideoInfo{
+						DurationInSeconds: 90,
+						Status:            video.StatusFailed,
+						StatusReason: "The video is longer than " +
+							"the maximum duration",
+					}).Return(processing, nil)
+				mockCloudflare.On("DeleteVideo", mock.Anything,
+					"externalID").Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "processing error",
+			body: `{"uid":"externalID","readyToStream":false,` +
+				`"status":{"state":"error","errorReasonCode":"ERR_DURATION_EXCEED_CONSTRAINT",` +
+				`"errorReasonText":"The video is too long"}}`,
+			setupMocks: func(mockStore *MockStore,
+				mockCloudflare *MockCloudflareService,
+				mockWebhooks *MockWebhookDispatcher) {
+
+				mockCloudflare.On("VerifyWebhookSignature", "signature",
+					mock.Anything).Return(nil)
+				mockStore.On("GetVideoByExternalID", mock.Anything,
+					"externalID").Return(processing, nil)
+				mockStore.On("UpdateCloudflareInfo", mock.Anything,
+					"externalID", &video.CloudflareVideoInfo{
+						Status:       video.StatusFailed,
+						StatusReason: "The video is too long",
+					}).Return(processing, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "already ready",
+			body: `{"uid":"externalID","readyToStream":true}`,
+			setupMocks: func(mockStore *MockStore,
+				mockCloudflare *MockCloudflareService,
+				mockWebhooks *MockWebhookDispatcher) {
+
+				mockCloudflare.On("VerifyWebhookSignature", "signature",
+					mock.Anything).Return(nil)
+				mockStore.On("GetVideoByExternalID", mock.Anything,
+					"externalID").Return(ready, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "unknown video",
+			body: `{"uid":"unknown","readyToStream":true}`,
+			setupMocks: func(mockStore *MockStore,
+				mockCloudflare *MockCloudflareService,
+				mockWebhooks *MockWebhookDispatcher) {
+
+				mockCloudflare.On("VerifyWebhookSignature", "signature",
+					mock.Anything).Return(nil)
+				mockStore.On("GetVideoByExternalID", mock.Anything,
+					"unknown").Return((*video.Video)(nil),
+					video.ErrVideoNotFound)
+			},
+
+			// Acknowledged so Stream doesn't retry it.
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			mockCloudflare := new(MockCloudflareService)
+			mockWebhooks := new(MockWebhookDispatcher)
+			mockLogger := slog.Default()
+
+			manager := video.NewManager(nil, mockCloudflare, nil, nil, nil,
+				mockWebhooks, nil, mockStore, mockLogger,
+				utils.NewMockClock(), video.DefaultConfig())
+			controller := video.NewController(manager, nil, mockStore,
+				mockLogger, video.DefaultConfig())
+
+			router := gin.New()
+			controller.RegisterPublicRoutes(router)
+
+			tc.setupMocks(mockStore, mockCloudflare, mockWebhooks)
+
+			req, err := http.NewRequest(http.MethodPost,
+				"/video/cloudflare/webhook", bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Webhook-Signature", "signature")
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			mockStore.AssertExpectations(t)
+			mockCloudflare.AssertExpectations(t)
+			mockWebhooks.AssertExpectations(t)
 		})
 	}
 }
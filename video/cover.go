@@ -0,0 +1,272 @@
+package video
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// CoverImagePrefix is the prefix of the storage keys of the cover images of
+// the videos and bundles.
+const CoverImagePrefix = "cover-images/"
+
+const (
+	// contentTypeJPEG is the format of the cover image variants.
+	contentTypeJPEG = "image/jpeg"
+
+	// coverVariantQuality is the JPEG quality of the cover image variants.
+	coverVariantQuality = 85
+)
+
+// ErrInvalidCoverImage is returned for cover images in an unsupported format,
+// over the size limits or that can't be decoded.
+var ErrInvalidCoverImage = errors.New("invalid cover image")
+
+// coverImageDecoders are the decoders of the accepted cover image formats, by
+// their sniffed MIME type.
+var coverImageDecoders = map[string]func(io.Reader) (image.Image, error){
+	"image/jpeg": jpeg.Decode,
+	"image/png":  png.Decode,
+	"image/webp": webp.Decode,
+}
+
+// coverImageDecodeConfigs read the dimensions of the accepted cover image
+// formats without decoding them.
+var coverImageDecodeConfigs = map[string]func(io.Reader) (image.Config,
+	error){
+
+	"image/jpeg": jpeg.DecodeConfig,
+	"image/png":  png.DecodeConfig,
+	"image/webp": webp.DecodeConfig,
+}
+
+// coverVariant is a size the cover images are resized to.
+type coverVariant struct {
+	name          string
+	width, height int
+}
+
+var (
+	// coverVariantCard is shown in the video cards.
+	coverVariantCard = coverVariant{name: "card", width: 640, height: 360}
+
+	// coverVariantHero is shown at the top of the video page.
+	coverVariantHero = coverVariant{name: "hero", width: 1920, height: 1080}
+
+	// coverVariantOG is the OpenGraph preview of the video page.
+	coverVariantOG = coverVariant{name: "og", width: 1200, height: 630}
+)
+
+// CoverVariants are the URLs of the cover image of a video resized for the
+// different places it is shown in.
+type CoverVariants struct {
+	CardURL string `json:"card_url"`
+	HeroURL string `json:"hero_url"`
+	OGURL   string `json:"og_url"`
+}
+
+// coverImage is a cover image validated and decoded.
+type coverImage struct {
+	data        []byte
+	contentType string
+	image       image.Image
+}
+
+// decodeCoverImage reads and decodes an uploaded cover image. Only JPEG, PNG
+// and WebP images, told by their content rather than their name, within the
+// configured size and dimensions are accepted.
+func (m *Manager) decodeCoverImage(header *multipart.FileHeader) (*coverImage,
+	error) {
+
+	if header.Size > m.cfg.MaxCoverImageSize {
+		return nil, fmt.Errorf("%w: larger than %d bytes",
+			ErrInvalidCoverImage, m.cfg.MaxCoverImageSize)
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cover image: %w", err)
+	}
+	defer file.Close()
+
+	// Read one byte over the limit in case the header size is wrong.
+	data, err := io.ReadAll(io.LimitReader(file, m.cfg.MaxCoverImageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cover image: %w", err)
+	}
+	if int64(len(data)) > m.cfg.MaxCoverImageSize {
+		return nil, fmt.Errorf("%w: larger than %d bytes",
+			ErrInvalidCoverImage, m.cfg.MaxCoverImageSize)
+	}
+
+	contentType := http.DetectContentType(data)
+	decode, ok := coverImageDecoders[contentType]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported format %s, only JPEG, "+
+			"PNG and WebP images are accepted", ErrInvalidCoverImage,
+			contentType)
+	}
+
+	// Check the dimensions before decoding so oversized images are not
+	// allocated.
+	config, err := coverImageDecodeConfigs[contentType](bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCoverImage, err)
+	}
+	maxDimension := m.cfg.MaxCoverImageDimension
+	if config.Width > maxDimension || config.Height > maxDimension {
+		return nil, fmt.Errorf("%w: larger than %dx%d pixels",
+			ErrInvalidCoverImage, maxDimension, maxDimension)
+	}
+
+	img, err := decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCoverImage, err)
+	}
+
+	return &coverImage{
+		data:        data,
+		contentType: contentType,
+		image:       img,
+	}, nil
+}
+
+// ProcessAndUploadCoverImage validates the cover image and uploads it with
+// its sniffed content type, returning its URL.
+func (m *Manager) ProcessAndUploadCoverImage(ctx context.Context,
+	externalID string, coverImageHeader *multipart.FileHeader) (string, error) {
+
+	cover, err := m.decodeCoverImage(coverImageHeader)
+	if err != nil {
+		return "", err
+	}
+
+	return m.uploadCoverImage(ctx, externalID, cover)
+}
+
+// uploadCoverImage uploads the cover image as it was received.
+func (m *Manager) uploadCoverImage(ctx context.Context, externalID string,
+	cover *coverImage) (string, error) {
+
+	key := coverImageKey(externalID)
+	err := m.storage.Put(ctx, key, bytes.NewReader(cover.data),
+		cover.contentType)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload cover file: %w", err)
+	}
+
+	return m.storage.PublicURL(key), nil
+}
+
+// uploadCoverVariants resizes the cover image to every variant and uploads
+// them as JPEG images.
+func (m *Manager) uploadCoverVariants(ctx context.Context, externalID string,
+	cover *coverImage) (*CoverVariants, error) {
+
+	upload := func(variant coverVariant) (string, error) {
+		var buf bytes.Buffer
+		err := jpeg.Encode(&buf, resizeCoverImage(cover.image, variant),
+			&jpeg.Options{Quality: coverVariantQuality})
+		if err != nil {
+			return "", fmt.Errorf("failed to encode %s cover: %w",
+				variant.name, err)
+		}
+
+		key := coverVariantKey(externalID, variant)
+		err = m.storage.Put(ctx, key, bytes.NewReader(buf.Bytes()),
+			contentTypeJPEG)
+		if err != nil {
+			return "", fmt.Errorf("failed to upload %s cover: %w",
+				variant.name, err)
+		}
+
+		return m.storage.PublicURL(key), nil
+	}
+
+	var (
+		variants CoverVariants
+		err      error
+	)
+	if variants.CardURL, err = upload(coverVariantCard); err != nil {
+		return nil, err
+	}
+	if variants.HeroURL, err = upload(coverVariantHero); err != nil {
+		return nil, err
+	}
+	if variants.OGURL, err = upload(coverVariantOG); err != nil {
+		return nil, err
+	}
+
+	return &variants, nil
+}
+
+// resizeCoverImage scales the image to cover the size of the variant and
+// crops the overflow evenly from both sides. Transparent areas are filled
+// with white, as JPEG has no alpha channel.
+func resizeCoverImage(img image.Image, variant coverVariant) image.Image {
+	bounds := img.Bounds()
+
+	// Crop the source to the aspect ratio of the variant.
+	crop := bounds
+	if bounds.Dx()*variant.height > bounds.Dy()*variant.width {
+		width := bounds.Dy() * variant.width / variant.height
+		crop.Min.X += (bounds.Dx() - width) / 2
+		crop.Max.X = crop.Min.X + width
+	} else {
+		height := bounds.Dx() * variant.height / variant.width
+		crop.Min.Y += (bounds.Dy() - height) / 2
+		crop.Max.Y = crop.Min.Y + height
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, variant.width, variant.height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White),
+		image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, crop, draw.Over, nil)
+
+	return dst
+}
+
+// coverImageKey returns the storage key of the cover image of a video or
+// bundle.
+func coverImageKey(externalID string) string {
+	return CoverImagePrefix + externalID
+}
+
+// coverVariantKey returns the storage key of a variant of the cover image of
+// a video.
+func coverVariantKey(externalID string, variant coverVariant) string {
+	return fmt.Sprintf("%s%s-%s.jpg", CoverImagePrefix, externalID,
+		variant.name)
+}
+
+// coverImageKeys returns the storage keys of the cover image of a video and
+// its variants.
+func coverImageKeys(externalID string) []string {
+	return []string{
+		coverImageKey(externalID),
+		coverVariantKey(externalID, coverVariantCard),
+		coverVariantKey(externalID, coverVariantHero),
+		coverVariantKey(externalID, coverVariantOG),
+	}
+}
+
+// CoverImageOwner returns the external ID of the video or bundle a cover
+// image or variant belongs to from its storage key.
+func CoverImageOwner(key string) string {
+	externalID := strings.TrimPrefix(key, CoverImagePrefix)
+	externalID, _, _ = strings.Cut(externalID, "-")
+
+	return externalID
+}
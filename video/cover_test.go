@@ -0,0 +1,210 @@
+package video
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStorage keeps the uploaded files in memory.
+type fakeStorage struct {
+	files        map[string][]byte
+	contentTypes map[string]string
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{
+		files:        make(map[string][]byte),
+		contentTypes: make(map[string]string),
+	}
+}
+
+func (s *fakeStorage) Put(_ context.Context, key string, body io.ReadSeeker,
+	contentType string) error {
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	s.files[key] = data
+	s.contentTypes[key] = contentType
+
+	return nil
+}
+
+func (s *fakeStorage) PublicURL(key string) string {
+	return "https://cdn.example.com/" + key
+}
+
+func (s *fakeStorage) Delete(_ context.Context, key string) error {
+	delete(s.files, key)
+	return nil
+}
+
+// coverImageHeader returns the file header of a cover image uploaded in a
+// multipart form.
+func coverImageHeader(t *testing.T, data []byte) *multipart.FileHeader {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("cover_image", "cover.png")
+	require.NoError(t, err)
+	_, err = part.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest("POST", "/", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	require.NoError(t, req.ParseMultipartForm(1<<20))
+
+	return req.MultipartForm.File["cover_image"][0]
+}
+
+// encodeImage returns a uniform image of the given size in the format of the
+// encoder.
+func encodeImage(t *testing.T, width, height int,
+	encode func(io.Writer, image.Image) error) []byte {
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: 200, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, encode(&buf, img))
+
+	return buf.Bytes()
+}
+
+func encodeJPEG(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, nil)
+}
+
+func encodeGIF(w io.Writer, img image.Image) error {
+	return gif.Encode(w, img, nil)
+}
+
+// TestDecodeCoverImage tests that only JPEG, PNG and WebP cover images within
+// the size limits are accepted, whatever their file name.
+func TestDecodeCoverImage(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxCoverImageSize = 64 << 10
+	cfg.MaxCoverImageDimension = 2000
+	m := &Manager{cfg: cfg}
+
+	cover, err := m.decodeCoverImage(coverImageHeader(t,
+		encodeImage(t, 800, 600, png.Encode)))
+	require.NoError(t, err)
+	require.Equal(t, "image/png", cover.contentType)
+	require.Equal(t, 800, cover.image.Bounds().Dx())
+
+	cover, err = m.decodeCoverImage(coverImageHeader(t,
+		encodeImage(t, 800, 600, encodeJPEG)))
+	require.NoError(t, err)
+	require.Equal(t, "image/jpeg", cover.contentType)
+
+	// Other formats are rejected even when named like an image.
+	_, err = m.decodeCoverImage(coverImageHeader(t,
+		encodeImage(t, 800, 600, encodeGIF)))
+	require.ErrorIs(t, err, ErrInvalidCoverImage)
+
+	_, err = m.decodeCoverImage(coverImageHeader(t,
+		[]byte("<html>not an image</html>")))
+	require.ErrorIs(t, err, ErrInvalidCoverImage)
+
+	// So are the images over the limits.
+	_, err = m.decodeCoverImage(coverImageHeader(t,
+		encodeImage(t, 2001, 10, png.Encode)))
+	require.ErrorIs(t, err, ErrInvalidCoverImage)
+
+	_, err = m.decodeCoverImage(coverImageHeader(t,
+		make([]byte, cfg.MaxCoverImageSize+1)))
+	require.ErrorIs(t, err, ErrInvalidCoverImage)
+
+	// And the truncated ones.
+	data := encodeImage(t, 800, 600, png.Encode)
+	_, err = m.decodeCoverImage(coverImageHeader(t, data[:len(data)/2]))
+	require.ErrorIs(t, err, ErrInvalidCoverImage)
+}
+
+// TestUploadCoverVariants tests that the cover image is uploaded as received
+// and resized to every variant, with their content types.
+func TestUploadCoverVariants(t *testing.T) {
+	ctx := context.Background()
+	storage := newFakeStorage()
+	m := &Manager{cfg: DefaultConfig(), storage: storage,
+		logger: slog.Default()}
+
+	data := encodeImage(t, 1000, 1000, png.Encode)
+	cover, err := m.decodeCoverImage(coverImageHeader(t, data))
+	require.NoError(t, err)
+
+	coverURL, err := m.uploadCoverImage(ctx, "video", cover)
+	require.NoError(t, err)
+	require.Equal(t, "https://cdn.example.com/cover-images/video", coverURL)
+	require.Equal(t, data, storage.files["cover-images/video"])
+	require.Equal(t, "image/png", storage.contentTypes["cover-images/video"])
+
+	variants, err := m.uploadCoverVariants(ctx, "video", cover)
+	require.NoError(t, err)
+	require.Equal(t, &CoverVariants{
+		CardURL: "https://cdn.example.com/cover-images/video-card.jpg",
+		HeroURL: "https://cdn.example.com/cover-images/video-hero.jpg",
+		OGURL:   "https://cdn.example.com/cover-images/video-og.jpg",
+	}, variants)
+
+	for _, variant := range []coverVariant{
+		coverVariantCard, coverVariantHero, coverVariantOG,
+	} {
+		key := coverVariantKey("video", variant)
+		require.Equal(t, "image/jpeg", storage.contentTypes[key])
+
+		img, err := jpeg.Decode(bytes.NewReader(storage.files[key]))
+		require.NoError(t, err)
+		require.Equal(t, variant.width, img.Bounds().Dx())
+		require.Equal(t, variant.height, img.Bounds().Dy())
+	}
+
+	// Every key belongs to the video.
+	keys := coverImageKeys("video")
+	require.Len(t, keys, len(storage.files))
+	for _, key := range keys {
+		require.Contains(t, storage.files, key)
+		require.Equal(t, "video", CoverImageOwner(key))
+	}
+}
+
+// TestResizeCoverImage tests that the images are cropped evenly to the aspect
+// ratio of the variant and that transparency is filled with white.
+func TestResizeCoverImage(t *testing.T) {
+	// A wide image with red sides and a transparent center.
+	img := image.NewRGBA(image.Rect(0, 0, 400, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 400; x++ {
+			if x < 100 || x >= 300 {
+				img.Set(x, y, color.RGBA{R: 255, A: 255})
+			}
+		}
+	}
+
+	// The square variant keeps the center only.
+	variant := coverVariant{name: "square", width: 50, height: 50}
+	resized := resizeCoverImage(img, variant)
+	require.Equal(t, image.Rect(0, 0, 50, 50), resized.Bounds())
+
+	r, g, b, _ := resized.At(25, 25).RGBA()
+	require.Equal(t, []uint32{0xffff, 0xffff, 0xffff}, []uint32{r, g, b})
+}
@@ -3,26 +3,49 @@ package video
 import (
 	"context"
 	"io"
+	"net/http"
 	"time"
 
 	"github.com/cloudflare/cloudflare-go"
+	"github.com/fewsats/blockbuster/jobs"
 	"github.com/fewsats/blockbuster/l402"
+	"github.com/fewsats/blockbuster/orders"
 )
 
 type Authenticator interface {
 	ValidateSignature(pubKeyHex, signatureHex, domain string,
 		timestamp int64) error
 
+	// ValidateActionSignature validates a signature of
+	// "<domain>:<action>:<params...>:<timestamp>".
+	ValidateActionSignature(pubKeyHex, signatureHex, domain string,
+		timestamp int64, action string, params ...string) error
+
 	NewChallenge(ctx context.Context, domain, pubKeyHex string,
 		priceInCents uint64, caveats map[string]string) (*l402.Challenge, error)
 
-	ValidateL402Credentials(ctx context.Context,
-		authHeader string) (string, error)
+	ValidateL402Credentials(ctx context.Context, authHeader string,
+		checkers map[string]l402.CaveatChecker) (string, error)
+
+	// PaidCredentials returns the macaroon and the preimage of the
+	// credentials for the given payment hash and public key, or
+	// l402.ErrInvoiceNotPaid if the invoice has not been paid.
+	PaidCredentials(ctx context.Context, paymentHash,
+		pubKeyHex string) (string, string, error)
+
+	// RestoreCredentials returns every paid and unexpired credential
+	// minted for the given public key.
+	RestoreCredentials(ctx context.Context,
+		pubKeyHex string) ([]*l402.StoredCredentials, error)
 }
 
 type Store interface {
 	GetOrCreateUserByEmail(ctx context.Context, email string) (int64, error)
 
+	// GetUserSubscriptionPrice returns the monthly subscription price of a
+	// creator or zero if subscriptions are disabled.
+	GetUserSubscriptionPrice(ctx context.Context, userID int64) (int64, error)
+
 	CreateVideo(ctx context.Context, params CreateVideoParams) (*Video, error)
 	// UpdateCloudflareInfo updates the video with info retrieved from Cloudflare.
 	// like the "readyToStream" status.
@@ -32,41 +55,230 @@ type Store interface {
 	GetVideoByExternalID(ctx context.Context, externalID string) (*Video, error)
 	ListUserVideos(ctx context.Context, userID int64) ([]*Video, error)
 
-	// IncrementVideoViews increments the views of a video by 1.
-	IncrementVideoViews(ctx context.Context, externalID string) error
-
 	// UpdateVideoInfo updates the video info the title, description and price.
 	UpdateVideoInfo(ctx context.Context, externalID string,
 		params *UpdateVideoInfoParams) (*Video, error)
-	DeleteVideo(ctx context.Context, externalID string) error
+
+	// DeleteVideo moves the video to the trash until purgeAt.
+	DeleteVideo(ctx context.Context, externalID string,
+		purgeAt time.Time) error
+
+	// GetTrashedVideo returns the deleted video with the given external
+	// ID, or ErrVideoNotFound if it is not in the trash.
+	GetTrashedVideo(ctx context.Context, externalID string) (*Video, error)
+
+	// ListUserTrashedVideos returns the external IDs of the deleted
+	// videos of a user that have not been purged yet.
+	ListUserTrashedVideos(ctx context.Context, userID int64) ([]string,
+		error)
+
+	// RestoreVideo moves the video of the given user out of the trash,
+	// returning ErrVideoNotFound if it is not there or was purged.
+	RestoreVideo(ctx context.Context, userID int64,
+		externalID string) error
+
+	// MarkVideoPurged records that the assets of a trashed video were
+	// deleted.
+	MarkVideoPurged(ctx context.Context, externalID string) error
+
+	// UpsertVideoRental creates or updates the rental option of a video
+	// with the given duration.
+	UpsertVideoRental(ctx context.Context, externalID string,
+		durationInHours, priceInCents int64) (*Rental, error)
+
+	// ListVideoRentals returns the rental options of a video sorted by
+	// duration.
+	ListVideoRentals(ctx context.Context, externalID string) ([]*Rental, error)
+
+	// DeleteVideoRental removes the rental option of a video with the given
+	// duration.
+	DeleteVideoRental(ctx context.Context, externalID string,
+		durationInHours int64) error
+
+	// CreateBundle creates a new bundle with its videos in the given order.
+	CreateBundle(ctx context.Context, params CreateBundleParams) (*Bundle,
+		error)
+
+	// GetBundleByExternalID returns a bundle that has not been deleted or
+	// ErrBundleNotFound.
+	GetBundleByExternalID(ctx context.Context, externalID string) (*Bundle,
+		error)
+
+	// GetPurchasedBundle returns a bundle, even if it has been deleted,
+	// with all its videos, deleted ones included, or ErrBundleNotFound.
+	GetPurchasedBundle(ctx context.Context, externalID string) (*Bundle,
+		error)
+	ListUserBundles(ctx context.Context, userID int64) ([]*Bundle, error)
+
+	// UpdateBundle updates the info of a bundle and replaces its videos
+	// when new ones are given. It returns ErrBundleHasSales when that
+	// removes videos from a bundle that has been offered.
+	UpdateBundle(ctx context.Context, externalID string,
+		params *UpdateBundleParams) (*Bundle, error)
+	DeleteBundle(ctx context.Context, externalID string) error
+
+	// IsBundleVideo returns whether the video is part of the bundle, even if
+	// the bundle has been deleted.
+	IsBundleVideo(ctx context.Context, bundleExternalID,
+		externalID string) (bool, error)
+
+	// CreatePromoCode creates a new promo code scoped to the given videos.
+	CreatePromoCode(ctx context.Context, params CreatePromoCodeParams) (
+		*PromoCode, error)
+
+	// GetPromoCode returns the promo code of a user or
+	// ErrPromoCodeNotFound.
+	GetPromoCode(ctx context.Context, userID int64, code string) (*PromoCode,
+		error)
+	ListUserPromoCodes(ctx context.Context, userID int64) ([]*PromoCode,
+		error)
+
+	// DeletePromoCode removes a promo code of a user or returns
+	// ErrPromoCodeNotFound.
+	DeletePromoCode(ctx context.Context, userID int64, code string) error
 }
 
-// NotificationService is the interface for sending notifications.
+// NotificationService is the interface for sending notifications. Purchases
+// are notified by the orders manager.
 type NotificationService interface {
-	// RegisterNewPurchaseEvent sends a notification for a new purchase.
-	RegisterNewPurchaseEvent(externalID, paymentHash, email string) error
+	// RegisterNewVideoUploadEvent notifies the creator of a new video upload.
+	RegisterNewVideoUploadEvent(ctx context.Context, userID int64,
+		externalID string) error
 
-	// RegisterNewVideoUploadEvent sends a notification for a new video upload.
-	RegisterNewVideoUploadEvent(externalID, email string) error
+	// RegisterNewVideoImportEvent notifies the creator of a new video
+	// import.
+	RegisterNewVideoImportEvent(ctx context.Context, userID int64,
+		externalID string) error
+}
+
+// WebhookDispatcher is the interface for pushing events to the webhook
+// endpoints of the users.
+type WebhookDispatcher interface {
+	// Enqueue schedules the delivery of an event of the user.
+	Enqueue(ctx context.Context, userID int64, eventType string,
+		data any) error
 }
 
 type OrdersMgr interface {
 	// CreateOffer creates a new offer.
-	CreateOffer(ctx context.Context, userID int64,
-		PriceInCents uint64, externalID, paymentHash string) error
+	CreateOffer(ctx context.Context, params *orders.CreateOfferParams) error
+
+	// ListRecipientOffers returns the most recent offers for the given
+	// service whose credentials are bound to the recipient public key.
+	ListRecipientOffers(ctx context.Context, externalID,
+		recipientPubKey string, limit int64) ([]*orders.Offer, error)
+
+	// ListRecipientPurchases returns the purchases made for the given
+	// public key, most recent first.
+	ListRecipientPurchases(ctx context.Context,
+		recipientPubKey string) ([]*orders.Purchase, error)
 
 	// RecordPurchase creates a new purchase if there is not one already for
 	// the given payment hash.
 	RecordPurchase(ctx context.Context, paymentHash, serviceType string) error
+
+	// RecordPurchaseAndView creates a new purchase if there is not one
+	// already for the given payment hash and counts a new view for the
+	// video, atomically.
+	RecordPurchaseAndView(ctx context.Context, paymentHash, serviceType,
+		externalID string) error
+}
+
+// StreamURLs are the signed URLs a video can be played from.
+type StreamURLs struct {
+	HLSURL  string `json:"hls_url,omitempty"`
+	DashURL string `json:"dash_url,omitempty"`
+
+	// MP4URL is the URL of the video file, for the backends that serve
+	// it without transcoding.
+	MP4URL string `json:"mp4_url,omitempty"`
+}
+
+// StreamURLOptions restrict the signed URLs of a stream.
+type StreamURLOptions struct {
+	// ExpiresAt is when the credentials paying for the stream expire, the
+	// URLs must not outlive them. Nil if they don't expire.
+	ExpiresAt *time.Time
+
+	// ClientIP is the IP of the viewer, which backends can bind the URLs
+	// to.
+	ClientIP string
+}
+
+// UploadOptions are the options of the upload of a video.
+type UploadOptions struct {
+	// Name is the name of the video in the streaming backend.
+	Name string
+
+	// Resumable requests a tus resumable upload, for backends that
+	// support them.
+	Resumable bool
+
+	// SizeInBytes is the size of the video file, required by the
+	// resumable uploads.
+	SizeInBytes int64
+}
+
+// Upload is where the file of a new video is uploaded to.
+type Upload struct {
+	ExternalID string
+	URL        string
+
+	// Resumable is set when the URL is a tus upload to resume on
+	// failures, rather than a URL to POST the file to.
+	Resumable bool
 }
 
 type CloudflareService interface {
 	GetStreamVideoInfo(ctx context.Context,
 		externalID string) (*cloudflare.StreamVideo, error)
-	GenerateVideoUploadURL(ctx context.Context) (string, string, error)
-	UploadPublicFile(ctx context.Context, key, prefix string,
-		reader io.ReadSeeker) (string, error)
-	GenerateStreamURL(ctx context.Context, externalID string) (string, string, error)
+
+	// GenerateVideoUploadURL creates a video of the given creator and
+	// returns where to upload its file to.
+	GenerateVideoUploadURL(ctx context.Context, userID int64,
+		opts *UploadOptions) (*Upload, error)
+
+	// ImportVideo creates a video of the given creator copied from the
+	// source URL in the background and returns its external ID.
+	ImportVideo(ctx context.Context, userID int64, sourceURL,
+		name string) (string, error)
+
+	// GenerateStreamURL returns the signed URLs to stream a video.
+	GenerateStreamURL(ctx context.Context, externalID string,
+		opts *StreamURLOptions) (*StreamURLs, error)
+
+	// DeleteVideo deletes the video from the backend. Deleting a video
+	// that does not exist is not an error.
+	DeleteVideo(ctx context.Context, externalID string) error
+
+	// VerifyWebhookSignature checks the signature in the headers of a
+	// Stream webhook against its body.
+	VerifyWebhookSignature(headers http.Header, body []byte) error
+
+	// MaxDuration returns the maximum duration of the videos of the given
+	// creator, zero if they are not limited.
+	MaxDuration(userID int64) time.Duration
+}
+
+// Storage stores the public files of the videos, e.g. their cover images.
+type Storage interface {
+	// Put uploads the file under the given key.
+	Put(ctx context.Context, key string, body io.ReadSeeker,
+		contentType string) error
+
+	// PublicURL returns the URL the file is served from.
+	PublicURL(key string) string
+
+	// Delete removes the file.
+	Delete(ctx context.Context, key string) error
+}
+
+// JobQueue runs the background work of the videos, like purging the deleted
+// ones.
+type JobQueue interface {
+	Register(kind string, handler jobs.Handler)
+	EnqueueAt(ctx context.Context, kind string, payload any,
+		runAt time.Time) (*jobs.Job, error)
 }
 
 type CreateVideoParams struct {
@@ -77,6 +289,17 @@ type CreateVideoParams struct {
 	VideoURL     string
 	CoverURL     string
 	PriceInCents int64
+
+	// CoverVariants are the URLs of the resized cover images.
+	CoverVariants *CoverVariants
+
+	// PayWhatYouWant lets buyers pay any amount of at least PriceInCents.
+	PayWhatYouWant        bool
+	SuggestedPriceInCents int64
+
+	// Status is the initial status of the video, awaiting its upload if
+	// empty.
+	Status string
 }
 
 type CloudflareVideoInfo struct {
@@ -88,6 +311,11 @@ type CloudflareVideoInfo struct {
 	ReadyToStream     bool
 	HLSURL            string
 	DashURL           string
+
+	// Status is one of the video statuses and StatusReason explains the
+	// failed and expired ones.
+	Status       string
+	StatusReason string
 }
 
 type Video struct {
@@ -104,6 +332,14 @@ type Video struct {
 	TotalViews     int64  `json:"total_views"`
 	TotalPurchases int64  `json:"total_purchases"`
 
+	// CoverVariants are the resized cover images, nil for the videos
+	// created before they existed.
+	CoverVariants *CoverVariants `json:"cover_variants,omitempty"`
+
+	// PayWhatYouWant videos use PriceInCents as the minimum price.
+	PayWhatYouWant        bool  `json:"pay_what_you_want"`
+	SuggestedPriceInCents int64 `json:"suggested_price_in_cents"`
+
 	ThumbnailURL      string  `json:"-"`
 	HlsURL            string  `json:"-"`
 	DashURL           string  `json:"-"`
@@ -112,12 +348,159 @@ type Video struct {
 	InputHeight       int32   `json:"input_height"`
 	InputWidth        int32   `json:"input_width"`
 
-	ReadyToStream bool      `json:"ready_to_stream"`
-	CreatedAt     time.Time `json:"created_at"`
+	ReadyToStream bool `json:"ready_to_stream"`
+
+	// Status is the processing status of the video and StatusReason
+	// explains the failed and expired ones.
+	Status       string `json:"status"`
+	StatusReason string `json:"status_reason,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+
+	// DeletedAt is set for the videos in the trash, which are purged at
+	// PurgeAt unless restored. PurgedAt is set once their assets are
+	// deleted.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	PurgeAt   *time.Time `json:"purge_at,omitempty"`
+	PurgedAt  *time.Time `json:"-"`
+}
+
+// Rental is a time-limited access option to a video.
+type Rental struct {
+	DurationInHours int64 `json:"duration_in_hours"`
+	PriceInCents    int64 `json:"price_in_cents"`
+}
+
+// PricingOption is one of the priced ways to access a video.
+type PricingOption struct {
+	// Key identifies the option when requesting a challenge.
+	Key string
+
+	// Type is the kind of access: purchase or rental.
+	Type string
+
+	// PriceInCents is the price of the option.
+	PriceInCents int64
+
+	// Duration is the time the option grants access to the video.
+	Duration time.Duration
+
+	// PayWhatYouWant options accept any amount of at least
+	// MinPriceInCents, PriceInCents being the suggested one.
+	PayWhatYouWant  bool
+	MinPriceInCents int64
 }
 
 type UpdateVideoInfoParams struct {
 	Title        string
 	Description  string
 	PriceInCents int64
+
+	// PayWhatYouWant is left unchanged when nil.
+	PayWhatYouWant        *bool
+	SuggestedPriceInCents int64
+}
+
+// Bundle is an ordered list of videos of a creator sold as a single product.
+type Bundle struct {
+	ID          int64  `json:"-"`
+	ExternalID  string `json:"external_id"`
+	UserID      int64  `json:"-"`
+	L402URL     string `json:"l402_url"`
+	L402InfoURI string `json:"l402_info_uri"`
+
+	Title        string   `json:"title"`
+	Description  string   `json:"description"`
+	CoverURL     string   `json:"cover_url"`
+	PriceInCents int64    `json:"price_in_cents"`
+	VideoIDs     []string `json:"video_ids"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateBundleParams struct {
+	ExternalID   string
+	UserID       int64
+	Title        string
+	Description  string
+	CoverURL     string
+	PriceInCents int64
+	VideoIDs     []string
+}
+
+type UpdateBundleParams struct {
+	Title        string
+	Description  string
+	PriceInCents int64
+
+	// VideoIDs replaces the videos of the bundle unless it is nil.
+	VideoIDs []string
+}
+
+// PromoCode is a discount a creator offers on the price of their videos.
+type PromoCode struct {
+	ID     int64 `json:"-"`
+	UserID int64 `json:"-"`
+
+	Code string `json:"code"`
+
+	// DiscountType is either percentage or fixed.
+	DiscountType string `json:"discount_type"`
+
+	// DiscountValue is the percentage or the amount in cents discounted.
+	DiscountValue int64 `json:"discount_value"`
+
+	// ExpiresAt is nil for codes that do not expire.
+	ExpiresAt *time.Time `json:"expires_at"`
+
+	// MaxRedemptions is zero for codes without a limit.
+	MaxRedemptions int64 `json:"max_redemptions"`
+	Redemptions    int64 `json:"redemptions"`
+
+	// VideoIDs is empty for codes valid for all the videos of the creator.
+	VideoIDs []string `json:"video_ids"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreatePromoCodeParams struct {
+	UserID         int64
+	Code           string
+	DiscountType   string
+	DiscountValue  int64
+	ExpiresAt      *time.Time
+	MaxRedemptions int64
+	VideoIDs       []string
+}
+
+// Credentials are the paid L402 credentials recovered by the public key they
+// are bound to.
+type Credentials struct {
+	PaymentHash string `json:"payment_hash"`
+	Macaroon    string `json:"macaroon"`
+	Preimage    string `json:"preimage"`
+
+	// PayerPubKey is the public key that paid the credentials. It differs
+	// from the recovering one for gifts.
+	PayerPubKey string `json:"payer_pub_key,omitempty"`
+
+	// ExpirationDate is when the credentials stop granting access, nil
+	// when it is not known.
+	ExpirationDate *time.Time `json:"expiration_date"`
+}
+
+// LibraryVideo is a video a public key has access to thanks to a purchase.
+type LibraryVideo struct {
+	*Video
+
+	// ServiceType is the type of the purchase granting access: a single
+	// video, a bundle or a subscription.
+	ServiceType string `json:"service_type"`
+
+	// PurchasedAt is when the purchase was recorded.
+	PurchasedAt time.Time `json:"purchased_at"`
+
+	// ExpirationDate is nil for purchases that do not expire, like the ones
+	// of whole videos.
+	ExpirationDate *time.Time `json:"expiration_date"`
 }
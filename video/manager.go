@@ -1,63 +1,265 @@
 package video
 
 import (
-	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log/slog"
-	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/cloudflare/cloudflare-go"
 	"github.com/fewsats/blockbuster/l402"
+	"github.com/fewsats/blockbuster/orders"
 	"github.com/fewsats/blockbuster/utils"
+	"github.com/fewsats/blockbuster/webhooks"
+)
+
+var (
+	// ErrSubscriptionsDisabled is returned when the creator of a video does
+	// not offer subscriptions.
+	ErrSubscriptionsDisabled = errors.New("creator does not offer subscriptions")
+
+	// ErrUnknownPricingOption is returned when a challenge is requested for
+	// a pricing option the video does not offer.
+	ErrUnknownPricingOption = errors.New("unknown pricing option")
+
+	// ErrInvalidWebhookSignature is returned when a Stream webhook is not
+	// signed by Cloudflare.
+	ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+
+	// ErrInvalidAmount is returned when a challenge is requested for an
+	// amount the pricing option does not accept.
+	ErrInvalidAmount = errors.New("invalid amount")
+
+	// ErrVideoNotFound is returned when a video does not exist or has been
+	// deleted.
+	ErrVideoNotFound = errors.New("video not found")
+
+	// ErrBundleNotFound is returned when a bundle does not exist or has been
+	// deleted.
+	ErrBundleNotFound = errors.New("bundle not found")
+
+	// ErrInvalidBundleVideos is returned when the videos of a bundle are
+	// empty, repeated or not owned by the creator of the bundle.
+	ErrInvalidBundleVideos = errors.New("invalid bundle videos")
+
+	// ErrBundleHasSales is returned when removing videos from a bundle that
+	// has already been sold, as its buyers would lose access to them.
+	ErrBundleHasSales = errors.New("videos can't be removed from a bundle " +
+		"that has been sold")
+
+	// ErrPromoCodeNotFound is returned when a creator has no promo code with
+	// the given code.
+	ErrPromoCodeNotFound = errors.New("promo code not found")
+
+	// ErrPromoCodeExists is returned when a creator already has a promo code
+	// with the given code.
+	ErrPromoCodeExists = errors.New("promo code already exists")
+
+	// ErrInvalidPromoCode is returned when a promo code can not be applied
+	// to a video: it does not exist, it has expired, it has no redemptions
+	// left or it is not valid for the video.
+	ErrInvalidPromoCode = errors.New("invalid promo code")
+
+	// ErrInvalidPromoCodeVideos is returned when the videos a promo code is
+	// scoped to are repeated or not owned by the creator of the code.
+	ErrInvalidPromoCodeVideos = errors.New("invalid promo code videos")
+)
+
+const (
+	// subscriptionPrefix is the prefix of the external IDs of
+	// subscriptions.
+	subscriptionPrefix = "subscription-"
+
+	// SubscriptionTitle is how the subscriptions to a creator are titled
+	// for the creator.
+	SubscriptionTitle = "Subscription to your videos"
 )
 
 // Manager is the main video service interface.
 type Manager struct {
 	orders        OrdersMgr
 	cf            CloudflareService
+	storage       Storage
 	authenticator Authenticator
+	notifier      NotificationService
+	webhooks      WebhookDispatcher
+	queue         JobQueue
 
+	cfg    *Config
 	store  Store
 	clock  utils.Clock
 	logger *slog.Logger
 }
 
 // NewManager creates a new storage service.
-func NewManager(orders OrdersMgr, cf CloudflareService,
-	authenticator Authenticator, store Store, logger *slog.Logger,
-	clock utils.Clock) *Manager {
+func NewManager(orders OrdersMgr, cf CloudflareService, storage Storage,
+	authenticator Authenticator, notifier NotificationService,
+	webhooks WebhookDispatcher, queue JobQueue, store Store,
+	logger *slog.Logger, clock utils.Clock, cfg *Config) *Manager {
 
-	return &Manager{
+	m := &Manager{
 		authenticator: authenticator,
 		cf:            cf,
+		storage:       storage,
 		orders:        orders,
+		notifier:      notifier,
+		webhooks:      webhooks,
+		queue:         queue,
 
+		cfg:    cfg,
 		clock:  clock,
 		store:  store,
 		logger: logger,
 	}
+
+	if queue != nil {
+		queue.Register(jobPurgeVideo, m.purgeVideo)
+	}
+
+	return m
+}
+
+// IsVideoReady returns the video if it is ready to stream. Videos that have
+// not reached a final status are checked on Cloudflare first, in case their
+// Stream webhook was missed. Otherwise, the returned error explains the
+// status of the video.
+func (m *Manager) IsVideoReady(ctx context.Context, externalID string) (*Video, error) {
+	video, err := m.RefreshVideoStatus(ctx, externalID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !video.ReadyToStream {
+		return nil, statusError(video)
+	}
+
+	return video, nil
 }
 
-// updateVideoIfNeeded retrieves the video info from cloudflare and updates the video in the database
-// if the video is not ready to stream.
-// When uploaded videos are set as not ready to stream by default.
-func (m *Manager) IsVideoReady(ctx context.Context, externalID string) error {
+// RefreshVideoStatus returns the video after checking the status of the ones
+// that have not reached a final status on the streaming backend.
+func (m *Manager) RefreshVideoStatus(ctx context.Context,
+	externalID string) (*Video, error) {
+
 	video, err := m.store.GetVideoByExternalID(ctx, externalID)
+	if errors.Is(err, ErrVideoNotFound) {
+		// The buyers of a deleted video keep streaming it until it is
+		// purged.
+		video, err = m.streamableTrashedVideo(ctx, externalID)
+	}
 	if err != nil {
 		m.logger.Error("Failed to get video by ID", "error", err)
-		return fmt.Errorf("failed to fetch video: %w", err)
+		return nil, fmt.Errorf("failed to fetch video: %w", err)
 	}
-	// first time the video is accessed we'll populate it with the cloudflare info
-	if !video.ReadyToStream {
-		videoInfo, err := m.cf.GetStreamVideoInfo(ctx, externalID)
+
+	if video.ReadyToStream || !canTransition(video.Status, StatusReady) {
+		return video, nil
+	}
+
+	videoInfo, err := m.cf.GetStreamVideoInfo(ctx, externalID)
+	if err != nil {
+		m.logger.Error("Failed to get video info", "error", err)
+		return nil, fmt.Errorf("failed to get video info: %w", err)
+	}
+
+	video, err = m.updateCloudflareInfo(ctx, video, videoInfo)
+	if err != nil {
+		m.logger.Error("Failed to update video", "error", err)
+		return nil, fmt.Errorf("failed to update video: %w", err)
+	}
+
+	return video, nil
+}
+
+// ExpireUpload expires a video whose upload window passed. The info listed by
+// the streaming backend, nil if it has no such video, is stored first in case
+// the upload did complete. Videos that are no longer awaiting their upload
+// are returned unchanged.
+func (m *Manager) ExpireUpload(ctx context.Context, video *Video,
+	videoInfo *cloudflare.StreamVideo) (*Video, error) {
+
+	if videoInfo != nil {
+		updated, err := m.updateCloudflareInfo(ctx, video, videoInfo)
 		if err != nil {
-			m.logger.Error("Failed to get video info", "error", err)
-			return fmt.Errorf("failed to get video info: %w", err)
+			return nil, fmt.Errorf("failed to update video: %w", err)
 		}
+		video = updated
+	}
+
+	if video.Status == StatusExpired ||
+		!canTransition(video.Status, StatusExpired) {
+
+		return video, nil
+	}
+
+	return m.store.UpdateCloudflareInfo(ctx, video.ExternalID,
+		&CloudflareVideoInfo{
+			Status:       StatusExpired,
+			StatusReason: uploadExpiredReason,
+		},
+	)
+}
+
+// HandleStreamWebhook verifies a Stream webhook and stores the state of the
+// video it reports on, so the video is ready before its first stream.
+func (m *Manager) HandleStreamWebhook(ctx context.Context,
+	headers http.Header, body []byte) (*Video, error) {
+
+	err := m.cf.VerifyWebhookSignature(headers, body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidWebhookSignature, err)
+	}
 
-		video, err = m.store.UpdateCloudflareInfo(ctx, externalID, &CloudflareVideoInfo{
+	var videoInfo cloudflare.StreamVideo
+	if err := json.Unmarshal(body, &videoInfo); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook: %w", err)
+	}
+
+	video, err := m.store.GetVideoByExternalID(ctx, videoInfo.UID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Webhooks can be delivered more than once.
+	if video.ReadyToStream {
+		return video, nil
+	}
+
+	return m.updateCloudflareInfo(ctx, video, &videoInfo)
+}
+
+// updateCloudflareInfo moves the video to the status reported by Cloudflare,
+// notifying the creator when it becomes ready to stream. Reports that would
+// move a video out of a final status are ignored.
+func (m *Manager) updateCloudflareInfo(ctx context.Context, video *Video,
+	videoInfo *cloudflare.StreamVideo) (*Video, error) {
+
+	status, reason := streamStatus(videoInfo, m.clock.Now())
+
+	// Stream limits the duration of the uploads but not of the imports,
+	// which are only checked once processed.
+	if status == StatusReady && m.exceedsMaxDuration(video, videoInfo) {
+		status, reason = StatusFailed, maxDurationReason
+	}
+
+	if !canTransition(video.Status, status) {
+		m.logger.Warn("Ignoring video status transition",
+			"externalID", video.ExternalID,
+			"from", video.Status,
+			"to", status)
+
+		return video, nil
+	}
+
+	updated, err := m.store.UpdateCloudflareInfo(ctx, video.ExternalID,
+		&CloudflareVideoInfo{
 			ThumbnailURL:      videoInfo.Thumbnail,
 			DashURL:           videoInfo.Playback.Dash,
 			HLSURL:            videoInfo.Playback.HLS,
@@ -65,58 +267,279 @@ func (m *Manager) IsVideoReady(ctx context.Context, externalID string) error {
 			SizeInBytes:       int64(videoInfo.Size),
 			InputHeight:       int32(videoInfo.Input.Height),
 			InputWidth:        int32(videoInfo.Input.Width),
-			ReadyToStream:     videoInfo.ReadyToStream,
-		})
+			ReadyToStream:     status == StatusReady,
+			Status:            status,
+			StatusReason:      reason,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if reason != "" {
+		m.logger.Warn("Video processing did not complete",
+			"externalID", updated.ExternalID,
+			"status", status,
+			"reason", reason)
+	}
+
+	if updated.ReadyToStream && !video.ReadyToStream {
+		m.enqueueWebhook(ctx, updated.UserID, webhooks.EventVideoReady,
+			updated)
+	}
+
+	// The video is never streamed, so its copy is not kept.
+	if reason == maxDurationReason {
+		err := m.cf.DeleteVideo(ctx, updated.ExternalID)
 		if err != nil {
-			m.logger.Error("Failed to update video", "error", err)
-			return fmt.Errorf("failed to update video: %w", err)
+			m.logger.Error("Failed to delete video over max duration",
+				"externalID", updated.ExternalID, "error", err)
 		}
 	}
 
-	// We already attempted to update the info, if video ain't ready here, it aint ready.
-	if !video.ReadyToStream {
-		return fmt.Errorf("video is not ready to stream: %w", err)
+	return updated, nil
+}
+
+// exceedsMaxDuration reports whether the processed video is longer than the
+// maximum duration of its creator.
+func (m *Manager) exceedsMaxDuration(video *Video,
+	videoInfo *cloudflare.StreamVideo) bool {
+
+	maxDuration := m.cf.MaxDuration(video.UserID)
+	if maxDuration <= 0 {
+		return false
 	}
 
-	return nil
+	duration := time.Duration(videoInfo.Duration * float64(time.Second))
+
+	return duration > maxDuration
 }
 
-func (m *Manager) GenerateStreamURL(ctx context.Context,
-	externalID string) (string, string, error) {
+// Access describes how a set of L402 credentials grants access to a video.
+type Access struct {
+	// ServiceType is the type of the purchase that granted the access.
+	ServiceType string
+
+	// ExpiresAt is when the credentials expire, nil if they don't.
+	ExpiresAt *time.Time
+}
+
+// AccessCheckers returns the caveat checkers for L402 credentials granting
+// access to the given video: credentials for the video itself, a bundle that
+// contains it or a subscription to its creator. The access is filled in while
+// the caveats are checked.
+func (m *Manager) AccessCheckers(ctx context.Context, video *Video,
+	access *Access) map[string]l402.CaveatChecker {
+
+	access.ServiceType = ServiceTypeVideos
+
+	return map[string]l402.CaveatChecker{
+		CaveatExternalID: func(value string) error {
+			if value != video.ExternalID {
+				return fmt.Errorf("credentials not valid for video %s",
+					video.ExternalID)
+			}
 
-	HLSURL, DashURL, err := m.cf.GenerateStreamURL(ctx, externalID)
+			return nil
+		},
+		CaveatCreatorID: func(value string) error {
+			if value != strconv.FormatInt(video.UserID, 10) {
+				return fmt.Errorf("subscription not valid for video %s",
+					video.ExternalID)
+			}
+
+			access.ServiceType = ServiceTypeSubscriptions
+			return nil
+		},
+		CaveatBundleID: func(value string) error {
+			isMember, err := m.store.IsBundleVideo(ctx, value,
+				video.ExternalID)
+			if err != nil {
+				return fmt.Errorf("%w: failed to check bundle %s: %v",
+					l402.ErrCaveatCheckFailed, value, err)
+			}
+
+			if !isMember {
+				return fmt.Errorf("bundle not valid for video %s",
+					video.ExternalID)
+			}
+
+			access.ServiceType = ServiceTypeBundles
+			return nil
+		},
+		l402.CaveatExpiresAt: func(value string) error {
+			expiresAt, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return fmt.Errorf("invalid expiration: %w", err)
+			}
+
+			// Caveats can only restrict the credentials, so the
+			// earliest expiration wins.
+			if access.ExpiresAt == nil ||
+				expiresAt.Before(*access.ExpiresAt) {
+
+				access.ExpiresAt = &expiresAt
+			}
+
+			return nil
+		},
+	}
+}
+
+// GenerateStreamURL returns the signed URLs for the given client to stream
+// the video. They expire no later than the credentials granting the access.
+func (m *Manager) GenerateStreamURL(ctx context.Context, externalID string,
+	access *Access, clientIP string) (*StreamURLs, error) {
+
+	urls, err := m.cf.GenerateStreamURL(ctx, externalID, &StreamURLOptions{
+		ExpiresAt: access.ExpiresAt,
+		ClientIP:  clientIP,
+	})
 	if err != nil {
-		m.logger.Error("Failed to generate presigned URL", "error", err)
+		return nil, fmt.Errorf("failed to generate stream URL: %w", err)
 	}
 
-	return HLSURL, DashURL, nil
+	return urls, nil
+}
+
+// PricingOptions returns the priced ways to access the given video: the full
+// purchase followed by the rentals offered by the creator. The purchase of
+// pay-what-you-want videos suggests an amount above their minimum price.
+func (m *Manager) PricingOptions(ctx context.Context,
+	video *Video) ([]*PricingOption, error) {
+
+	rentals, err := m.store.ListVideoRentals(ctx, video.ExternalID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list video rentals: %w", err)
+	}
+
+	buy := &PricingOption{
+		Key:          PricingOptionBuy,
+		Type:         PricingTypePurchase,
+		PriceInCents: video.PriceInCents,
+		Duration:     ExpirationTime,
+	}
+	if video.PayWhatYouWant {
+		buy.PayWhatYouWant = true
+		buy.MinPriceInCents = video.PriceInCents
+		buy.PriceInCents = max(video.SuggestedPriceInCents,
+			video.PriceInCents)
+	}
+
+	options := []*PricingOption{buy}
+	for _, rental := range rentals {
+		options = append(options, &PricingOption{
+			Key:          RentalOptionKey(rental.DurationInHours),
+			Type:         PricingTypeRental,
+			PriceInCents: rental.PriceInCents,
+			Duration:     time.Duration(rental.DurationInHours) * time.Hour,
+		})
+	}
+
+	return options, nil
+}
+
+// RentalOptionKey returns the pricing option key of a rental with the given
+// duration.
+func RentalOptionKey(durationInHours int64) string {
+	return fmt.Sprintf("rent_%dh", durationInHours)
 }
 
 // CreateChallenge creates a new L402 challenge for downloading a file from our
-// storage service.
-func (m *Manager) CreateL402Challenge(ctx context.Context, pubKeyHex string,
-	externalID string) (*l402.Challenge, error) {
+// storage service. The pricing option in the request selects the price and
+// the expiration of the credentials, defaulting to the full purchase. Options
+// that are pay-what-you-want charge the amount in the request, if any, or the
+// suggested one. The promo code in the request, if any, is applied to the
+// price of the option. When a recipient public key is given the credentials are
+// bound to it instead of the public key of the payer, so the video is gifted.
+func (m *Manager) CreateL402Challenge(ctx context.Context, externalID string,
+	req *ChallengeRequest) (*l402.Challenge, error) {
 
 	video, err := m.store.GetVideoByExternalID(ctx, externalID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch video: %w", err)
 	}
 
-	expiresAt := m.clock.Now().Add(ExpirationTime)
+	options, err := m.PricingOptions(ctx, video)
+	if err != nil {
+		return nil, err
+	}
+
+	optionKey := req.Option
+	if optionKey == "" {
+		optionKey = PricingOptionBuy
+	}
+
+	var option *PricingOption
+	for _, o := range options {
+		if o.Key == optionKey {
+			option = o
+			break
+		}
+	}
+	if option == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPricingOption, optionKey)
+	}
+
+	priceInCents := option.PriceInCents
+	if req.AmountInCents != 0 {
+		if !option.PayWhatYouWant {
+			return nil, fmt.Errorf("%w: option %s has a fixed price",
+				ErrInvalidAmount, option.Key)
+		}
+
+		if req.AmountInCents < option.MinPriceInCents {
+			return nil, fmt.Errorf("%w: the minimum is %d cents",
+				ErrInvalidAmount, option.MinPriceInCents)
+		}
+
+		priceInCents = req.AmountInCents
+	}
+
+	var promoCodeID int64
+	if req.PromoCode != "" {
+		promoCode, err := m.findPromoCode(ctx, video, req.PromoCode)
+		if err != nil {
+			return nil, err
+		}
+
+		priceInCents = DiscountedPrice(promoCode, priceInCents)
+		promoCodeID = promoCode.ID
+	}
+
+	expiresAt := m.clock.Now().Add(option.Duration)
 	caveats := map[string]string{
-		"external_id": video.ExternalID,
-		"expires_at":  expiresAt.Format(time.RFC3339),
+		CaveatExternalID:     video.ExternalID,
+		l402.CaveatExpiresAt: expiresAt.Format(time.RFC3339),
+	}
+
+	recipientPubKey := req.RecipientPubKey
+	if recipientPubKey == "" {
+		recipientPubKey = req.PubKey
 	}
 
-	creds, err := m.authenticator.NewChallenge(ctx, video.Title, pubKeyHex,
-		uint64(video.PriceInCents), caveats)
+	creds, err := m.authenticator.NewChallenge(ctx, video.Title,
+		recipientPubKey, uint64(priceInCents), caveats)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create L402 challenge: %w", err)
 	}
 
-	paymentHash := creds.Invoice.PaymentHash
-	err = m.orders.CreateOffer(ctx, video.UserID, uint64(video.PriceInCents),
-		video.ExternalID, paymentHash)
+	offer := &orders.CreateOfferParams{
+		UserID:          video.UserID,
+		PriceInCents:    uint64(priceInCents),
+		ExternalID:      video.ExternalID,
+		PaymentHash:     creds.Invoice.PaymentHash,
+		PromoCodeID:     promoCodeID,
+		PayerPubKey:     req.PubKey,
+		RecipientPubKey: recipientPubKey,
+		AmountInSats:    creds.Invoice.PaymentAmount.Amount,
+		ServiceType:     ServiceTypeVideos,
+	}
+	if option.Type == PricingTypeRental {
+		offer.ExpirationDate = &expiresAt
+	}
+
+	err = m.orders.CreateOffer(ctx, offer)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create offer for file(%s): %w",
 			video.ExternalID, err)
@@ -125,85 +548,423 @@ func (m *Manager) CreateL402Challenge(ctx context.Context, pubKeyHex string,
 	return creds, nil
 }
 
-func (m *Manager) RecordPurchaseAndView(ctx context.Context, externalID, paymentHash,
-	serviceType string) error {
+// CreateSubscriptionChallenge creates a new L402 challenge for a monthly
+// subscription to all the videos of the creator of the given video. Every
+// renewal creates a new offer.
+func (m *Manager) CreateSubscriptionChallenge(ctx context.Context,
+	pubKeyHex string, externalID string) (*l402.Challenge, error) {
 
-	err := m.orders.RecordPurchase(ctx, paymentHash, serviceType)
+	video, err := m.store.GetVideoByExternalID(ctx, externalID)
 	if err != nil {
-		return fmt.Errorf("failed to record purchase: %w", err)
+		return nil, fmt.Errorf("failed to fetch video: %w", err)
 	}
 
-	err = m.store.IncrementVideoViews(ctx, externalID)
+	priceInCents, err := m.store.GetUserSubscriptionPrice(ctx, video.UserID)
 	if err != nil {
-		return fmt.Errorf("failed to increment video views: %w", err)
+		return nil, fmt.Errorf("failed to get subscription price: %w", err)
 	}
 
-	return nil
+	if priceInCents == 0 {
+		return nil, ErrSubscriptionsDisabled
+	}
+
+	expiresAt := m.clock.Now().Add(SubscriptionPeriod)
+	caveats := map[string]string{
+		CaveatCreatorID:      strconv.FormatInt(video.UserID, 10),
+		l402.CaveatExpiresAt: expiresAt.Format(time.RFC3339),
+	}
+
+	creds, err := m.authenticator.NewChallenge(ctx, "Monthly subscription",
+		pubKeyHex, uint64(priceInCents), caveats)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create L402 challenge: %w", err)
+	}
+
+	err = m.orders.CreateOffer(ctx, &orders.CreateOfferParams{
+		UserID:          video.UserID,
+		PriceInCents:    uint64(priceInCents),
+		ExternalID:      SubscriptionExternalID(video.UserID),
+		PaymentHash:     creds.Invoice.PaymentHash,
+		ExpirationDate:  &expiresAt,
+		PayerPubKey:     pubKeyHex,
+		RecipientPubKey: pubKeyHex,
+		AmountInSats:    creds.Invoice.PaymentAmount.Amount,
+		ServiceType:     ServiceTypeSubscriptions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subscription offer for "+
+			"user %d: %w", video.UserID, err)
+	}
+
+	return creds, nil
 }
 
-func (m *Manager) ProcessAndUploadCoverImage(gCtx context.Context,
-	externalID string, coverImageHeader *multipart.FileHeader) (string, error) {
+// RecoverCredentials returns the credentials of the paid offers for the given
+// video that are bound to the recipient public key, e.g. videos gifted to it.
+// Only the most recent offers are checked and their purchases are recorded,
+// as the payer may never have used the credentials.
+func (m *Manager) RecoverCredentials(ctx context.Context, externalID,
+	recipientPubKey string) ([]*Credentials, error) {
 
-	file, err := coverImageHeader.Open()
+	offers, err := m.orders.ListRecipientOffers(ctx, externalID,
+		recipientPubKey, MaxRecoverableOffers)
 	if err != nil {
-		return "", fmt.Errorf("failed to open cover image: %w", err)
+		return nil, fmt.Errorf("failed to list offers: %w", err)
 	}
-	defer file.Close()
 
-	coverImageBytes, err := io.ReadAll(file)
+	credentials := make([]*Credentials, 0, len(offers))
+	for _, offer := range offers {
+		macaroon, preimage, err := m.authenticator.PaidCredentials(ctx,
+			offer.PaymentHash, recipientPubKey)
+		switch {
+		case errors.Is(err, l402.ErrInvoiceNotPaid):
+			continue
+
+		case err != nil:
+			return nil, fmt.Errorf("failed to get credentials for payment "+
+				"hash(%s): %w", offer.PaymentHash, err)
+		}
+
+		err = m.orders.RecordPurchase(ctx, offer.PaymentHash,
+			ServiceTypeVideos)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record purchase: %w", err)
+		}
+
+		credentials = append(credentials, &Credentials{
+			PaymentHash:    offer.PaymentHash,
+			Macaroon:       macaroon,
+			Preimage:       preimage,
+			PayerPubKey:    offer.PayerPubKey,
+			ExpirationDate: offer.ExpirationDate,
+		})
+	}
+
+	return credentials, nil
+}
+
+// RestoreCredentials returns every paid credential minted for the given
+// public key that has not expired yet, whatever it grants access to.
+func (m *Manager) RestoreCredentials(ctx context.Context,
+	pubKeyHex string) ([]*Credentials, error) {
+
+	stored, err := m.authenticator.RestoreCredentials(ctx, pubKeyHex)
 	if err != nil {
-		return "", fmt.Errorf("failed to read cover image: %w", err)
+		return nil, fmt.Errorf("failed to restore credentials: %w", err)
 	}
 
-	coverImageReader := bytes.NewReader(coverImageBytes)
+	credentials := make([]*Credentials, 0, len(stored))
+	for _, creds := range stored {
+		credentials = append(credentials, &Credentials{
+			PaymentHash:    creds.PaymentHash,
+			Macaroon:       creds.Macaroon,
+			Preimage:       creds.Preimage,
+			ExpirationDate: creds.ExpiresAt,
+		})
+	}
 
-	coverURL, err := m.cf.UploadPublicFile(gCtx, externalID,
-		"cover-images", coverImageReader)
+	return credentials, nil
+}
+
+// Library returns the videos the given public key has purchased, directly or
+// through bundles and subscriptions, skipping expired purchases and the
+// deleted videos that were purged. Each video is listed once, with its most
+// recent purchase.
+func (m *Manager) Library(ctx context.Context,
+	pubKeyHex string) ([]*LibraryVideo, error) {
+
+	purchases, err := m.orders.ListRecipientPurchases(ctx, pubKeyHex)
 	if err != nil {
-		return "", fmt.Errorf("failed to upload cover file: %w", err)
+		return nil, fmt.Errorf("failed to list purchases: %w", err)
 	}
 
-	return coverURL, nil
+	now := m.clock.Now()
+	seen := make(map[string]bool)
+	library := make([]*LibraryVideo, 0, len(purchases))
+	for _, purchase := range purchases {
+		if purchase.ExpirationDate != nil &&
+			!now.Before(*purchase.ExpirationDate) {
+
+			continue
+		}
+
+		videos, err := m.purchasedVideos(ctx, purchase)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, video := range videos {
+			if seen[video.ExternalID] {
+				continue
+			}
+			seen[video.ExternalID] = true
+
+			library = append(library, &LibraryVideo{
+				Video:          video,
+				ServiceType:    purchase.ServiceType,
+				PurchasedAt:    purchase.CreatedAt,
+				ExpirationDate: purchase.ExpirationDate,
+			})
+		}
+	}
+
+	return library, nil
+}
+
+// purchasedVideos returns the videos a purchase grants access to that can
+// still be streamed, i.e. that have not been deleted or are still in the
+// trash.
+func (m *Manager) purchasedVideos(ctx context.Context,
+	purchase *orders.Purchase) ([]*Video, error) {
+
+	switch purchase.ServiceType {
+	case ServiceTypeSubscriptions:
+		userID, err := subscriptionUserID(purchase.ExternalID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid subscription %s: %w",
+				purchase.ExternalID, err)
+		}
+
+		videos, err := m.store.ListUserVideos(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list videos of user %d: %w",
+				userID, err)
+		}
+
+		trashed, err := m.store.ListUserTrashedVideos(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list trashed videos of "+
+				"user %d: %w", userID, err)
+		}
+
+		for _, externalID := range trashed {
+			video, err := m.purchasedVideo(ctx, externalID)
+			if err != nil {
+				return nil, err
+			}
+			if video != nil {
+				videos = append(videos, video)
+			}
+		}
+
+		return videos, nil
+
+	case ServiceTypeBundles:
+		// Buyers keep the videos of the bundles deleted after their
+		// purchase.
+		bundle, err := m.store.GetPurchasedBundle(ctx,
+			purchase.ExternalID)
+		if errors.Is(err, ErrBundleNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch bundle %s: %w",
+				purchase.ExternalID, err)
+		}
+
+		videos := make([]*Video, 0, len(bundle.VideoIDs))
+		for _, externalID := range bundle.VideoIDs {
+			video, err := m.purchasedVideo(ctx, externalID)
+			if err != nil {
+				return nil, err
+			}
+			if video != nil {
+				videos = append(videos, video)
+			}
+		}
+
+		return videos, nil
+
+	default:
+		video, err := m.purchasedVideo(ctx, purchase.ExternalID)
+		if err != nil || video == nil {
+			return nil, err
+		}
+
+		return []*Video{video}, nil
+	}
 }
 
-func (m *Manager) GenerateVideoUploadURL(ctx context.Context) (string, string, error) {
-	uploadURL, externalID, err := m.cf.GenerateVideoUploadURL(ctx)
+// purchasedVideo returns the purchased video with the given external ID, or
+// nil if it has been deleted and can't be streamed anymore.
+func (m *Manager) purchasedVideo(ctx context.Context,
+	externalID string) (*Video, error) {
+
+	video, err := m.store.GetVideoByExternalID(ctx, externalID)
+	if errors.Is(err, ErrVideoNotFound) {
+		// The buyers of a deleted video keep streaming it until it is
+		// purged.
+		video, err = m.streamableTrashedVideo(ctx, externalID)
+	}
+	if errors.Is(err, ErrVideoNotFound) {
+		return nil, nil
+	}
 	if err != nil {
-		return "", "", fmt.Errorf("failed to generate upload URL: %w", err)
+		return nil, fmt.Errorf("failed to fetch video %s: %w", externalID,
+			err)
 	}
-	return uploadURL, externalID, nil
+
+	return video, nil
 }
 
+// SubscriptionExternalID returns the external ID used in the offers and
+// purchases of the subscription to the given creator.
+func SubscriptionExternalID(userID int64) string {
+	return fmt.Sprintf("%s%d", subscriptionPrefix, userID)
+}
+
+// subscriptionUserID returns the ID of the creator of the subscription with
+// the given external ID.
+func subscriptionUserID(externalID string) (int64, error) {
+	if !strings.HasPrefix(externalID, subscriptionPrefix) {
+		return 0, fmt.Errorf("missing %s prefix", subscriptionPrefix)
+	}
+
+	return strconv.ParseInt(strings.TrimPrefix(externalID,
+		subscriptionPrefix), 10, 64)
+}
+
+func (m *Manager) RecordPurchaseAndView(ctx context.Context, externalID, paymentHash,
+	serviceType string) error {
+
+	err := m.orders.RecordPurchaseAndView(ctx, paymentHash, serviceType,
+		externalID)
+	if err != nil {
+		return fmt.Errorf("failed to record purchase: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Manager) GenerateVideoUploadURL(ctx context.Context,
+	userID int64, opts *UploadOptions) (*Upload, error) {
+
+	upload, err := m.cf.GenerateVideoUploadURL(ctx, userID, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate upload URL: %w", err)
+	}
+	return upload, nil
+}
+
+// PrepareVideoUpload creates the video with its cover image and returns
+// where to upload its file to, with a resumable upload if requested and
+// supported by the streaming backend.
 func (m *Manager) PrepareVideoUpload(ctx context.Context, userID int64,
-	req UploadVideoRequest) (string, string, error) {
+	req UploadVideoRequest) (*Upload, error) {
 
-	uploadURL, externalID, err := m.GenerateVideoUploadURL(ctx)
+	// Invalid covers are rejected before creating the video in the
+	// streaming backend.
+	cover, err := m.decodeCoverImage(req.CoverImage)
+	if err != nil {
+		return nil, err
+	}
+
+	upload, err := m.GenerateVideoUploadURL(ctx, userID, &UploadOptions{
+		Name:        req.Title,
+		Resumable:   req.Resumable,
+		SizeInBytes: req.SizeInBytes,
+	})
 	if err != nil {
 		m.logger.Error("Failed to generate upload URL", "error", err)
-		return "", "", fmt.Errorf("failed to generate upload URL: %w", err)
+		return nil, fmt.Errorf("failed to generate upload URL: %w", err)
 	}
 
-	coverURL, err := m.ProcessAndUploadCoverImage(ctx, externalID, req.CoverImage)
+	err = m.createVideo(ctx, userID, upload.ExternalID,
+		StatusAwaitingUpload, &req.VideoMetadata, cover)
+	if err != nil {
+		return nil, err
+	}
+
+	// A failed notification must not fail the upload.
+	if m.notifier != nil {
+		err = m.notifier.RegisterNewVideoUploadEvent(ctx, userID,
+			upload.ExternalID)
+		if err != nil {
+			m.logger.Error("Failed to notify video upload",
+				"externalID", upload.ExternalID, "error", err)
+		}
+	}
+
+	return upload, nil
+}
+
+// ImportVideo creates a video copied by the streaming backend from the
+// source URL, along with its cover image. The video is processing from the
+// start and follows the same pipeline as the uploads once copied.
+func (m *Manager) ImportVideo(ctx context.Context, userID int64,
+	req ImportVideoRequest) (string, error) {
+
+	cover, err := m.decodeCoverImage(req.CoverImage)
+	if err != nil {
+		return "", err
+	}
+
+	externalID, err := m.cf.ImportVideo(ctx, userID, req.SourceURL,
+		req.Title)
+	if err != nil {
+		m.logger.Error("Failed to import video", "error", err)
+		return "", fmt.Errorf("failed to import video: %w", err)
+	}
+
+	err = m.createVideo(ctx, userID, externalID, StatusProcessing,
+		&req.VideoMetadata, cover)
+	if err != nil {
+		return "", err
+	}
+
+	// A failed notification must not fail the import.
+	if m.notifier != nil {
+		err = m.notifier.RegisterNewVideoImportEvent(ctx, userID,
+			externalID)
+		if err != nil {
+			m.logger.Error("Failed to notify video import",
+				"externalID", externalID, "error", err)
+		}
+	}
+
+	return externalID, nil
+}
+
+// createVideo uploads the cover image with its variants and creates the
+// video of a new upload or import in the given status.
+func (m *Manager) createVideo(ctx context.Context, userID int64,
+	externalID, status string, metadata *VideoMetadata,
+	cover *coverImage) error {
+
+	coverURL, err := m.uploadCoverImage(ctx, externalID, cover)
 	if err != nil {
 		m.logger.Error("Failed to upload cover image", "error", err)
-		return "", "", fmt.Errorf("failed to upload cover image: %w", err)
+		return fmt.Errorf("failed to upload cover image: %w", err)
+	}
+
+	coverVariants, err := m.uploadCoverVariants(ctx, externalID, cover)
+	if err != nil {
+		m.logger.Error("Failed to upload cover variants", "error", err)
+		return fmt.Errorf("failed to upload cover image: %w", err)
 	}
 
 	_, err = m.store.CreateVideo(ctx, CreateVideoParams{
 		ExternalID:   externalID,
 		UserID:       userID,
-		Title:        req.Title,
-		Description:  req.Description,
+		Title:        metadata.Title,
+		Description:  metadata.Description,
 		CoverURL:     coverURL,
-		PriceInCents: req.PriceInCents,
+		PriceInCents: metadata.PriceInCents,
+
+		CoverVariants: coverVariants,
+
+		PayWhatYouWant:        metadata.PayWhatYouWant,
+		SuggestedPriceInCents: metadata.SuggestedPriceInCents,
+
+		Status: status,
 	})
 
 	if err != nil {
 		m.logger.Error("Failed to create video", "error", err)
-		return "", "", fmt.Errorf("failed to save video metadata: %w", err)
+		return fmt.Errorf("failed to save video metadata: %w", err)
 	}
 
-	return uploadURL, externalID, nil
+	return nil
 }
 
 func (m *Manager) UpdateVideoInfo(ctx context.Context, externalID string, req UpdateVideoInfoRequest) (*Video, error) {
@@ -211,6 +972,9 @@ func (m *Manager) UpdateVideoInfo(ctx context.Context, externalID string, req Up
 		Title:        req.Title,
 		Description:  req.Description,
 		PriceInCents: req.PriceInCents,
+
+		PayWhatYouWant:        req.PayWhatYouWant,
+		SuggestedPriceInCents: req.SuggestedPriceInCents,
 	})
 	if err != nil {
 		m.logger.Error("Failed to update video info", "error", err)
@@ -219,12 +983,367 @@ func (m *Manager) UpdateVideoInfo(ctx context.Context, externalID string, req Up
 	return video, nil
 }
 
-func (m *Manager) DeleteVideo(ctx context.Context, externalID string) error {
-	// Then, delete the video from the database
-	err := m.store.DeleteVideo(ctx, externalID)
+// DeletedVideo is the data of the video.deleted webhook event.
+type DeletedVideo struct {
+	ExternalID string `json:"external_id"`
+}
+
+func (m *Manager) DeleteVideo(ctx context.Context, userID int64,
+	externalID string) error {
+
+	purgeAt := m.clock.Now().Add(m.cfg.DeletionGracePeriod)
+	err := m.store.DeleteVideo(ctx, externalID, purgeAt)
 	if err != nil {
 		return fmt.Errorf("failed to delete video from database: %w", err)
 	}
 
+	m.schedulePurge(ctx, externalID, purgeAt)
+
+	m.enqueueWebhook(ctx, userID, webhooks.EventVideoDeleted, &DeletedVideo{
+		ExternalID: externalID,
+	})
+
+	return nil
+}
+
+// enqueueWebhook pushes an event to the webhook endpoints of the user. A
+// failed webhook must not fail the operation that triggered it.
+func (m *Manager) enqueueWebhook(ctx context.Context, userID int64,
+	eventType string, data any) {
+
+	if m.webhooks == nil {
+		return
+	}
+
+	err := m.webhooks.Enqueue(ctx, userID, eventType, data)
+	if err != nil {
+		m.logger.Error("Failed to enqueue webhook", "userID", userID,
+			"event", eventType, "error", err)
+	}
+}
+
+// SetVideoRental creates or updates the rental option of a video with the
+// given duration.
+func (m *Manager) SetVideoRental(ctx context.Context, externalID string,
+	req SetVideoRentalRequest) (*Rental, error) {
+
+	rental, err := m.store.UpsertVideoRental(ctx, externalID,
+		req.DurationInHours, req.PriceInCents)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save video rental: %w", err)
+	}
+
+	return rental, nil
+}
+
+// CreateBundle creates a new bundle of videos of the given user and uploads
+// its cover image.
+func (m *Manager) CreateBundle(ctx context.Context, userID int64,
+	req CreateBundleRequest) (*Bundle, error) {
+
+	err := m.validateBundleVideos(ctx, userID, req.VideoIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	externalID, err := generateBundleExternalID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate bundle ID: %w", err)
+	}
+
+	coverURL, err := m.ProcessAndUploadCoverImage(ctx, externalID,
+		req.CoverImage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload cover image: %w", err)
+	}
+
+	bundle, err := m.store.CreateBundle(ctx, CreateBundleParams{
+		ExternalID:   externalID,
+		UserID:       userID,
+		Title:        req.Title,
+		Description:  req.Description,
+		CoverURL:     coverURL,
+		PriceInCents: req.PriceInCents,
+		VideoIDs:     req.VideoIDs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save bundle: %w", err)
+	}
+
+	return bundle, nil
+}
+
+// UpdateBundle updates the info of the given bundle and replaces its videos
+// when the request contains them.
+func (m *Manager) UpdateBundle(ctx context.Context, bundle *Bundle,
+	req UpdateBundleRequest) (*Bundle, error) {
+
+	if req.VideoIDs != nil {
+		err := m.validateBundleVideos(ctx, bundle.UserID, req.VideoIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	updated, err := m.store.UpdateBundle(ctx, bundle.ExternalID,
+		&UpdateBundleParams{
+			Title:        req.Title,
+			Description:  req.Description,
+			PriceInCents: req.PriceInCents,
+			VideoIDs:     req.VideoIDs,
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update bundle: %w", err)
+	}
+
+	return updated, nil
+}
+
+// DeleteBundle stops selling a bundle. Users that bought it keep access to
+// its videos.
+func (m *Manager) DeleteBundle(ctx context.Context, externalID string) error {
+	err := m.store.DeleteBundle(ctx, externalID)
+	if err != nil {
+		return fmt.Errorf("failed to delete bundle: %w", err)
+	}
+
 	return nil
 }
+
+// BundleVideos returns the videos of a bundle in order.
+func (m *Manager) BundleVideos(ctx context.Context,
+	bundle *Bundle) ([]*Video, error) {
+
+	videos := make([]*Video, 0, len(bundle.VideoIDs))
+	for _, externalID := range bundle.VideoIDs {
+		video, err := m.store.GetVideoByExternalID(ctx, externalID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch video %s: %w",
+				externalID, err)
+		}
+
+		videos = append(videos, video)
+	}
+
+	return videos, nil
+}
+
+// BundleAccessCheckers returns the caveat checkers for L402 credentials
+// granting access to the given bundle.
+func (m *Manager) BundleAccessCheckers(
+	bundle *Bundle) map[string]l402.CaveatChecker {
+
+	return map[string]l402.CaveatChecker{
+		CaveatBundleID: func(value string) error {
+			if value != bundle.ExternalID {
+				return fmt.Errorf("credentials not valid for bundle %s",
+					bundle.ExternalID)
+			}
+
+			return nil
+		},
+	}
+}
+
+// CreateBundleChallenge creates a new L402 challenge for buying all the videos
+// of a bundle.
+func (m *Manager) CreateBundleChallenge(ctx context.Context, bundle *Bundle,
+	pubKeyHex string) (*l402.Challenge, error) {
+
+	expiresAt := m.clock.Now().Add(ExpirationTime)
+	caveats := map[string]string{
+		CaveatBundleID:       bundle.ExternalID,
+		l402.CaveatExpiresAt: expiresAt.Format(time.RFC3339),
+	}
+
+	creds, err := m.authenticator.NewChallenge(ctx, bundle.Title, pubKeyHex,
+		uint64(bundle.PriceInCents), caveats)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create L402 challenge: %w", err)
+	}
+
+	err = m.orders.CreateOffer(ctx, &orders.CreateOfferParams{
+		UserID:          bundle.UserID,
+		PriceInCents:    uint64(bundle.PriceInCents),
+		ExternalID:      bundle.ExternalID,
+		PaymentHash:     creds.Invoice.PaymentHash,
+		PayerPubKey:     pubKeyHex,
+		RecipientPubKey: pubKeyHex,
+		AmountInSats:    creds.Invoice.PaymentAmount.Amount,
+		ServiceType:     ServiceTypeBundles,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create offer for bundle(%s): %w",
+			bundle.ExternalID, err)
+	}
+
+	return creds, nil
+}
+
+// RecordBundlePurchase creates a new bundle purchase if there is not one
+// already for the given payment hash.
+func (m *Manager) RecordBundlePurchase(ctx context.Context,
+	paymentHash string) error {
+
+	err := m.orders.RecordPurchase(ctx, paymentHash, ServiceTypeBundles)
+	if err != nil {
+		return fmt.Errorf("failed to record purchase: %w", err)
+	}
+
+	return nil
+}
+
+// validateBundleVideos checks that the videos of a bundle are not empty nor
+// repeated and that all of them belong to the given user.
+func (m *Manager) validateBundleVideos(ctx context.Context, userID int64,
+	videoIDs []string) error {
+
+	if len(videoIDs) == 0 {
+		return fmt.Errorf("%w: a bundle needs at least one video",
+			ErrInvalidBundleVideos)
+	}
+
+	err := m.validateOwnedVideos(ctx, userID, videoIDs)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBundleVideos, err)
+	}
+
+	return nil
+}
+
+// validateOwnedVideos checks that the videos are not repeated and that all of
+// them belong to the given user.
+func (m *Manager) validateOwnedVideos(ctx context.Context, userID int64,
+	videoIDs []string) error {
+
+	seen := make(map[string]struct{}, len(videoIDs))
+	for _, externalID := range videoIDs {
+		if _, ok := seen[externalID]; ok {
+			return fmt.Errorf("video %s is repeated", externalID)
+		}
+		seen[externalID] = struct{}{}
+
+		video, err := m.store.GetVideoByExternalID(ctx, externalID)
+		if err != nil || video.UserID != userID {
+			return fmt.Errorf("video %s not found", externalID)
+		}
+	}
+
+	return nil
+}
+
+// generateBundleExternalID returns a random external ID for a new bundle.
+func generateBundleExternalID() (string, error) {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+// CreatePromoCode creates a new promo code of the given user. Codes are case
+// insensitive and stored upper-cased.
+func (m *Manager) CreatePromoCode(ctx context.Context, userID int64,
+	req CreatePromoCodeRequest) (*PromoCode, error) {
+
+	code := NormalizePromoCode(req.Code)
+	_, err := m.store.GetPromoCode(ctx, userID, code)
+	switch {
+	case err == nil:
+		return nil, ErrPromoCodeExists
+
+	case !errors.Is(err, ErrPromoCodeNotFound):
+		return nil, fmt.Errorf("failed to get promo code: %w", err)
+	}
+
+	err = m.validateOwnedVideos(ctx, userID, req.VideoIDs)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPromoCodeVideos, err)
+	}
+
+	promoCode, err := m.store.CreatePromoCode(ctx, CreatePromoCodeParams{
+		UserID:         userID,
+		Code:           code,
+		DiscountType:   req.DiscountType,
+		DiscountValue:  req.DiscountValue,
+		ExpiresAt:      req.ExpiresAt,
+		MaxRedemptions: req.MaxRedemptions,
+		VideoIDs:       req.VideoIDs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save promo code: %w", err)
+	}
+
+	return promoCode, nil
+}
+
+// findPromoCode returns the promo code of the creator of the video if it can
+// be applied to the video right now.
+func (m *Manager) findPromoCode(ctx context.Context, video *Video,
+	code string) (*PromoCode, error) {
+
+	promoCode, err := m.store.GetPromoCode(ctx, video.UserID,
+		NormalizePromoCode(code))
+	switch {
+	case errors.Is(err, ErrPromoCodeNotFound):
+		return nil, fmt.Errorf("%w: %s does not exist", ErrInvalidPromoCode,
+			code)
+
+	case err != nil:
+		return nil, fmt.Errorf("failed to get promo code: %w", err)
+	}
+
+	if promoCode.ExpiresAt != nil &&
+		!m.clock.Now().Before(*promoCode.ExpiresAt) {
+
+		return nil, fmt.Errorf("%w: %s has expired", ErrInvalidPromoCode,
+			code)
+	}
+
+	// The redemptions are only counted once the purchases are recorded, so
+	// a few pending challenges may still exceed the limit.
+	if promoCode.MaxRedemptions > 0 &&
+		promoCode.Redemptions >= promoCode.MaxRedemptions {
+
+		return nil, fmt.Errorf("%w: %s has no redemptions left",
+			ErrInvalidPromoCode, code)
+	}
+
+	if len(promoCode.VideoIDs) == 0 {
+		return promoCode, nil
+	}
+	for _, externalID := range promoCode.VideoIDs {
+		if externalID == video.ExternalID {
+			return promoCode, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s is not valid for this video",
+		ErrInvalidPromoCode, code)
+}
+
+// DiscountedPrice returns the price after applying the promo code. Discounted
+// prices never go below one cent as invoices need a positive amount.
+func DiscountedPrice(promoCode *PromoCode, priceInCents int64) int64 {
+	if priceInCents <= 0 {
+		return priceInCents
+	}
+
+	discounted := priceInCents
+	switch promoCode.DiscountType {
+	case DiscountTypePercentage:
+		discounted -= priceInCents * promoCode.DiscountValue / 100
+
+	case DiscountTypeFixed:
+		discounted -= promoCode.DiscountValue
+	}
+
+	return max(discounted, 1)
+}
+
+// NormalizePromoCode returns the canonical form of a promo code.
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -0,0 +1,151 @@
+package video
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+const (
+	// StatusAwaitingUpload is the status of a video whose upload URL was
+	// handed out but not used yet.
+	StatusAwaitingUpload = "awaiting_upload"
+
+	// StatusProcessing is the status of a video uploaded and being
+	// transcoded by Cloudflare.
+	StatusProcessing = "processing"
+
+	// StatusReady is the status of a video ready to stream.
+	StatusReady = "ready"
+
+	// StatusFailed is the status of a video Cloudflare failed to process,
+	// e.g. because it exceeds the maximum duration. The status reason holds
+	// the error.
+	StatusFailed = "failed"
+
+	// StatusExpired is the status of a video whose upload URL expired
+	// before the upload completed.
+	StatusExpired = "expired"
+
+	// uploadExpiredReason is the status reason of the expired videos.
+	uploadExpiredReason = "The upload URL expired before the upload completed"
+
+	// maxDurationReason is the status reason of the videos longer than the
+	// maximum duration of their creator.
+	maxDurationReason = "The video is longer than the maximum duration"
+)
+
+// Cloudflare Stream processing states.
+const (
+	streamStatePendingUpload = "pendingupload"
+	streamStateReady         = "ready"
+	streamStateError         = "error"
+)
+
+var (
+	// ErrVideoAwaitingUpload is returned when streaming a video that has
+	// not been uploaded yet.
+	ErrVideoAwaitingUpload = errors.New("video has not been uploaded yet")
+
+	// ErrVideoProcessing is returned when streaming a video that is still
+	// being processed.
+	ErrVideoProcessing = errors.New("video is still processing")
+
+	// ErrVideoFailed is returned when streaming a video that failed to
+	// process.
+	ErrVideoFailed = errors.New("video processing failed")
+
+	// ErrVideoExpired is returned when streaming a video whose upload
+	// expired.
+	ErrVideoExpired = errors.New("video upload expired")
+
+	// statusTransitions are the statuses each status can move to. Ready,
+	// failed and expired videos are final.
+	statusTransitions = map[string][]string{
+		StatusAwaitingUpload: {
+			StatusProcessing, StatusReady, StatusFailed, StatusExpired,
+		},
+		StatusProcessing: {StatusReady, StatusFailed},
+	}
+)
+
+// canTransition returns whether a video can move from one status to another.
+// Videos created before statuses existed have none and are treated as
+// awaiting upload.
+func canTransition(from, to string) bool {
+	if from == "" {
+		from = StatusAwaitingUpload
+	}
+
+	if from == to {
+		return true
+	}
+
+	for _, next := range statusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// streamStatus returns the status and status reason of a video from the
+// info reported by Cloudflare.
+func streamStatus(videoInfo *cloudflare.StreamVideo,
+	now time.Time) (string, string) {
+
+	switch {
+	case videoInfo.ReadyToStream ||
+		videoInfo.Status.State == streamStateReady:
+
+		return StatusReady, ""
+
+	case videoInfo.Status.State == streamStateError:
+		reason := videoInfo.Status.ErrorReasonText
+		if reason == "" {
+			reason = videoInfo.Status.ErrorReasonCode
+		}
+
+		return StatusFailed, reason
+
+	case videoInfo.Status.State == streamStatePendingUpload ||
+		videoInfo.Status.State == "":
+
+		if videoInfo.UploadExpiry != nil &&
+			now.After(*videoInfo.UploadExpiry) {
+
+			return StatusExpired, uploadExpiredReason
+		}
+
+		return StatusAwaitingUpload, ""
+
+	// Downloading, queued or in progress.
+	default:
+		return StatusProcessing, ""
+	}
+}
+
+// statusError returns the error explaining why a video that is not ready
+// can't be streamed.
+func statusError(video *Video) error {
+	switch video.Status {
+	case StatusProcessing:
+		return ErrVideoProcessing
+
+	case StatusFailed:
+		if video.StatusReason == "" {
+			return ErrVideoFailed
+		}
+
+		return fmt.Errorf("%w: %s", ErrVideoFailed, video.StatusReason)
+
+	case StatusExpired:
+		return ErrVideoExpired
+
+	default:
+		return ErrVideoAwaitingUpload
+	}
+}
@@ -0,0 +1,114 @@
+package video
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fewsats/blockbuster/jobs"
+)
+
+// jobPurgeVideo is the kind of the jobs deleting the assets of a video once
+// its grace period in the trash is over.
+const jobPurgeVideo = "video.purge"
+
+// purgeVideoPayload is the payload of the purge jobs.
+type purgeVideoPayload struct {
+	ExternalID string `json:"external_id"`
+}
+
+// RestoreVideo moves a deleted video of the user out of the trash. It
+// returns ErrVideoNotFound once the grace period of the video is over.
+func (m *Manager) RestoreVideo(ctx context.Context, userID int64,
+	externalID string) (*Video, error) {
+
+	err := m.store.RestoreVideo(ctx, userID, externalID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore video: %w", err)
+	}
+
+	return m.store.GetVideoByExternalID(ctx, externalID)
+}
+
+// streamableTrashedVideo returns a deleted video whose assets have not been
+// purged yet, or ErrVideoNotFound.
+func (m *Manager) streamableTrashedVideo(ctx context.Context,
+	externalID string) (*Video, error) {
+
+	video, err := m.store.GetTrashedVideo(ctx, externalID)
+	if err != nil {
+		return nil, err
+	}
+
+	if video.PurgedAt != nil || !m.clock.Now().Before(*video.PurgeAt) {
+		return nil, ErrVideoNotFound
+	}
+
+	return video, nil
+}
+
+// schedulePurge enqueues the deletion of the assets of a video at purgeAt.
+// Failing to schedule it leaves the assets behind, which must not fail the
+// deletion of the video.
+func (m *Manager) schedulePurge(ctx context.Context, externalID string,
+	purgeAt time.Time) {
+
+	if m.queue == nil {
+		return
+	}
+
+	_, err := m.queue.EnqueueAt(ctx, jobPurgeVideo, &purgeVideoPayload{
+		ExternalID: externalID,
+	}, purgeAt)
+	if err != nil {
+		m.logger.Error("Failed to schedule video purge",
+			"externalID", externalID, "error", err)
+	}
+}
+
+// purgeVideo deletes the video and cover of a trashed video from the
+// streaming backend and the storage. Videos restored in the meantime are
+// left untouched; failures are returned so the job is retried.
+func (m *Manager) purgeVideo(ctx context.Context, job *jobs.Job) error {
+	var payload purgeVideoPayload
+	if err := job.Decode(&payload); err != nil {
+		return err
+	}
+
+	video, err := m.store.GetTrashedVideo(ctx, payload.ExternalID)
+	switch {
+	case errors.Is(err, ErrVideoNotFound):
+		return nil
+
+	case err != nil:
+		return fmt.Errorf("failed to fetch trashed video: %w", err)
+	}
+
+	// The video was deleted again after a restore, so a later job purges
+	// it.
+	if video.PurgedAt != nil || m.clock.Now().Before(*video.PurgeAt) {
+		return nil
+	}
+
+	err = m.cf.DeleteVideo(ctx, video.ExternalID)
+	if err != nil {
+		return fmt.Errorf("failed to delete video from backend: %w", err)
+	}
+
+	for _, key := range coverImageKeys(video.ExternalID) {
+		err = m.storage.Delete(ctx, key)
+		if err != nil {
+			return fmt.Errorf("failed to delete cover image: %w", err)
+		}
+	}
+
+	err = m.store.MarkVideoPurged(ctx, video.ExternalID)
+	if err != nil {
+		return fmt.Errorf("failed to mark video purged: %w", err)
+	}
+
+	m.logger.Info("Purged deleted video", "externalID", video.ExternalID)
+
+	return nil
+}
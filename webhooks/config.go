@@ -0,0 +1,25 @@
+package webhooks
+
+import "time"
+
+// DefaultConfig returns all default values for the Config struct.
+func DefaultConfig() *Config {
+	return &Config{
+		PollInterval: 10 * time.Second,
+		Timeout:      10 * time.Second,
+		MaxAttempts:  8,
+		RetryBackoff: 30 * time.Second,
+		MaxBackoff:   6 * time.Hour,
+		BatchSize:    50,
+	}
+}
+
+type Config struct {
+	PollInterval time.Duration `long:"poll_interval" description:"Time between checks for due deliveries, 0 disables the dispatcher"`
+	Timeout      time.Duration `long:"timeout" description:"Timeout of every delivery attempt"`
+	MaxAttempts  int64         `long:"max_attempts" description:"Attempts before a delivery is marked as failed"`
+	RetryBackoff time.Duration `long:"retry_backoff" description:"Delay before the first retry, doubled on every attempt"`
+	MaxBackoff   time.Duration `long:"max_backoff" description:"Maximum delay between retries"`
+	BatchSize    int64         `long:"batch_size" description:"Number of deliveries sent per poll"`
+	AllowPrivate bool          `long:"allow_private" description:"Allow endpoints on loopback and private network addresses"`
+}
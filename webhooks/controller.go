@@ -0,0 +1,168 @@
+package webhooks
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxListedDeliveries is the number of most recent deliveries listed for an
+// endpoint.
+const MaxListedDeliveries = 100
+
+type Controller struct {
+	dispatcher *Dispatcher
+
+	logger *slog.Logger
+}
+
+func NewController(dispatcher *Dispatcher, logger *slog.Logger) *Controller {
+	return &Controller{
+		dispatcher: dispatcher,
+
+		logger: logger,
+	}
+}
+
+func (c *Controller) RegisterProtectedRoutes(router *gin.Engine) {
+	router.POST("/webhook", c.CreateEndpoint)
+	router.GET("/user/webhooks", c.ListEndpoints)
+	router.DELETE("/webhook/:id", c.DeleteEndpoint)
+	router.GET("/webhook/:id/deliveries", c.ListDeliveries)
+	router.POST("/webhook/delivery/:id/redeliver", c.Redeliver)
+}
+
+// CreateEndpointRequest is the request to register a webhook endpoint.
+type CreateEndpointRequest struct {
+	URL string `json:"url" binding:"required"`
+
+	// Events are the subscribed event types, empty for all of them.
+	Events []string `json:"events"`
+}
+
+// CreateEndpoint registers a webhook endpoint. The response includes the
+// secret to verify the signatures, which is not returned again.
+func (c *Controller) CreateEndpoint(gCtx *gin.Context) {
+	userID := gCtx.GetInt64("user_id")
+
+	var req CreateEndpointRequest
+	if err := gCtx.ShouldBindJSON(&req); err != nil {
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	endpoint, err := c.dispatcher.CreateEndpoint(gCtx.Request.Context(),
+		userID, req.URL, req.Events)
+	switch {
+	case errors.Is(err, ErrInvalidURL) || errors.Is(err, ErrUnknownEvent):
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+
+	case err != nil:
+		c.logger.Error("Failed to create webhook endpoint", "error", err)
+		gCtx.JSON(http.StatusInternalServerError,
+			gin.H{"error": "Failed to create webhook endpoint"})
+		return
+	}
+
+	gCtx.JSON(http.StatusCreated, gin.H{"webhook": endpoint})
+}
+
+// ListEndpoints returns the webhook endpoints of the user.
+func (c *Controller) ListEndpoints(gCtx *gin.Context) {
+	userID := gCtx.GetInt64("user_id")
+
+	endpoints, err := c.dispatcher.ListEndpoints(gCtx.Request.Context(),
+		userID)
+	if err != nil {
+		c.logger.Error("Failed to list webhook endpoints", "error", err)
+		gCtx.JSON(http.StatusInternalServerError,
+			gin.H{"error": "Failed to list webhook endpoints"})
+		return
+	}
+
+	gCtx.JSON(http.StatusOK, gin.H{"webhooks": endpoints})
+}
+
+// DeleteEndpoint removes a webhook endpoint of the user.
+func (c *Controller) DeleteEndpoint(gCtx *gin.Context) {
+	userID := gCtx.GetInt64("user_id")
+
+	id, err := strconv.ParseInt(gCtx.Param("id"), 10, 64)
+	if err != nil {
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook ID"})
+		return
+	}
+
+	err = c.dispatcher.DeleteEndpoint(gCtx.Request.Context(), userID, id)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		gCtx.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+		return
+
+	case err != nil:
+		c.logger.Error("Failed to delete webhook endpoint", "error", err)
+		gCtx.JSON(http.StatusInternalServerError,
+			gin.H{"error": "Failed to delete webhook endpoint"})
+		return
+	}
+
+	gCtx.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// ListDeliveries returns the delivery log of a webhook endpoint.
+func (c *Controller) ListDeliveries(gCtx *gin.Context) {
+	userID := gCtx.GetInt64("user_id")
+
+	id, err := strconv.ParseInt(gCtx.Param("id"), 10, 64)
+	if err != nil {
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook ID"})
+		return
+	}
+
+	deliveries, err := c.dispatcher.ListDeliveries(gCtx.Request.Context(),
+		userID, id, MaxListedDeliveries)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		gCtx.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+		return
+
+	case err != nil:
+		c.logger.Error("Failed to list webhook deliveries", "error", err)
+		gCtx.JSON(http.StatusInternalServerError,
+			gin.H{"error": "Failed to list webhook deliveries"})
+		return
+	}
+
+	gCtx.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
+
+// Redeliver schedules a delivery to be sent again.
+func (c *Controller) Redeliver(gCtx *gin.Context) {
+	userID := gCtx.GetInt64("user_id")
+
+	id, err := strconv.ParseInt(gCtx.Param("id"), 10, 64)
+	if err != nil {
+		gCtx.JSON(http.StatusBadRequest, gin.H{"error": "invalid delivery ID"})
+		return
+	}
+
+	err = c.dispatcher.Redeliver(gCtx.Request.Context(), userID, id)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		gCtx.JSON(http.StatusNotFound, gin.H{"error": "delivery not found"})
+		return
+
+	case err != nil:
+		c.logger.Error("Failed to redeliver webhook", "error", err)
+		gCtx.JSON(http.StatusInternalServerError,
+			gin.H{"error": "Failed to redeliver webhook"})
+		return
+	}
+
+	gCtx.JSON(http.StatusAccepted, gin.H{"message": "Delivery scheduled"})
+}
@@ -0,0 +1,379 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/fewsats/blockbuster/utils"
+)
+
+const (
+	// EventPurchaseCreated is sent when a purchase is recorded.
+	EventPurchaseCreated = "purchase.created"
+
+	// EventVideoReady is sent when an uploaded video is ready to stream.
+	EventVideoReady = "video.ready"
+
+	// EventVideoDeleted is sent when a video is deleted.
+	EventVideoDeleted = "video.deleted"
+
+	// DeliveryStatusPending is the status of a delivery waiting for its
+	// next attempt.
+	DeliveryStatusPending = "pending"
+
+	// DeliveryStatusDelivered is the status of a delivery acknowledged
+	// with a 2xx response.
+	DeliveryStatusDelivered = "delivered"
+
+	// DeliveryStatusFailed is the status of a delivery that ran out of
+	// attempts. It can still be redelivered manually.
+	DeliveryStatusFailed = "failed"
+
+	// SignatureHeader holds the timestamp and the HMAC-SHA256 of the
+	// payload as "t=<unix timestamp>,v1=<hex signature>". The signed
+	// message is "<unix timestamp>.<payload>".
+	SignatureHeader = "X-Blockbuster-Signature"
+
+	// EventHeader holds the event type of the payload.
+	EventHeader = "X-Blockbuster-Event"
+
+	// DeliveryHeader holds the ID of the delivery, which is kept across
+	// retries so receivers can deduplicate them.
+	DeliveryHeader = "X-Blockbuster-Delivery"
+
+	// secretSize is the number of random bytes of the endpoint secrets.
+	secretSize = 32
+
+	// jobDispatch is the kind of the recurring job that sends the due
+	// deliveries.
+	jobDispatch = "webhooks_dispatch"
+)
+
+var (
+	// ErrNotFound is returned when the endpoint or the delivery does not
+	// exist or belongs to another user.
+	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidURL is returned when the endpoint URL is not an absolute
+	// http(s) URL.
+	ErrInvalidURL = errors.New("invalid webhook URL")
+
+	// ErrUnknownEvent is returned when subscribing to an unsupported
+	// event type.
+	ErrUnknownEvent = errors.New("unknown event type")
+
+	// Events are the supported event types.
+	Events = []string{EventPurchaseCreated, EventVideoReady, EventVideoDeleted}
+)
+
+// Payload is the body sent to the endpoints.
+type Payload struct {
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// Dispatcher stores the events of the users and pushes them to their
+// endpoints, retrying the failed attempts with exponential backoff.
+type Dispatcher struct {
+	store  Store
+	client HTTPClient
+	queue  Queue
+
+	cfg    *Config
+	clock  utils.Clock
+	logger *slog.Logger
+}
+
+// NewDispatcher creates a new webhooks dispatcher.
+func NewDispatcher(store Store, client HTTPClient, queue Queue, cfg *Config,
+	clock utils.Clock, logger *slog.Logger) *Dispatcher {
+
+	return &Dispatcher{
+		store:  store,
+		client: client,
+		queue:  queue,
+		cfg:    cfg,
+		clock:  clock,
+		logger: logger,
+	}
+}
+
+// NewHTTPClient returns the client the deliveries are sent with. Unless
+// allowed, it refuses to connect to loopback and private network addresses,
+// and it never follows redirects so the endpoints can't point it elsewhere.
+func NewHTTPClient(cfg *Config) *http.Client {
+	client := utils.NewPublicHTTPClient(cfg.AllowPrivate)
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return client
+}
+
+// CreateEndpoint registers a new endpoint for the user with a random
+// secret.
+func (d *Dispatcher) CreateEndpoint(ctx context.Context, userID int64,
+	endpointURL string, events []string) (*Endpoint, error) {
+
+	parsed, err := url.Parse(endpointURL)
+	if err != nil || parsed.Host == "" ||
+		(parsed.Scheme != "http" && parsed.Scheme != "https") {
+
+		return nil, ErrInvalidURL
+	}
+
+	// The client checks every connection too, this only spares the users
+	// an endpoint that would never receive a delivery.
+	if !d.cfg.AllowPrivate {
+		err := utils.CheckPublicHost(ctx, parsed.Hostname())
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+		}
+	}
+
+	for _, event := range events {
+		if !slices.Contains(Events, event) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownEvent, event)
+		}
+	}
+
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+
+	endpoint := &Endpoint{
+		UserID: userID,
+		URL:    endpointURL,
+		Secret: hex.EncodeToString(secret),
+		Events: events,
+	}
+	if err := d.store.CreateWebhookEndpoint(ctx, endpoint); err != nil {
+		return nil, err
+	}
+
+	return endpoint, nil
+}
+
+// ListEndpoints returns the endpoints of the user without their secrets.
+func (d *Dispatcher) ListEndpoints(ctx context.Context,
+	userID int64) ([]*Endpoint, error) {
+
+	endpoints, err := d.store.ListUserWebhookEndpoints(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, endpoint := range endpoints {
+		endpoint.Secret = ""
+	}
+
+	return endpoints, nil
+}
+
+// DeleteEndpoint removes an endpoint of the user and its deliveries.
+func (d *Dispatcher) DeleteEndpoint(ctx context.Context, userID,
+	id int64) error {
+
+	return d.store.DeleteWebhookEndpoint(ctx, userID, id)
+}
+
+// ListDeliveries returns the most recent deliveries of an endpoint of the
+// user.
+func (d *Dispatcher) ListDeliveries(ctx context.Context, userID,
+	endpointID, limit int64) ([]*Delivery, error) {
+
+	endpoint, err := d.store.GetWebhookEndpoint(ctx, endpointID)
+	if err != nil {
+		return nil, err
+	}
+
+	if endpoint.UserID != userID {
+		return nil, ErrNotFound
+	}
+
+	return d.store.ListEndpointWebhookDeliveries(ctx, endpointID, limit)
+}
+
+// Redeliver schedules a delivery of the user to be sent again right away,
+// with a fresh set of attempts.
+func (d *Dispatcher) Redeliver(ctx context.Context, userID, id int64) error {
+	return d.store.RedeliverWebhookDelivery(ctx, userID, id)
+}
+
+// Enqueue stores a delivery of the event for every endpoint of the user
+// subscribed to it. They are sent by the dispatcher loop.
+func (d *Dispatcher) Enqueue(ctx context.Context, userID int64,
+	eventType string, data any) error {
+
+	endpoints, err := d.store.ListUserWebhookEndpoints(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to list webhook endpoints: %w", err)
+	}
+
+	now := d.clock.Now()
+	payload, err := json.Marshal(&Payload{
+		Type:      eventType,
+		CreatedAt: now,
+		Data:      data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	var deliveries []*Delivery
+	for _, endpoint := range endpoints {
+		if len(endpoint.Events) > 0 &&
+			!slices.Contains(endpoint.Events, eventType) {
+
+			continue
+		}
+
+		deliveries = append(deliveries, &Delivery{
+			EndpointID:    endpoint.ID,
+			UserID:        userID,
+			EventType:     eventType,
+			Payload:       string(payload),
+			Status:        DeliveryStatusPending,
+			NextAttemptAt: now,
+		})
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	return d.store.InsertWebhookDeliveries(ctx, deliveries)
+}
+
+// RegisterJobs registers the sending of the due deliveries in the queue to
+// run every poll interval, unless disabled.
+func (d *Dispatcher) RegisterJobs() {
+	if d.cfg.PollInterval <= 0 {
+		d.logger.Info("Webhooks dispatcher disabled")
+		return
+	}
+
+	d.queue.RegisterRecurring(jobDispatch, d.cfg.PollInterval,
+		d.DispatchDue)
+}
+
+// DispatchDue sends a batch of the deliveries whose next attempt is due.
+func (d *Dispatcher) DispatchDue(ctx context.Context) error {
+	deliveries, err := d.store.ListDueWebhookDeliveries(ctx, d.clock.Now(),
+		d.cfg.BatchSize)
+	if err != nil {
+		return fmt.Errorf("failed to list due deliveries: %w", err)
+	}
+
+	var errs []error
+	for _, delivery := range deliveries {
+		attempt := d.send(ctx, delivery)
+
+		err := d.store.UpdateWebhookDeliveryAttempt(ctx, attempt)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// send makes an attempt to deliver the payload and returns its result.
+func (d *Dispatcher) send(ctx context.Context,
+	delivery *DueDelivery) *DeliveryAttempt {
+
+	now := d.clock.Now()
+	attempt := &DeliveryAttempt{
+		ID:            delivery.ID,
+		Status:        DeliveryStatusDelivered,
+		Attempts:      delivery.Attempts + 1,
+		NextAttemptAt: now,
+	}
+
+	statusCode, err := d.post(ctx, delivery, now)
+	attempt.LastStatusCode = int64(statusCode)
+	if err == nil {
+		return attempt
+	}
+
+	attempt.LastError = utils.LastError(err)
+
+	if attempt.Attempts >= d.cfg.MaxAttempts {
+		attempt.Status = DeliveryStatusFailed
+	} else {
+		attempt.Status = DeliveryStatusPending
+		attempt.NextAttemptAt = now.Add(utils.Backoff(attempt.Attempts,
+			d.cfg.RetryBackoff, d.cfg.MaxBackoff))
+	}
+
+	d.logger.Warn("Webhook delivery failed",
+		"deliveryID", delivery.ID,
+		"attempts", attempt.Attempts,
+		"status", attempt.Status,
+		"error", err)
+
+	return attempt
+}
+
+// post sends the signed payload and returns the response status code.
+func (d *Dispatcher) post(ctx context.Context, delivery *DueDelivery,
+	now time.Time) (int, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		delivery.URL, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(SignatureHeader, fmt.Sprintf("t=%d,v1=%s", timestamp,
+		Sign(delivery.Secret, timestamp, []byte(delivery.Payload))))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Drain the body so the connection can be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code: %d",
+			resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of "<timestamp>.<payload>" with
+// the endpoint secret, as sent in the SignatureHeader.
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,370 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/fewsats/blockbuster/utils"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is an in-memory Store for the dispatcher tests.
+type memoryStore struct {
+	endpoints  []*Endpoint
+	deliveries []*Delivery
+}
+
+func (m *memoryStore) CreateWebhookEndpoint(_ context.Context,
+	endpoint *Endpoint) error {
+
+	endpoint.ID = int64(len(m.endpoints) + 1)
+	m.endpoints = append(m.endpoints, endpoint)
+
+	return nil
+}
+
+func (m *memoryStore) GetWebhookEndpoint(_ context.Context,
+	id int64) (*Endpoint, error) {
+
+	for _, endpoint := range m.endpoints {
+		if endpoint.ID == id {
+			copied := *endpoint
+			return &copied, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (m *memoryStore) ListUserWebhookEndpoints(_ context.Context,
+	userID int64) ([]*Endpoint, error) {
+
+	var endpoints []*Endpoint
+	for _, endpoint := range m.endpoints {
+		if endpoint.UserID == userID {
+			copied := *endpoint
+			endpoints = append(endpoints, &copied)
+		}
+	}
+
+	return endpoints, nil
+}
+
+func (m *memoryStore) DeleteWebhookEndpoint(_ context.Context, _,
+	_ int64) error {
+
+	return nil
+}
+
+func (m *memoryStore) InsertWebhookDeliveries(_ context.Context,
+	deliveries []*Delivery) error {
+
+	for _, delivery := range deliveries {
+		delivery.ID = int64(len(m.deliveries) + 1)
+		m.deliveries = append(m.deliveries, delivery)
+	}
+
+	return nil
+}
+
+func (m *memoryStore) ListDueWebhookDeliveries(ctx context.Context,
+	now time.Time, limit int64) ([]*DueDelivery, error) {
+
+	var due []*DueDelivery
+	for _, delivery := range m.deliveries {
+		if delivery.Status != DeliveryStatusPending ||
+			delivery.NextAttemptAt.After(now) {
+
+			continue
+		}
+
+		endpoint, err := m.GetWebhookEndpoint(ctx, delivery.EndpointID)
+		if err != nil {
+			return nil, err
+		}
+
+		copied := *delivery
+		due = append(due, &DueDelivery{
+			Delivery: &copied,
+			URL:      endpoint.URL,
+			Secret:   endpoint.Secret,
+		})
+	}
+
+	return due, nil
+}
+
+func (m *memoryStore) ListEndpointWebhookDeliveries(_ context.Context,
+	_, _ int64) ([]*Delivery, error) {
+
+	return m.deliveries, nil
+}
+
+func (m *memoryStore) UpdateWebhookDeliveryAttempt(_ context.Context,
+	attempt *DeliveryAttempt) error {
+
+	delivery := m.deliveries[attempt.ID-1]
+	delivery.Status = attempt.Status
+	delivery.Attempts = attempt.Attempts
+	delivery.NextAttemptAt = attempt.NextAttemptAt
+	delivery.LastStatusCode = attempt.LastStatusCode
+	delivery.LastError = attempt.LastError
+
+	return nil
+}
+
+func (m *memoryStore) RedeliverWebhookDelivery(_ context.Context, _,
+	id int64) error {
+
+	delivery := m.deliveries[id-1]
+	delivery.Status = DeliveryStatusPending
+	delivery.Attempts = 0
+
+	return nil
+}
+
+// TestDispatch tests that the deliveries are signed, retried with backoff
+// and marked as failed once they run out of attempts.
+func TestDispatch(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	clock := utils.NewMockClock()
+	clock.SetMockClockTime(now)
+
+	var (
+		statusCode = http.StatusInternalServerError
+		received   []string
+		secret     string
+	)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+
+			var timestamp int64
+			var signature string
+			_, err = fmt.Sscanf(r.Header.Get(SignatureHeader), "t=%d,v1=%s",
+				&timestamp, &signature)
+			require.NoError(t, err)
+			require.Equal(t, now.Unix(), timestamp)
+			require.Equal(t, Sign(secret, timestamp, body), signature)
+			require.Equal(t, EventPurchaseCreated, r.Header.Get(EventHeader))
+
+			received = append(received, r.Header.Get(DeliveryHeader))
+			w.WriteHeader(statusCode)
+		},
+	))
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	cfg.MaxAttempts = 3
+	cfg.AllowPrivate = true
+	store := &memoryStore{}
+	dispatcher := NewDispatcher(store, server.Client(), nil, cfg,
+		clock, slog.Default())
+
+	_, err := dispatcher.CreateEndpoint(ctx, 1, "ftp://example.com", nil)
+	require.ErrorIs(t, err, ErrInvalidURL)
+
+	_, err = dispatcher.CreateEndpoint(ctx, 1, server.URL,
+		[]string{"video.unknown"})
+	require.ErrorIs(t, err, ErrUnknownEvent)
+
+	endpoint, err := dispatcher.CreateEndpoint(ctx, 1, server.URL,
+		[]string{EventPurchaseCreated})
+	require.NoError(t, err)
+	require.NotEmpty(t, endpoint.Secret)
+	secret = endpoint.Secret
+
+	// Events the endpoint is not subscribed to are not delivered.
+	err = dispatcher.Enqueue(ctx, 1, EventVideoReady, nil)
+	require.NoError(t, err)
+	require.Empty(t, store.deliveries)
+
+	err = dispatcher.Enqueue(ctx, 1, EventPurchaseCreated,
+		map[string]string{"external_id": "externalID"})
+	require.NoError(t, err)
+	require.Len(t, store.deliveries, 1)
+	delivery := store.deliveries[0]
+
+	// The first attempt fails and is retried after the backoff.
+	require.NoError(t, dispatcher.DispatchDue(ctx))
+	require.Equal(t, DeliveryStatusPending, delivery.Status)
+	require.EqualValues(t, 1, delivery.Attempts)
+	require.EqualValues(t, http.StatusInternalServerError,
+		delivery.LastStatusCode)
+	require.Equal(t, now.Add(cfg.RetryBackoff), delivery.NextAttemptAt)
+
+	require.NoError(t, dispatcher.DispatchDue(ctx))
+	require.Len(t, received, 1)
+
+	// The backoff doubles until the delivery runs out of attempts.
+	require.NoError(t, dispatcher.DispatchDue(ctx))
+	clock.SetMockClockTime(now.Add(cfg.RetryBackoff))
+	now = clock.Now()
+	require.NoError(t, dispatcher.DispatchDue(ctx))
+	require.EqualValues(t, 2, delivery.Attempts)
+	require.Equal(t, now.Add(2*cfg.RetryBackoff), delivery.NextAttemptAt)
+
+	clock.SetMockClockTime(now.Add(2 * cfg.RetryBackoff))
+	now = clock.Now()
+	require.NoError(t, dispatcher.DispatchDue(ctx))
+	require.Equal(t, DeliveryStatusFailed, delivery.Status)
+	require.EqualValues(t, 3, delivery.Attempts)
+
+	// A manual redelivery is sent again and acknowledged.
+	statusCode = http.StatusOK
+	require.NoError(t, dispatcher.Redeliver(ctx, 1, delivery.ID))
+	require.NoError(t, dispatcher.DispatchDue(ctx))
+	require.Equal(t, DeliveryStatusDelivered, delivery.Status)
+	require.EqualValues(t, http.StatusOK, delivery.LastStatusCode)
+
+	deliveryID := strconv.FormatInt(delivery.ID, 10)
+	require.Equal(t, []string{deliveryID, deliveryID, deliveryID,
+		deliveryID}, received)
+
+	// The secrets are not listed.
+	endpoints, err := dispatcher.ListEndpoints(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, endpoints, 1)
+	require.Empty(t, endpoints[0].Secret)
+}
+
+// TestPrivateEndpoints tests that endpoints on loopback, private and
+// link-local addresses are rejected, and that the deliveries neither reach
+// them nor follow redirects.
+func TestPrivateEndpoints(t *testing.T) {
+	ctx := context.Background()
+	clock := utils.NewMockClock()
+	clock.SetMockClockTime(time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC))
+
+	var received int
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			received++
+		},
+	))
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	store := &memoryStore{}
+	dispatcher := NewDispatcher(store, NewHTTPClient(cfg), nil, cfg,
+		clock, slog.Default())
+
+	for _, endpointURL := range []string{
+		server.URL,
+		"http://localhost:8080/hook",
+		"http://[::1]/hook",
+		"http://10.0.0.1/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://0.0.0.0/hook",
+	} {
+		_, err := dispatcher.CreateEndpoint(ctx, 1, endpointURL, nil)
+		require.ErrorIs(t, err, ErrInvalidURL, endpointURL)
+	}
+	require.Empty(t, store.endpoints)
+
+	// Endpoints whose names resolve to private addresses once registered are
+	// refused when dialing.
+	err := store.CreateWebhookEndpoint(ctx, &Endpoint{
+		UserID: 1,
+		URL:    server.URL,
+		Secret: "secret",
+	})
+	require.NoError(t, err)
+
+	err = dispatcher.Enqueue(ctx, 1, EventVideoReady, nil)
+	require.NoError(t, err)
+	require.NoError(t, dispatcher.DispatchDue(ctx))
+	require.Zero(t, received)
+
+	delivery := store.deliveries[0]
+	require.Equal(t, DeliveryStatusPending, delivery.Status)
+	require.Contains(t, delivery.LastError, utils.ErrPrivateAddress.Error())
+
+	// Redirects are not followed, even when private addresses are allowed.
+	redirect := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, server.URL, http.StatusTemporaryRedirect)
+		},
+	))
+	defer redirect.Close()
+
+	cfg = DefaultConfig()
+	cfg.AllowPrivate = true
+	store = &memoryStore{}
+	dispatcher = NewDispatcher(store, NewHTTPClient(cfg), nil, cfg,
+		clock, slog.Default())
+
+	_, err = dispatcher.CreateEndpoint(ctx, 1, redirect.URL, nil)
+	require.NoError(t, err)
+	err = dispatcher.Enqueue(ctx, 1, EventVideoReady, nil)
+	require.NoError(t, err)
+	require.NoError(t, dispatcher.DispatchDue(ctx))
+	require.Zero(t, received)
+	require.EqualValues(t, http.StatusTemporaryRedirect,
+		store.deliveries[0].LastStatusCode)
+}
+
+// recurringQueue records the recurring jobs registered in it.
+type recurringQueue struct {
+	intervals map[string]time.Duration
+	runs      map[string]func(ctx context.Context) error
+}
+
+func (q *recurringQueue) RegisterRecurring(kind string,
+	interval time.Duration, run func(ctx context.Context) error) {
+
+	q.intervals[kind] = interval
+	q.runs[kind] = run
+}
+
+// TestRegisterJobs tests that the due deliveries are sent by a recurring job
+// of the queue unless the dispatcher is disabled.
+func TestRegisterJobs(t *testing.T) {
+	ctx := context.Background()
+
+	var received int
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			received++
+		},
+	))
+	defer server.Close()
+
+	queue := &recurringQueue{
+		intervals: make(map[string]time.Duration),
+		runs:      make(map[string]func(ctx context.Context) error),
+	}
+	cfg := DefaultConfig()
+	cfg.AllowPrivate = true
+	store := &memoryStore{}
+	dispatcher := NewDispatcher(store, server.Client(), queue, cfg,
+		utils.NewMockClock(), slog.Default())
+
+	dispatcher.RegisterJobs()
+	require.Equal(t, cfg.PollInterval, queue.intervals[jobDispatch])
+
+	_, err := dispatcher.CreateEndpoint(ctx, 1, server.URL, nil)
+	require.NoError(t, err)
+	err = dispatcher.Enqueue(ctx, 1, EventVideoReady, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, queue.runs[jobDispatch](ctx))
+	require.Equal(t, 1, received)
+	require.Equal(t, DeliveryStatusDelivered, store.deliveries[0].Status)
+
+	// A zero poll interval disables the dispatcher.
+	delete(queue.intervals, jobDispatch)
+	cfg.PollInterval = 0
+	dispatcher.RegisterJobs()
+	require.NotContains(t, queue.intervals, jobDispatch)
+}
@@ -0,0 +1,113 @@
+package webhooks
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Endpoint is a URL where the events of a user are pushed.
+type Endpoint struct {
+	ID     int64  `json:"id"`
+	UserID int64  `json:"-"`
+	URL    string `json:"url"`
+
+	// Secret signs the payloads. It is only returned on creation.
+	Secret string `json:"secret,omitempty"`
+
+	// Events are the subscribed event types, empty for all of them.
+	Events []string `json:"events"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Delivery is an event sent, or to be sent, to an endpoint.
+type Delivery struct {
+	ID         int64  `json:"id"`
+	EndpointID int64  `json:"endpoint_id"`
+	UserID     int64  `json:"-"`
+	EventType  string `json:"event_type"`
+	Payload    string `json:"payload"`
+
+	// Status is one of the DeliveryStatus values.
+	Status string `json:"status"`
+
+	Attempts      int64     `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+
+	// LastStatusCode and LastError are the result of the last attempt.
+	LastStatusCode int64  `json:"last_status_code"`
+	LastError      string `json:"last_error"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DueDelivery is a pending delivery with the endpoint it is sent to.
+type DueDelivery struct {
+	*Delivery
+
+	URL    string
+	Secret string
+}
+
+// DeliveryAttempt is the result of sending a delivery.
+type DeliveryAttempt struct {
+	ID             int64
+	Status         string
+	Attempts       int64
+	NextAttemptAt  time.Time
+	LastStatusCode int64
+	LastError      string
+}
+
+// Store is the interface for the webhook endpoints and their deliveries.
+type Store interface {
+	// CreateWebhookEndpoint stores a new endpoint.
+	CreateWebhookEndpoint(ctx context.Context, endpoint *Endpoint) error
+
+	// GetWebhookEndpoint returns the endpoint with the given ID or
+	// ErrNotFound.
+	GetWebhookEndpoint(ctx context.Context, id int64) (*Endpoint, error)
+
+	// ListUserWebhookEndpoints returns the endpoints of a user.
+	ListUserWebhookEndpoints(ctx context.Context, userID int64) ([]*Endpoint,
+		error)
+
+	// DeleteWebhookEndpoint removes an endpoint of a user and its
+	// deliveries, returning ErrNotFound if it does not exist.
+	DeleteWebhookEndpoint(ctx context.Context, userID, id int64) error
+
+	// InsertWebhookDeliveries stores the deliveries of an event.
+	InsertWebhookDeliveries(ctx context.Context,
+		deliveries []*Delivery) error
+
+	// ListDueWebhookDeliveries returns up to limit pending deliveries whose
+	// next attempt is due.
+	ListDueWebhookDeliveries(ctx context.Context, now time.Time,
+		limit int64) ([]*DueDelivery, error)
+
+	// ListEndpointWebhookDeliveries returns the most recent deliveries of
+	// an endpoint.
+	ListEndpointWebhookDeliveries(ctx context.Context, endpointID,
+		limit int64) ([]*Delivery, error)
+
+	// UpdateWebhookDeliveryAttempt stores the result of an attempt.
+	UpdateWebhookDeliveryAttempt(ctx context.Context,
+		attempt *DeliveryAttempt) error
+
+	// RedeliverWebhookDelivery schedules a delivery of a user to be sent
+	// again, returning ErrNotFound if it does not exist.
+	RedeliverWebhookDelivery(ctx context.Context, userID, id int64) error
+}
+
+// Queue is the interface for the job queue that sends the due deliveries.
+type Queue interface {
+	RegisterRecurring(kind string, interval time.Duration,
+		run func(ctx context.Context) error)
+}
+
+// HTTPClient is the interface for sending the deliveries.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}